@@ -5,6 +5,7 @@ import axios from 'axios';
 import Axios from 'axios';
 import { buildRequest } from './common';
 import { IAPISession } from './session';
+import { TDictionary } from '@/utils/types';
 
 export interface IAPIFailedSessions {
     acknowledged: ISession[];
@@ -25,10 +26,11 @@ export function retrieveStatusDataAPI() {
     return buildRequest<IAPIStatusData>(() => Axios.get(`/_polo_/api/status`));
 }
 
-export function createNewSessionAPI(applicationName: string, checkout: string) {
+export function createNewSessionAPI(applicationName: string, checkout: string, parameters?: TDictionary<string>) {
     return buildRequest<ISession>(() => axios.post(`/_polo_/api/session`, {
         checkout,
-        applicationName
+        applicationName,
+        parameters
     }));
 }
 
@@ -0,0 +1,26 @@
+import Axios from 'axios';
+import { buildRequest } from './common';
+import { IAPISession } from './session';
+
+export interface IAPIStackApplication {
+    application: string;
+    fallback: string;
+    entry: boolean;
+}
+
+export interface IAPIStackInstance {
+    checkout: string;
+    smartURL: string;
+    sessions: Omit<IAPISession, 'logs'>[];
+}
+
+export interface IAPIStack {
+    name: string;
+    defaultBranch: string;
+    applications: IAPIStackApplication[];
+    instances: IAPIStackInstance[];
+}
+
+export function retrieveStacksAPI() {
+    return buildRequest<IAPIStack[]>(() => Axios.get(`/_polo_/api/stacks`));
+}
@@ -21,6 +21,8 @@ import { observe, values } from 'mobx';
 import { IApplicationNotification } from '@/state/models/application-notification-model';
 import { ApplicationNotifications } from './notifications/application-notifications';
 import { onPatch } from 'mobx-state-tree';
+import { TDictionary } from '@/utils/types';
+import { defaultParameterValues, SessionParametersForm } from './parameters/session-parameters-form';
 
 type TProps = {
     sessions   : ISession[] | null;
@@ -31,17 +33,19 @@ type TProps = {
 export const Application = observer((props: TProps) => {
 
     const [newSessionCheckout, setNewSessionCheckout] = useState<string>("")
+    const parameters = props.application.configuration.parameters;
+    const [newSessionParameters, setNewSessionParameters] = useState(() => defaultParameterValues(parameters));
     const { subscribe } = useSubscription();
     const { notify } = useNotification();
     const history = useHistory();
 
     const onCheckoutChange = (value: string) => setNewSessionCheckout(value);
 
-    const submitSessionCreation = async (checkout: string) => {
+    const submitSessionCreation = async (checkout: string, parameters?: TDictionary<string>) => {
         if (!checkout) return;
         checkout = checkout.trim();
         if (checkout) {
-            const newSession = await props.application.newSession(checkout);
+            const newSession = await props.application.newSession(checkout, parameters);
             if (newSession.result === APIRequestResult.SUCCEEDED) {
                 subscribe(newSession.payload.uuid, SessionSubscriptionEventType.FAIL, session => {
                     notify(buildFailedNotification(session, notification => {
@@ -80,6 +84,7 @@ export const Application = observer((props: TProps) => {
             <ApplicationCheckouts
                 branches={props.application.branchesMap}
                 tags={props.application.tagsMap}
+                parameters={parameters}
                 onSessionCreationSubmission={submitSessionCreation} />
         </div>}
         
@@ -98,14 +103,20 @@ export const Application = observer((props: TProps) => {
                     placeholder="Commit, branch or tag"
                     value={newSessionCheckout}
                     onChange={e => onCheckoutChange(e.target.value)}
-                    onKeyUp={e => e.key === 'Enter' && submitSessionCreation(newSessionCheckout)} />
+                    onKeyUp={e => e.key === 'Enter' && submitSessionCreation(newSessionCheckout, newSessionParameters)} />
                 <Button
                     success
                     small
-                    onClick={() => submitSessionCreation(newSessionCheckout)}
+                    onClick={() => submitSessionCreation(newSessionCheckout, newSessionParameters)}
                     label="Create"
                     icon={<CubeIcon />} />
             </div>
+            {parameters.length > 0 && <div className="mt-3">
+                <SessionParametersForm
+                    parameters={parameters}
+                    values={newSessionParameters}
+                    onChange={setNewSessionParameters} />
+            </div>}
         </div>
     </div>;
 })
\ No newline at end of file
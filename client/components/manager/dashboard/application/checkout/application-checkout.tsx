@@ -10,6 +10,8 @@ import { CommitModal } from '@/components/manager/shared/commit-modal';
 import { Button } from '@/components/shared/elements/button/button';
 import { CubeIcon } from '@/components/shared/elements/icons/cube/cube-icon';
 import { HorizontalDotsIcon } from '@/components/shared/elements/icons/horizontal-dots/horizontal-dots-icon';
+import { IApplicationParameter } from '@/state/models/application-model';
+import { TDictionary } from '@/utils/types';
 
 type TProps = {
     type                       : 'branch' | 'tag';
@@ -18,7 +20,8 @@ type TProps = {
     author                     : string;
     authorEmail                : string;
     date                       : string;
-    onSessionCreationSubmission: (checkout: string) => void;
+    parameters                 : IApplicationParameter[];
+    onSessionCreationSubmission: (checkout: string, parameters?: TDictionary<string>) => void;
 }
 export const ApplicationCheckout = (props: TProps) => {
 
@@ -27,6 +30,16 @@ export const ApplicationCheckout = (props: TProps) => {
     const commitMessageModalName = `${checkoutOptionsModalName}-commit`;
     const checkoutBuildConfirmationModalName = `${checkoutOptionsModalName}-build-confirmation`;
 
+    // The sessions of applications with parameters are created
+    // once their values get confirmed
+    const createSession = () => {
+        if (props.parameters.length) {
+            show(checkoutBuildConfirmationModalName);
+        } else {
+            props.onSessionCreationSubmission(props.name);
+        }
+    }
+
     return <div
         className="application-checkout">
         <div className="__content" onClick={() => show(checkoutBuildConfirmationModalName)}>
@@ -81,7 +94,7 @@ export const ApplicationCheckout = (props: TProps) => {
         <span className="text-center whitespace-nowrap flex flex-nowrap items-start">
             <Button
                 ghost
-                onClick={() => props.onSessionCreationSubmission(props.name)}
+                onClick={createSession}
                 label="Create"
                 icon={<CubeIcon />} />
             <Button
@@ -94,7 +107,7 @@ export const ApplicationCheckout = (props: TProps) => {
             checkoutName={props.name}
             onSessionCreationSubmission={() => {
                 hide();
-                props.onSessionCreationSubmission(props.name);
+                createSession();
             }}
             onCommitMessageSelection={() => show(commitMessageModalName)} />
         <CommitModal
@@ -111,6 +124,7 @@ export const ApplicationCheckout = (props: TProps) => {
             commitAuthorEmail={props.authorEmail}
             commitDate={props.date}
             commitMessage={props.message}
+            parameters={props.parameters}
             onSessionCreationSubmission={props.onSessionCreationSubmission} />
     </div>
 }
\ No newline at end of file
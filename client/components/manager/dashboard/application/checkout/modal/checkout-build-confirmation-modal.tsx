@@ -3,7 +3,10 @@ import { useModal } from '@/components/manager/modal/modal-hooks';
 import { CommitMessage } from '@/components/manager/shared/commit-message';
 import { Button } from '@/components/shared/elements/button/button';
 import { CubeIcon } from '@/components/shared/elements/icons/cube/cube-icon';
-import React from 'react';
+import { IApplicationParameter } from '@/state/models/application-model';
+import { TDictionary } from '@/utils/types';
+import React, { useState } from 'react';
+import { defaultParameterValues, SessionParametersForm } from '../../parameters/session-parameters-form';
 import './checkout-build-confirmation-modal.scss';
 
 type TProps = {
@@ -13,11 +16,13 @@ type TProps = {
     commitAuthorEmail          : string;
     commitDate                 : string;
     commitMessage              : string;
-    onSessionCreationSubmission: (checkout: string) => void;
+    parameters                 : IApplicationParameter[];
+    onSessionCreationSubmission: (checkout: string, parameters?: TDictionary<string>) => void;
 }
 export const CheckoutBuildConfirmationModal = (props: TProps) => {
 
     const { hide } = useModal();
+    const [parameterValues, setParameterValues] = useState(() => defaultParameterValues(props.parameters));
 
     return <DefaultModal name={props.name}>
         <div className="checkout-build-confirmation-modal">
@@ -29,6 +34,12 @@ export const CheckoutBuildConfirmationModal = (props: TProps) => {
                 commitAuthorName={props.commitAuthor}
                 commitDate={props.commitDate}
                 commitMessage={props.commitMessage} />
+            {props.parameters.length > 0 && <div className="__parameters-container">
+                <SessionParametersForm
+                    parameters={props.parameters}
+                    values={parameterValues}
+                    onChange={setParameterValues} />
+            </div>}
             <div className="__actions-container">
                 <Button
                     success
@@ -37,7 +48,7 @@ export const CheckoutBuildConfirmationModal = (props: TProps) => {
                     label="Create session"
                     onClick={() => {
                         hide();
-                        props.onSessionCreationSubmission(props.checkoutName);
+                        props.onSessionCreationSubmission(props.checkoutName, parameterValues);
                     }} />
             </div>
         </div>
@@ -1,4 +1,6 @@
 import { IApplication, IApplicationBranchModel } from '@/state/models';
+import { IApplicationParameter } from '@/state/models/application-model';
+import { TDictionary } from '@/utils/types';
 import { observer } from 'mobx-react-lite';
 import React, { useState } from 'react';
 import { ApplicationCheckout } from '../checkout/application-checkout';
@@ -6,7 +8,8 @@ import { ApplicationCheckout } from '../checkout/application-checkout';
 type TProps = {
     branches                   : IApplication['branchesMap'];
     tags                       : IApplication['tagsMap'];
-    onSessionCreationSubmission: (checkout: string) => void;
+    parameters                 : IApplicationParameter[];
+    onSessionCreationSubmission: (checkout: string, parameters?: TDictionary<string>) => void;
 }
 
 export const ApplicationCheckouts = observer((props: TProps) => {
@@ -59,6 +62,7 @@ export const ApplicationCheckouts = observer((props: TProps) => {
                 <ApplicationCheckout
                     key={key}
                     type={selectBranches ? 'branch' : 'tag'}
+                    parameters={props.parameters}
                     onSessionCreationSubmission={props.onSessionCreationSubmission}
                     {...checkout} />
                 )}
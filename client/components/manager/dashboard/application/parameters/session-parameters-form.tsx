@@ -0,0 +1,64 @@
+import { ApplicationParameterType, IApplicationParameter } from '@/state/models/application-model';
+import { TDictionary } from '@/utils/types';
+import React from 'react';
+import './session-parameters-form.scss';
+
+type TProps = {
+    parameters: IApplicationParameter[];
+    values    : TDictionary<string>;
+    onChange  : (values: TDictionary<string>) => void;
+}
+
+export const SessionParametersForm = (props: TProps) => {
+
+    if (!props.parameters.length) return null;
+
+    const onValueChange = (name: string, value: string) =>
+        props.onChange({ ...props.values, [name]: value });
+
+    return <div className="session-parameters-form">
+        {props.parameters.map((parameter, key) => {
+            const value = props.values[parameter.name] ?? parameter.default;
+            return <div className="__parameter" key={key}>
+                <label className="__label" htmlFor={`parameter-${parameter.name}`}>
+                    {parameter.name}
+                    {parameter.description && <span className="__description">{parameter.description}</span>}
+                </label>
+                {parameter.type === ApplicationParameterType.ENUM &&
+                    <select
+                        id={`parameter-${parameter.name}`}
+                        value={value}
+                        onChange={e => onValueChange(parameter.name, e.target.value)}>
+                        {parameter.values.map(allowedValue =>
+                            <option key={allowedValue} value={allowedValue}>{allowedValue}</option>)}
+                    </select>}
+                {parameter.type === ApplicationParameterType.BOOL &&
+                    <input
+                        id={`parameter-${parameter.name}`}
+                        type="checkbox"
+                        checked={isTrue(value)}
+                        onChange={e => onValueChange(parameter.name, `${e.target.checked}`)} />}
+                {parameter.type === ApplicationParameterType.STRING &&
+                    <input
+                        id={`parameter-${parameter.name}`}
+                        type="text"
+                        placeholder={parameter.default}
+                        value={value}
+                        onChange={e => onValueChange(parameter.name, e.target.value)} />}
+            </div>;
+        })}
+    </div>;
+}
+
+// Retrieves the default values of the parameters,
+// for them to be sent along with the session request
+export function defaultParameterValues(parameters: IApplicationParameter[]): TDictionary<string> {
+    return parameters.reduce<TDictionary<string>>((acc, parameter) => {
+        acc[parameter.name] = parameter.default;
+        return acc;
+    }, {});
+}
+
+function isTrue(value: string): boolean {
+    return ['1', 't', 'true'].includes(value.toLowerCase());
+}
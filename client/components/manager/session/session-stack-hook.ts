@@ -0,0 +1,77 @@
+import { APIRequestResult } from "@/api/common";
+import { retrieveLogsAndStatusAPI } from "@/api/session";
+import { retrieveStacksAPI } from "@/api/stacks";
+import { ISession, ISessionLog } from "@/state/models";
+import { SessionStatus } from "@/state/models/session-model-enums";
+import { useEffect, useRef, useState } from "react";
+
+export type TStackMember = {
+    uuid: string;
+    applicationName: string;
+    checkout: string;
+    status: SessionStatus;
+    logs: ISessionLog[];
+}
+
+// Retrieves the sessions built along with the entry session of a stack instance,
+// together with their logs, for the whole stack to be followed from a single page
+export const useStackMembers = (session: ISession) => {
+    const [members, setMembers] = useState<TStackMember[]>([]);
+    const membersByUUID = useRef<{ [uuid: string]: TStackMember }>({});
+    const timeout = useRef<NodeJS.Timeout | null>(null);
+
+    useEffect(() => {
+        let stopped = false;
+
+        const stackMembersRetrieval = async () => {
+            if (!session.stack) return;
+            try {
+                const stacksRequest = await retrieveStacksAPI();
+                if (stacksRequest.result === APIRequestResult.SUCCEEDED) {
+                    const stack = (stacksRequest.payload || []).find(stack => stack.name === session.stack!.name);
+                    const instance = stack && (stack.instances || []).find(instance => instance.checkout === session.stack!.checkout);
+                    const sessions = instance ? (instance.sessions || []).filter(member => member.uuid !== session.uuid) : [];
+                    for (const member of sessions) {
+                        if (!membersByUUID.current[member.uuid]) {
+                            membersByUUID.current[member.uuid] = {
+                                uuid: member.uuid,
+                                applicationName: member.applicationName,
+                                checkout: member.checkout,
+                                status: member.status,
+                                logs: [],
+                            };
+                        }
+                    }
+                }
+
+                for (const member of Object.values(membersByUUID.current)) {
+                    const lastLogUUID = member.logs.length ? member.logs[member.logs.length - 1].uuid : undefined;
+                    const logsRequest = await retrieveLogsAndStatusAPI(member.uuid, lastLogUUID);
+                    if (logsRequest.result === APIRequestResult.SUCCEEDED) {
+                        membersByUUID.current[member.uuid] = {
+                            ...member,
+                            status: logsRequest.payload.status,
+                            logs: member.logs.concat(logsRequest.payload.logs || []),
+                        };
+                    }
+                }
+            } catch (e) {
+                console.error(e);
+            }
+
+            if (stopped) return;
+            setMembers(Object.values(membersByUUID.current));
+            timeout.current = setTimeout(() => stackMembersRetrieval(), 1000);
+        };
+
+        stackMembersRetrieval();
+
+        return () => {
+            stopped = true;
+            if (timeout.current)
+                clearTimeout(timeout.current);
+        }
+    }, [session.uuid]);
+
+    return members;
+}
@@ -0,0 +1,73 @@
+import { ISession, ISessionLog } from '@/state/models/session-model';
+import { SessionStatus } from '@/state/models/session-model-enums';
+import { values } from 'mobx';
+import { observer } from 'mobx-react-lite';
+import React, { useState } from 'react';
+import { SessionLogs } from './session-logs';
+import { useStackMembers } from './session-stack-hook';
+
+type TProps = {
+    session: ISession;
+    onLogsProportionChanged: (proportions: number) => void;
+}
+
+// Shows the build of every session of the stack instance,
+// the entry one first, switching between their logs
+export const SessionStack = observer((props: TProps) => {
+    const members = useStackMembers(props.session);
+    const [selectedUUID, setSelectedUUID] = useState(props.session.uuid);
+
+    const tabs = [
+        {
+            uuid           : props.session.uuid,
+            applicationName: props.session.applicationName,
+            checkout       : props.session.checkout,
+            status         : props.session.status,
+        },
+        ...members
+    ];
+
+    const selectedMember = members.find(member => member.uuid === selectedUUID);
+    const logs = selectedMember ?
+        selectedMember.logs :
+        values(props.session.logs) as any as ISessionLog[];
+
+    return <>
+        <div className="flex justify-center mt-4 z-10">
+            <div className="border border-gray-400 dark:border-gray-600 rounded-md overflow-hidden inline-flex flex-wrap items-stretch text-xs text-nord1 dark:text-nord5">
+                {tabs.map((tab, key) =>
+                    <div
+                        key={key}
+                        onClick={() => setSelectedUUID(tab.uuid)}
+                        className={`flex flex-nowrap items-center px-3 py-2 cursor-pointer hover:bg-nord4 dark:hover:bg-nord10
+                            ${key > 0 ? 'border-l border-gray-500 dark:border-gray-600' : ''}
+                            ${tab.uuid === selectedUUID ? 'bg-nord4 dark:bg-nord10' : ''}`}>
+                        <span
+                            className="inline-block w-2 h-2 rounded-full mr-2 flex-shrink-0"
+                            style={{ backgroundColor: colorByStatus(tab.status) }}></span>
+                        <span className="whitespace-nowrap">{tab.applicationName}</span>
+                        <span className="whitespace-nowrap ml-1 opacity-60">{tab.checkout}</span>
+                    </div>
+                )}
+            </div>
+        </div>
+        <SessionLogs
+            key={selectedUUID}
+            logs={logs}
+            failed={selectedMember && selectedMember.status === SessionStatus.START_FAILED}
+            onLogsProportionChanged={props.onLogsProportionChanged} />
+    </>;
+});
+
+function colorByStatus(status: SessionStatus): string {
+    switch (status) {
+        case SessionStatus.STARTED:
+            return '#a3be8c';
+        case SessionStatus.START_FAILED:
+        case SessionStatus.STOPPING:
+        case SessionStatus.STOPPED:
+            return '#bf616a';
+        default:
+            return '#ebcb8b';
+    }
+}
@@ -8,6 +8,7 @@ import React, { useState } from 'react';
 import { useHistory } from 'react-router-dom';
 import { CommitMessage } from '../shared/commit-message';
 import { SessionLogs } from './session-logs';
+import { SessionStack } from './session-stack';
 import { useSessionRetrieval } from './session-retrieval-hook';
 
 type TProps = {
@@ -43,15 +44,21 @@ export const Session = observer((props: TProps) => {
         mx-auto w-full max-w-6xl flex flex-col min-w-0 min-h-0 flex-1 pt-3 font-quicksand" style={{height:'calc(100vh - 120px)'}}>
         <div className="main-gradient-faded absolute left-0 right-0 top-0 pointer-events-none" style={{ bottom: `${overlayBottom}%`, zIndex: 1 }}></div>
         <h1 className="text-4xl px-2 lg:px-0 mb-3 font-quicksand font-light text-nord1 dark:text-nord5 z-10">
-            Session
+            {props.session.stack ? 'Stack' : 'Session'}
         </h1>
         <div className="text-lg text-nord1 dark:text-nord5 mb-4 z-10 border-l pl-3 border-gray-500">
-            <span>{props.session.displayName}</span>
+            {props.session.stack ?
+                <span>{props.session.stack.name} ({props.session.stack.checkout})</span> :
+                <span>{props.session.displayName}</span>}
         </div>
         <CommitMessage {...props.session} maxHeight />
-        <SessionLogs
-            logs={values(props.session.logs) as any as ISessionLog[]}
-            onLogsProportionChanged={setOverlayProportions} />
+        {props.session.stack ?
+            <SessionStack
+                session={props.session}
+                onLogsProportionChanged={setOverlayProportions} /> :
+            <SessionLogs
+                logs={values(props.session.logs) as any as ISessionLog[]}
+                onLogsProportionChanged={setOverlayProportions} />}
     </div>
 });
 
@@ -24,6 +24,22 @@ export const ApplicationTagModel = types.model({
 
 export interface IApplicationBranchModel extends Instance<typeof ApplicationBranchModel> {}
 
+export enum ApplicationParameterType {
+    STRING = 'string',
+    ENUM   = 'enum',
+    BOOL   = 'bool',
+}
+
+export const ApplicationParameterModel = types.model({
+    name       : types.string,
+    type       : types.enumeration<ApplicationParameterType>(Object.values(ApplicationParameterType)),
+    default    : types.string,
+    values     : types.array(types.string),
+    description: types.string,
+});
+
+export interface IApplicationParameter extends Instance<typeof ApplicationParameterModel> {}
+
 export const ApplicationConfigurationModel = types.model({
     id                   : types.string,
     name                 : types.string,
@@ -32,6 +48,7 @@ export const ApplicationConfigurationModel = types.model({
     target               : types.string,
     host                 : types.string,
     maxConcurrentSessions: types.number,
+    parameters           : types.optional(types.array(ApplicationParameterModel), []),
 })
 
 export const ApplicationModel = types.model({
@@ -45,8 +62,8 @@ export const ApplicationModel = types.model({
 })
 .actions(self => {
 
-    const newSession = flow(function* newSession(checkout: string) {
-        const session: APIPayload<ISession> = yield createNewSessionAPI(self.configuration.name, checkout);
+    const newSession = flow(function* newSession(checkout: string, parameters?: TDictionary<string>) {
+        const session: APIPayload<ISession> = yield createNewSessionAPI(self.configuration.name, checkout, parameters);
         return session;
     });
 
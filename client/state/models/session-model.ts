@@ -7,6 +7,12 @@ export const SessionConfigurationModel = types.model({
     isDefault: types.boolean,
 });
 
+export const SessionStackModel = types.model({
+    name    : types.string,
+    checkout: types.string,
+    entry   : types.boolean,
+});
+
 export enum SessionLogType {
     TRACE    = 'trace',
     DEBUG    = 'debug',
@@ -68,6 +74,7 @@ export const SessionModel = types.model({
     replacedBy       : types.string,
     permalink        : types.string,
     smartURL         : types.string,
+    stack            : types.maybe(SessionStackModel),
 }).views(self => ({
     get beingReplacedBySession() {
         return self.beingReplacedBy as ISession;
@@ -40,6 +40,16 @@ func main() {
 	container.AddDatabase()
 	container.AddApplicationStorage()
 	container.AddSessionStorage()
+	container.AddSessionLogStorage()
+	container.AddCommitIndexStorage()
+
+	// Metrics
+
+	container.AddMetrics()
+
+	// Tracing
+
+	container.AddTracer()
 
 	// Command
 
@@ -60,6 +70,7 @@ func main() {
 	// Workers command execution
 
 	container.AddSessionCommandExecution()
+	container.AddSessionRuntimeLogs()
 
 	// Workers
 
@@ -71,6 +82,8 @@ func main() {
 	container.AddSessionHealthcheckWorker()
 	container.AddApplicationInitWorker()
 	container.AddApplicationFetchWorker()
+	container.AddWebhookWorker()
+	container.AddCommitStatusWorker()
 
 	// Services
 
@@ -78,6 +91,8 @@ func main() {
 	container.AddQueryService()
 	container.AddRequestService()
 	container.AddAliasingService()
+	container.AddConfigurationReloader()
+	container.AddConfigurationService()
 
 	// HTTP
 
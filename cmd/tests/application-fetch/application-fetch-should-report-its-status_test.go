@@ -0,0 +1,139 @@
+package application_fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/models/output"
+	"github.com/wufe/polo/pkg/versioning"
+)
+
+func getFetchStatus(t *testing.T, url string) (int, output.ApplicationFetchStatus) {
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer response.Body.Close()
+	decoded := struct {
+		Result output.ApplicationFetchStatus `json:"result"`
+	}{}
+	json.NewDecoder(response.Body).Decode(&decoded)
+	return response.StatusCode, decoded.Result
+}
+
+func requestFetch(t *testing.T, url string) int {
+	response, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	response.Body.Close()
+	return response.StatusCode
+}
+
+// A fetch should be triggered on demand, evaluating the watched branches only if requested,
+// and its outcome should be reported along with the new commits and the errors
+func Test_ApplicationFetchShouldReportItsStatus(t *testing.T) {
+
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	branch := fetcher.NewBranch("main")
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), branch)
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_ApplicationFetchShouldReportItsStatus").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		WithBranch(models.BuildBranchConfigurationMatch("main").SetWatch(true)))
+
+	application := di.GetApplications()[0]
+	applicationChan := application.GetEventBus().GetChan()
+	events_assertions.AssertApplicationGetsInitializedAndFetched(applicationChan, t)
+
+	server := httptest.NewServer(di.GetRestHandler().Router)
+	defer server.Close()
+	url := server.URL + "/_polo_/api/applications/Test_ApplicationFetchShouldReportItsStatus/fetch"
+
+	// The fetch of the initialization
+	status, fetch := getFetchStatus(t, url)
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+	if fetch.State != string(models.ApplicationFetchStateIdle) || fetch.Last == nil {
+		t.Fatalf("expected the initial fetch to be reported, got %+v", fetch)
+	}
+	if fetch.Last.Result != string(models.ApplicationFetchResultSucceeded) || fetch.Last.NewCommitsCount != 1 {
+		t.Errorf("expected the initial fetch to succeed finding a commit, got %+v", fetch.Last)
+	}
+
+	// A manual fetch does not evaluate the watched branches by default
+	fetcher.AddCommitToBranch(fetcher.NewCommit("Second commit"), branch)
+	if status := requestFetch(t, url); status != http.StatusAccepted {
+		t.Fatalf("expected status %d requesting a fetch, got %d", http.StatusAccepted, status)
+	}
+	events_assertions.AssertApplicationGetsFetched(applicationChan, t)
+	_, fetch = getFetchStatus(t, url)
+	if fetch.Last.Watch || fetch.Last.NewCommitsCount != 1 || len(fetch.Last.NewCommits) != 1 || fetch.Last.NewCommits[0].Message != "Second commit" {
+		t.Errorf("expected the fetch to find the second commit, got %+v", fetch.Last)
+	}
+
+	// With the watch option, the watched branch gets built
+	fetcher.AddCommitToBranch(fetcher.NewCommit("Third commit"), branch)
+	if status := requestFetch(t, url+"?watch=true"); status != http.StatusAccepted {
+		t.Fatalf("expected status %d requesting a fetch, got %d", http.StatusAccepted, status)
+	}
+	events_assertions.AssertApplicationEvents(
+		applicationChan,
+		[]models.ApplicationEventType{
+			models.ApplicationEventTypeFetchStarted,
+			models.ApplicationEventTypeAutoStart,
+		},
+		t,
+		2*time.Second,
+	)
+	_, fetch = getFetchStatus(t, url)
+	if !fetch.Last.Watch || fetch.Last.NewCommitsCount != 1 {
+		t.Errorf("expected the fetch to watch the branches, got %+v", fetch.Last)
+	}
+
+	// The errors of the fetch are reported
+	fetcher.SetFetchErrors(&versioning.FetcherError{Error: errors.New("remote unreachable"), Critical: true})
+	di.GetRequestService().FetchApplication("Test_ApplicationFetchShouldReportItsStatus", nil, false)
+	for start := time.Now(); ; time.Sleep(50 * time.Millisecond) {
+		_, fetch = getFetchStatus(t, url)
+		if len(fetch.Last.Errors) > 0 && fetch.State == string(models.ApplicationFetchStateIdle) {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("expected the failing fetch to complete, got %+v", fetch)
+		}
+	}
+	if fetch.Last.Result != string(models.ApplicationFetchResultPartial) || fetch.Last.Errors[0] != "remote unreachable" {
+		t.Errorf("expected the fetch to report its errors, got %+v", fetch.Last)
+	}
+
+	if status, _ := getFetchStatus(t, server.URL+"/_polo_/api/applications/unknown/fetch"); status != http.StatusNotFound {
+		t.Errorf("expected status %d for an unknown application, got %d", http.StatusNotFound, status)
+	}
+}
@@ -0,0 +1,248 @@
+package application_fetch
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/versioning"
+)
+
+type historyRepository struct {
+	t        *testing.T
+	folder   string
+	repo     *git.Repository
+	worktree *git.Worktree
+	commits  map[string]plumbing.Hash
+}
+
+func newHistoryRepository(t *testing.T) *historyRepository {
+	folder, err := ioutil.TempDir("", "polo-history")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	repo, err := git.PlainInit(folder, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return &historyRepository{
+		t:        t,
+		folder:   folder,
+		repo:     repo,
+		worktree: worktree,
+		commits:  map[string]plumbing.Hash{},
+	}
+}
+
+func (r *historyRepository) commit(message string, date string) plumbing.Hash {
+	when, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		r.t.Fatal(err.Error())
+	}
+	signature := &object.Signature{Name: "Polo", Email: "polo@example.com", When: when}
+	hash, err := r.worktree.Commit(message, &git.CommitOptions{Author: signature, Committer: signature})
+	if err != nil {
+		r.t.Fatal(err.Error())
+	}
+	r.commits[message] = hash
+	return hash
+}
+
+func (r *historyRepository) checkout(branch string, create bool) {
+	if err := r.worktree.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Create: create,
+	}); err != nil {
+		r.t.Fatal(err.Error())
+	}
+}
+
+func (r *historyRepository) fetch(options versioning.FetchOptions) *versioning.FetchResult {
+	result, errors := versioning.NewRepositoryFetcher(versioning_fixture.NewGitClient()).Fetch(r.folder, options)
+	if len(errors) > 0 {
+		r.t.Fatal(errors[0].Error.Error())
+	}
+	return result
+}
+
+func (r *historyRepository) assertCommits(result *versioning.FetchResult, messages ...string) {
+	if len(result.AppCommits) != len(messages) {
+		r.t.Errorf("expected %d commits to be indexed, got %d", len(messages), len(result.AppCommits))
+		return
+	}
+	for i, message := range messages {
+		if result.AppCommits[i] != r.commits[message].String() {
+			r.t.Errorf("expected commit %d to be %q, got %q", i, message, result.CommitMap[result.AppCommits[i]].Message)
+		}
+	}
+}
+
+// The fetch should index the commits within the configured window of the history,
+// always indexing the ones the refs point to, and read only the commits not already known
+func Test_RepositoryFetchShouldIndexTheHistoryWindow(t *testing.T) {
+
+	repository := newHistoryRepository(t)
+	defer os.RemoveAll(repository.folder)
+
+	repository.commit("Initial", "2017-06-01")
+	if _, err := repository.repo.CreateTag("v1", repository.commits["Initial"], &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "Polo", Email: "polo@example.com", When: time.Now()},
+		Message: "v1",
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+	repository.commit("January", "2019-01-01")
+	repository.checkout("feature", true)
+	repository.commit("Feature", "2019-01-15")
+	repository.checkout("master", false)
+	repository.commit("February", "2019-02-01")
+	repository.commit("March", "2019-03-01")
+	repository.commit("April", "2019-04-01")
+	repository.commit("May", "2019-05-01")
+
+	// By default, the commits since 2018, with the tagged one
+	result := repository.fetch(versioning.FetchOptions{History: models.FetchHistory{Since: models.DefaultFetchHistorySince}})
+	repository.assertCommits(result, "May", "April", "March", "February", "Feature", "January", "Initial")
+	if result.TagsMap["v1"] == nil || result.TagsMap["v1"].Message != "Initial" {
+		t.Errorf("expected the annotated tag to be indexed, got %+v", result.TagsMap["v1"])
+	}
+
+	repository.assertCommits(
+		repository.fetch(versioning.FetchOptions{History: models.FetchHistory{Since: "2019-01-10"}}),
+		"May", "April", "March", "February", "Feature", "Initial",
+	)
+	repository.assertCommits(
+		repository.fetch(versioning.FetchOptions{History: models.FetchHistory{Since: "2000-01-01", MaxCommits: 2}}),
+		"May", "April", "Feature", "January", "Initial",
+	)
+	repository.assertCommits(
+		repository.fetch(versioning.FetchOptions{History: models.FetchHistory{Since: "2000-01-01", RefsOnly: true}}),
+		"May", "Feature", "Initial",
+	)
+
+	// The known commits are not read again
+	known := result.CommitMap
+	april := *known[repository.commits["April"].String()]
+	april.Message = "Known"
+	known[april.Hash.String()] = &april
+	repository.commit("June", "2019-06-01")
+	incremental := repository.fetch(versioning.FetchOptions{
+		History: models.FetchHistory{Since: models.DefaultFetchHistorySince},
+		Known:   known,
+	})
+	repository.assertCommits(incremental, "June", "May", "April", "March", "February", "Feature", "January", "Initial")
+	if message := incremental.CommitMap[april.Hash.String()].Message; message != "Known" {
+		t.Errorf("expected the known commit to be reused, got %q", message)
+	}
+	if message := incremental.CommitMap[repository.commits["June"].String()].Message; message != "June" {
+		t.Errorf("expected the new commit to be read, got %q", message)
+	}
+}
+
+// The fetch should walk the history only up to the commits indexed from the known tips,
+// taking their ancestors from the index
+func Test_RepositoryFetchShouldWalkOnlyTheNewCommits(t *testing.T) {
+
+	repository := newHistoryRepository(t)
+	defer os.RemoveAll(repository.folder)
+	repository.commit("First", "2019-01-01")
+	repository.commit("Second", "2019-02-01")
+	repository.commit("Third", "2019-03-01")
+
+	history := models.FetchHistory{Since: models.DefaultFetchHistorySince}
+	result := repository.fetch(versioning.FetchOptions{History: history})
+	repository.assertCommits(result, "Third", "Second", "First")
+	if len(result.Tips) != 1 || result.Tips[0] != repository.commits["Third"].String() {
+		t.Errorf("expected the tip to be the last commit, got %v", result.Tips)
+	}
+
+	// The first commit is left out of the index on purpose:
+	// the walk stopping at the third one, the first one is not read from the repository
+	known := map[string]*object.Commit{}
+	for key, commit := range result.CommitMap {
+		if commit.Hash != repository.commits["First"] {
+			known[key] = commit
+		}
+	}
+	repository.commit("Fourth", "2019-04-01")
+	repository.assertCommits(
+		repository.fetch(versioning.FetchOptions{History: history, Known: known, KnownTips: result.Tips}),
+		"Fourth", "Third", "Second",
+	)
+
+	// Without the known tips, the whole window gets walked
+	repository.assertCommits(
+		repository.fetch(versioning.FetchOptions{History: history, Known: known}),
+		"Fourth", "Third", "Second", "First",
+	)
+}
+
+// The commit index should be persisted, so that a restart does not read the history again
+func Test_CommitIndexShouldBePersisted(t *testing.T) {
+
+	repository := newHistoryRepository(t)
+	defer os.RemoveAll(repository.folder)
+	repository.commit("First", "2019-01-01")
+	repository.commit("Second", "2019-02-01")
+	result := repository.fetch(versioning.FetchOptions{History: models.FetchHistory{Since: models.DefaultFetchHistorySince}})
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), fetcher.NewBranch("main"))
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     net_fixture.NewPortRetrieverFixture(),
+	}, models.BuildApplicationConfiguration("Test_CommitIndexShouldBePersisted").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe"))
+	events_assertions.AssertApplicationGetsInitializedAndFetched(di.GetApplications()[0].GetEventBus().GetChan(), t)
+
+	commitIndex := di.GetCommitIndexStorage()
+	commitIndex.Update("history", map[string]*object.Commit{}, result.CommitMap)
+	persisted := commitIndex.Get("history")
+	if len(persisted) != 2 {
+		t.Fatalf("expected 2 commits to be persisted, got %d", len(persisted))
+	}
+	second := persisted[repository.commits["Second"].String()]
+	if second == nil || second.Message != "Second" || len(second.ParentHashes) != 1 || second.ParentHashes[0] != repository.commits["First"] {
+		t.Errorf("expected the commit to be persisted with its parents, got %+v", second)
+	}
+
+	// Only the changes are persisted
+	current := map[string]*object.Commit{repository.commits["Second"].String(): second}
+	commitIndex.Update("history", persisted, current)
+	if persisted := commitIndex.Get("history"); len(persisted) != 1 || persisted[repository.commits["Second"].String()] == nil {
+		t.Errorf("expected the removed commit not to be persisted anymore, got %d commits", len(persisted))
+	}
+
+	// The tips are used only with the window of the history they have been indexed with
+	history := models.FetchHistory{Since: models.DefaultFetchHistorySince}
+	commitIndex.UpdateTips("history", history, result.Tips)
+	if tips := commitIndex.GetTips("history", history); len(tips) != 1 || tips[0] != repository.commits["Second"].String() {
+		t.Errorf("expected the tips to be persisted, got %v", tips)
+	}
+	if tips := commitIndex.GetTips("history", models.FetchHistory{Since: "90d"}); tips != nil {
+		t.Errorf("expected the tips indexed with another window not to be used, got %v", tips)
+	}
+
+	commitIndex.Delete("history")
+	if len(commitIndex.Get("history")) > 0 || commitIndex.GetTips("history", history) != nil {
+		t.Errorf("expected the commit index to be deleted")
+	}
+}
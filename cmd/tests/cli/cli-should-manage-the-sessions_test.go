@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/cli"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/models/output"
+)
+
+func run(t *testing.T, host string, args ...string) string {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := cli.Run(append([]string{"-host", host}, args...), stdout, stderr); code != 0 {
+		t.Fatalf("polo %s exited with code %d: %s", strings.Join(args, " "), code, stderr.String())
+	}
+	return stdout.String()
+}
+
+// The CLI should list the applications and the sessions of a running instance,
+// start and stop sessions and print their logs
+func Test_CLIShouldManageTheSessions(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	branch := fetcher.NewBranch("main")
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), branch)
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_CLIShouldManageTheSessions").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true))
+
+	application := di.GetApplications()[0]
+	applicationChan := application.GetEventBus().GetChan()
+	events_assertions.AssertApplicationGetsInitializedAndFetched(applicationChan, t)
+
+	server := httptest.NewServer(di.GetRestHandler().Router)
+	defer server.Close()
+
+	// Applications
+	apps := run(t, server.URL, "apps")
+	if !strings.HasPrefix(apps, "NAME") || !strings.Contains(apps, "Test_CLIShouldManageTheSessions") {
+		t.Errorf("unexpected applications output:\n%s", apps)
+	}
+
+	// Start
+	started := output.Session{}
+	if err := json.Unmarshal([]byte(run(t, server.URL, "-json", "start", "Test_CLIShouldManageTheSessions", "main")), &started); err != nil {
+		t.Fatal(err.Error())
+	}
+	if started.Checkout != "main" || started.UUID == "" {
+		t.Fatalf("unexpected session %+v", started)
+	}
+	events_assertions.AssertApplicationSessionSucceeded(applicationChan, t)
+
+	// Sessions
+	for start := time.Now(); ; time.Sleep(100 * time.Millisecond) {
+		sessions := []output.Session{}
+		json.Unmarshal([]byte(run(t, server.URL, "-json", "sessions")), &sessions)
+		if len(sessions) == 1 && sessions[0].Status == string(models.SessionStatusStarted) {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("expected the session to get started, got %+v", sessions)
+		}
+	}
+	sessions := run(t, server.URL, "sessions", "-app", "Test_CLIShouldManageTheSessions")
+	if !strings.Contains(sessions, started.UUID[:8]) || !strings.Contains(sessions, started.Alias) {
+		t.Errorf("unexpected sessions output:\n%s", sessions)
+	}
+
+	// Logs, by alias
+	if logs := run(t, server.URL, "logs", started.Alias); !strings.Contains(logs, "Requested checkout to main") {
+		t.Errorf("unexpected logs:\n%s", logs)
+	}
+
+	// Open
+	if url := run(t, server.URL, "open", "-print", started.Alias); strings.TrimSpace(url) != server.URL+"/s/main/" {
+		t.Errorf("unexpected URL %q", url)
+	}
+
+	// Fetch
+	if fetch := run(t, server.URL, "fetch", "-wait", "Test_CLIShouldManageTheSessions"); !strings.Contains(fetch, "Last fetch of Test_CLIShouldManageTheSessions succeeded") {
+		t.Errorf("unexpected fetch outcome:\n%s", fetch)
+	}
+	events_assertions.AssertApplicationGetsFetched(applicationChan, t)
+
+	// Stop, by UUID prefix
+	run(t, server.URL, "stop", started.UUID[:8])
+	for start := time.Now(); ; time.Sleep(100 * time.Millisecond) {
+		sessions := []output.Session{}
+		json.Unmarshal([]byte(run(t, server.URL, "-json", "sessions")), &sessions)
+		if len(sessions) == 0 {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("expected the session to get stopped, got %+v", sessions)
+		}
+	}
+
+	// Unknown session
+	stderr := &bytes.Buffer{}
+	if code := cli.Run([]string{"-host", server.URL, "stop", "unknown"}, &bytes.Buffer{}, stderr); code != 1 {
+		t.Errorf("expected exit code 1 stopping an unknown session, got %d", code)
+	}
+}
@@ -0,0 +1,215 @@
+package configuration_api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/storage"
+	"github.com/wufe/polo/pkg/utils"
+)
+
+const applicationName = "Test_ApplicationsShouldBeManagedThroughTheAPI"
+
+const fileConfiguration = `applications:
+  - name: Test_ApplicationsShouldBeManagedThroughTheAPI
+    remote: FakeRemote
+    commands:
+      start:
+        - command: valid-command.exe
+      stop:
+        - command: valid-command.exe
+`
+
+const managedApplication = `{
+	"name": "managed",
+	"remote": "FakeRemote",
+	"commands": {
+		"start": [{"command": "valid-command.exe"}],
+		"stop": [{"command": "valid-command.exe"}]
+	},
+	"pull_requests": {"token": "secret-token"}
+}`
+
+type applicationResponse struct {
+	Result struct {
+		Name          string                 `json:"name"`
+		Filename      string                 `json:"filename"`
+		Managed       bool                   `json:"managed"`
+		Hash          string                 `json:"hash"`
+		Configuration map[string]interface{} `json:"configuration"`
+	} `json:"result"`
+}
+
+func request(t *testing.T, method string, url string, etag string, body string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer response.Body.Close()
+	content, _ := ioutil.ReadAll(response.Body)
+	return response, content
+}
+
+// The applications should be added, changed and removed through the API,
+// with the changes written to the managed configuration file and applied at runtime;
+// the changes should require the hash of the current configuration
+func Test_ApplicationsShouldBeManagedThroughTheAPI(t *testing.T) {
+
+	executableFolder, err := ioutil.TempDir("", "polo-api")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(executableFolder)
+	previousCwd := os.Getenv("GO_CWD")
+	os.Setenv("GO_CWD", executableFolder)
+	defer os.Setenv("GO_CWD", previousCwd)
+
+	configurationFolder := filepath.Join(executableFolder, "conf")
+	os.Mkdir(configurationFolder, 0755)
+	configurationFile := filepath.Join(configurationFolder, "polo.yml")
+	ioutil.WriteFile(configurationFile, []byte(fileConfiguration), 0644)
+	os.Setenv(utils.ConfigurationPathsVariable, configurationFolder)
+	defer os.Unsetenv(utils.ConfigurationPathsVariable)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	branch := fetcher.NewBranch("main")
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), branch)
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+	}, models.BuildApplicationConfiguration(applicationName).
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe"))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	server := httptest.NewServer(di.GetRestHandler().Router)
+	defer server.Close()
+	url := server.URL + "/_polo_/api/applications"
+
+	// Creation
+	response, content := request(t, http.MethodPost, url, "", managedApplication)
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status %d creating the application, got %d: %s", http.StatusCreated, response.StatusCode, content)
+	}
+	created := applicationResponse{}
+	json.Unmarshal(content, &created)
+	managedFile := filepath.Join(executableFolder, storage.ManagedConfigurationFilename)
+	if !created.Result.Managed || created.Result.Filename != managedFile {
+		t.Errorf("expected the application to be written in %s, got %s", managedFile, created.Result.Filename)
+	}
+	if token := created.Result.Configuration["pull_requests"].(map[string]interface{})["token"]; token != "***" {
+		t.Errorf("expected the token to be redacted, got %v", token)
+	}
+	if di.GetQueryService().GetApplication("managed") == nil {
+		t.Fatal("expected the application created to be loaded")
+	}
+
+	response, _ = request(t, http.MethodPost, url, "", managedApplication)
+	if response.StatusCode != http.StatusConflict {
+		t.Errorf("expected status %d creating an existing application, got %d", http.StatusConflict, response.StatusCode)
+	}
+
+	// Retrieval
+	response, content = request(t, http.MethodGet, url+"/managed", "", "")
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d retrieving the application, got %d", http.StatusOK, response.StatusCode)
+	}
+	etag := response.Header.Get("ETag")
+	if etag != `"`+created.Result.Hash+`"` {
+		t.Errorf("expected the ETag to be the hash of the configuration, got %s", etag)
+	}
+
+	// Update
+	updated := strings.Replace(strings.Replace(managedApplication, "\"name\": \"managed\",", "", 1), "secret-token", "***", 1)
+	updated = strings.Replace(updated, `"remote"`, `"fetch": {"interval": 120}, "remote"`, 1)
+	response, _ = request(t, http.MethodPut, url+"/managed", "", updated)
+	if response.StatusCode != http.StatusPreconditionRequired {
+		t.Errorf("expected status %d updating without a hash, got %d", http.StatusPreconditionRequired, response.StatusCode)
+	}
+	response, _ = request(t, http.MethodPut, url+"/managed", `"wrong"`, updated)
+	if response.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d updating with a stale hash, got %d", http.StatusPreconditionFailed, response.StatusCode)
+	}
+	response, content = request(t, http.MethodPut, url+"/managed", etag, `{"remote": [}`)
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d updating with an invalid body, got %d", http.StatusBadRequest, response.StatusCode)
+	}
+	response, content = request(t, http.MethodPut, url+"/managed", etag, strings.Replace(updated, `"start"`, `"startt"`, 1))
+	if response.StatusCode != http.StatusBadRequest || !bytes.Contains(content, []byte("issues")) {
+		t.Errorf("expected status %d with the issues updating with an invalid configuration, got %d: %s", http.StatusBadRequest, response.StatusCode, content)
+	}
+	response, content = request(t, http.MethodPut, url+"/managed", etag, updated)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d updating the application, got %d: %s", http.StatusOK, response.StatusCode, content)
+	}
+	if interval := di.GetQueryService().GetApplication("managed").GetConfiguration().Fetch.Interval; interval != 120 {
+		t.Errorf("expected the configuration to be applied, got a fetch interval of %d", interval)
+	}
+	managedContent, _ := ioutil.ReadFile(managedFile)
+	if !bytes.Contains(managedContent, []byte("secret-token")) {
+		t.Errorf("expected the redacted token to keep its value, got:\n%s", managedContent)
+	}
+	etag = response.Header.Get("ETag")
+
+	// A change failing to be applied is not left in the managed file
+	os.Setenv(utils.ConfigurationPathsVariable, configurationFolder+string(os.PathListSeparator)+filepath.Join(executableFolder, "missing.yml"))
+	response, _ = request(t, http.MethodPut, url+"/managed", etag, strings.Replace(updated, "120", "240", 1))
+	if response.StatusCode == http.StatusOK {
+		t.Errorf("expected the change not to be applied with a missing configuration file")
+	}
+	if content, _ := ioutil.ReadFile(managedFile); !bytes.Equal(content, managedContent) {
+		t.Errorf("expected the managed file to be restored, got:\n%s", content)
+	}
+	os.Setenv(utils.ConfigurationPathsVariable, configurationFolder)
+	response, _ = request(t, http.MethodGet, url+"/managed", "", "")
+	if response.Header.Get("ETag") != etag {
+		t.Errorf("expected the ETag to be kept, got %s", response.Header.Get("ETag"))
+	}
+
+	// The applications defined in the other files cannot be changed
+	response, _ = request(t, http.MethodGet, url+"/"+applicationName, "", "")
+	response, _ = request(t, http.MethodDelete, url+"/"+applicationName, response.Header.Get("ETag"), "")
+	if response.StatusCode != http.StatusConflict {
+		t.Errorf("expected status %d deleting an application not managed, got %d", http.StatusConflict, response.StatusCode)
+	}
+
+	// Deletion
+	response, content = request(t, http.MethodDelete, url+"/managed", etag, "")
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d deleting the application, got %d: %s", http.StatusOK, response.StatusCode, content)
+	}
+	if di.GetQueryService().GetApplication("managed") != nil {
+		t.Errorf("expected the application to be removed")
+	}
+	if di.GetQueryService().GetApplication(applicationName) == nil {
+		t.Errorf("expected the application defined in the file to be kept")
+	}
+	response, _ = request(t, http.MethodGet, url+"/managed", "", "")
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status %d retrieving the application deleted, got %d", http.StatusNotFound, response.StatusCode)
+	}
+}
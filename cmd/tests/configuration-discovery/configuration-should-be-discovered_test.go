@@ -0,0 +1,122 @@
+package configuration_discovery
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/utils_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/cli"
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/storage"
+	"github.com/wufe/polo/pkg/utils"
+)
+
+const applicationConfiguration = `  - name: %s
+    remote: FakeRemote
+    commands:
+      start:
+        - command: valid-command.exe
+      stop:
+        - command: valid-command.exe
+`
+
+func applications(names ...string) string {
+	configuration := "applications:\n"
+	for _, name := range names {
+		configuration += strings.Replace(applicationConfiguration, "%s", name, 1)
+	}
+	return configuration
+}
+
+// The configuration should be loaded from files, folders and globs,
+// following the include directives, each file once
+func Test_ConfigurationShouldBeDiscovered(t *testing.T) {
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: versioning_fixture.NewRepositoryFetcher(),
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+	}, models.BuildApplicationConfiguration("Test_ConfigurationShouldBeDiscovered").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe"))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	folder, err := ioutil.TempDir("", "polo-discovery")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(folder)
+	mainFile := filepath.Join(folder, "polo.yaml")
+	apiFile := filepath.Join(folder, "apps", "api.yml")
+	workerFile := filepath.Join(folder, "apps", "nested", "worker.yaml")
+	extraFile := filepath.Join(folder, "extra", "extra.yml")
+	os.MkdirAll(filepath.Join(folder, "apps", "nested"), 0755)
+	os.MkdirAll(filepath.Join(folder, "apps", ".hidden"), 0755)
+	os.MkdirAll(filepath.Join(folder, "extra"), 0755)
+	ioutil.WriteFile(mainFile, []byte("include:\n  - apps\n  - extra/*.yml\n"+applications("main")), 0644)
+	ioutil.WriteFile(apiFile, []byte(applications("api")), 0644)
+	ioutil.WriteFile(workerFile, []byte("include:\n  - ../../polo.yaml\n"+applications("worker")), 0644)
+	ioutil.WriteFile(filepath.Join(folder, "apps", ".hidden", "hidden.yml"), []byte(applications("hidden")), 0644)
+	ioutil.WriteFile(filepath.Join(folder, "apps", "README.md"), []byte("# Applications"), 0644)
+	ioutil.WriteFile(extraFile, []byte(applications("extra", "api")), 0644)
+	ioutil.WriteFile(filepath.Join(folder, "ignored.yml"), []byte(applications("ignored")), 0644)
+
+	os.Setenv(utils.ConfigurationPathsVariable, mainFile)
+	defer os.Unsetenv(utils.ConfigurationPathsVariable)
+
+	var root *models.RootConfiguration
+	var loaded []*models.Application
+	err = di.GetContainer().Invoke(func(applicationBuilder *models.ApplicationBuilder, logger logging.Logger) {
+		root, loaded = storage.LoadConfigurations(utils_fixture.BuildTestEnvironment(), applicationBuilder, logger)
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expectedFiles := []string{mainFile, apiFile, workerFile, extraFile}
+	if strings.Join(root.Files, "\n") != strings.Join(expectedFiles, "\n") {
+		t.Errorf("expected the files\n%s\ngot\n%s", strings.Join(expectedFiles, "\n"), strings.Join(root.Files, "\n"))
+	}
+
+	// The duplicate api of extra.yml is ignored
+	expectedFilenames := map[string]string{"main": mainFile, "api": apiFile, "worker": workerFile, "extra": extraFile}
+	if len(loaded) != len(expectedFilenames) {
+		t.Errorf("expected %d applications, got %d", len(expectedFilenames), len(loaded))
+	}
+	for _, application := range loaded {
+		name := application.GetConfiguration().Name
+		if filename := application.ToOutput().Filename; filename != expectedFilenames[name] {
+			t.Errorf("expected application %s to be defined in %s, got %s", name, expectedFilenames[name], filename)
+		}
+	}
+
+	// The folder is read recursively and the duplicate is reported by polo validate
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cli.Run([]string{"validate", filepath.Join(folder, "apps"), filepath.Join(folder, "extra", "*.yml")}, stdout, stderr)
+	if !strings.Contains(stdout.String(), extraFile+":9:5: error: application api already defined in "+apiFile) || !strings.Contains(stdout.String(), "1 errors, 0 warnings") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+	if strings.Contains(stdout.String(), "hidden") {
+		t.Errorf("expected the hidden folders to be skipped, got:\n%s", stdout.String())
+	}
+
+	// A missing include is reported by the including file
+	ioutil.WriteFile(apiFile, []byte("include:\n  - missing.yml\n"+applications("api")), 0644)
+	stdout.Reset()
+	cli.Run([]string{"validate", apiFile}, stdout, stderr)
+	if !strings.Contains(stdout.String(), apiFile+": error: ") || !strings.Contains(stdout.String(), filepath.Join(folder, "apps", "missing.yml")) {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,126 @@
+package configuration_reload
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/utils"
+)
+
+const readApplicationName = "Test_ConfigurationShouldBeReloadedWhileRead"
+
+const readConfiguration = `global:
+  max_concurrent_sessions: %d
+  public_url: https://polo-%d.example.com
+applications:
+  - name: Test_ConfigurationShouldBeReloadedWhileRead
+    remote: FakeRemote
+    commands:
+      start:
+        - command: valid-command.exe
+      stop:
+        - command: valid-command.exe
+stacks:
+  - name: stack-%d
+    applications:
+      - application: Test_ConfigurationShouldBeReloadedWhileRead
+        entry: true
+`
+
+// The settings changing at runtime should be read safely while the configuration
+// gets reloaded (run with -race to detect the unguarded accesses)
+func Test_ConfigurationShouldBeReloadedWhileRead(t *testing.T) {
+
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	branch := fetcher.NewBranch("main")
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), branch)
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration(readApplicationName).
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	folder, err := ioutil.TempDir("", "polo-reload")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(folder)
+	file := filepath.Join(folder, "polo.yml")
+	os.Setenv(utils.ConfigurationPathsVariable, folder)
+	defer os.Unsetenv(utils.ConfigurationPathsVariable)
+
+	var root *models.RootConfiguration
+	di.GetContainer().Invoke(func(configuration *models.RootConfiguration) {
+		root = configuration
+	})
+	reloader := di.GetConfigurationReloader()
+	query := di.GetQueryService()
+
+	// The settings are read while the configuration gets reloaded
+	const reloads = 20
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				root.GetMaxConcurrentSessions()
+				root.GetPublicURL()
+				root.GetFiles()
+				for _, stack := range query.GetStacks() {
+					query.GetMatchingStackBySmartUrl(stack.Name + "/main/")
+				}
+			}
+		}()
+	}
+	for i := 1; i <= reloads; i++ {
+		ioutil.WriteFile(file, []byte(fmt.Sprintf(readConfiguration, i, i, i)), 0644)
+		if err := reloader.Reload(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	close(done)
+	readers.Wait()
+
+	if maxConcurrentSessions := root.GetMaxConcurrentSessions(); maxConcurrentSessions != reloads {
+		t.Errorf("expected max_concurrent_sessions to be %d, got %d", reloads, maxConcurrentSessions)
+	}
+	if publicURL := root.GetPublicURL(); publicURL != fmt.Sprintf("https://polo-%d.example.com", reloads) {
+		t.Errorf("unexpected public URL %s", publicURL)
+	}
+	if stacks := query.GetStacks(); len(stacks) != 1 || stacks[0].Name != fmt.Sprintf("stack-%d", reloads) {
+		t.Errorf("expected the stacks to be reloaded, got %+v", stacks)
+	}
+}
@@ -0,0 +1,174 @@
+package configuration_reload
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/utils"
+)
+
+const applicationName = "Test_ConfigurationShouldBeReloaded"
+
+const reloadedConfiguration = `global:
+  max_concurrent_sessions: 3
+applications:
+  - name: Test_ConfigurationShouldBeReloaded
+    remote: FakeRemote
+    fetch:
+      interval: 120
+    commands:
+      start:
+        - command: valid-command.exe
+      stop:
+        - command: valid-command.exe
+  - name: added
+    remote: FakeRemote
+    commands:
+      start:
+        - command: valid-command.exe
+      stop:
+        - command: valid-command.exe
+`
+
+// The changes of the configuration files should be applied at runtime:
+// applications added, changed and removed along with their sessions and global settings
+func Test_ConfigurationShouldBeReloaded(t *testing.T) {
+
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	branch := fetcher.NewBranch("main")
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), branch)
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration(applicationName).
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	sessionBuildResult, err := di.GetRequestService().NewSession(branch.Name, applicationName, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	session := sessionBuildResult.Session
+	events_assertions.AssertSessionEvents(
+		session.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	folder, err := ioutil.TempDir("", "polo-reload")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(folder)
+	file := filepath.Join(folder, "polo.yml")
+	ioutil.WriteFile(file, []byte(reloadedConfiguration), 0644)
+	os.Setenv(utils.ConfigurationPathsVariable, folder)
+	defer os.Unsetenv(utils.ConfigurationPathsVariable)
+
+	var root *models.RootConfiguration
+	di.GetContainer().Invoke(func(configuration *models.RootConfiguration) {
+		root = configuration
+	})
+	reloader := di.GetConfigurationReloader()
+
+	// The application added gets initialized, the existing one gets the new configuration
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if maxConcurrentSessions := root.GetMaxConcurrentSessions(); maxConcurrentSessions != 3 {
+		t.Errorf("expected max_concurrent_sessions to be applied, got %d", maxConcurrentSessions)
+	}
+	if interval := application.GetConfiguration().Fetch.Interval; interval != 120 {
+		t.Errorf("expected the configuration of the application to be updated, got a fetch interval of %d", interval)
+	}
+	if application.Filename != file {
+		t.Errorf("expected the application to be defined in %s, got %s", file, application.Filename)
+	}
+	added := di.GetQueryService().GetApplication("added")
+	if added == nil {
+		t.Fatal("expected the application added to be stored")
+	}
+	waitFor(t, func() bool { return added.GetStatus() == models.ApplicationStatusReady }, "the application added to be initialized")
+
+	// An invalid configuration is not applied
+	ioutil.WriteFile(file, []byte(strings.Replace(reloadedConfiguration, "remote: FakeRemote", "remote: [", 1)), 0644)
+	if err := reloader.Reload(); err == nil {
+		t.Errorf("expected the invalid configuration not to be reloaded")
+	}
+	if len(di.GetQueryService().GetAllApplications()) != 2 {
+		t.Errorf("expected the applications to be kept")
+	}
+
+	// The file changes are watched; the application removed gets retired along with its sessions
+	// and its commit index
+	applicationID := application.GetConfiguration().ID
+	if len(di.GetCommitIndexStorage().Get(applicationID)) == 0 {
+		t.Fatal("expected the commits of the application to be indexed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := reloader.Watch(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	removed := strings.Replace(reloadedConfiguration, "Test_ConfigurationShouldBeReloaded", "renamed", 1)
+	ioutil.WriteFile(file, []byte(removed), 0644)
+	waitFor(t, func() bool { return di.GetQueryService().GetApplication(applicationName) == nil }, "the application to be removed")
+	if application.GetStatus() != models.ApplicationStatusRetired {
+		t.Errorf("expected the application to be retired, got %s", application.GetStatus())
+	}
+	if di.GetQueryService().GetApplication("renamed") == nil {
+		t.Errorf("expected the renamed application to be added")
+	}
+	waitFor(t, func() bool { return !session.GetStatus().IsAlive() }, "the session of the application removed to be destroyed")
+	if reason := session.GetKillReason(); reason != models.KillReasonApplicationRemoved {
+		t.Errorf("expected the session to be killed because its application has been removed, got %q", reason)
+	}
+	commitIndex := di.GetCommitIndexStorage()
+	if len(commitIndex.Get(applicationID)) > 0 || commitIndex.GetTips(applicationID, application.GetConfiguration().Fetch.History) != nil {
+		t.Errorf("expected the commit index of the application removed to be deleted")
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool, description string) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", description)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,222 @@
+package configuration_schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/cli"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// computedFields are decoded but not part of the configuration format
+var computedFields = map[string]bool{
+	"applications[].id":   true,
+	"applications[].hash": true,
+}
+
+var kindTypes = map[reflect.Kind]string{
+	reflect.String:  "string",
+	reflect.Bool:    "boolean",
+	reflect.Int:     "integer",
+	reflect.Float32: "number",
+	reflect.Slice:   "array",
+	reflect.Map:     "object",
+	reflect.Struct:  "object",
+}
+
+// checkProperties checks that the schema describes all and only the fields of the type
+func checkProperties(t *testing.T, schema *models.JSONSchema, typ reflect.Type, path string) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if expected := kindTypes[typ.Kind()]; schema.Type != expected {
+		t.Errorf("%s: expected type %s, got %s", path, expected, schema.Type)
+	}
+	switch typ.Kind() {
+	case reflect.Slice:
+		if schema.Items == nil {
+			t.Errorf("%s: items not described", path)
+			return
+		}
+		checkProperties(t, schema.Items, typ.Elem(), path+"[]")
+	case reflect.Struct:
+		described := map[string]bool{}
+		for _, field := range models.YAMLFields(typ) {
+			fieldPath := field.Name
+			if path != "" {
+				fieldPath = path + "." + field.Name
+			}
+			if computedFields[fieldPath] {
+				continue
+			}
+			described[field.Name] = true
+			property, ok := schema.Properties[field.Name]
+			if !ok {
+				t.Errorf("%s: not described", fieldPath)
+				continue
+			}
+			if property.Description == "" {
+				t.Errorf("%s: missing description", fieldPath)
+			}
+			checkProperties(t, property, field.Type, fieldPath)
+		}
+		for name := range schema.Properties {
+			if !described[name] {
+				t.Errorf("%s: described but not a field", path+"."+name)
+			}
+		}
+	}
+}
+
+// checkDefaults checks that the defaults of the schema are those applied
+// to the values, skipping the lists
+func checkDefaults(t *testing.T, schema *models.JSONSchema, value reflect.Value, path string) {
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return
+	}
+	for _, field := range models.YAMLFields(value.Type()) {
+		property, ok := schema.Properties[field.Name]
+		if !ok {
+			continue
+		}
+		fieldValue := value.FieldByName(field.StructField.Name)
+		if property.Default != nil {
+			actual := fieldValue
+			for actual.Kind() == reflect.Ptr {
+				actual = actual.Elem()
+			}
+			if fmt.Sprint(actual.Interface()) != fmt.Sprint(property.Default) {
+				t.Errorf("%s.%s: the schema states the default %v, but %v is applied", path, field.Name, property.Default, actual.Interface())
+			}
+		}
+		checkDefaults(t, property, fieldValue, path+"."+field.Name)
+	}
+}
+
+// checkKeys checks that the keys of a configuration are described by the schema
+func checkKeys(t *testing.T, schema *models.JSONSchema, value interface{}, path string) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		for key, item := range v {
+			property, ok := schema.Properties[fmt.Sprint(key)]
+			if !ok {
+				t.Errorf("%s.%v: not described", path, key)
+				continue
+			}
+			checkKeys(t, property, item, fmt.Sprintf("%s.%v", path, key))
+		}
+	case []interface{}:
+		for i, item := range v {
+			if schema.Items != nil {
+				checkKeys(t, schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+// The JSON Schema of the configuration should describe all the fields
+// of the configuration structs, with the defaults actually applied
+func Test_ConfigurationSchemaShouldMatchTheStructs(t *testing.T) {
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: versioning_fixture.NewRepositoryFetcher(),
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+	}, models.BuildApplicationConfiguration("Test_ConfigurationSchemaShouldMatchTheStructs").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe"))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	server := httptest.NewServer(di.GetRestHandler().Router)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/_polo_/api/schema")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/schema+json" {
+		t.Fatalf("unexpected response %d %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	schema := &models.JSONSchema{}
+	if err := json.Unmarshal(body, schema); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// The same schema is exported by polo -schema
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := cli.Run([]string{"-schema"}, stdout, stderr); code != 0 {
+		t.Errorf("expected exit code 0 exporting the schema, got %d: %s", code, stderr.String())
+	}
+	if !bytes.Equal(bytes.TrimSpace(stdout.Bytes()), bytes.TrimSpace(body)) {
+		t.Errorf("expected polo -schema to export the served schema")
+	}
+
+	// Fields
+	checkProperties(t, schema, reflect.TypeOf(models.RootConfiguration{}), "")
+	applicationSchema := schema.Properties["applications"].Items
+	if len(applicationSchema.Required) != 1 || applicationSchema.Required[0] != "name" {
+		t.Errorf("expected applications[].name to be required, got %v", applicationSchema.Required)
+	}
+	// The applications not extending a template require a remote and the commands
+	if len(applicationSchema.AnyOf) != 2 || fmt.Sprint(applicationSchema.AnyOf[1].Required) != "[commands remote]" {
+		t.Errorf("expected applications[] to require either extends or remote and commands")
+	}
+	if enum := applicationSchema.Properties["helper"].Properties["position"].Enum; len(enum) == 0 {
+		t.Errorf("expected the helper positions to be enumerated")
+	}
+
+	// Defaults
+	configuration, err := models.NewApplicationConfiguration(&models.ApplicationConfiguration{
+		SharedConfiguration: models.SharedConfiguration{
+			Remote: "FakeRemote",
+			Commands: models.Commands{
+				Start: []models.Command{{Command: "start"}},
+				Stop:  []models.Command{{Command: "stop"}},
+			},
+		},
+		Name: "app",
+		PullRequests: models.PullRequestsConfiguration{
+			CommitStatus: models.CommitStatusConfiguration{Provider: models.CommitStatusProviderGitHub, Repository: "owner/name"},
+		},
+	}, func() utils.RWLocker { return &sync.RWMutex{} })
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	checkDefaults(t, applicationSchema, reflect.ValueOf(configuration), "applications[]")
+	checkDefaults(t, schema.Properties["global"], reflect.ValueOf(models.NewGlobalConfiguration(&models.GlobalConfiguration{})), "global")
+
+	// Example
+	content, err := ioutil.ReadFile("../../../examples/example-configuration.yml")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var example interface{}
+	if err := yaml.Unmarshal(content, &example); err != nil {
+		t.Fatal(err.Error())
+	}
+	checkKeys(t, schema, example, "")
+}
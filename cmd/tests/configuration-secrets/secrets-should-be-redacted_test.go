@@ -0,0 +1,174 @@
+package configuration_secrets
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/cli"
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/storage"
+)
+
+const configuration = `global:
+  secrets:
+    file: polo.env
+    folder: secrets
+applications:
+  - name: app
+    remote: ${POLO_TEST_REMOTE}
+    target: http://${POLO_TEST_HOST:-127.0.0.1}:{{port}}
+    headers:
+      add:
+        - Authorization=Bearer {{secret.api_key}}
+    commands:
+      start:
+        - command: docker login -p {{secret.registry_token}} $${REGISTRY}
+      stop:
+        - command: docker logout
+`
+
+const dotenv = `# Registry
+export registry_token="t0k3n-from-dotenv"
+api_key=k3y-from-dotenv
+`
+
+// The environment variables should be interpolated into the configuration files
+// and the secrets read from their source
+func Test_ConfigurationShouldReadEnvironmentAndSecrets(t *testing.T) {
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: versioning_fixture.NewRepositoryFetcher(),
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+	}, models.BuildApplicationConfiguration("Test_ConfigurationShouldReadEnvironmentAndSecrets").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe"))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	folder, err := ioutil.TempDir("", "polo-secrets")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(folder)
+	file := filepath.Join(folder, "polo.yml")
+	ioutil.WriteFile(file, []byte(configuration), 0644)
+	ioutil.WriteFile(filepath.Join(folder, "polo.env"), []byte(dotenv), 0644)
+	os.Mkdir(filepath.Join(folder, "secrets"), 0755)
+	ioutil.WriteFile(filepath.Join(folder, "secrets", "api_key"), []byte("k3y-from-folder\n"), 0644)
+	os.Setenv("POLO_TEST_REMOTE", "https://example.com/app.git")
+	defer os.Unsetenv("POLO_TEST_REMOTE")
+
+	var root *models.RootConfiguration
+	err = di.GetContainer().Invoke(func(applicationBuilder *models.ApplicationBuilder, logger logging.Logger) error {
+		var err error
+		root, err = storage.UnmarshalConfiguration(file, storage.LoadConfigurationSources([]string{file}, logger), applicationBuilder, logger)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	app := root.ApplicationConfigurations[0]
+	if app.Remote != "https://example.com/app.git" || app.Target != "http://127.0.0.1:{{port}}" {
+		t.Errorf("expected the environment variables to be interpolated, got %s and %s", app.Remote, app.Target)
+	}
+	if app.Commands.Start[0].Command != "docker login -p {{secret.registry_token}} ${REGISTRY}" {
+		t.Errorf("expected $${ to be kept as ${, got %s", app.Commands.Start[0].Command)
+	}
+	if app.Secrets["registry_token"] != "t0k3n-from-dotenv" || app.Secrets["api_key"] != "k3y-from-folder" {
+		t.Errorf("expected the secrets to be read from the dotenv file and the folder, got %v", app.Secrets)
+	}
+
+	// An unset variable is reported by polo validate
+	os.Unsetenv("POLO_TEST_REMOTE")
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cli.Run([]string{"validate", folder}, stdout, stderr)
+	if !strings.Contains(stdout.String(), file+":7:13: warning: environment variable POLO_TEST_REMOTE is not set") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+	if strings.Contains(stdout.String(), "secret.") {
+		t.Errorf("expected the secrets to be known, got:\n%s", stdout.String())
+	}
+}
+
+// The secrets should be replaced in the commands
+// and redacted from the logs and the variables of the sessions
+func Test_SecretsShouldBeRedacted(t *testing.T) {
+
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	branch := fetcher.NewBranch("main")
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), branch)
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_SecretsShouldBeRedacted").
+		WithRemote("FakeRemote").
+		WithSecret("registry_token", "t0k3n").
+		WithOutputVariableStartCommand("login.exe {{secret.registry_token}}", "login").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	sessionBuildResult, err := di.GetRequestService().NewSession(branch.Name, application.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	session := sessionBuildResult.Session
+
+	events_assertions.AssertSessionEvents(
+		session.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	// The command got the secret, which is not exposed
+	if variable := session.Variables["login"]; !strings.Contains(variable, "t0k3n") {
+		t.Errorf("expected the secret to be replaced in the command, got %q", variable)
+	}
+	if variable := session.ToOutput().Variables["login"]; strings.Contains(variable, "t0k3n") || !strings.Contains(variable, models.RedactedSecret) {
+		t.Errorf("expected the secret to be redacted from the variables, got %q", variable)
+	}
+	redacted := false
+	for _, log := range session.GetLogs() {
+		if strings.Contains(log.Message, "t0k3n") {
+			t.Errorf("expected the secret to be redacted from the logs, got %q", log.Message)
+		}
+		redacted = redacted || strings.Contains(log.Message, "login.exe "+models.RedactedSecret)
+	}
+	if !redacted {
+		t.Errorf("expected the command to be logged with the secret redacted")
+	}
+}
@@ -0,0 +1,160 @@
+package configuration_templates
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/cli"
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/storage"
+)
+
+const baseConfiguration = `templates:
+  base:
+    headers:
+      add:
+        - X-Polo=1
+    healthcheck:
+      url: /health
+      max_retries: 3
+    recycle:
+      inactivity_timeout: 600
+    commands:
+      stop:
+        - command: docker stop {{container_id}}
+  web:
+    extends: base
+    healthcheck:
+      status: 204
+`
+
+const applicationsConfiguration = `applications:
+  - name: api
+    extends: web
+    remote: FakeRemote
+    headers: ~
+    healthcheck:
+      url: /ready
+    commands:
+      start:
+        - command: docker run {{name}}
+          output_variable: container_id
+    branches:
+      - test: ^main$
+        healthcheck:
+          url: /main
+  - name: worker
+    extends: base
+    remote: FakeRemote
+    commands:
+      start:
+        - command: docker run {{name}}
+          output_variable: container_id
+`
+
+// An application extending a template should be deep-merged with it,
+// even if the template is declared in another file
+func Test_ApplicationShouldExtendTemplate(t *testing.T) {
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: versioning_fixture.NewRepositoryFetcher(),
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+	}, models.BuildApplicationConfiguration("Test_ApplicationShouldExtendTemplate").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe"))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	folder, err := ioutil.TempDir("", "polo-templates")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(folder)
+	baseFile := filepath.Join(folder, "base.yml")
+	applicationsFile := filepath.Join(folder, "applications.yml")
+	ioutil.WriteFile(baseFile, []byte(baseConfiguration), 0644)
+	ioutil.WriteFile(applicationsFile, []byte(applicationsConfiguration), 0644)
+
+	var root *models.RootConfiguration
+	err = di.GetContainer().Invoke(func(applicationBuilder *models.ApplicationBuilder, logger logging.Logger) error {
+		sources := storage.LoadConfigurationSources([]string{baseFile, applicationsFile}, logger)
+		var err error
+		root, err = storage.UnmarshalConfiguration(applicationsFile, sources, applicationBuilder, logger)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(root.ApplicationConfigurations) != 2 {
+		t.Fatalf("expected 2 applications, got %d", len(root.ApplicationConfigurations))
+	}
+
+	api := root.ApplicationConfigurations[0]
+	if api.Healthcheck.URL != "/ready" || api.Healthcheck.Status != 204 || api.Healthcheck.MaxRetries != 3 {
+		t.Errorf("expected the healthcheck to be merged, got %+v", api.Healthcheck)
+	}
+	if api.Recycle.InactivityTimeout != 600 {
+		t.Errorf("expected the recycle to be inherited, got %d", api.Recycle.InactivityTimeout)
+	}
+	if len(api.Commands.Stop) != 1 || len(api.Commands.Start) != 1 {
+		t.Errorf("expected the stop commands to be inherited, got %+v", api.Commands)
+	}
+	if len(api.Headers.Add) != 0 {
+		t.Errorf("expected the headers to be removed, got %+v", api.Headers.Add)
+	}
+	if len(api.Branches) != 1 || api.Branches[0].Healthcheck.URL != "/main" {
+		t.Errorf("expected the branch rules to be kept, got %+v", api.Branches)
+	}
+
+	// The template is not modified by the applications extending it
+	worker := root.ApplicationConfigurations[1]
+	if worker.Healthcheck.URL != "/health" || worker.Healthcheck.Status != 200 || len(worker.Headers.Add) != 1 {
+		t.Errorf("expected the worker to get the base template, got %+v %+v", worker.Healthcheck, worker.Headers)
+	}
+
+	expectedSources := map[string]string{
+		"extends":                    "application api (" + applicationsFile + ")",
+		"healthcheck.url":            "application api (" + applicationsFile + ")",
+		"healthcheck.status":         "template web (" + baseFile + ")",
+		"healthcheck.max_retries":    "template base (" + baseFile + ")",
+		"recycle.inactivity_timeout": "template base (" + baseFile + ")",
+		"commands.stop":              "template base (" + baseFile + ")",
+	}
+	for path, source := range expectedSources {
+		if api.Provenance[path].Source != source {
+			t.Errorf("expected %s to come from %s, got %q", path, source, api.Provenance[path].Source)
+		}
+	}
+	if value := api.Provenance["healthcheck.max_retries"].Value; value != "3" {
+		t.Errorf("expected the provenance to report the value, got %q", value)
+	}
+	if _, ok := api.Provenance["headers.add"]; ok {
+		t.Errorf("expected the removed headers not to be reported")
+	}
+
+	// The templates are resolved by polo validate too
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := cli.Run([]string{"validate", folder}, stdout, stderr); code != 0 {
+		t.Errorf("expected the configuration to be valid, got:\n%s", stdout.String())
+	}
+	ioutil.WriteFile(filepath.Join(folder, "broken.yml"), []byte("applications:\n  - name: broken\n    extends: missing\n"), 0644)
+	stdout.Reset()
+	if code := cli.Run([]string{"validate", folder}, stdout, stderr); code != 1 {
+		t.Errorf("expected exit code 1 extending an undefined template, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "broken.yml:3:5: error: application.extends: template missing is not defined") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
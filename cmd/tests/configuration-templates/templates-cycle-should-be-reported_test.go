@@ -0,0 +1,47 @@
+package configuration_templates
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wufe/polo/pkg/storage"
+)
+
+const cyclicTemplates = `templates:
+  base:
+    extends: web
+  web:
+    extends: node
+  node:
+    extends: base
+  self:
+    extends: self
+applications:
+  - name: api
+    remote: FakeRemote
+    extends: web
+  - name: worker
+    remote: FakeRemote
+    extends: self
+`
+
+// The templates extending each other should be reported
+// along with the whole chain forming the cycle
+func Test_TemplatesCycleShouldBeReported(t *testing.T) {
+
+	issues := storage.ValidateConfigurations([]storage.ConfigurationFile{
+		{Name: "polo.yml", Content: []byte(cyclicTemplates)},
+	}, nil)
+
+	messages := []string{}
+	for _, issue := range issues {
+		messages = append(messages, issue.Message)
+	}
+	all := strings.Join(messages, "\n")
+	if !strings.Contains(all, "application.extends: templates web → node → base → web form a cycle") {
+		t.Errorf("expected the indirect cycle to be reported, got:\n%s", all)
+	}
+	if !strings.Contains(all, "application.extends: template self extends itself") {
+		t.Errorf("expected the template extending itself to be reported, got:\n%s", all)
+	}
+}
@@ -0,0 +1,44 @@
+package configuration_validation
+
+import (
+	"testing"
+
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/storage"
+)
+
+const loadedConfiguration = `applications:
+  - &first
+    name: first
+    remote: FakeRemote
+    is_default: yes
+    max_concurrent_sessions: 010
+    commands:
+      start: []
+      stop:
+        - command: stop
+    branches:
+      - test: ^main$
+        watch: on
+  - <<: *first
+    name: second
+    is_default: no
+    start_timeout: 10
+`
+
+// The configuration should be decoded by the validation as the loader does,
+// with its merge keys and its yes/no/on/off booleans
+func Test_ConfigurationShouldBeValidatedAsLoaded(t *testing.T) {
+
+	issues := storage.ValidateConfigurations([]storage.ConfigurationFile{
+		{Name: "polo.yml", Content: []byte(loadedConfiguration)},
+	}, nil)
+
+	if len(issues) != 1 {
+		t.Fatalf("expected one issue, got %+v", issues)
+	}
+	issue := issues[0]
+	if issue.Path != "applications[1].start_timeout" || issue.Severity != models.ConfigurationIssueSeverityWarning || issue.Line != 17 || issue.Column != 5 {
+		t.Errorf("expected the unknown key of the second application to be reported, got %+v", issue)
+	}
+}
@@ -0,0 +1,150 @@
+package configuration_validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/cli"
+	"github.com/wufe/polo/pkg/models"
+)
+
+const configuration = `global:
+  port: abc
+applications:
+  - name: app
+    remote: FakeRemote
+    healthcheck:
+      retry_timeout: 20
+    forwards:
+      - pattern: "(["
+        to: http://127.0.0.1:{{port2}}
+    commands:
+      start:
+        - command: "run {{foo}} {{container_id}}"
+          output_variable: container_id
+      stop: []
+    branches:
+      - test: ".*"
+      - test: ^main$
+      - test: "[z"
+  - name: other
+    commands:
+      start: []
+stacks:
+  - name: Other
+    applications:
+      - application: app
+`
+
+type validationResponse struct {
+	Result models.ConfigurationValidation `json:"result"`
+}
+
+func findIssue(validation models.ConfigurationValidation, path string) *models.ConfigurationIssue {
+	for i, issue := range validation.Issues {
+		if issue.Path == path {
+			return &validation.Issues[i]
+		}
+	}
+	return nil
+}
+
+// All the issues of a configuration file should be reported,
+// located by line and column
+func Test_ConfigurationShouldBeValidated(t *testing.T) {
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: versioning_fixture.NewRepositoryFetcher(),
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+	}, models.BuildApplicationConfiguration("Test_ConfigurationShouldBeValidated").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe"))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	server := httptest.NewServer(di.GetRestHandler().Router)
+	defer server.Close()
+
+	res, err := http.Post(server.URL+"/_polo_/api/configuration/validate?file=polo.yml", "application/x-yaml", strings.NewReader(configuration))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code 200, got %d", res.StatusCode)
+	}
+	response := validationResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		t.Fatal(err.Error())
+	}
+	validation := response.Result
+	if validation.Valid {
+		t.Errorf("expected the configuration not to be valid")
+	}
+
+	expectations := []struct {
+		path     string
+		severity models.ConfigurationIssueSeverity
+		line     int
+		column   int
+	}{
+		{"", models.ConfigurationIssueSeverityError, 2, 3},                                              // port: abc
+		{"applications[0].healthcheck.retry_timeout", models.ConfigurationIssueSeverityWarning, 7, 7},   // unknown key
+		{"applications[0].forwards[0].pattern", models.ConfigurationIssueSeverityError, 9, 9},           // invalid regex
+		{"applications[0].commands.start[0].command", models.ConfigurationIssueSeverityWarning, 13, 11}, // {{foo}}
+		{"applications[0].branches[1].test", models.ConfigurationIssueSeverityWarning, 18, 9},           // unreachable
+		{"applications[0].branches[2].test", models.ConfigurationIssueSeverityError, 19, 9},             // invalid regex
+		{"applications[1].remote", models.ConfigurationIssueSeverityError, 20, 5},                       // missing remote
+		{"applications[1].commands.stop", models.ConfigurationIssueSeverityError, 21, 5},                // no stop commands
+		{"stacks[0].name", models.ConfigurationIssueSeverityError, 24, 5},                               // named like an application
+	}
+	for _, expected := range expectations {
+		issue := findIssue(validation, expected.path)
+		if issue == nil {
+			t.Errorf("expected an issue at %q, got %+v", expected.path, validation.Issues)
+			continue
+		}
+		if issue.File != "polo.yml" || issue.Severity != expected.severity || issue.Line != expected.line || issue.Column != expected.column {
+			t.Errorf("unexpected issue at %q: %+v", expected.path, *issue)
+		}
+	}
+	if issue := findIssue(validation, "applications[0].commands.start[0].command"); issue != nil && !strings.Contains(issue.Message, "{{foo}}") {
+		t.Errorf("expected the {{foo}} placeholder to be reported, got %q", issue.Message)
+	}
+	for _, issue := range validation.Issues {
+		if strings.Contains(issue.Message, "container_id") || strings.Contains(issue.Message, "port2") {
+			t.Errorf("unexpected issue %+v", issue)
+		}
+	}
+
+	// The same issues are reported by polo validate
+	folder, err := ioutil.TempDir("", "polo-validate")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(folder)
+	file := filepath.Join(folder, "polo.yml")
+	ioutil.WriteFile(file, []byte(configuration), 0644)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := cli.Run([]string{"validate", folder}, stdout, stderr); code != 1 {
+		t.Errorf("expected exit code 1 validating an invalid configuration, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), file+":19:9: error: ") || !strings.Contains(stdout.String(), "6 errors, 3 warnings") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,60 @@
+package configuration_validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+const stackConfiguration = `applications:
+  - name: Test_StackNamedLikeACheckoutShouldBeRejected
+    remote: FakeRemote
+    is_default: true
+    commands:
+      start:
+        - command: valid-command.exe
+      stop:
+        - command: valid-command.exe
+stacks:
+  - name: feature
+    applications:
+      - application: Test_StackNamedLikeACheckoutShouldBeRejected
+`
+
+// A stack named like a checkout of the default application of the running instance
+// should be rejected, since its urls would take the place of the ones of the checkout
+func Test_StackNamedLikeACheckoutShouldBeRejected(t *testing.T) {
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), fetcher.NewBranch("feature/login"))
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+	}, models.BuildApplicationConfiguration("Test_StackNamedLikeACheckoutShouldBeRejected").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		SetAsDefault(true))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	validation := di.GetQueryService().ValidateConfigurationContent("polo.yml", []byte(stackConfiguration))
+	if validation.Valid {
+		t.Errorf("expected the configuration not to be valid")
+	}
+	issue := findIssue(validation, "stacks[0].name")
+	if issue == nil {
+		t.Fatalf("expected the name of the stack to be rejected, got %+v", validation.Issues)
+	}
+	if issue.Severity != models.ConfigurationIssueSeverityError || issue.Line != 11 || !strings.Contains(issue.Message, "checkout feature/login") {
+		t.Errorf("unexpected issue %+v", *issue)
+	}
+}
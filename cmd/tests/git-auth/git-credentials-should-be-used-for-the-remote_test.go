@@ -0,0 +1,133 @@
+package git_auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/execution"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/utils"
+	"github.com/wufe/polo/pkg/versioning"
+)
+
+// remoteFixture is an HTTPS remote requiring credentials,
+// recording the ones it receives
+type remoteFixture struct {
+	sync.Mutex
+	*httptest.Server
+	authorizations []string
+}
+
+func newRemoteFixture() *remoteFixture {
+	remote := &remoteFixture{}
+	remote.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="polo"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		remote.Lock()
+		remote.authorizations = append(remote.authorizations, authorization)
+		remote.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	return remote
+}
+
+func (r *remoteFixture) receivedAuthorization(authorization string) bool {
+	r.Lock()
+	defer r.Unlock()
+	for _, received := range r.authorizations {
+		if received == authorization {
+			return true
+		}
+	}
+	return false
+}
+
+// The credentials of an application should be sent to its remote by both the git clients,
+// without appearing in their errors
+func Test_GitCredentialsShouldBeUsedForTheRemote(t *testing.T) {
+
+	folder, err := ioutil.TempDir("", "polo-git-auth")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(folder)
+
+	auth := models.GitAuth{HTTPS: models.GitHTTPSAuth{Username: "polo", Token: "s3cr3t-token"}}
+	expected := "Basic " + base64.StdEncoding.EncodeToString([]byte("polo:s3cr3t-token"))
+
+	clients := map[string]versioning.GitClient{
+		"cli":      versioning.NewCLIGitClient(execution.NewCommandRunner()),
+		"embedded": versioning.NewEmbeddedGitClient(nil),
+	}
+	for name, client := range clients {
+		remote := newRemoteFixture()
+		err := client.WithAuth(auth).Clone(folder, name, remote.URL+"/polo.git")
+		remote.Close()
+		if err == nil {
+			t.Errorf("%s: expected the clone of a missing repository to fail", name)
+			continue
+		}
+		if strings.Contains(err.Error(), "s3cr3t-token") {
+			t.Errorf("%s: expected the token not to appear in the error, got %q", name, err.Error())
+		}
+		if !remote.receivedAuthorization(expected) {
+			t.Errorf("%s: expected the credentials to be sent, got %v", name, remote.authorizations)
+		}
+	}
+}
+
+// The credentials should be validated, may be secrets and should be left out of the outputs
+func Test_GitCredentialsShouldBeConfigured(t *testing.T) {
+
+	invalid := models.BuildApplicationConfiguration("invalid").
+		WithRemote("FakeRemote").
+		WithSSHAuth("", "passphrase", "sometimes")
+	_, err := models.NewApplicationConfiguration(invalid, func() utils.RWLocker { return &sync.RWMutex{} })
+	if err == nil || !strings.Contains(err.Error(), "application.auth.ssh.known_hosts") || !strings.Contains(err.Error(), "application.auth.ssh.passphrase") {
+		t.Errorf("expected the credentials not to be valid, got %v", err)
+	}
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: versioning_fixture.NewRepositoryFetcher(),
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+	}, models.BuildApplicationConfiguration("Test_GitCredentialsShouldBeConfigured").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithSecret("git_token", "s3cr3t-token").
+		WithHTTPSAuth("", "{{secret.git_token}}"))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	configuration := application.GetConfiguration()
+	auth := configuration.GetAuth()
+	if auth.HTTPS.Username != "git" || auth.HTTPS.Token != "s3cr3t-token" {
+		t.Errorf("expected the token to be read from the secrets, got %+v", auth.HTTPS)
+	}
+
+	output := models.MapApplications(di.GetApplications())[0].Configuration.Auth
+	if !output.HTTPS.HasToken || output.HTTPS.Username != "git" {
+		t.Errorf("expected the credentials to be reported, got %+v", output)
+	}
+	encoded, _ := json.Marshal(models.MapApplications(di.GetApplications()))
+	if strings.Contains(string(encoded), "s3cr3t-token") || strings.Contains(string(encoded), "secret.git_token") {
+		t.Errorf("expected the token to be left out of the outputs")
+	}
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+// The metrics endpoint should expose the sessions, the build stages,
+// the healthchecks, the fetches, the queues and the proxied requests
+// in the Prometheus text format
+func Test_MetricsShouldBeExposed(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_MetricsShouldBeExposed").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true),
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	firstApplication := applications[0]
+	firstApplicationChan := firstApplication.GetEventBus().GetChan()
+
+	// Assert application is being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(firstApplicationChan, t)
+
+	// Request new session to be built
+	requestService := di.GetRequestService()
+	sessionBuildResult, err := requestService.NewSession(branch.Name, firstApplication.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	session := sessionBuildResult.Session
+
+	events_assertions.AssertSessionEvents(
+		session.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+	for session.GetStatus() != models.SessionStatusStarted {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server := httptest.NewServer(di.GetRestHandler().Router)
+	defer server.Close()
+
+	// Proxy a request to the session
+	req, _ := http.NewRequest("GET", server.URL+"/", nil)
+	req.AddCookie(&http.Cookie{Name: "PoloSession", Value: session.UUID})
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	res, err = http.Get(server.URL + "/_polo_/metrics")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	metrics := string(body)
+
+	app := firstApplication.GetConfiguration().Name
+	expected := []string{
+		fmt.Sprintf(`polo_sessions{application="%s",status="started"} 1`, app),
+		fmt.Sprintf(`polo_session_build_stage_duration_seconds_count{application="%s",stage="Startup commands"} 1`, app),
+		fmt.Sprintf(`polo_session_healthchecks_total{application="%s",result="succeeded"}`, app),
+		fmt.Sprintf(`polo_application_fetch_duration_seconds_count{application="%s"}`, app),
+		`polo_mediator_queue_wait_seconds_count{queue="session_build"} 1`,
+		fmt.Sprintf(`polo_proxy_requests_total{application="%s",session="%s",code="200"} 1`, app, session.UUID),
+		fmt.Sprintf(`polo_proxy_response_bytes_total{application="%s",session="%s"} 16`, app, session.UUID),
+		"# TYPE polo_session_build_failures_total counter",
+	}
+	for _, e := range expected {
+		if !strings.Contains(metrics, e) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", e, metrics)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package pull_requests
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/storage"
+)
+
+type commitStatus struct {
+	path          string
+	authorization string
+	State         string `json:"state"`
+	TargetURL     string `json:"target_url"`
+	Context       string `json:"context"`
+}
+
+// forge records the commit statuses posted as a Gitea instance
+type forge struct {
+	sync.Mutex
+	statuses []commitStatus
+}
+
+func (f *forge) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	status := commitStatus{path: req.URL.Path, authorization: req.Header.Get("Authorization")}
+	json.Unmarshal(body, &status)
+	f.Lock()
+	f.statuses = append(f.statuses, status)
+	f.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (f *forge) waitFor(t *testing.T, state string) commitStatus {
+	for start := time.Now(); time.Since(start) < 15*time.Second; time.Sleep(100 * time.Millisecond) {
+		f.Lock()
+		for _, status := range f.statuses {
+			if status.State == state {
+				f.Unlock()
+				return status
+			}
+		}
+		f.Unlock()
+	}
+	t.Fatalf("expected a %s commit status to be posted", state)
+	return commitStatus{}
+}
+
+func waitForSession(t *testing.T, sessionStorage *storage.Session, checkout string, condition func(*models.Session) bool) *models.Session {
+	for start := time.Now(); time.Since(start) < 15*time.Second; time.Sleep(100 * time.Millisecond) {
+		for _, session := range sessionStorage.GetByApplicationName("Test_PullRequestsShouldBePreviewed") {
+			if session.Checkout == checkout && condition(session) {
+				return session
+			}
+		}
+	}
+	t.Fatalf("the session of %s did not reach the expected state", checkout)
+	return nil
+}
+
+// An opened pull request matching the branch rules should be started automatically,
+// posting its URL as commit status, and its session should be destroyed
+// once the pull request gets closed
+func Test_PullRequestsShouldBePreviewed(t *testing.T) {
+
+	forge := &forge{}
+	forgeServer := httptest.NewServer(forge)
+	defer forgeServer.Close()
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	branch := fetcher.NewBranch("main")
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), branch)
+
+	configuration := models.BuildApplicationConfiguration("Test_PullRequestsShouldBePreviewed").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		WithCommitStatus(models.CommitStatusProviderGitea, forgeServer.URL, "forge-token", "polo/app").
+		WithBranch(models.BuildBranchConfigurationMatch("^pr-").SetWatch(true)).
+		SetAsDefault(true)
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, configuration)
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	// Open the pull request
+	pullRequestCommit := fetcher.NewCommit("Pull request commit")
+	fetcher.OpenPullRequest(7, pullRequestCommit)
+	di.GetMediator().ApplicationFetch.Enqueue(application, true)
+
+	sessionStorage := di.GetSessionStorage()
+	session := waitForSession(t, sessionStorage, "pr-7", func(s *models.Session) bool {
+		return s.GetStatus() == models.SessionStatusStarted
+	})
+	if session.DisplayName != "pr-7" {
+		t.Errorf("expected the display name of the session to be pr-7, got %q", session.DisplayName)
+	}
+
+	forge.waitFor(t, "pending")
+	success := forge.waitFor(t, "success")
+	if success.path != "/api/v1/repos/polo/app/statuses/"+pullRequestCommit.Hash.String() {
+		t.Errorf("unexpected commit status path %q", success.path)
+	}
+	if success.authorization != "token forge-token" {
+		t.Errorf("unexpected authorization %q", success.authorization)
+	}
+	if !strings.HasSuffix(success.TargetURL, "/s/pr-7/") || success.Context != "polo" {
+		t.Errorf("unexpected commit status %+v", success)
+	}
+
+	// Close the pull request
+	fetcher.ClosePullRequest(7)
+	di.GetMediator().ApplicationFetch.Enqueue(application, true)
+
+	waitForSession(t, sessionStorage, "pr-7", func(s *models.Session) bool {
+		return !s.GetStatus().IsAlive()
+	})
+	if reason := session.GetKillReason(); reason != models.KillReasonPullRequestClosed {
+		t.Errorf("expected the kill reason to be %s, got %s", models.KillReasonPullRequestClosed, reason)
+	}
+}
@@ -0,0 +1,99 @@
+package push_hooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+func postPushHook(t *testing.T, url string, event string, secret string, body []byte) int {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	response.Body.Close()
+	return response.StatusCode
+}
+
+// A signed push hook should trigger an immediate fetch of the pushed ref;
+// the ones with an invalid signature should be rejected
+func Test_PushHooksShouldTriggerAFetch(t *testing.T) {
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	branch := fetcher.NewBranch("main")
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), branch)
+
+	configuration := models.BuildApplicationConfiguration("Test_PushHooksShouldTriggerAFetch").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithPushHook("secret", true).
+		SetAsDefault(true)
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+	}, configuration)
+
+	application := di.GetApplications()[0]
+	applicationChan := application.GetEventBus().GetChan()
+
+	// Assert application is being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(applicationChan, t)
+
+	server := httptest.NewServer(di.GetRestHandler().Router)
+	defer server.Close()
+	url := server.URL + "/_polo_/api/hooks/Test_PushHooksShouldTriggerAFetch"
+
+	// Invalid signature
+	status := postPushHook(t, url, "push", "wrong-secret", []byte(`{"ref": "refs/heads/main", "after": "abc"}`))
+	if status != http.StatusUnauthorized {
+		t.Errorf("expected status %d with an invalid signature, got %d", http.StatusUnauthorized, status)
+	}
+
+	// Ping, sent when the hook gets created
+	status = postPushHook(t, url, "ping", "secret", []byte(`{"zen": "Keep it simple."}`))
+	if status != http.StatusOK {
+		t.Errorf("expected status %d for a ping, got %d", http.StatusOK, status)
+	}
+
+	// Push
+	status = postPushHook(t, url, "push", "secret", []byte(`{"ref": "refs/heads/main", "after": "abc"}`))
+	if status != http.StatusAccepted {
+		t.Fatalf("expected status %d for a push, got %d", http.StatusAccepted, status)
+	}
+	events_assertions.AssertApplicationGetsFetched(applicationChan, t)
+
+	fetchedRefs := fetcher.GetFetchedRefs()
+	expected := []string{"refs/heads/main"}
+	if last := fetchedRefs[len(fetchedRefs)-1]; !reflect.DeepEqual(last, expected) {
+		t.Errorf("expected only %v to be fetched, got %v", expected, last)
+	}
+
+	// Unknown application
+	status = postPushHook(t, server.URL+"/_polo_/api/hooks/unknown", "push", "secret", []byte(`{"ref": "refs/heads/main"}`))
+	if status != http.StatusNotFound {
+		t.Errorf("expected status %d for an unknown application, got %d", http.StatusNotFound, status)
+	}
+}
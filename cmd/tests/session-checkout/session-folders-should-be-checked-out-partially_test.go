@@ -0,0 +1,132 @@
+package session_checkout
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/wufe/polo/pkg/execution"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/utils"
+	"github.com/wufe/polo/pkg/versioning"
+)
+
+func commitFiles(t *testing.T, folder string, worktree *git.Worktree, message string, files ...string) plumbing.Hash {
+	for _, file := range files {
+		path := filepath.Join(folder, file)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(message), 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := worktree.Add(file); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	signature := &object.Signature{Name: "Polo", Email: "polo@example.com", When: time.Now()}
+	hash, err := worktree.Commit(message, &git.CommitOptions{Author: signature, Committer: signature})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return hash
+}
+
+func checkedOutFiles(t *testing.T, folder string) []string {
+	files := []string{}
+	filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			relative, _ := filepath.Rel(folder, path)
+			files = append(files, filepath.ToSlash(relative))
+		}
+		return nil
+	})
+	return files
+}
+
+// The session folders should be cloned with the history, the objects and the paths
+// of the checkout options, and get checked out at commits beyond the history cloned
+func Test_SessionFoldersShouldBeCheckedOutPartially(t *testing.T) {
+
+	folder, err := ioutil.TempDir("", "polo-checkout")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(folder)
+
+	remoteFolder := filepath.Join(folder, "remote")
+	repo, err := git.PlainInit(remoteFolder, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	first := commitFiles(t, remoteFolder, worktree, "First", "services/api/main.go", "services/web/index.html", "README.md")
+	second := commitFiles(t, remoteFolder, worktree, "Second", "services/api/handler.go")
+
+	client := versioning.NewCLIGitClient(execution.NewCommandRunner()).WithCheckout(models.CheckoutConfiguration{
+		Depth:  1,
+		Sparse: []string{"/services/api/"},
+	})
+	sessionFolder := filepath.Join(folder, "main")
+	if err := client.Clone(folder, "main", "file://"+filepath.ToSlash(remoteFolder)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := client.FetchAll(sessionFolder); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := client.HardReset(sessionFolder, second.String()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if files := checkedOutFiles(t, sessionFolder); strings.Join(files, ",") != "services/api/handler.go,services/api/main.go" {
+		t.Errorf("expected only the sparse paths to be checked out, got %v", files)
+	}
+	if _, err := os.Stat(filepath.Join(sessionFolder, ".git", "shallow")); err != nil {
+		t.Errorf("expected the session folder to be a shallow clone")
+	}
+
+	// A commit beyond the depth gets fetched
+	if err := client.HardReset(sessionFolder, first.String()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if files := checkedOutFiles(t, sessionFolder); strings.Join(files, ",") != "services/api/main.go" {
+		t.Errorf("expected the first commit to be checked out, got %v", files)
+	}
+
+	// The embedded client cannot check out partially
+	embedded := versioning.NewEmbeddedGitClient(nil).WithCheckout(models.CheckoutConfiguration{Filter: "blob:none"})
+	if err := embedded.Clone(folder, "embedded", "file://"+filepath.ToSlash(remoteFolder)); err == nil {
+		t.Errorf("expected the embedded client to refuse partial clones")
+	}
+}
+
+// The checkout options should be validated
+func Test_CheckoutOptionsShouldBeValidated(t *testing.T) {
+
+	configuration := models.BuildApplicationConfiguration("invalid").WithRemote("FakeRemote")
+	configuration.Checkout = models.CheckoutConfiguration{Depth: -1, Filter: "blobs", Sparse: []string{""}}
+	_, err := models.NewApplicationConfiguration(configuration, func() utils.RWLocker { return &sync.RWMutex{} })
+	for _, expected := range []string{"application.checkout.depth", "application.checkout.filter", "application.checkout.sparse[0]"} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %s not to be valid, got %v", expected, err)
+		}
+	}
+
+	configuration = models.BuildApplicationConfiguration("folder-copy").WithRemote("FakeRemote")
+	configuration.UseFolderCopy = true
+	configuration.Checkout = models.CheckoutConfiguration{Depth: 1}
+	issues := models.LintApplicationConfiguration(configuration, nil)
+	if len(issues) != 1 || issues[0].Path != "checkout" {
+		t.Errorf("expected the checkout options to be reported as ignored, got %+v", issues)
+	}
+}
@@ -0,0 +1,93 @@
+package session_checkout
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wufe/polo/pkg/execution"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/versioning"
+)
+
+func runGit(t *testing.T, folder string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=Polo", "-c", "user.email=polo@example.com"}, args...)...)
+	cmd.Dir = folder
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s\n%s", strings.Join(args, " "), err.Error(), output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// The submodules of the session folders should be checked out if configured,
+// and the failures of the LFS checkout should be reported as such
+func Test_SessionFoldersShouldCheckOutSubmodulesAndLFS(t *testing.T) {
+
+	folder, err := ioutil.TempDir("", "polo-submodules")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(folder)
+
+	// The submodules of local repositories are allowed for the test only
+	os.Setenv("GIT_CONFIG_COUNT", "1")
+	os.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	os.Setenv("GIT_CONFIG_VALUE_0", "always")
+	defer os.Unsetenv("GIT_CONFIG_COUNT")
+	defer os.Unsetenv("GIT_CONFIG_KEY_0")
+	defer os.Unsetenv("GIT_CONFIG_VALUE_0")
+
+	library := filepath.Join(folder, "library")
+	os.MkdirAll(library, 0755)
+	runGit(t, library, "init", "-q")
+	ioutil.WriteFile(filepath.Join(library, "library.txt"), []byte("library"), 0644)
+	runGit(t, library, "add", ".")
+	runGit(t, library, "commit", "-q", "-m", "Library")
+
+	remote := filepath.Join(folder, "remote")
+	os.MkdirAll(remote, 0755)
+	runGit(t, remote, "init", "-q")
+	ioutil.WriteFile(filepath.Join(remote, "main.txt"), []byte("main"), 0644)
+	runGit(t, remote, "submodule", "add", "-q", "file://"+filepath.ToSlash(library), "library")
+	runGit(t, remote, "add", ".")
+	runGit(t, remote, "commit", "-q", "-m", "Main")
+	commit := runGit(t, remote, "rev-parse", "HEAD")
+
+	client := versioning.NewCLIGitClient(execution.NewCommandRunner()).WithCheckout(models.CheckoutConfiguration{Submodules: true, LFS: true})
+	sessionFolder := filepath.Join(folder, "main")
+	if err := client.Clone(folder, "main", "file://"+filepath.ToSlash(remote)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := client.FetchAll(sessionFolder); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := client.HardReset(sessionFolder, commit); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := os.Stat(filepath.Join(sessionFolder, "library", "library.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected the submodule not to be checked out by the hard reset")
+	}
+	if err := client.UpdateSubmodules(sessionFolder); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := os.Stat(filepath.Join(sessionFolder, "library", "library.txt")); err != nil {
+		t.Errorf("expected the submodule to be checked out")
+	}
+
+	err = client.CheckoutLFS(sessionFolder)
+	if exec.Command("git", "lfs", "version").Run() != nil {
+		if err == nil || !strings.Contains(err.Error(), "git-lfs is not installed") {
+			t.Errorf("expected the missing git-lfs to be reported, got %v", err)
+		}
+	} else if err != nil {
+		t.Errorf("expected the LFS objects to be checked out, got %s", err.Error())
+	}
+
+	if err := versioning.NewEmbeddedGitClient(nil).CheckoutLFS(sessionFolder); err == nil {
+		t.Errorf("expected the embedded client to refuse the LFS checkout")
+	}
+}
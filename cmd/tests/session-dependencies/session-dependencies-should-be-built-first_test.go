@@ -0,0 +1,114 @@
+package session_dependencies
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+// The session of a dependency should be built before the dependent one,
+// and its target should be exposed to the dependent session
+func Test_SessionDependenciesShouldBeBuiltFirst(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	// Setup the applications
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_SessionDependenciesShouldBeBuiltFirst_Frontend").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true).
+		WithDependency("Test_SessionDependenciesShouldBeBuiltFirst_Backend", "main"),
+		models.BuildApplicationConfiguration("Test_SessionDependenciesShouldBeBuiltFirst_Backend").
+			WithRemote("FakeRemote").
+			WithStartCommand("valid-command.exe").
+			WithStopCommand("valid-command.exe").
+			WithHealthcheckRetryInterval(1).
+			SetAsDefault(false),
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	frontendApplication := applications[0]
+	frontendApplicationChan := frontendApplication.GetEventBus().GetChan()
+	backendApplication := applications[1]
+	backendApplicationChan := backendApplication.GetEventBus().GetChan()
+
+	// Assert applications are being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(frontendApplicationChan, t)
+	events_assertions.AssertApplicationGetsInitializedAndFetched(backendApplicationChan, t)
+
+	// Request new session to be built
+	requestService := di.GetRequestService()
+	sessionBuildResult, err := requestService.NewSession(branch.Name, frontendApplication.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Get events channel
+	session := sessionBuildResult.Session
+	sessionChan := session.GetEventBus().GetChan()
+
+	// Assert session waits for its dependencies and then gets available
+	events_assertions.AssertSessionEvents(
+		sessionChan,
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypeDependenciesStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	// Assert the dependency session has been started
+	backendSessions := di.GetSessionStorage().GetAliveApplicationSession(backendApplication)
+	if len(backendSessions) != 1 {
+		t.Fatalf("expected 1 dependency session, got %d", len(backendSessions))
+	}
+	backendSession := backendSessions[0]
+	if backendSession.GetStatus() != models.SessionStatusStarted {
+		t.Errorf("expected dependency session to be started, got %s", backendSession.GetStatus())
+	}
+
+	// Assert the dependency target is available to the dependent session
+	dependencyName := frontendApplication.GetConfiguration().Dependencies[0].Name
+	session.RLock()
+	target := session.Variables["deps."+dependencyName+".target"]
+	session.RUnlock()
+	if target != backendSession.GetTarget() {
+		t.Errorf("expected dependency target to be %q, got %q", backendSession.GetTarget(), target)
+	}
+}
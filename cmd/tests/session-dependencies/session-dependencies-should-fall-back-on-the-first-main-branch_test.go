@@ -0,0 +1,100 @@
+package session_dependencies
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+// A dependency without its checkout and without a fallback should use
+// the branch flagged as "main" by the first of the branch rules
+func Test_SessionDependenciesShouldFallBackOnTheFirstMainBranch(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branches, two of them flagged as "main"
+	firstCommit := fetcher.NewCommit("First commit")
+	for _, name := range []string{"main", "master", "feature"} {
+		fetcher.AddCommitToBranch(firstCommit, fetcher.NewBranch(name))
+	}
+
+	frontend := models.BuildApplicationConfiguration("Test_SessionDependenciesShouldFallBackOnTheFirstMainBranch_Frontend").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true).
+		WithDependency("Test_SessionDependenciesShouldFallBackOnTheFirstMainBranch_Backend", "")
+	frontend.Dependencies[0].Checkout = "missing"
+
+	// Setup the applications
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, frontend,
+		models.BuildApplicationConfiguration("Test_SessionDependenciesShouldFallBackOnTheFirstMainBranch_Backend").
+			WithRemote("FakeRemote").
+			WithStartCommand("valid-command.exe").
+			WithStopCommand("valid-command.exe").
+			WithHealthcheckRetryInterval(1).
+			WithBranch(models.BuildBranchConfigurationMatch("^master$").SetMain(true)).
+			WithBranch(models.BuildBranchConfigurationMatch("^main$").SetMain(true)).
+			SetAsDefault(false),
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	frontendApplication := applications[0]
+	backendApplication := applications[1]
+
+	// Assert applications are being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(frontendApplication.GetEventBus().GetChan(), t)
+	events_assertions.AssertApplicationGetsInitializedAndFetched(backendApplication.GetEventBus().GetChan(), t)
+
+	// Request new session to be built
+	sessionBuildResult, err := di.GetRequestService().NewSession("feature", frontendApplication.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	session := sessionBuildResult.Session
+	events_assertions.AssertSessionEvents(
+		session.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypeDependenciesStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	// Assert the first main branch has been used
+	dependencyName := frontendApplication.GetConfiguration().Dependencies[0].Name
+	session.RLock()
+	checkout := session.Variables["deps."+dependencyName+".checkout"]
+	session.RUnlock()
+	if checkout != "master" {
+		t.Errorf("expected the dependency to use the master branch, got %q", checkout)
+	}
+}
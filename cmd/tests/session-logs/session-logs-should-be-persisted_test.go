@@ -0,0 +1,128 @@
+package session_logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+// Session logs should be persisted, paged through
+// and available after the session has been destroyed
+func Test_SessionLogsShouldBePersisted(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_SessionLogsShouldBePersisted").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true),
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	firstApplication := applications[0]
+	firstApplicationChan := firstApplication.GetEventBus().GetChan()
+
+	// Assert application is being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(firstApplicationChan, t)
+
+	// Request new session to be built
+	requestService := di.GetRequestService()
+	sessionBuildResult, err := requestService.NewSession(branch.Name, firstApplication.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	session := sessionBuildResult.Session
+
+	events_assertions.AssertSessionEvents(
+		session.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	queryService := di.GetQueryService()
+
+	// Assert logs are stored in order
+	logs := session.GetLogs()
+	if len(logs) < 3 {
+		t.Fatalf("expected session logs to be stored, got %d", len(logs))
+	}
+	for i := 1; i < len(logs); i++ {
+		if logs[i].When.Before(logs[i-1].When) {
+			t.Fatalf("expected logs to be ordered")
+		}
+	}
+
+	// Assert logs can be paged through
+	page := queryService.GetSessionLogs(session.UUID, models.LogChannelBuild, logs[0].UUID, 2)
+	if len(page) != 2 || page[0].UUID != logs[1].UUID || page[1].UUID != logs[2].UUID {
+		t.Errorf("unexpected page of logs %+v", page)
+	}
+
+	// Assert logs are still available after the session has been destroyed
+	if err := requestService.SessionDeletion(session.UUID); err != nil {
+		t.Fatal(err.Error())
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for session.GetStatus().IsAlive() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected session to be destroyed, got %s", session.GetStatus())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if postMortem := queryService.GetSessionLogs(session.UUID, models.LogChannelBuild, "", 0); len(postMortem) < len(logs) {
+		t.Errorf("expected at least %d logs after the session has been destroyed, got %d", len(logs), len(postMortem))
+	}
+
+	// Assert the logs appended once the session has been forgotten follow the stored ones
+	storedLogs := queryService.GetSessionLogs(session.UUID, models.LogChannelBuild, "", 0)
+	di.GetSessionLogStorage().Forget(session.UUID)
+	session.LogInfo("Appended after the session has been forgotten")
+	appended := -1
+	for i, log := range queryService.GetSessionLogs(session.UUID, models.LogChannelBuild, "", 0) {
+		if log.Message == "Appended after the session has been forgotten" {
+			appended = i
+		}
+	}
+	if appended < len(storedLogs) {
+		t.Errorf("expected the log appended to follow the %d stored logs, got it at %d", len(storedLogs), appended)
+	}
+}
@@ -0,0 +1,57 @@
+package session_logs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+// Session logs should be retrieved in the order they have been appended with,
+// even when they share the same time
+func Test_SessionLogsShouldKeepTheirOrder(t *testing.T) {
+
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: versioning_fixture.NewRepositoryFetcher(),
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+	}, models.BuildApplicationConfiguration("Test_SessionLogsShouldKeepTheirOrder").
+		WithRemote("FakeRemote").
+		SetAsDefault(true),
+	)
+
+	logStorage := di.GetSessionLogStorage()
+	sessionUUID := uuid.NewString()
+
+	// Append logs sharing the same time
+	when := time.Now()
+	appended := []models.Log{}
+	for i := 0; i < 100; i++ {
+		log := models.NewLog(fmt.Sprintf("Line %d", i), models.LogTypeStdout)
+		log.When = when
+		logStorage.Append(sessionUUID, models.LogChannelBuild, log)
+		appended = append(appended, log)
+	}
+
+	// Assert logs are retrieved in order
+	logs := logStorage.GetLogs(sessionUUID, models.LogChannelBuild, "", 0)
+	if len(logs) != len(appended) {
+		t.Fatalf("expected %d logs, got %d", len(appended), len(logs))
+	}
+	for i, log := range logs {
+		if log.UUID != appended[i].UUID {
+			t.Fatalf("expected log %d to be %q, got %q", i, appended[i].Message, log.Message)
+		}
+	}
+
+	// Assert logs following a given one are retrieved in order
+	page := logStorage.GetLogs(sessionUUID, models.LogChannelBuild, appended[49].UUID, 2)
+	if len(page) != 2 || page[0].UUID != appended[50].UUID || page[1].UUID != appended[51].UUID {
+		t.Errorf("unexpected page of logs %+v", page)
+	}
+}
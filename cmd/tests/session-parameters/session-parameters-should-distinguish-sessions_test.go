@@ -0,0 +1,154 @@
+package session_parameters
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/background/queues"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/services"
+)
+
+// Sessions of the same commit requested with different build parameters
+// should be distinct sessions, and parameters should be exposed as variables
+func Test_SessionParametersShouldDistinguishSessions(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_SessionParametersShouldDistinguishSessions").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true).
+		WithParameter("locale", models.ParameterTypeEnum, "en", "it").
+		WithParameter("seed_data", models.ParameterTypeBool),
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	firstApplication := applications[0]
+	firstApplicationChan := firstApplication.GetEventBus().GetChan()
+
+	// Assert application is being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(firstApplicationChan, t)
+
+	requestService := di.GetRequestService()
+	appName := firstApplication.GetConfiguration().Name
+
+	// Request a session with parameters
+	italianResult, err := requestService.NewSessionWithParameters(branch.Name, appName, false, map[string]string{
+		"locale":    "it",
+		"seed_data": "1",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	italianSession := italianResult.Session
+
+	events_assertions.AssertSessionEvents(
+		italianSession.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	// Assert parameters are normalized and exposed as variables
+	italianSession.RLock()
+	locale := italianSession.Variables["param.locale"]
+	seedData := italianSession.Variables["param.seed_data"]
+	italianSession.RUnlock()
+	if locale != "it" || seedData != "true" {
+		t.Errorf("expected param variables to be it/true, got %q/%q", locale, seedData)
+	}
+
+	// Assert the same commit with different parameters is a distinct session
+	defaultResult, err := requestService.NewSession(branch.Name, appName, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if defaultResult.Result == queues.SessionBuildResultAlreadyBuilt || defaultResult.Session == italianSession {
+		t.Errorf("expected a new session to be built for different parameters")
+	}
+	if defaultResult.Session.Parameters["locale"] != "en" || defaultResult.Session.Parameters["seed_data"] != "false" {
+		t.Errorf("expected default parameters, got %s", defaultResult.Session.Parameters)
+	}
+
+	// Assert the same commit with the same parameters is deduplicated
+	sameResult, err := requestService.NewSessionWithParameters(branch.Name, appName, false, map[string]string{
+		"locale":    "it",
+		"seed_data": "true",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if sameResult.Result != queues.SessionBuildResultAlreadyBuilt || sameResult.Session != italianSession {
+		t.Errorf("expected the session with the same parameters to be reused")
+	}
+
+	// Assert invalid parameters are rejected
+	_, err = requestService.NewSessionWithParameters(branch.Name, appName, false, map[string]string{
+		"locale": "fr",
+	})
+	if !errors.Is(err, services.ErrInvalidParameters) {
+		t.Errorf("expected invalid parameters error, got %v", err)
+	}
+
+	// Assert the parameters rejected by the build worker do not get the build announced
+	events, unsubscribe := firstApplication.GetEventBus().Subscribe()
+	defer unsubscribe()
+	rejectedResult := di.GetMediator().BuildSession.EnqueueInput(&queues.SessionBuildInput{
+		Checkout:    branch.Name,
+		Application: firstApplication,
+		Parameters:  map[string]string{"locale": "fr"},
+	})
+	if rejectedResult.Result != queues.SessionBuildResultFailed {
+		t.Errorf("expected the build with invalid parameters to fail, got %s", rejectedResult.Result)
+	}
+	timeout := time.After(500 * time.Millisecond)
+	for {
+		select {
+		case event := <-events:
+			if event.EventType == models.ApplicationEventTypeSessionBuild {
+				t.Fatalf("expected the rejected build not to be announced")
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package session_runtime_logs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+func containsLog(logs []models.Log, message string) bool {
+	for _, log := range logs {
+		if strings.Contains(log.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func waitForRuntimeLog(t *testing.T, session *models.Session, message string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !containsLog(session.GetChannelLogsAfter(models.LogChannelRuntime, "", 0), message) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected runtime log %q, got %+v", message, session.GetChannelLogsAfter(models.LogChannelRuntime, "", 0))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// The output of supervised commands and of the log sources
+// should be captured into the runtime logs of the session,
+// until the session gets destroyed
+func Test_SessionRuntimeLogsShouldBeCaptured(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_SessionRuntimeLogsShouldBeCaptured").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithSupervisedStartCommand("supervised-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithLogCommand("follow-command.exe {{uuid}}").
+		WithLogFile("logs/*.log").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true),
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	firstApplication := applications[0]
+	firstApplicationChan := firstApplication.GetEventBus().GetChan()
+
+	// Assert application is being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(firstApplicationChan, t)
+
+	// Request new session to be built
+	requestService := di.GetRequestService()
+	sessionBuildResult, err := requestService.NewSession(branch.Name, firstApplication.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	session := sessionBuildResult.Session
+
+	events_assertions.AssertSessionEvents(
+		session.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	// Assert the output of the supervised command and of the followed command
+	// goes into the runtime logs only
+	waitForRuntimeLog(t, session, "supervised-command.exe")
+	waitForRuntimeLog(t, session, "follow-command.exe "+session.UUID)
+	for _, log := range session.GetLogs() {
+		if log.Type == models.LogTypeStdout && strings.Contains(log.Message, "supervised-command.exe") {
+			t.Errorf("expected the supervised command output not to be in the build logs")
+		}
+	}
+
+	// Assert the log files are tailed
+	logsFolder := filepath.Join(session.Folder, "logs")
+	if err := os.MkdirAll(logsFolder, 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(session.Folder)
+	logFile := filepath.Join(logsFolder, "app.log")
+	if err := ioutil.WriteFile(logFile, []byte("first line\nsecond "), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	waitForRuntimeLog(t, session, "logs/app.log: first line")
+	appendToFile(t, logFile, "line\n")
+	waitForRuntimeLog(t, session, "logs/app.log: second line")
+
+	// Assert the runtime logs are available through the query service
+	queryService := di.GetQueryService()
+	if runtimeLogs := queryService.GetSessionLogs(session.UUID, models.LogChannelRuntime, "", 0); !containsLog(runtimeLogs, "second line") {
+		t.Errorf("expected the runtime logs to be retrievable, got %+v", runtimeLogs)
+	}
+
+	// Assert the tailing stops when the session gets destroyed
+	if err := requestService.SessionDeletion(session.UUID); err != nil {
+		t.Fatal(err.Error())
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for session.GetStatus().IsAlive() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected session to be destroyed, got %s", session.GetStatus())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// The session folder has been removed on cleanup
+	os.MkdirAll(logsFolder, 0755)
+	if err := ioutil.WriteFile(logFile, []byte("after destruction\n"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	time.Sleep(2 * time.Second)
+	if containsLog(session.GetChannelLogsAfter(models.LogChannelRuntime, "", 0), "after destruction") {
+		t.Errorf("expected the log files not to be tailed after the session has been destroyed")
+	}
+}
+
+func appendToFile(t *testing.T, path string, content string) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err.Error())
+	}
+}
@@ -0,0 +1,150 @@
+package session_stack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+// The sessions of the same checkout belonging to different stack instances,
+// and the ones outside of the stacks, should each get their own replacement
+// when a new commit gets detected
+func Test_SessionStackMembersShouldBeReplacedSeparately(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	// The backend runs both its sessions and their replacements
+	backend := models.BuildApplicationConfiguration("Test_SessionStackMembersShouldBeReplacedSeparately_Backend").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(false)
+	backend.MaxConcurrentSessions = 6
+
+	// Setup the applications and the stack
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+		Stacks: []*models.StackConfiguration{
+			{
+				Name:          "test-stack",
+				DefaultBranch: branch.Name,
+				Applications: []models.StackApplication{
+					{Application: "Test_SessionStackMembersShouldBeReplacedSeparately_Frontend", Entry: true},
+					{Application: "Test_SessionStackMembersShouldBeReplacedSeparately_Backend"},
+				},
+			},
+		},
+	}, models.BuildApplicationConfiguration("Test_SessionStackMembersShouldBeReplacedSeparately_Frontend").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true),
+		backend,
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	frontendApplication := applications[0]
+	backendApplication := applications[1]
+	backendApplicationChan := backendApplication.GetEventBus().GetChan()
+
+	// Assert applications are being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(frontendApplication.GetEventBus().GetChan(), t)
+	events_assertions.AssertApplicationGetsInitializedAndFetched(backendApplicationChan, t)
+
+	// Request two instances of the stack, both falling back on the main branch
+	requestService := di.GetRequestService()
+	for _, checkout := range []string{"feature/first", "feature/second"} {
+		result, err := requestService.NewStackSession("test-stack", checkout)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		events_assertions.AssertSessionEvents(
+			result.Session.GetEventBus().GetChan(),
+			[]models.SessionEventType{
+				models.SessionEventTypeBuildStarted,
+				models.SessionEventTypeDependenciesStarted,
+				models.SessionEventTypePreparingFolders,
+				models.SessionEventTypeCommandsExecutionStarted,
+				models.SessionEventTypeHealthcheckStarted,
+				models.SessionEventTypeHealthcheckSucceded,
+				models.SessionEventTypeSessionAvailable,
+			},
+			t,
+			10*time.Second,
+		)
+	}
+
+	// Request a session of the backend outside of the stacks
+	result, err := requestService.NewSession(branch.Name, backendApplication.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	events_assertions.AssertSessionGetsBuiltAndGetsAvailable(result.Session.GetEventBus().GetChan(), t)
+
+	if sessions := di.GetSessionStorage().GetAliveApplicationSession(backendApplication); len(sessions) != 3 {
+		t.Fatalf("expected 3 backend sessions, got %d", len(sessions))
+	}
+
+	// Creating the second commit and fetching the backend, watching started branches
+	secondCommit := fetcher.NewCommit("Second commit")
+	fetcher.AddCommitToBranch(secondCommit, branch)
+	di.GetMediator().ApplicationFetch.Enqueue(backendApplication, true)
+
+	// Assert each of the backend sessions gets its own replacement
+	replaced := func() (map[models.SessionStack]int, bool) {
+		replacements := make(map[models.SessionStack]int)
+		for _, session := range di.GetSessionStorage().GetAliveApplicationSession(backendApplication) {
+			if session.CommitID != secondCommit.Hash.String() || session.GetStatus() != models.SessionStatusStarted {
+				return nil, false
+			}
+			replacements[session.Stack]++
+		}
+		return replacements, len(replacements) == 3
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	replacements, ok := replaced()
+	for !ok {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the backend sessions to be replaced, got %v", replacements)
+		}
+		time.Sleep(100 * time.Millisecond)
+		replacements, ok = replaced()
+	}
+	for _, stack := range []models.SessionStack{
+		{},
+		{Name: "test-stack", Checkout: "feature/first"},
+		{Name: "test-stack", Checkout: "feature/second"},
+	} {
+		if replacements[stack] != 1 {
+			t.Errorf("expected 1 replacement for %+v, got %d", stack, replacements[stack])
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package session_stack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+// All the applications of a stack should be started for the requested checkout,
+// falling back to the default branch, and the stack should be reachable
+// through the session of its entry application
+func Test_SessionStackShouldBeLaunchedTogether(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	// Setup the applications and the stack
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+		Stacks: []*models.StackConfiguration{
+			{
+				Name:          "test-stack",
+				DefaultBranch: branch.Name,
+				Applications: []models.StackApplication{
+					{Application: "Test_SessionStackShouldBeLaunchedTogether_Frontend", Entry: true},
+					{Application: "Test_SessionStackShouldBeLaunchedTogether_Backend"},
+				},
+			},
+		},
+	}, models.BuildApplicationConfiguration("Test_SessionStackShouldBeLaunchedTogether_Frontend").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true),
+		models.BuildApplicationConfiguration("Test_SessionStackShouldBeLaunchedTogether_Backend").
+			WithRemote("FakeRemote").
+			WithStartCommand("valid-command.exe").
+			WithStopCommand("valid-command.exe").
+			WithHealthcheckRetryInterval(1).
+			SetAsDefault(false),
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	frontendApplication := applications[0]
+	frontendApplicationChan := frontendApplication.GetEventBus().GetChan()
+	backendApplication := applications[1]
+	backendApplicationChan := backendApplication.GetEventBus().GetChan()
+
+	// Assert applications are being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(frontendApplicationChan, t)
+	events_assertions.AssertApplicationGetsInitializedAndFetched(backendApplicationChan, t)
+
+	// Request the stack for a checkout which does not exist
+	requestService := di.GetRequestService()
+	sessionBuildResult, err := requestService.NewStackSession("test-stack", "feature/missing")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Get events channel
+	session := sessionBuildResult.Session
+	sessionChan := session.GetEventBus().GetChan()
+
+	// Assert the entry session waits for the other members and then gets available
+	events_assertions.AssertSessionEvents(
+		sessionChan,
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypeDependenciesStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	// Assert the backend session has been started on the fallback branch
+	backendSessions := di.GetSessionStorage().GetAliveApplicationSession(backendApplication)
+	if len(backendSessions) != 1 {
+		t.Fatalf("expected 1 backend session, got %d", len(backendSessions))
+	}
+	backendSession := backendSessions[0]
+	if backendSession.GetStatus() != models.SessionStatusStarted {
+		t.Errorf("expected backend session to be started, got %s", backendSession.GetStatus())
+	}
+	if backendSession.Checkout != branch.Name {
+		t.Errorf("expected backend session checkout to be %q, got %q", branch.Name, backendSession.Checkout)
+	}
+	if backendSession.Stack.Name != "test-stack" || backendSession.Stack.Checkout != "feature/missing" || backendSession.Stack.Entry {
+		t.Errorf("unexpected backend session stack %+v", backendSession.Stack)
+	}
+
+	// Assert the backend target is available to the entry session
+	session.RLock()
+	target := session.Variables["deps."+backendApplication.GetConfiguration().ID+".target"]
+	session.RUnlock()
+	if target != backendSession.GetTarget() {
+		t.Errorf("expected backend target to be %q, got %q", backendSession.GetTarget(), target)
+	}
+
+	// Assert the stack url leads to the entry session
+	stack, checkout, path, found, entrySession := di.GetQueryService().GetMatchingStackBySmartUrl("test-stack/feature/missing/some/path")
+	if !found {
+		t.Fatal("expected the stack url to be matched")
+	}
+	if stack != "test-stack" || checkout != "feature/missing" || path != "some/path" {
+		t.Errorf("unexpected stack url match: %q %q %q", stack, checkout, path)
+	}
+	if entrySession != session {
+		t.Errorf("expected the stack url to lead to the entry session")
+	}
+
+	// Assert the whole stack gets destroyed together
+	if err := requestService.SessionDeletion(session.UUID); err != nil {
+		t.Fatal(err.Error())
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for backendSession.GetStatus().IsAlive() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected backend session to be destroyed with the stack, got %s", backendSession.GetStatus())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
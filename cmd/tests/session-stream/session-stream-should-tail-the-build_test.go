@@ -0,0 +1,142 @@
+package session_stream
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+type streamEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// readStream reads the server-sent events until the given event is found
+func readStream(t *testing.T, url string, until func(streamEvent) bool) []streamEvent {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer res.Body.Close()
+	if contentType := res.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", contentType)
+	}
+
+	events := []streamEvent{}
+	current := streamEvent{}
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			current.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			current.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && current.event != "":
+			events = append(events, current)
+			if until(current) {
+				return events
+			}
+			current = streamEvent{}
+		}
+	}
+	t.Fatalf("stream closed before the expected event; got %+v", events)
+	return events
+}
+
+// The session stream should push logs and status transitions
+// as they happen, and should be resumable from a log UUID
+func Test_SessionStreamShouldTailTheBuild(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_SessionStreamShouldTailTheBuild").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true),
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	firstApplication := applications[0]
+	firstApplicationChan := firstApplication.GetEventBus().GetChan()
+
+	// Assert application is being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(firstApplicationChan, t)
+
+	server := httptest.NewServer(di.GetRestHandler().Router)
+	defer server.Close()
+
+	// Request new session to be built
+	requestService := di.GetRequestService()
+	sessionBuildResult, err := requestService.NewSession(branch.Name, firstApplication.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	session := sessionBuildResult.Session
+	streamURL := fmt.Sprintf("%s/_polo_/api/session/%s/stream", server.URL, session.UUID)
+
+	// Assert the stream follows the build until the session gets started
+	events := readStream(t, streamURL, func(e streamEvent) bool {
+		return e.event == "status" && strings.Contains(e.data, string(models.SessionStatusStarted))
+	})
+	logs := []streamEvent{}
+	for _, e := range events {
+		if e.event == "log" {
+			logs = append(logs, e)
+		}
+	}
+	if len(logs) < 2 {
+		t.Fatalf("expected logs to be streamed, got %+v", events)
+	}
+
+	// Assert the stream can be resumed from a log
+	resumed := readStream(t, streamURL+"?last_log="+logs[0].id, func(e streamEvent) bool {
+		return e.event == "log"
+	})
+	if first := resumed[len(resumed)-1]; first.id != logs[1].id {
+		t.Errorf("expected the stream to resume from log %s, got %s", logs[1].id, first.id)
+	}
+}
@@ -0,0 +1,121 @@
+package session_variants
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/background/queues"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/services"
+)
+
+// Different variants of the same commit should be distinct sessions,
+// reachable through their own smart url
+func Test_SessionVariantsShouldRunTheSameCommit(t *testing.T) {
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_SessionVariantsShouldRunTheSameCommit").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true),
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	firstApplication := applications[0]
+	firstApplicationChan := firstApplication.GetEventBus().GetChan()
+
+	// Assert application is being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(firstApplicationChan, t)
+
+	requestService := di.GetRequestService()
+	appName := firstApplication.GetConfiguration().Name
+
+	// Request the first variant
+	aliceResult, err := requestService.NewSessionVariant(branch.Name, appName, false, "alice", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	aliceSession := aliceResult.Session
+
+	events_assertions.AssertSessionEvents(
+		aliceSession.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	// Assert another variant of the same commit is a distinct session
+	bobResult, err := requestService.NewSessionVariant(branch.Name, appName, false, "bob", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if bobResult.Result == queues.SessionBuildResultAlreadyBuilt || bobResult.Session == aliceSession {
+		t.Errorf("expected a new session to be built for another variant")
+	}
+
+	// Assert the same variant is deduplicated
+	sameResult, err := requestService.NewSessionVariant(branch.Name, appName, false, "alice", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if sameResult.Result != queues.SessionBuildResultAlreadyBuilt || sameResult.Session != aliceSession {
+		t.Errorf("expected the session of the same variant to be reused")
+	}
+
+	// Assert the smart url selects the variant
+	checkout, _, variant, path, found, foundSession := di.GetQueryService().GetMatchingCheckoutBySmartUrl(branch.Name + "~alice/some/path")
+	if !found || checkout != branch.Name || variant != "alice" || path != "some/path" {
+		t.Errorf("unexpected smart url match: %q %q %q %v", checkout, variant, path, found)
+	}
+	if foundSession != aliceSession {
+		t.Errorf("expected the smart url to lead to the session of the variant")
+	}
+	if smartURL := aliceSession.ToOutput().SmartURL; smartURL != "/s/"+branch.Name+"~alice" {
+		t.Errorf("unexpected smart url %q", smartURL)
+	}
+
+	// Assert invalid variants are rejected
+	_, err = requestService.NewSessionVariant(branch.Name, appName, false, "alice/bob", nil)
+	if !errors.Is(err, services.ErrInvalidVariant) {
+		t.Errorf("expected invalid variant error, got %v", err)
+	}
+}
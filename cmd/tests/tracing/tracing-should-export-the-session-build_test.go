@@ -0,0 +1,184 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+}
+
+// collector receives the spans exported using OTLP/HTTP
+type collector struct {
+	sync.Mutex
+	spans []exportedSpan
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var payload struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []exportedSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	for _, resource := range payload.ResourceSpans {
+		for _, scope := range resource.ScopeSpans {
+			c.spans = append(c.spans, scope.Spans...)
+		}
+	}
+}
+
+// waitForSpan waits for a span matching the predicate to be exported
+func (c *collector) waitForSpan(t *testing.T, predicate func(exportedSpan) bool) exportedSpan {
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(100 * time.Millisecond) {
+		c.Lock()
+		for _, span := range c.spans {
+			if predicate(span) {
+				c.Unlock()
+				return span
+			}
+		}
+		c.Unlock()
+	}
+	t.Fatal("expected span has not been exported")
+	return exportedSpan{}
+}
+
+// The session build should be exported as a trace,
+// and the proxied requests should join the propagated traces
+func Test_TracingShouldExportTheSessionBuild(t *testing.T) {
+
+	collector := &collector{}
+	collectorServer := httptest.NewServer(collector)
+	defer collectorServer.Close()
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+		Tracing: models.TracingConfiguration{
+			Endpoint:    collectorServer.URL,
+			ServiceName: "polo",
+		},
+	}, models.BuildApplicationConfiguration("Test_TracingShouldExportTheSessionBuild").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		SetAsDefault(true),
+	)
+
+	// Get events channel
+	applications := di.GetApplications()
+	firstApplication := applications[0]
+	firstApplicationChan := firstApplication.GetEventBus().GetChan()
+
+	// Assert application is being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(firstApplicationChan, t)
+
+	// Request new session to be built
+	requestService := di.GetRequestService()
+	sessionBuildResult, err := requestService.NewSession(branch.Name, firstApplication.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	session := sessionBuildResult.Session
+
+	events_assertions.AssertSessionEvents(
+		session.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+	for session.GetStatus() != models.SessionStatusStarted {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	build := collector.waitForSpan(t, func(s exportedSpan) bool {
+		return s.Name == "session.build"
+	})
+	if build.ParentSpanID != "" {
+		t.Errorf("expected the session build to be the root span, got parent %s", build.ParentSpanID)
+	}
+	for _, name := range []string{"prepareFolders", "command", "healthcheck"} {
+		collector.waitForSpan(t, func(s exportedSpan) bool {
+			return s.Name == name && s.TraceID == build.TraceID && s.ParentSpanID == build.SpanID
+		})
+	}
+
+	server := httptest.NewServer(di.GetRestHandler().Router)
+	defer server.Close()
+
+	// Proxy a request to the session, propagating a trace context
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID := "00f067aa0ba902b7"
+	req, _ := http.NewRequest("GET", server.URL+"/", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	req.AddCookie(&http.Cookie{Name: "PoloSession", Value: session.UUID})
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	collector.waitForSpan(t, func(s exportedSpan) bool {
+		return s.TraceID == traceID && s.ParentSpanID == parentID && s.Kind == 2
+	})
+}
@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/models"
+)
+
+// The webhooks should be notified of the events published
+// as soon as the build of a session starts, for each attempt to build it
+func Test_WebhooksShouldNotifyTheFirstEventsOfTheBuild(t *testing.T) {
+
+	receiver := &receiver{attempts: make(map[string]int)}
+	receiverServer := httptest.NewServer(receiver)
+	defer receiverServer.Close()
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+	branch := fetcher.NewBranch("main")
+	fetcher.AddCommitToBranch(fetcher.NewCommit("First commit"), branch)
+
+	// Create command runner fixture
+	commandRunner := execution_fixture.NewCommandRunnerFixture()
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     commandRunner,
+		PortRetriever:     portRetriever,
+	}, models.BuildApplicationConfiguration("Test_WebhooksShouldNotifyTheFirstEventsOfTheBuild").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		WithStartupRetries(1).
+		WithWebhook(receiverServer.URL+"/build", "", "build_started", "preparing_folders").
+		SetAsDefault(true))
+
+	application := di.GetApplications()[0]
+	events_assertions.AssertApplicationGetsInitializedAndFetched(application.GetEventBus().GetChan(), t)
+
+	// The first attempt to build the session fails
+	commandRunner.FailNextNCommands(1)
+
+	sessionBuildResult, err := di.GetRequestService().NewSession(branch.Name, application.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	events_assertions.AssertSessionEvents(
+		sessionBuildResult.Session.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			// First attempt
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeCommandsExecutionFailed,
+			models.SessionEventTypeBuildGettingRetried,
+			// Second attempt
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	receiver.waitFor(t, "/build", "preparing_folders")
+	if count := receiver.count("/build", "build_started", 2); count != 2 {
+		t.Errorf("expected the webhook to be notified of build_started twice, got %d notifications", count)
+	}
+}
@@ -0,0 +1,204 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wufe/polo/internal/tests"
+	"github.com/wufe/polo/internal/tests/events_assertions"
+	"github.com/wufe/polo/internal/tests/execution_fixture"
+	"github.com/wufe/polo/internal/tests/net_fixture"
+	"github.com/wufe/polo/internal/tests/versioning_fixture"
+	"github.com/wufe/polo/pkg/background"
+	"github.com/wufe/polo/pkg/models"
+)
+
+type delivery struct {
+	path    string
+	header  http.Header
+	payload []byte
+}
+
+// receiver records the webhook deliveries,
+// failing the first one of each path to test the retries
+type receiver struct {
+	sync.Mutex
+	deliveries []delivery
+	attempts   map[string]int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	r.Lock()
+	defer r.Unlock()
+	r.attempts[req.URL.Path]++
+	if r.attempts[req.URL.Path] == 1 {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	r.deliveries = append(r.deliveries, delivery{path: req.URL.Path, header: req.Header, payload: body})
+}
+
+func (r *receiver) waitFor(t *testing.T, path string, event string) delivery {
+	for start := time.Now(); time.Since(start) < 15*time.Second; time.Sleep(100 * time.Millisecond) {
+		r.Lock()
+		for _, d := range r.deliveries {
+			if d.path == path && d.header.Get("X-Polo-Event") == event {
+				r.Unlock()
+				return d
+			}
+		}
+		r.Unlock()
+	}
+	t.Fatalf("expected webhook %s to be notified of %s", path, event)
+	return delivery{}
+}
+
+// count waits for the webhook to be notified of an event the expected number of times,
+// returning the number of notifications received
+func (r *receiver) count(path string, event string, expected int) int {
+	count := 0
+	for start := time.Now(); time.Since(start) < 15*time.Second; time.Sleep(100 * time.Millisecond) {
+		r.Lock()
+		count = 0
+		for _, d := range r.deliveries {
+			if d.path == path && d.header.Get("X-Polo-Event") == event {
+				count++
+			}
+		}
+		r.Unlock()
+		if count >= expected {
+			break
+		}
+	}
+	return count
+}
+
+// The webhooks should be notified of the selected events,
+// with signed payloads, retrying the failed deliveries
+func Test_WebhooksShouldNotifyTheSessionEvents(t *testing.T) {
+
+	receiver := &receiver{attempts: make(map[string]int)}
+	receiverServer := httptest.NewServer(receiver)
+	defer receiverServer.Close()
+
+	// Create the HTTP server and start it
+	httpServer := net_fixture.NewHTTPServerFixture()
+	port, tearDown := httpServer.Setup()
+	defer tearDown()
+
+	// Create port retriever to set up HTTP server port
+	portRetriever := net_fixture.NewPortRetrieverFixture()
+	portRetriever.SetFreePort(port)
+
+	fetcher := versioning_fixture.NewRepositoryFetcher()
+
+	// Creating the branch
+	branch := fetcher.NewBranch("main")
+
+	// Creating the first commit
+	firstCommit := fetcher.NewCommit("First commit")
+	fetcher.AddCommitToBranch(firstCommit, branch)
+
+	configuration := models.BuildApplicationConfiguration("Test_WebhooksShouldNotifyTheSessionEvents").
+		WithRemote("FakeRemote").
+		WithStartCommand("valid-command.exe").
+		WithStopCommand("valid-command.exe").
+		WithHealthcheckRetryInterval(1).
+		WithWebhook(receiverServer.URL+"/json", "secret", "session_available", "session_build_succeeded").
+		WithWebhook(receiverServer.URL+"/slack", "", "session_available").
+		SetAsDefault(true)
+	configuration.Webhooks[1].Preset = models.WebhookPresetSlack
+
+	// Setup the application
+	di := tests.Fixture(&tests.InjectableServices{
+		RepositoryFetcher: fetcher,
+		GitClient:         versioning_fixture.NewGitClient(),
+		CommandRunner:     execution_fixture.NewCommandRunnerFixture(),
+		PortRetriever:     portRetriever,
+	}, configuration)
+
+	// Get events channel
+	applications := di.GetApplications()
+	firstApplication := applications[0]
+	firstApplicationChan := firstApplication.GetEventBus().GetChan()
+
+	// Assert application is being loaded
+	events_assertions.AssertApplicationGetsInitializedAndFetched(firstApplicationChan, t)
+
+	// Request new session to be built
+	requestService := di.GetRequestService()
+	sessionBuildResult, err := requestService.NewSession(branch.Name, firstApplication.GetConfiguration().Name, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	session := sessionBuildResult.Session
+
+	events_assertions.AssertSessionEvents(
+		session.GetEventBus().GetChan(),
+		[]models.SessionEventType{
+			models.SessionEventTypeBuildStarted,
+			models.SessionEventTypePreparingFolders,
+			models.SessionEventTypeCommandsExecutionStarted,
+			models.SessionEventTypeHealthcheckStarted,
+			models.SessionEventTypeHealthcheckSucceded,
+			models.SessionEventTypeSessionAvailable,
+		},
+		t,
+		10*time.Second,
+	)
+
+	// Default payload, signed
+	available := receiver.waitFor(t, "/json", "session_available")
+	if signature := available.header.Get("X-Polo-Signature"); signature != background.SignWebhookPayload("secret", available.payload) {
+		t.Errorf("expected a valid signature, got %q", signature)
+	}
+	var payload struct {
+		Event       string `json:"event"`
+		Application string `json:"application"`
+		Session     struct {
+			UUID     string `json:"uuid"`
+			Checkout string `json:"checkout"`
+			URL      string `json:"url"`
+		} `json:"session"`
+	}
+	if err := json.Unmarshal(available.payload, &payload); err != nil {
+		t.Fatal(err.Error())
+	}
+	if payload.Event != "session_available" || payload.Session.UUID != session.UUID || payload.Session.Checkout != "main" {
+		t.Errorf("unexpected payload %s", available.payload)
+	}
+	if !strings.HasSuffix(payload.Session.URL, "/s/main/") {
+		t.Errorf("expected the smart URL of the session, got %q", payload.Session.URL)
+	}
+	receiver.waitFor(t, "/json", "session_build_succeeded")
+
+	// Slack preset, not signed
+	slack := receiver.waitFor(t, "/slack", "session_available")
+	if slack.header.Get("X-Polo-Signature") != "" {
+		t.Errorf("expected the payload not to be signed")
+	}
+	var message struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(slack.payload, &message); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.HasPrefix(message.Text, "main of Test_WebhooksShouldNotifyTheSessionEvents is ready at ") {
+		t.Errorf("unexpected message %q", message.Text)
+	}
+
+	receiver.Lock()
+	defer receiver.Unlock()
+	for _, d := range receiver.deliveries {
+		if d.header.Get("X-Polo-Event") != "session_available" && d.header.Get("X-Polo-Event") != "session_build_succeeded" {
+			t.Errorf("unexpected notification of %s", d.header.Get("X-Polo-Event"))
+		}
+	}
+}
@@ -5,6 +5,7 @@ go 1.15
 require (
 	github.com/asaskevich/EventBus v0.0.0-20200907212545-49d423059eef
 	github.com/dgraph-io/badger/v3 v3.2011.1
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/go-git/go-git/v5 v5.2.0
 	github.com/google/uuid v1.2.0
 	github.com/iris-contrib/go.uuid v2.0.0+incompatible
@@ -20,6 +21,7 @@ require (
 	github.com/sasha-s/go-deadlock v0.2.0
 	github.com/sirupsen/logrus v1.7.0
 	go.uber.org/dig v1.10.0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	golang.org/x/net v0.0.0-20210119194325-5f4716e94777 // indirect
 	golang.org/x/sys v0.0.0-20210426230700-d19ff857e887 // indirect
 	gopkg.in/yaml.v2 v2.4.0
@@ -15,9 +15,11 @@ import (
 	"github.com/wufe/polo/pkg/http/rest"
 	"github.com/wufe/polo/pkg/http/routing"
 	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/metrics"
 	"github.com/wufe/polo/pkg/models"
 	"github.com/wufe/polo/pkg/services"
 	"github.com/wufe/polo/pkg/storage"
+	"github.com/wufe/polo/pkg/tracing"
 	"github.com/wufe/polo/pkg/utils"
 	"github.com/wufe/polo/pkg/versioning"
 	"go.uber.org/dig"
@@ -66,7 +68,9 @@ func (d *DI) AddMutexBuilder() {
 }
 
 func (d *DI) AddSessionBuilder() {
-	if err := d.container.Provide(models.NewSessionBuilder); err != nil {
+	if err := d.container.Provide(func(mutexBuilder utils.MutexBuilder, logger logging.Logger, logStorage *storage.SessionLog, metrics *metrics.Metrics) *models.SessionBuilder {
+		return models.NewSessionBuilder(mutexBuilder, logger, logStorage, metrics)
+	}); err != nil {
 		log.Panic(err)
 	}
 }
@@ -111,6 +115,18 @@ func (d *DI) AddConfiguration(applicationConfigurations ...*models.ApplicationCo
 				MaxConcurrentSessions: 999,
 			},
 			ApplicationConfigurations: applicationConfigurations,
+			Stacks:                    []*models.StackConfiguration{},
+		}
+
+		if d.injectable != nil {
+			for _, stack := range d.injectable.Stacks {
+				stack, err := models.NewStackConfiguration(stack)
+				if err != nil {
+					panic(err)
+				}
+				configuration.Stacks = append(configuration.Stacks, stack)
+			}
+			configuration.Global.Tracing = d.injectable.Tracing
 		}
 
 		applications := []*models.Application{}
@@ -163,12 +179,48 @@ func (d *DI) AddApplicationStorage() {
 	}
 }
 
+func (d *DI) AddSessionLogStorage() {
+	if err := d.container.Provide(func(db storage.Database, configuration *models.RootConfiguration, mutexBuilder utils.MutexBuilder, logger logging.Logger) *storage.SessionLog {
+		return storage.NewSessionLog(db, &configuration.Global, mutexBuilder, logger)
+	}); err != nil {
+		log.Panic(err)
+	}
+}
+
 func (d *DI) AddSessionStorage() {
 	if err := d.container.Provide(storage.NewSession); err != nil {
 		log.Panic(err)
 	}
 }
 
+func (d *DI) AddCommitIndexStorage() {
+	if err := d.container.Provide(storage.NewCommitIndex); err != nil {
+		log.Panic(err)
+	}
+}
+
+// Metrics
+
+func (d *DI) AddMetrics() {
+	if err := d.container.Provide(func(sesStorage *storage.Session) *metrics.Metrics {
+		m := metrics.NewMetrics()
+		m.SetSessionsSource(sesStorage)
+		return m
+	}); err != nil {
+		log.Panic(err)
+	}
+}
+
+// Tracing
+
+func (d *DI) AddTracer() {
+	if err := d.container.Provide(func(configuration *models.RootConfiguration, logger logging.Logger) *tracing.Tracer {
+		return tracing.NewTracer(&configuration.Global, logger)
+	}); err != nil {
+		log.Panic(err)
+	}
+}
+
 // Command
 
 func (d *DI) AddCommandRunner() {
@@ -185,64 +237,64 @@ func (d *DI) AddCommandRunner() {
 // Mediator
 
 func (d *DI) AddSessionBuildQueue() {
-	if err := d.container.Provide(func() queues.SessionBuildQueue {
-		return queues.NewSessionBuild()
+	if err := d.container.Provide(func(m *metrics.Metrics) queues.SessionBuildQueue {
+		return queues.NewSessionBuild(m)
 	}); err != nil {
 		log.Panic(err)
 	}
 }
 
 func (d *DI) AddSessionDestroyQueue() {
-	if err := d.container.Provide(func() queues.SessionDestroyQueue {
-		return queues.NewSessionDestroy()
+	if err := d.container.Provide(func(m *metrics.Metrics) queues.SessionDestroyQueue {
+		return queues.NewSessionDestroy(m)
 	}); err != nil {
 		log.Panic(err)
 	}
 }
 
 func (d *DI) AddSessionFilesystemQueue() {
-	if err := d.container.Provide(func() queues.SessionFilesystemQueue {
-		return queues.NewSessionFilesystem()
+	if err := d.container.Provide(func(m *metrics.Metrics) queues.SessionFilesystemQueue {
+		return queues.NewSessionFilesystem(m)
 	}); err != nil {
 		log.Panic(err)
 	}
 }
 
 func (d *DI) AddSessionCleanupQueue() {
-	if err := d.container.Provide(func() queues.SessionCleanupQueue {
-		return queues.NewSessionCleanup()
+	if err := d.container.Provide(func(m *metrics.Metrics) queues.SessionCleanupQueue {
+		return queues.NewSessionCleanup(m)
 	}); err != nil {
 		log.Panic(err)
 	}
 }
 
 func (d *DI) AddSessionStartQueue() {
-	if err := d.container.Provide(func() queues.SessionStartQueue {
-		return queues.NewSessionStart()
+	if err := d.container.Provide(func(m *metrics.Metrics) queues.SessionStartQueue {
+		return queues.NewSessionStart(m)
 	}); err != nil {
 		log.Panic(err)
 	}
 }
 
 func (d *DI) AddSessionHealthCheckQueue() {
-	if err := d.container.Provide(func() queues.SessionHealthcheckQueue {
-		return queues.NewSessionHealthCheck()
+	if err := d.container.Provide(func(m *metrics.Metrics) queues.SessionHealthcheckQueue {
+		return queues.NewSessionHealthCheck(m)
 	}); err != nil {
 		log.Panic(err)
 	}
 }
 
 func (d *DI) AddApplicationInitQueue() {
-	if err := d.container.Provide(func() queues.ApplicationInitQueue {
-		return queues.NewApplicationInit()
+	if err := d.container.Provide(func(m *metrics.Metrics) queues.ApplicationInitQueue {
+		return queues.NewApplicationInit(m)
 	}); err != nil {
 		log.Panic(err)
 	}
 }
 
 func (d *DI) AddApplicationFetchQueue() {
-	if err := d.container.Provide(func() queues.ApplicationFetchQueue {
-		return queues.NewApplicationFetch()
+	if err := d.container.Provide(func(m *metrics.Metrics) queues.ApplicationFetchQueue {
+		return queues.NewApplicationFetch(m)
 	}); err != nil {
 		log.Panic(err)
 	}
@@ -282,6 +334,12 @@ func (d *DI) AddSessionCommandExecution() {
 	}
 }
 
+func (d *DI) AddSessionRuntimeLogs() {
+	if err := d.container.Provide(background.NewSessionRuntimeLogs); err != nil {
+		log.Panic(err)
+	}
+}
+
 // Workers
 
 func (d *DI) AddSessionBuildWorker() {
@@ -293,9 +351,12 @@ func (d *DI) AddSessionBuildWorker() {
 		sessionBuilder *models.SessionBuilder,
 		logger logging.Logger,
 		sessionCommandExecution background.SessionCommandExecution,
+		sessionRuntimeLogs background.SessionRuntimeLogs,
 		portRetriever net.PortRetriever,
+		tracer *tracing.Tracer,
+		webhooks *background.WebhookWorker,
 	) *background.SessionBuildWorker {
-		return background.NewSessionBuildWorker(&configuration.Global, appStorage, sesStorage, mediator, sessionBuilder, logger, sessionCommandExecution, portRetriever)
+		return background.NewSessionBuildWorker(configuration, appStorage, sesStorage, mediator, sessionBuilder, logger, sessionCommandExecution, sessionRuntimeLogs, portRetriever, tracer, webhooks)
 	}); err != nil {
 		log.Panic(err)
 	}
@@ -330,8 +391,8 @@ func (d *DI) AddSessionDestroyWorker() {
 }
 
 func (d *DI) AddSessionHealthcheckWorker() {
-	if err := d.container.Provide(func(mediator *background.Mediator, logger logging.Logger) *background.SessionHealthcheckWorker {
-		return background.NewSessionHealthcheckWorker(mediator, logger)
+	if err := d.container.Provide(func(mediator *background.Mediator, metrics *metrics.Metrics, tracer *tracing.Tracer, logger logging.Logger) *background.SessionHealthcheckWorker {
+		return background.NewSessionHealthcheckWorker(mediator, metrics, tracer, logger)
 	}); err != nil {
 		log.Panic(err)
 	}
@@ -346,8 +407,24 @@ func (d *DI) AddApplicationInitWorker() {
 }
 
 func (d *DI) AddApplicationFetchWorker() {
-	if err := d.container.Provide(func(sesStorage *storage.Session, fetcher versioning.RepositoryFetcher, mediator *background.Mediator, logger logging.Logger) *background.ApplicationFetchWorker {
-		return background.NewApplicationFetchWorker(sesStorage, fetcher, mediator, logger)
+	if err := d.container.Provide(func(sesStorage *storage.Session, commitIndex *storage.CommitIndex, fetcher versioning.RepositoryFetcher, mediator *background.Mediator, metrics *metrics.Metrics, logger logging.Logger) *background.ApplicationFetchWorker {
+		return background.NewApplicationFetchWorker(sesStorage, commitIndex, fetcher, mediator, metrics, logger)
+	}); err != nil {
+		log.Panic(err)
+	}
+}
+
+func (d *DI) AddWebhookWorker() {
+	if err := d.container.Provide(func(configuration *models.RootConfiguration, applications []*models.Application, mutexBuilder utils.MutexBuilder, logger logging.Logger) *background.WebhookWorker {
+		return background.NewWebhookWorker(configuration, applications, mutexBuilder, logger)
+	}); err != nil {
+		log.Panic(err)
+	}
+}
+
+func (d *DI) AddCommitStatusWorker() {
+	if err := d.container.Provide(func(configuration *models.RootConfiguration, applications []*models.Application, logger logging.Logger) *background.CommitStatusWorker {
+		return background.NewCommitStatusWorker(configuration, applications, logger)
 	}); err != nil {
 		log.Panic(err)
 	}
@@ -364,21 +441,43 @@ func (d *DI) AddStaticService() {
 }
 
 func (d *DI) AddQueryService() {
-	if err := d.container.Provide(func(environment utils.Environment, sesStorage *storage.Session, appStorage *storage.Application, logger logging.Logger) *services.QueryService {
-		return services.NewQueryService(environment, sesStorage, appStorage, logger)
+	if err := d.container.Provide(func(environment utils.Environment, configuration *models.RootConfiguration, sesStorage *storage.Session, appStorage *storage.Application, logStorage *storage.SessionLog, logger logging.Logger) *services.QueryService {
+		return services.NewQueryService(environment, configuration, sesStorage, appStorage, logStorage, logger)
 	}); err != nil {
 		log.Panic(err)
 	}
 }
 
 func (d *DI) AddRequestService() {
-	if err := d.container.Provide(func(environment utils.Environment, sesStorage *storage.Session, appStorage *storage.Application, mediator *background.Mediator) *services.RequestService {
-		return services.NewRequestService(environment, sesStorage, appStorage, mediator)
+	if err := d.container.Provide(func(environment utils.Environment, configuration *models.RootConfiguration, sesStorage *storage.Session, appStorage *storage.Application, mediator *background.Mediator) *services.RequestService {
+		return services.NewRequestService(environment, configuration, sesStorage, appStorage, mediator)
 	}); err != nil {
 		log.Panic(err)
 	}
 }
 
+func (d *DI) AddConfigurationReloader() {
+	if err := d.container.Provide(services.NewConfigurationReloader); err != nil {
+		log.Panic(err)
+	}
+}
+
+func (d *DI) GetConfigurationReloader() *services.ConfigurationReloader {
+	var reloader *services.ConfigurationReloader
+	if err := d.container.Invoke(func(r *services.ConfigurationReloader) {
+		reloader = r
+	}); err != nil {
+		log.Panic(err)
+	}
+	return reloader
+}
+
+func (d *DI) AddConfigurationService() {
+	if err := d.container.Provide(services.NewConfigurationService); err != nil {
+		log.Panic(err)
+	}
+}
+
 func (d *DI) AddAliasingService() {
 	if err := d.container.Provide(services.NewAliasingService); err != nil {
 		log.Panic(err)
@@ -415,9 +514,11 @@ func (d *DI) AddHTTPRouter() {
 		queryService *services.QueryService,
 		requestService *services.RequestService,
 		staticService *services.StaticService,
+		metrics *metrics.Metrics,
+		tracer *tracing.Tracer,
 		logger logging.Logger,
 	) *routing.Handler {
-		return routing.NewHandler(environment, proxy, sesStorage, appStorage, queryService, requestService, staticService, logger)
+		return routing.NewHandler(environment, proxy, sesStorage, appStorage, queryService, requestService, staticService, metrics, tracer, logger)
 	}); err != nil {
 		log.Panic(err)
 	}
@@ -431,9 +532,11 @@ func (d *DI) AddHTTPRestHandler() {
 		proxy *proxy.Handler,
 		queryService *services.QueryService,
 		requestService *services.RequestService,
+		configurationService *services.ConfigurationService,
+		metrics *metrics.Metrics,
 		logger logging.Logger,
 	) *rest.Handler {
-		return rest.NewHandler(environment, staticService, routing, proxy, queryService, requestService, logger)
+		return rest.NewHandler(environment, staticService, routing, proxy, queryService, requestService, configurationService, metrics, logger)
 	}); err != nil {
 		log.Panic(err)
 	}
@@ -491,6 +594,26 @@ func (d *DI) GetRequestService() *services.RequestService {
 	return service
 }
 
+func (d *DI) GetQueryService() *services.QueryService {
+	var service *services.QueryService
+	if err := d.container.Invoke(func(s *services.QueryService) {
+		service = s
+	}); err != nil {
+		log.Panic(err)
+	}
+	return service
+}
+
+func (d *DI) GetRestHandler() *rest.Handler {
+	var handler *rest.Handler
+	if err := d.container.Invoke(func(h *rest.Handler) {
+		handler = h
+	}); err != nil {
+		log.Panic(err)
+	}
+	return handler
+}
+
 func (d *DI) GetEnvironment() utils.Environment {
 	var environment utils.Environment
 	if err := d.container.Invoke(func(e utils.Environment) {
@@ -511,9 +634,31 @@ func (d *DI) GetSessionStorage() *storage.Session {
 	return sessionStorage
 }
 
+func (d *DI) GetSessionLogStorage() *storage.SessionLog {
+	var sessionLogStorage *storage.SessionLog
+	if err := d.container.Invoke(func(s *storage.SessionLog) {
+		sessionLogStorage = s
+	}); err != nil {
+		log.Panic(err)
+	}
+	return sessionLogStorage
+}
+
+func (d *DI) GetCommitIndexStorage() *storage.CommitIndex {
+	var commitIndex *storage.CommitIndex
+	if err := d.container.Invoke(func(c *storage.CommitIndex) {
+		commitIndex = c
+	}); err != nil {
+		log.Panic(err)
+	}
+	return commitIndex
+}
+
 type InjectableServices struct {
 	RepositoryFetcher versioning.RepositoryFetcher
 	GitClient         versioning.GitClient
 	CommandRunner     execution.CommandRunner
 	PortRetriever     net.PortRetriever
+	Stacks            []*models.StackConfiguration
+	Tracing           models.TracingConfiguration
 }
@@ -37,6 +37,16 @@ func Fixture(injectable *InjectableServices, applicationConfigurations ...*model
 	container.AddDatabase()
 	container.AddApplicationStorage()
 	container.AddSessionStorage()
+	container.AddSessionLogStorage()
+	container.AddCommitIndexStorage()
+
+	// Metrics
+
+	container.AddMetrics()
+
+	// Tracing
+
+	container.AddTracer()
 
 	// Command
 
@@ -57,6 +67,7 @@ func Fixture(injectable *InjectableServices, applicationConfigurations ...*model
 	// Workers command execution
 
 	container.AddSessionCommandExecution()
+	container.AddSessionRuntimeLogs()
 
 	// Workers
 
@@ -68,6 +79,8 @@ func Fixture(injectable *InjectableServices, applicationConfigurations ...*model
 	container.AddSessionHealthcheckWorker()
 	container.AddApplicationInitWorker()
 	container.AddApplicationFetchWorker()
+	container.AddWebhookWorker()
+	container.AddCommitStatusWorker()
 
 	// Services
 
@@ -75,6 +88,8 @@ func Fixture(injectable *InjectableServices, applicationConfigurations ...*model
 	container.AddQueryService()
 	container.AddRequestService()
 	container.AddAliasingService()
+	container.AddConfigurationReloader()
+	container.AddConfigurationService()
 
 	// HTTP
 
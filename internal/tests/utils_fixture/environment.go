@@ -35,3 +35,7 @@ func (e *testEnvironmentImpl) DevServerURL() string {
 func (e *testEnvironmentImpl) GetExecutableFolder() string {
 	return os.Getenv("GO_CWD")
 }
+
+func (e *testEnvironmentImpl) GetConfigurationPaths() []string {
+	return utils.ConfigurationPathsFromEnvironment()
+}
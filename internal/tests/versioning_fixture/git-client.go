@@ -1,6 +1,7 @@
 package versioning_fixture
 
 import (
+	"github.com/wufe/polo/pkg/models"
 	"github.com/wufe/polo/pkg/versioning"
 )
 
@@ -20,7 +21,32 @@ func (c *FixtureGitClient) FetchAll(repoFolder string) error {
 	return nil
 }
 
+func (c *FixtureGitClient) FetchRef(repoFolder string, ref string) error {
+	// NOOP
+	return nil
+}
+
 func (c *FixtureGitClient) HardReset(repoFolder string, commit string) error {
 	// NOOP
 	return nil
 }
+
+func (c *FixtureGitClient) UpdateSubmodules(repoFolder string) error {
+	// NOOP
+	return nil
+}
+
+func (c *FixtureGitClient) CheckoutLFS(repoFolder string) error {
+	// NOOP
+	return nil
+}
+
+func (c *FixtureGitClient) WithAuth(auth models.GitAuth) versioning.GitClient {
+	// NOOP
+	return c
+}
+
+func (c *FixtureGitClient) WithCheckout(checkout models.CheckoutConfiguration) versioning.GitClient {
+	// NOOP
+	return c
+}
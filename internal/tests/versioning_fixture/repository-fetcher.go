@@ -1,6 +1,8 @@
 package versioning_fixture
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-git/go-git/v5/plumbing"
@@ -11,8 +13,11 @@ import (
 )
 
 type FixtureRepositoryFetcher struct {
-	result *versioning.FetchResult
-	author object.Signature
+	sync.Mutex
+	result      *versioning.FetchResult
+	author      object.Signature
+	fetchedRefs [][]string
+	errors      []*versioning.FetcherError
 }
 
 func NewRepositoryFetcher() *FixtureRepositoryFetcher {
@@ -28,14 +33,34 @@ func NewRepositoryFetcher() *FixtureRepositoryFetcher {
 			HashToObjectsMap: make(map[string]*models.RemoteObject),
 			BranchesMap:      make(map[string]*models.Branch),
 			TagsMap:          make(map[string]*models.Tag),
+			PullRequestsMap:  make(map[string]*models.PullRequest),
 			Commits:          []string{},
 			CommitMap:        make(map[string]*object.Commit),
 		},
 	}
 }
 
-func (f *FixtureRepositoryFetcher) Fetch(baseFolder string) (*versioning.FetchResult, []*versioning.FetcherError) {
-	return f.result, []*versioning.FetcherError{}
+func (f *FixtureRepositoryFetcher) Fetch(baseFolder string, options versioning.FetchOptions, refs ...string) (*versioning.FetchResult, []*versioning.FetcherError) {
+	f.Lock()
+	f.fetchedRefs = append(f.fetchedRefs, refs)
+	errors := append([]*versioning.FetcherError{}, f.errors...)
+	f.Unlock()
+	return f.result, errors
+}
+
+// SetFetchErrors sets the errors returned by the next fetches, along with the objects
+func (f *FixtureRepositoryFetcher) SetFetchErrors(errors ...*versioning.FetcherError) {
+	f.Lock()
+	defer f.Unlock()
+	f.errors = errors
+}
+
+// GetFetchedRefs retrieves the refs requested by each fetch;
+// an empty list stands for a fetch of all the refs
+func (f *FixtureRepositoryFetcher) GetFetchedRefs() [][]string {
+	f.Lock()
+	defer f.Unlock()
+	return append([][]string{}, f.fetchedRefs...)
 }
 
 func (f *FixtureRepositoryFetcher) NewCommit(message string) *object.Commit {
@@ -89,5 +114,46 @@ func (f *FixtureRepositoryFetcher) AddCommitToBranch(commit *object.Commit, bran
 	if !foundBranch {
 		f.result.HashToObjectsMap[hash].Branches = append(f.result.HashToObjectsMap[hash].Branches, branch.Name)
 	}
-	f.result.BranchesMap[hash] = branch
+	f.result.BranchesMap[branch.Name] = branch
+}
+
+// OpenPullRequest points the pull request to the commit, as if it got opened or updated
+func (f *FixtureRepositoryFetcher) OpenPullRequest(number int, commit *object.Commit) {
+	hash := commit.Hash.String()
+	checkout := models.PullRequestCheckout(number)
+	ref := fmt.Sprintf("refs/pull/%d/head", number)
+	f.Lock()
+	defer f.Unlock()
+	f.AddCommit(commit)
+	f.result.ObjectsToHashMap[checkout] = hash
+	f.result.ObjectsToHashMap[ref] = hash
+	if _, exists := f.result.HashToObjectsMap[hash]; !exists {
+		f.result.HashToObjectsMap[hash] = &models.RemoteObject{
+			Branches:     []string{},
+			Tags:         []string{},
+			PullRequests: []string{},
+		}
+	}
+	f.result.HashToObjectsMap[hash].PullRequests = append(f.result.HashToObjectsMap[hash].PullRequests, checkout)
+	f.result.PullRequestsMap[checkout] = &models.PullRequest{
+		CheckoutObject: models.CheckoutObject{
+			Name:    checkout,
+			Hash:    hash,
+			Author:  f.author.Name,
+			Date:    time.Now(),
+			Message: commit.Message,
+		},
+		Number: number,
+		Ref:    ref,
+	}
+}
+
+// ClosePullRequest removes the ref of the pull request, as if it got closed
+func (f *FixtureRepositoryFetcher) ClosePullRequest(number int) {
+	checkout := models.PullRequestCheckout(number)
+	f.Lock()
+	defer f.Unlock()
+	delete(f.result.ObjectsToHashMap, checkout)
+	delete(f.result.ObjectsToHashMap, fmt.Sprintf("refs/pull/%d/head", number))
+	delete(f.result.PullRequestsMap, checkout)
 }
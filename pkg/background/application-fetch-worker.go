@@ -1,7 +1,12 @@
 package background
 
 import (
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/metrics"
 	"github.com/wufe/polo/pkg/models"
 	"github.com/wufe/polo/pkg/storage"
 	"github.com/wufe/polo/pkg/versioning"
@@ -9,16 +14,20 @@ import (
 
 type ApplicationFetchWorker struct {
 	sessionStorage    *storage.Session
+	commitIndex       *storage.CommitIndex
 	mediator          *Mediator
 	repositoryFetcher versioning.RepositoryFetcher
+	metrics           *metrics.Metrics
 	log               logging.Logger
 }
 
-func NewApplicationFetchWorker(sessionStorage *storage.Session, repositoryFetcher versioning.RepositoryFetcher, mediator *Mediator, log logging.Logger) *ApplicationFetchWorker {
+func NewApplicationFetchWorker(sessionStorage *storage.Session, commitIndex *storage.CommitIndex, repositoryFetcher versioning.RepositoryFetcher, mediator *Mediator, metrics *metrics.Metrics, log logging.Logger) *ApplicationFetchWorker {
 	worker := &ApplicationFetchWorker{
 		sessionStorage:    sessionStorage,
+		commitIndex:       commitIndex,
 		repositoryFetcher: repositoryFetcher,
 		mediator:          mediator,
+		metrics:           metrics,
 		log:               log,
 	}
 	return worker
@@ -32,13 +41,13 @@ func (w *ApplicationFetchWorker) startAcceptingFetchRequests() {
 	go func() {
 		for {
 			applicationFetchReq := <-w.mediator.ApplicationFetch.RequestChan
-			w.FetchApplicationRemote(applicationFetchReq.Application, applicationFetchReq.WatchObjects)
+			w.FetchApplicationRemote(applicationFetchReq.Application, applicationFetchReq.WatchObjects, applicationFetchReq.Refs...)
 			w.mediator.ApplicationFetch.ResponseChan <- nil
 		}
 	}()
 }
 
-func (w *ApplicationFetchWorker) FetchApplicationRemote(application *models.Application, watchObjects bool) {
+func (w *ApplicationFetchWorker) FetchApplicationRemote(application *models.Application, watchObjects bool, refs ...string) {
 
 	// TODO: Handle all these errors
 
@@ -47,18 +56,50 @@ func (w *ApplicationFetchWorker) FetchApplicationRemote(application *models.Appl
 	defer bus.PublishEvent(models.ApplicationEventTypeFetchCompleted, application)
 
 	var baseFolder string
+	var known map[string]*object.Commit
 
 	application.WithRLock(func(a *models.Application) {
 		baseFolder = a.BaseFolder
+		known = a.CommitMap
 	})
 
 	conf := application.GetConfiguration()
 	appName := conf.Name
 	appID := conf.ID
 
-	fetchResult, errors := w.repositoryFetcher.Fetch(baseFolder)
+	// FEATURE: Commit index
+	// The commits indexed by the previous fetch are not read again;
+	// after a restart they are the ones persisted.
+	// The history gets walked only up to them, from the tips they have been indexed from
+	if len(known) == 0 {
+		known = w.commitIndex.Get(appID)
+	}
+	knownTips := w.commitIndex.GetTips(appID, conf.Fetch.History)
+
+	// FEATURE: Fetch status
+	application.StartFetch()
+	fetchStart := time.Now()
+	fetchResult, errors := w.repositoryFetcher.Fetch(baseFolder, versioning.FetchOptions{
+		History:   conf.Fetch.History,
+		Known:     known,
+		KnownTips: knownTips,
+		Auth:      conf.GetAuth(),
+	}, refs...)
+	fetchDuration := time.Since(fetchStart)
+	w.metrics.ObserveApplicationFetch(appName, fetchDuration, len(errors) > 0)
+	fetch := models.ApplicationFetch{
+		StartedAt:    fetchStart,
+		Duration:     fetchDuration,
+		Result:       models.ApplicationFetchResultSucceeded,
+		Errors:       []string{},
+		Refs:         refs,
+		WatchObjects: watchObjects,
+		NewCommits:   []models.CheckoutObject{},
+	}
 	if len(errors) > 0 {
+		fetch.Result = models.ApplicationFetchResultPartial
 		for _, err := range errors {
+			fetch.Errors = append(fetch.Errors, err.Error.Error())
 			w.log.Errorf("Error while loading application: %s", err.Error.Error())
 			w.defaultApplicationErrorLog(appName, err.Error)
 			if err.Critical {
@@ -76,30 +117,40 @@ func (w *ApplicationFetchWorker) FetchApplicationRemote(application *models.Appl
 
 	// Something gone terribly wrong
 	if fetchResult == nil {
+		fetch.Result = models.ApplicationFetchResultFailed
+		application.CompleteFetch(fetch)
 		return
 	}
 
-	var lastCommitsCount int
-	newCommitsCount := len(fetchResult.AppCommits)
+	w.commitIndex.Update(appID, known, fetchResult.CommitMap)
+	w.commitIndex.UpdateTips(appID, conf.Fetch.History, fetchResult.Tips)
+
+	var lastCommits []string
 	application.WithLock(func(a *models.Application) {
-		lastCommitsCount = len(a.Commits)
+		lastCommits = a.Commits
 
 		a.ObjectsToHashMap = fetchResult.ObjectsToHashMap
 		a.HashToObjectsMap = fetchResult.HashToObjectsMap
 		a.BranchesMap = fetchResult.BranchesMap
 		a.TagsMap = fetchResult.TagsMap
+		a.PullRequestsMap = fetchResult.PullRequestsMap
 		a.Commits = fetchResult.AppCommits
 		a.CommitMap = fetchResult.CommitMap
 	})
 
-	if newCommitsCount > lastCommitsCount {
-		w.log.Infof("[APP:%s] Found %d new commits", appName, newCommitsCount-lastCommitsCount)
+	fetch.NewCommits, fetch.NewCommitsCount = models.NewFetchCommits(lastCommits, fetchResult.AppCommits, fetchResult.CommitMap)
+	application.CompleteFetch(fetch)
+	if fetch.NewCommitsCount > 0 {
+		w.log.Infof("[APP:%s] Found %d new commits", appName, fetch.NewCommitsCount)
 	}
 
 	if !watchObjects {
 		return
 	}
 
+	// FEATURE: Pull request previews
+	w.destroyClosedPullRequestSessions(application, fetchResult.PullRequestsMap)
+
 	aliveRefs := []string{}
 	for _, s := range w.sessionStorage.GetAllAliveApplicationSessions(appID) {
 		s.RLock()
@@ -143,7 +194,10 @@ func (w *ApplicationFetchWorker) FetchApplicationRemote(application *models.Appl
 				// This new one will be aware that it is a replacement for another session that is going to expire.
 				// When the new one gets started, the old one gets destroyed.
 				bus.PublishEvent(models.ApplicationEventTypeHotSwap, application, lastSession)
-				buildSession(w.mediator, nil, w.getAllAppSessionsToBeReplaced(appID, appName, checkout))
+				// Each variant, and each stack instance, gets replaced separately
+				for _, sessionsToBeReplaced := range groupSessionsToBeReplaced(w.getAllAppSessionsToBeReplaced(appID, appName, checkout)) {
+					buildSession(w.mediator, nil, sessionsToBeReplaced)
+				}
 			}
 		} else {
 
@@ -166,6 +220,28 @@ func (w *ApplicationFetchWorker) FetchApplicationRemote(application *models.Appl
 	}
 }
 
+// destroyClosedPullRequestSessions destroys the sessions of the pull requests
+// whose ref disappeared from the remote
+func (w *ApplicationFetchWorker) destroyClosedPullRequestSessions(application *models.Application, pullRequests map[string]*models.PullRequest) {
+	conf := application.GetConfiguration()
+	bus := application.GetEventBus()
+	for _, session := range w.sessionStorage.GetAllAliveApplicationSessions(conf.ID) {
+		session.RLock()
+		checkout := session.Checkout
+		session.RUnlock()
+		if !models.IsPullRequestCheckout(checkout) {
+			continue
+		}
+		if _, open := pullRequests[checkout]; open {
+			continue
+		}
+		w.log.Infof("[APP:%s][WATCH] Pull request %s has been closed", conf.Name, checkout)
+		bus.PublishEvent(models.ApplicationEventTypePullRequestClosed, application, session)
+		session.SetKillReason(models.KillReasonPullRequestClosed)
+		w.mediator.DestroySession.Enqueue(session, nil)
+	}
+}
+
 func (w *ApplicationFetchWorker) getLastAppSessionByCheckout(appName string, checkout string) *models.Session {
 	sessions := w.sessionStorage.GetByApplicationName(appName)
 	var foundSession *models.Session
@@ -192,6 +268,29 @@ func (w *ApplicationFetchWorker) getAllAppSessionsToBeReplaced(appID, appName st
 	return foundSessions
 }
 
+// groupSessionsToBeReplaced groups the sessions by their variant key
+// and by the stack instance they belong to, for each group to get its own replacement.
+// Always returns at least one group
+func groupSessionsToBeReplaced(sessions []*models.Session) [][]*models.Session {
+	groups := [][]*models.Session{}
+	indexes := make(map[string]int)
+	for _, session := range sessions {
+		stack := session.GetStack()
+		key := strings.Join([]string{session.VariantKey(), stack.Name, stack.Checkout}, "|")
+		i, ok := indexes[key]
+		if !ok {
+			i = len(groups)
+			indexes[key] = i
+			groups = append(groups, []*models.Session{})
+		}
+		groups[i] = append(groups[i], session)
+	}
+	if len(groups) == 0 {
+		groups = append(groups, []*models.Session{})
+	}
+	return groups
+}
+
 func requestSessionBuilder(a *models.Application, ref string) func(*Mediator, *models.Session, []*models.Session) {
 	return func(mediator *Mediator, previousSession *models.Session, sessionsToBeReplaced []*models.Session) {
 		mediator.BuildSession.Enqueue(ref, a, previousSession, sessionsToBeReplaced, false)
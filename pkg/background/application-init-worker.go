@@ -44,6 +44,11 @@ func (w *ApplicationInitWorker) startAcceptingInitRequests() {
 }
 
 func (w *ApplicationInitWorker) InitApplication(application *models.Application) error {
+	// FEATURE: Hot reload
+	// An application removed from the configuration while queued is not initialized
+	if application.GetStatus() == models.ApplicationStatusRetired {
+		return nil
+	}
 	bus := application.GetEventBus()
 	bus.PublishEvent(models.ApplicationEventTypeInitializationStarted, application)
 	conf := application.GetConfiguration()
@@ -74,7 +79,7 @@ func (w *ApplicationInitWorker) InitApplication(application *models.Application)
 	baseFolder := filepath.Join(applicationFolder, "_base") // Folder used for performing periodic git fetch --all and/or git log
 	if _, err := os.Stat(baseFolder); os.IsNotExist(err) {  // Application folder does not exist
 
-		err = w.gitClient.Clone(applicationFolder, "_base", remote)
+		err = w.gitClient.WithAuth(conf.GetAuth()).Clone(applicationFolder, "_base", remote)
 		if err != nil {
 			application.AddNotification(
 				models.ApplicationNotificationTypeGitClone,
@@ -106,6 +111,18 @@ func (w *ApplicationInitWorker) startApplicationFetchRoutine(application *models
 			fetchInterval := conf.Fetch.Interval
 			time.Sleep(time.Duration(fetchInterval) * time.Second)
 
+			// FEATURE: Hot reload
+			// The applications removed from the configuration are not fetched anymore
+			if application.GetStatus() == models.ApplicationStatusRetired {
+				return
+			}
+
+			// FEATURE: Push hooks
+			// The fetch gets triggered by the push hooks only
+			if application.GetConfiguration().Fetch.Hook.DisablePolling {
+				continue
+			}
+
 			w.mediator.ApplicationFetch.Enqueue(application, true)
 		}
 	}()
@@ -0,0 +1,115 @@
+package background
+
+import (
+	"github.com/wufe/polo/pkg/forge"
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+)
+
+// CommitStatusWorker posts the state of the sessions of the pull requests
+// as commit statuses on the forge, linking the ones started to their smart URL
+type CommitStatusWorker struct {
+	configuration *models.RootConfiguration
+	applications  []*models.Application
+	log           logging.Logger
+}
+
+func NewCommitStatusWorker(
+	configuration *models.RootConfiguration,
+	applications []*models.Application,
+	logger logging.Logger,
+) *CommitStatusWorker {
+	return &CommitStatusWorker{
+		configuration: configuration,
+		applications:  applications,
+		log:           logger,
+	}
+}
+
+func (w *CommitStatusWorker) Start() {
+	for _, application := range w.applications {
+		w.WatchApplication(application)
+	}
+}
+
+// WatchApplication starts following the builds of the sessions of the pull requests of the application
+func (w *CommitStatusWorker) WatchApplication(application *models.Application) {
+	events, _ := application.GetEventBus().Subscribe()
+	go func() {
+		for event := range events {
+			if event.EventType != models.ApplicationEventTypeSessionBuild {
+				continue
+			}
+			session := payloadSession(event.EventPayload)
+			if session == nil {
+				continue
+			}
+			session.RLock()
+			checkout := session.Checkout
+			session.RUnlock()
+			if models.IsPullRequestCheckout(checkout) && application.GetConfiguration().PullRequests.CommitStatus.IsEnabled() {
+				w.watchSession(application, session)
+			}
+		}
+	}()
+}
+
+// watchSession posts the status of the commit of the session
+// until it gets started or it fails
+func (w *CommitStatusWorker) watchSession(application *models.Application, session *models.Session) {
+	events, unsubscribe := session.GetEventBus().Subscribe()
+	go func() {
+		defer unsubscribe()
+		w.post(application, session, forge.CommitStatePending, "The preview is being built")
+		// The session may have got started while subscribing
+		if w.postFinalState(application, session, session.GetStatus()) {
+			return
+		}
+		for event := range events {
+			if event.Type != models.SessionStreamEventTypeStatus {
+				continue
+			}
+			if w.postFinalState(application, session, event.Status) || !event.Status.IsAlive() {
+				return
+			}
+		}
+	}()
+}
+
+// postFinalState posts the outcome of the build, if the status of the session tells it
+func (w *CommitStatusWorker) postFinalState(application *models.Application, session *models.Session, status models.SessionStatus) bool {
+	switch status {
+	case models.SessionStatusStarted:
+		w.post(application, session, forge.CommitStateSuccess, "The preview is ready")
+		return true
+	case models.SessionStatusStartFailed:
+		w.post(application, session, forge.CommitStateFailure, "The preview failed to start")
+		return true
+	}
+	return false
+}
+
+func (w *CommitStatusWorker) post(application *models.Application, session *models.Session, state forge.CommitState, description string) {
+	conf := application.GetConfiguration()
+	client, err := forge.NewClient(conf.PullRequests.CommitStatus)
+	if err != nil {
+		w.log.Errorf("[APP:%s] %s", conf.Name, err.Error())
+		return
+	}
+	session.RLock()
+	commit := session.CommitID
+	checkout := session.Checkout
+	variant := session.Variant
+	session.RUnlock()
+	err = client.SetCommitStatus(commit, forge.CommitStatus{
+		State:       state,
+		TargetURL:   models.SmartURL(w.configuration.GetPublicURL(), checkout, variant),
+		Description: description,
+		Context:     conf.PullRequests.CommitStatus.Context,
+	})
+	if err != nil {
+		w.log.Errorf("[APP:%s] Could not post the commit status of %s: %s", conf.Name, checkout, err.Error())
+		return
+	}
+	w.log.Debugf("[APP:%s] Posted the commit status %s of %s", conf.Name, state, checkout)
+}
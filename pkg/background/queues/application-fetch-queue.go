@@ -5,24 +5,41 @@ import "github.com/wufe/polo/pkg/models"
 type ApplicationFetchQueue struct {
 	RequestChan  chan ApplicationFetchInput
 	ResponseChan chan error
+	wait         waitTimer
 }
 
 type ApplicationFetchInput struct {
 	Application  *models.Application
 	WatchObjects bool
+	Refs         []string // Fetches only these refs, if set
 }
 
-func NewApplicationFetch() ApplicationFetchQueue {
+func NewApplicationFetch(observer WaitObserver) ApplicationFetchQueue {
 	return ApplicationFetchQueue{
 		RequestChan:  make(chan ApplicationFetchInput),
 		ResponseChan: make(chan error),
+		wait:         waitTimer{queue: "application_fetch", observer: observer},
 	}
 }
 
 func (q *ApplicationFetchQueue) Enqueue(app *models.Application, watchObjects bool) error {
+	accepted := q.wait.start()
 	q.RequestChan <- ApplicationFetchInput{
 		Application:  app,
 		WatchObjects: watchObjects,
 	}
+	accepted()
+	return <-q.ResponseChan
+}
+
+// EnqueueRefs requests a fetch limited to the given refs (e.g. the ones just pushed)
+func (q *ApplicationFetchQueue) EnqueueRefs(app *models.Application, refs []string) error {
+	accepted := q.wait.start()
+	q.RequestChan <- ApplicationFetchInput{
+		Application:  app,
+		WatchObjects: true,
+		Refs:         refs,
+	}
+	accepted()
 	return <-q.ResponseChan
 }
@@ -7,16 +7,20 @@ import (
 type ApplicationInitQueue struct {
 	RequestChan  chan *models.Application
 	ResponseChan chan error
+	wait         waitTimer
 }
 
-func NewApplicationInit() ApplicationInitQueue {
+func NewApplicationInit(observer WaitObserver) ApplicationInitQueue {
 	return ApplicationInitQueue{
 		RequestChan:  make(chan *models.Application),
 		ResponseChan: make(chan error),
+		wait:         waitTimer{queue: "application_init", observer: observer},
 	}
 }
 
 func (q *ApplicationInitQueue) Enqueue(input *models.Application) error {
+	accepted := q.wait.start()
 	q.RequestChan <- input
+	accepted()
 	return <-q.ResponseChan
 }
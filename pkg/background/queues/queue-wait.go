@@ -0,0 +1,25 @@
+package queues
+
+import "time"
+
+// WaitObserver gets notified of the time spent by the requests
+// waiting for a worker to accept them
+type WaitObserver interface {
+	ObserveQueueWait(queue string, wait time.Duration)
+}
+
+type waitTimer struct {
+	queue    string
+	observer WaitObserver
+}
+
+// start starts timing a request.
+// The returned function must be called once a worker has accepted the request
+func (t waitTimer) start() func() {
+	begin := time.Now()
+	return func() {
+		if t.observer != nil {
+			t.observer.ObserveQueueWait(t.queue, time.Since(begin))
+		}
+	}
+}
@@ -13,12 +13,14 @@ const (
 type SessionBuildQueue struct {
 	RequestChan  chan *SessionBuildInput
 	ResponseChan chan *SessionBuildResult
+	wait         waitTimer
 }
 
-func NewSessionBuild() SessionBuildQueue {
+func NewSessionBuild(observer WaitObserver) SessionBuildQueue {
 	return SessionBuildQueue{
 		RequestChan:  make(chan *SessionBuildInput),
 		ResponseChan: make(chan *SessionBuildResult),
+		wait:         waitTimer{queue: "session_build", observer: observer},
 	}
 }
 
@@ -28,6 +30,12 @@ type SessionBuildInput struct {
 	PreviousSession      *models.Session
 	SessionsToBeReplaced []*models.Session
 	DetectBranchOrTag    bool
+	// Stack is set when the session is part of a stack instance
+	Stack models.SessionStack
+	// Parameters are the values of the build parameters, not yet validated
+	Parameters map[string]string
+	// Variant distinguishes sessions of the same commit
+	Variant string
 }
 type SessionBuildResultType string
 
@@ -39,12 +47,18 @@ type SessionBuildResult struct {
 }
 
 func (q *SessionBuildQueue) Enqueue(checkout string, app *models.Application, prevSession *models.Session, sessionsToBeReplaced []*models.Session, detectBranchOrTag bool) *SessionBuildResult {
-	q.RequestChan <- &SessionBuildInput{
+	return q.EnqueueInput(&SessionBuildInput{
 		Checkout:             checkout,
 		Application:          app,
 		PreviousSession:      prevSession,
 		SessionsToBeReplaced: sessionsToBeReplaced,
 		DetectBranchOrTag:    detectBranchOrTag,
-	}
+	})
+}
+
+func (q *SessionBuildQueue) EnqueueInput(input *SessionBuildInput) *SessionBuildResult {
+	accepted := q.wait.start()
+	q.RequestChan <- input
+	accepted()
 	return <-q.ResponseChan
 }
@@ -4,16 +4,20 @@ import "github.com/wufe/polo/pkg/models"
 
 type SessionCleanupQueue struct {
 	Chan chan *SessionCleanupInput
+	wait waitTimer
 }
 
-func NewSessionCleanup() SessionCleanupQueue {
+func NewSessionCleanup(observer WaitObserver) SessionCleanupQueue {
 	return SessionCleanupQueue{
 		Chan: make(chan *SessionCleanupInput),
+		wait: waitTimer{queue: "session_cleanup", observer: observer},
 	}
 }
 
 func (q *SessionCleanupQueue) Enqueue(session *models.Session, status models.SessionStatus) {
+	accepted := q.wait.start()
 	q.Chan <- &SessionCleanupInput{session, status}
+	accepted()
 }
 
 type SessionCleanupInput struct {
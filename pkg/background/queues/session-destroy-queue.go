@@ -4,22 +4,33 @@ import "github.com/wufe/polo/pkg/models"
 
 type SessionDestroyQueue struct {
 	Chan chan SessionDestroyInput
+	wait waitTimer
 }
 
 type SessionDestroyInput struct {
 	Session  *models.Session
 	Callback func(*models.Session)
+	// Stopping gets closed once the session is no longer alive
+	Stopping chan struct{}
 }
 
-func NewSessionDestroy() SessionDestroyQueue {
+func NewSessionDestroy(observer WaitObserver) SessionDestroyQueue {
 	return SessionDestroyQueue{
 		Chan: make(chan SessionDestroyInput),
+		wait: waitTimer{queue: "session_destroy", observer: observer},
 	}
 }
 
+// Enqueue requests the destruction of a session,
+// returning once the session has left the set of alive sessions
 func (q *SessionDestroyQueue) Enqueue(session *models.Session, callback func(*models.Session)) {
+	accepted := q.wait.start()
+	stopping := make(chan struct{})
 	q.Chan <- SessionDestroyInput{
 		Session:  session,
 		Callback: callback,
+		Stopping: stopping,
 	}
+	accepted()
+	<-stopping
 }
@@ -5,12 +5,14 @@ import "github.com/wufe/polo/pkg/models"
 type SessionFilesystemQueue struct {
 	RequestChan  chan *models.Session
 	ResponseChan chan *SessionFilesystemResult
+	wait         waitTimer
 }
 
-func NewSessionFilesystem() SessionFilesystemQueue {
+func NewSessionFilesystem(observer WaitObserver) SessionFilesystemQueue {
 	return SessionFilesystemQueue{
 		RequestChan:  make(chan *models.Session),
 		ResponseChan: make(chan *SessionFilesystemResult),
+		wait:         waitTimer{queue: "session_filesystem", observer: observer},
 	}
 }
 
@@ -20,6 +22,8 @@ type SessionFilesystemResult struct {
 }
 
 func (q *SessionFilesystemQueue) Enqueue(session *models.Session) *SessionFilesystemResult {
+	accepted := q.wait.start()
 	q.RequestChan <- session
+	accepted()
 	return <-q.ResponseChan
 }
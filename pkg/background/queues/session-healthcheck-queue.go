@@ -7,20 +7,24 @@ import (
 type SessionHealthcheckQueue struct {
 	RequestChan  chan SessionHealthcheckInput
 	ResponseChan chan struct{}
+	wait         waitTimer
 }
 
 type SessionHealthcheckInput struct {
 	Session *models.Session
 }
 
-func NewSessionHealthCheck() SessionHealthcheckQueue {
+func NewSessionHealthCheck(observer WaitObserver) SessionHealthcheckQueue {
 	return SessionHealthcheckQueue{
 		RequestChan:  make(chan SessionHealthcheckInput),
 		ResponseChan: make(chan struct{}),
+		wait:         waitTimer{queue: "session_healthcheck", observer: observer},
 	}
 }
 
 func (q *SessionHealthcheckQueue) Enqueue(input SessionHealthcheckInput) struct{} {
+	accepted := q.wait.start()
 	q.RequestChan <- input
+	accepted()
 	return <-q.ResponseChan
 }
@@ -6,18 +6,22 @@ import (
 
 type SessionStartQueue struct {
 	Chan chan SessionStartInput
+	wait waitTimer
 }
 
 type SessionStartInput struct {
 	Session *models.Session
 }
 
-func NewSessionStart() SessionStartQueue {
+func NewSessionStart(observer WaitObserver) SessionStartQueue {
 	return SessionStartQueue{
 		Chan: make(chan SessionStartInput),
+		wait: waitTimer{queue: "session_start", observer: observer},
 	}
 }
 
 func (q *SessionStartQueue) Enqueue(input SessionStartInput) {
+	accepted := q.wait.start()
 	q.Chan <- input
+	accepted()
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +14,7 @@ import (
 	"github.com/wufe/polo/pkg/logging"
 	"github.com/wufe/polo/pkg/models"
 	"github.com/wufe/polo/pkg/storage"
+	"github.com/wufe/polo/pkg/tracing"
 )
 
 var (
@@ -21,35 +23,44 @@ var (
 )
 
 type SessionBuildWorker struct {
-	global                  *models.GlobalConfiguration
+	configuration           *models.RootConfiguration
 	applicationStorage      *storage.Application
 	sessionStorage          *storage.Session
 	mediator                *Mediator
 	sessionBuilder          *models.SessionBuilder
 	log                     logging.Logger
 	sessionCommandExecution SessionCommandExecution
+	sessionRuntimeLogs      SessionRuntimeLogs
 	portRetriever           net.PortRetriever
+	tracer                  *tracing.Tracer
+	webhooks                *WebhookWorker
 }
 
 func NewSessionBuildWorker(
-	globalConfiguration *models.GlobalConfiguration,
+	configuration *models.RootConfiguration,
 	applicationStorage *storage.Application,
 	sessionStorage *storage.Session,
 	mediator *Mediator,
 	sessionBuilder *models.SessionBuilder,
 	log logging.Logger,
 	sessionCommandExecution SessionCommandExecution,
+	sessionRuntimeLogs SessionRuntimeLogs,
 	portRetriever net.PortRetriever,
+	tracer *tracing.Tracer,
+	webhooks *WebhookWorker,
 ) *SessionBuildWorker {
 	worker := &SessionBuildWorker{
-		global:                  globalConfiguration,
+		configuration:           configuration,
 		applicationStorage:      applicationStorage,
 		sessionStorage:          sessionStorage,
 		mediator:                mediator,
 		sessionBuilder:          sessionBuilder,
 		log:                     log,
 		sessionCommandExecution: sessionCommandExecution,
+		sessionRuntimeLogs:      sessionRuntimeLogs,
 		portRetriever:           portRetriever,
+		tracer:                  tracer,
+		webhooks:                webhooks,
 	}
 	return worker
 }
@@ -73,7 +84,7 @@ func (w *SessionBuildWorker) startAcceptingNewSessionRequests() {
 }
 
 func (w *SessionBuildWorker) RequestNewSession(buildInput *queues.SessionBuildInput) *queues.SessionBuildResult {
-	return w.mediator.BuildSession.Enqueue(buildInput.Checkout, buildInput.Application, buildInput.PreviousSession, buildInput.SessionsToBeReplaced, buildInput.DetectBranchOrTag)
+	return w.mediator.BuildSession.EnqueueInput(buildInput)
 }
 
 func (w *SessionBuildWorker) acceptSessionBuild(input *queues.SessionBuildInput) *queues.SessionBuildResult {
@@ -84,7 +95,7 @@ func (w *SessionBuildWorker) acceptSessionBuild(input *queues.SessionBuildInput)
 	appMaxConcurrentSessions := conf.MaxConcurrentSessions
 
 	aliveCount := len(w.sessionStorage.GetAllAliveSessions())
-	if aliveCount >= w.global.MaxConcurrentSessions {
+	if aliveCount >= w.configuration.GetMaxConcurrentSessions() {
 		return &queues.SessionBuildResult{
 			Result:        queues.SessionBuildResultFailed,
 			FailingReason: "Reached global maximum concurrent sessions",
@@ -126,23 +137,71 @@ func (w *SessionBuildWorker) acceptSessionBuild(input *queues.SessionBuildInput)
 			CommitID:    input.Checkout, // Commit ID
 			Checkout:    input.Checkout, // The branch name or the commit ID
 			DisplayName: input.Checkout, // The branch name or the alias
+			Stack:       input.Stack,
 		})
 	}
 
+	// FEATURE: Stacks
+	// A session replacing a stack member (hot swap)
+	// takes its place in the stack
+	if !session.GetStack().IsSet() {
+		for _, replaced := range input.SessionsToBeReplaced {
+			if replacedStack := replaced.GetStack(); replacedStack.IsSet() {
+				session.SetStack(replacedStack)
+				break
+			}
+		}
+	}
+
 	// Build new alias
 	sessionsNames := w.sessionStorage.GetAllSessionsNames()
 	session.Alias = models.NewSessionAlias(sessionsNames)
 
+	// Getting configuration matching this session
+	conf = session.GetConfiguration()
+	appPort := conf.Port
+
+	// FEATURE: Build parameters and variants
+	// They are validated before the build gets announced,
+	// as a rejected request is not followed by any failure event.
+	// A recycled session keeps its parameters and its variant,
+	// a replacement inherits those of the session it replaces
+	if !recyclingPreviousSession {
+		session.Variant = input.Variant
+		if session.Variant == "" && len(input.SessionsToBeReplaced) > 0 {
+			session.Variant = input.SessionsToBeReplaced[0].Variant
+		}
+		if err := models.ValidateVariant(session.Variant); err != nil {
+			return &queues.SessionBuildResult{
+				Result:        queues.SessionBuildResultFailed,
+				FailingReason: err.Error(),
+			}
+		}
+		supplied := input.Parameters
+		if supplied == nil && len(input.SessionsToBeReplaced) > 0 {
+			supplied = make(map[string]string)
+			for name, value := range input.SessionsToBeReplaced[0].Parameters {
+				if conf.Parameters.Has(name) {
+					supplied[name] = value
+				}
+			}
+		}
+		parameters, err := conf.Parameters.Resolve(supplied)
+		if err != nil {
+			return &queues.SessionBuildResult{
+				Result:        queues.SessionBuildResultFailed,
+				FailingReason: fmt.Sprintf("Invalid build parameters: %s", err.Error()),
+			}
+		}
+		session.Parameters = parameters
+	}
+
 	appBus.PublishEvent(models.ApplicationEventTypeSessionBuild, input.Application, session)
 
 	if input.SessionsToBeReplaced != nil && len(input.SessionsToBeReplaced) > 0 {
 		session.SetReplaces(input.SessionsToBeReplaced)
 	}
 
-	// Getting configuration matching this session
-	conf = session.GetConfiguration()
-	appPort := conf.Port
-
 	commitID, ok := input.Application.ObjectsToHashMap[input.Checkout]
 	if !ok {
 		return &queues.SessionBuildResult{
@@ -164,7 +223,7 @@ func (w *SessionBuildWorker) acceptSessionBuild(input *queues.SessionBuildInput)
 	session.Port = freePort
 	session.LogInfo(fmt.Sprintf("Found new free port: %d", session.Port))
 
-	session.CommitID = commitID
+	session.SetCommitID(commitID)
 	session.Commit = *input.Application.CommitMap[commitID]
 	session.LogInfo(fmt.Sprintf("Requested checkout to %s (%s)", input.Checkout, session.CommitID))
 
@@ -174,6 +233,7 @@ func (w *SessionBuildWorker) acceptSessionBuild(input *queues.SessionBuildInput)
 	// if given checkout name corresponds to a commitID
 	_, checkoutIsTag := input.Application.TagsMap[input.Checkout]
 	_, checkoutIsBranch := input.Application.BranchesMap[input.Checkout]
+	_, checkoutIsPullRequest := input.Application.PullRequestsMap[input.Checkout]
 	object, checkoutIsObject := input.Application.HashToObjectsMap[input.Checkout]
 	if input.DetectBranchOrTag && checkoutIsObject {
 		// input.checkout is a commitID
@@ -182,8 +242,10 @@ func (w *SessionBuildWorker) acceptSessionBuild(input *queues.SessionBuildInput)
 			session.DisplayName = object.Branches[0]
 		} else if len(object.Tags) > 0 {
 			session.DisplayName = object.Tags[0]
+		} else if len(object.PullRequests) > 0 {
+			session.DisplayName = object.PullRequests[0]
 		}
-	} else if !checkoutIsTag && !checkoutIsBranch {
+	} else if !checkoutIsTag && !checkoutIsBranch && !checkoutIsPullRequest {
 		session.DisplayName = session.Alias
 	} else {
 		session.DisplayName = input.Checkout
@@ -192,10 +254,21 @@ func (w *SessionBuildWorker) acceptSessionBuild(input *queues.SessionBuildInput)
 	if !basedOnPreviousSession {
 		// Check if someone else just requested the same type of session
 		// looking through all open session and comparing applications and checkouts
-		sessionAlreadyBeingBuilt := w.sessionStorage.GetAliveApplicationSessionByCommitID(
-			commitID,
-			input.Application,
-		)
+		var sessionAlreadyBeingBuilt *models.Session
+		if stack := session.GetStack(); stack.IsSet() {
+			// Stack members get reused only inside the same stack instance
+			for _, s := range w.sessionStorage.GetAliveStackSessions(stack.Name, stack.Checkout) {
+				if s.Application == input.Application && s.GetCommitID() == commitID && s.VariantKey() == session.VariantKey() {
+					sessionAlreadyBeingBuilt = s
+				}
+			}
+		} else {
+			sessionAlreadyBeingBuilt = w.sessionStorage.GetAliveApplicationSessionByCommitID(
+				commitID,
+				session.VariantKey(),
+				input.Application,
+			)
+		}
 		if sessionAlreadyBeingBuilt != nil {
 			session.LogWarn(fmt.Sprintf("Another session with the UUID %s has already being requested for checkout %s", sessionAlreadyBeingBuilt.UUID, input.Checkout))
 			return &queues.SessionBuildResult{
@@ -211,9 +284,22 @@ func (w *SessionBuildWorker) acceptSessionBuild(input *queues.SessionBuildInput)
 	session.Variables["name"] = session.Alias
 	session.Variables["port"] = fmt.Sprint(session.Port)
 	session.Variables["commit"] = session.CommitID
+	session.Parameters.ApplyTo(session.Variables)
+	if session.Variant != "" {
+		session.Variables["variant"] = session.Variant
+		session.LogInfo(fmt.Sprintf("Variant: %s", session.Variant))
+	}
+	if len(session.Parameters) > 0 {
+		session.LogInfo(fmt.Sprintf("Build parameters: %s", session.Parameters))
+	}
 
 	w.sessionStorage.Add(session)
 
+	// FEATURE: Webhooks
+	// Following the session before its build starts,
+	// for none of its events to be missed
+	w.webhooks.WatchSession(session)
+
 	go w.buildSession(session)
 
 	return &queues.SessionBuildResult{
@@ -229,13 +315,30 @@ func (w *SessionBuildWorker) buildSession(session *models.Session) {
 	appStartupTimeout := conf.Startup.Timeout
 	appHealthcheck := conf.Healthcheck
 
-	sessionStartContext, cancelSessionStart := context.WithTimeout(context.Background(), time.Second*time.Duration(appStartupTimeout))
-	sessionStartContext, cancelSessionStart = context.WithCancel(sessionStartContext)
+	sessionBuildContext, cancelSessionBuild := context.WithCancel(context.Background())
+
+	// FEATURE: Tracing
+	// One trace per session build; its context is kept
+	// until the session gets available, for the healthchecks to join it
+	sessionBuildContext, buildSpan := w.tracer.Start(sessionBuildContext, "session.build")
+	buildSpan.SetAttribute("polo.session.uuid", session.UUID)
+	buildSpan.SetAttribute("polo.application", session.ApplicationName)
+	buildSpan.SetAttribute("polo.checkout", session.Checkout)
+	buildSpan.SetAttribute("polo.commit", session.CommitID)
+	defer buildSpan.End()
+	if buildSpan != nil {
+		traceContext, cancelTrace := context.WithCancel(tracing.ContextWithSpan(context.Background(), buildSpan))
+		session.Context.Add(models.SessionTraceContextKey, traceContext, cancelTrace)
+		if appHealthcheck == (models.Healthcheck{}) {
+			defer endSessionTrace(session)
+		}
+	}
+
 	defer session.Context.
 		Named(models.SessionBuildContextKey).
-		With(sessionStartContext, cancelSessionStart).
+		With(sessionBuildContext, cancelSessionBuild).
 		Delete()
-	defer cancelSessionStart()
+	defer cancelSessionBuild()
 
 	done := make(chan struct{})
 	quit := make(chan struct{})
@@ -243,14 +346,40 @@ func (w *SessionBuildWorker) buildSession(session *models.Session) {
 		close(done)
 	}
 	abort := func() {
-		session.Application.GetEventBus().PublishEvent(models.ApplicationEventTypeSessionBuildFailed, session.Application)
+		session.Application.GetEventBus().PublishEvent(models.ApplicationEventTypeSessionBuildFailed, session.Application, session)
 		close(quit)
 	}
 
 	calcBuildMetrics := models.NewMetricsForSession(session)("Build (total)")
+
+	// FEATURE: Dependencies
+	// Sessions of the applications this one depends on
+	// must be available before building this one.
+	// The entry session of a stack depends on all the other members of the stack
+	if len(conf.Dependencies) > 0 || session.GetStack().Entry {
+		session.GetEventBus().PublishEvent(models.SessionEventTypeDependenciesStarted, session)
+		err := w.resolveDependencies(sessionBuildContext, session, conf)
+		if err != nil {
+			buildSpan.SetError(err)
+			session.LogError(fmt.Sprintf("Could not resolve session dependencies: %s", err.Error()))
+			session.SetKillReason(models.KillReasonBuildFailed)
+			session.GetEventBus().PublishEvent(models.SessionEventTypeDependenciesFailed, session)
+			abort()
+			w.mediator.CleanSession.Enqueue(session, models.SessionStatusStartFailed)
+			return
+		}
+		w.sessionStorage.Update(session)
+	}
+
+	// The startup timeout runs once the dependencies are available:
+	// the wait for them is bounded by their own startup timeouts
+	sessionStartContext, cancelSessionStart := context.WithTimeout(sessionBuildContext, time.Second*time.Duration(appStartupTimeout))
+	defer cancelSessionStart()
+
 	session.GetEventBus().PublishEvent(models.SessionEventTypePreparingFolders, session)
-	err := w.prepareFolders(session)
+	err := w.prepareFolders(sessionStartContext, session)
 	if err != nil {
+		buildSpan.SetError(err)
 		session.LogError(fmt.Sprintf("Could not build session commit structure: %s", err.Error()))
 		session.SetKillReason(models.KillReasonBuildFailed)
 		session.GetEventBus().PublishEvent(models.SessionEventTypePreparingFoldersFailed, session)
@@ -278,6 +407,7 @@ func (w *SessionBuildWorker) buildSession(session *models.Session) {
 	}()
 	healthcheckingStarted, err := w.execCommands(sessionStartContext, session, conf)
 	if err != nil {
+		buildSpan.SetError(err)
 		if err == ErrWrongSessionState {
 			if session.GetKillReason() == models.KillReasonNone {
 				session.SetKillReason(models.KillReasonStopped)
@@ -291,11 +421,17 @@ func (w *SessionBuildWorker) buildSession(session *models.Session) {
 		return
 	}
 
+	// FEATURE: Runtime logs
+	// Once the start commands have returned, the output of the application
+	// is collected from the configured sources
+	w.sessionRuntimeLogs.Tail(session, conf.Logs)
+
 	warmup := conf.Warmup
 	if len(warmup.URLs) > 0 {
 		session.GetEventBus().PublishEvent(models.SessionEventTypeWarmupStarted, session)
 		err := w.execWarmups(sessionStartContext, session, conf)
 		if err != nil {
+			buildSpan.SetError(err)
 			if err == ErrWrongSessionState {
 				if session.GetKillReason() == models.KillReasonNone {
 					session.SetKillReason(models.KillReasonStopped)
@@ -329,17 +465,201 @@ func (w *SessionBuildWorker) buildSession(session *models.Session) {
 		}
 	}
 
-	session.Application.GetEventBus().PublishEvent(models.ApplicationEventTypeSessionBuildSucceeded, session.Application)
+	session.Application.GetEventBus().PublishEvent(models.ApplicationEventTypeSessionBuildSucceeded, session.Application, session)
 	confirm()
 }
 
-func (w *SessionBuildWorker) prepareFolders(session *models.Session) error {
+// resolveDependencies builds or reuses the sessions of the applications
+// the session depends on, waits for them to be started
+// and exposes their data as variables (e.g. {{deps.backend.target}})
+func (w *SessionBuildWorker) resolveDependencies(ctx context.Context, session *models.Session, conf models.ApplicationConfiguration) (err error) {
+	calcDependenciesMetrics := models.NewMetricsForSession(session)("Dependencies")
+	defer calcDependenciesMetrics()
+	ctx, span := w.tracer.StartChild(ctx, "dependencies")
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	if w.dependsOn(conf, conf.Name, make(map[string]bool)) {
+		return fmt.Errorf("application %s has a circular dependency", conf.Name)
+	}
+
+	dependencies := []*models.Session{}
+	if session.GetStack().Entry {
+		members, err := w.resolveStackMembers(ctx, session)
+		if err != nil {
+			return err
+		}
+		dependencies = append(dependencies, members...)
+	}
+	for _, dependency := range conf.Dependencies {
+		application := w.applicationStorage.Get(dependency.Application)
+		if application == nil {
+			return fmt.Errorf("dependency %s: application %s not found", dependency.Name, dependency.Application)
+		}
+		if application.GetStatus() != models.ApplicationStatusReady {
+			return fmt.Errorf("dependency %s: application %s is not ready yet", dependency.Name, dependency.Application)
+		}
+
+		checkout, err := w.resolveDependencyCheckout(session, application, dependency)
+		if err != nil {
+			return fmt.Errorf("dependency %s: %s", dependency.Name, err.Error())
+		}
+		session.LogInfo(fmt.Sprintf("Dependency %s: using %s of application %s", dependency.Name, checkout, dependency.Application))
+
+		dependencySession := w.sessionStorage.GetAliveApplicationSessionByCheckout(checkout, application)
+		if dependencySession == nil {
+			result := w.mediator.BuildSession.Enqueue(checkout, application, nil, nil, false)
+			if result.Result == queues.SessionBuildResultFailed {
+				return fmt.Errorf("dependency %s: %s", dependency.Name, result.FailingReason)
+			}
+			dependencySession = result.Session
+		}
+
+		session.LogInfo(fmt.Sprintf("Dependency %s: waiting for session %s", dependency.Name, dependencySession.UUID))
+		err = w.waitForDependencySession(ctx, dependencySession)
+		if err != nil {
+			return fmt.Errorf("dependency %s: %s", dependency.Name, err.Error())
+		}
+
+		dependencySession.RLock()
+		dependencyPort := dependencySession.Port
+		dependencyCommitID := dependencySession.CommitID
+		dependencySession.RUnlock()
+
+		prefix := fmt.Sprintf("deps.%s.", dependency.Name)
+		session.SetVariable(prefix+"target", dependencySession.GetTarget())
+		session.SetVariable(prefix+"port", fmt.Sprint(dependencyPort))
+		session.SetVariable(prefix+"uuid", dependencySession.UUID)
+		session.SetVariable(prefix+"checkout", checkout)
+		session.SetVariable(prefix+"commit", dependencyCommitID)
+
+		dependencies = append(dependencies, dependencySession)
+	}
+	session.SetDependencies(dependencies)
+	return nil
+}
+
+// resolveStackMembers waits for the other sessions of the stack instance
+// to be started and exposes their data as variables (e.g. {{deps.backend.target}}),
+// using the IDs of their applications as names
+func (w *SessionBuildWorker) resolveStackMembers(ctx context.Context, session *models.Session) ([]*models.Session, error) {
+	members := []*models.Session{}
+	stack := session.GetStack()
+	for _, member := range w.sessionStorage.GetAliveStackSessions(stack.Name, stack.Checkout) {
+		if member == session || member.GetStack().Entry || member.GetReplacedBy() != nil {
+			continue
+		}
+		memberConf := member.GetConfiguration()
+
+		session.LogInfo(fmt.Sprintf("Stack %s: waiting for session %s of application %s", stack.Name, member.UUID, memberConf.Name))
+		err := w.waitForDependencySession(ctx, member)
+		if err != nil {
+			return nil, fmt.Errorf("stack %s: %s", stack.Name, err.Error())
+		}
+
+		member.RLock()
+		memberPort := member.Port
+		memberCheckout := member.Checkout
+		memberCommitID := member.CommitID
+		member.RUnlock()
+
+		prefix := fmt.Sprintf("deps.%s.", memberConf.ID)
+		session.SetVariable(prefix+"target", member.GetTarget())
+		session.SetVariable(prefix+"port", fmt.Sprint(memberPort))
+		session.SetVariable(prefix+"uuid", member.UUID)
+		session.SetVariable(prefix+"checkout", memberCheckout)
+		session.SetVariable(prefix+"commit", memberCommitID)
+
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// resolveDependencyCheckout looks for the checkout requested by the dependency
+// in the dependency application, falling back on the configured fallback
+// or on the branch flagged as "main"
+func (w *SessionBuildWorker) resolveDependencyCheckout(session *models.Session, application *models.Application, dependency models.Dependency) (string, error) {
+	checkout := models.Variables{"checkout": session.Checkout}.ApplyTo(dependency.Checkout)
+	checkout = session.Variables.ApplyTo(checkout)
+
+	var objectsToHashMap map[string]string
+	var branchesMap map[string]*models.Branch
+	application.WithRLock(func(a *models.Application) {
+		objectsToHashMap = a.ObjectsToHashMap
+		branchesMap = a.BranchesMap
+	})
+
+	if _, ok := objectsToHashMap[checkout]; ok {
+		return checkout, nil
+	}
+	if dependency.Fallback != "" {
+		if _, ok := objectsToHashMap[dependency.Fallback]; ok {
+			return dependency.Fallback, nil
+		}
+		return "", fmt.Errorf("neither %s nor fallback %s found", checkout, dependency.Fallback)
+	}
+	branchNames := make([]string, 0, len(branchesMap))
+	for branchName := range branchesMap {
+		branchNames = append(branchNames, branchName)
+	}
+	conf := application.GetConfiguration()
+	if branchName, ok := conf.Branches.FirstMainBranch(branchNames, w.log); ok {
+		return branchName, nil
+	}
+	return "", fmt.Errorf("%s not found and no fallback or main branch available", checkout)
+}
+
+// waitForDependencySession blocks until the dependency session gets started
+func (w *SessionBuildWorker) waitForDependencySession(ctx context.Context, dependency *models.Session) error {
+	for {
+		status := dependency.GetStatus()
+		if status == models.SessionStatusStarted {
+			return nil
+		}
+		if !status.IsAlive() {
+			return fmt.Errorf("session %s is not alive (%s)", dependency.UUID, status)
+		}
+		select {
+		case <-ctx.Done():
+			return context.Canceled
+		case <-time.After(1 * time.Second):
+		}
+	}
+}
+
+// dependsOn checks if the application configuration depends,
+// directly or transitively, on the application named target
+func (w *SessionBuildWorker) dependsOn(conf models.ApplicationConfiguration, target string, visited map[string]bool) bool {
+	for _, dependency := range conf.Dependencies {
+		if strings.EqualFold(dependency.Application, target) {
+			return true
+		}
+		key := strings.ToLower(dependency.Application)
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+		application := w.applicationStorage.Get(dependency.Application)
+		if application != nil && w.dependsOn(application.GetConfiguration(), target, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *SessionBuildWorker) prepareFolders(ctx context.Context, session *models.Session) error {
 	calcFolderPrepareMetrics := models.NewMetricsForSession(session)("Prepare folder")
 	defer calcFolderPrepareMetrics()
+	_, span := w.tracer.StartChild(ctx, "prepareFolders")
+	defer span.End()
 	fsResponse := w.mediator.SessionFileSystem.Enqueue(session)
 	workingDir := fsResponse.CommitFolder
 	err := fsResponse.Err
 	session.Folder = workingDir
+	span.SetAttribute("polo.session.folder", workingDir)
+	span.SetError(err)
 	return err
 }
 
@@ -363,7 +683,15 @@ func (w *SessionBuildWorker) execCommands(ctx context.Context, session *models.S
 				return healthcheckingStarted, ErrWrongSessionState
 			}
 
-			err := w.sessionCommandExecution.ExecCommand(ctx, &command, session)
+			var err error
+			if command.Supervise {
+				// FEATURE: Runtime logs
+				// Supervised commands keep running in background
+				supervised := command
+				w.sessionRuntimeLogs.Supervise(session, &supervised)
+			} else {
+				err = w.sessionCommandExecution.ExecCommand(ctx, &command, session)
+			}
 
 			if err != nil {
 				if !command.ContinueOnError {
@@ -403,7 +731,14 @@ func (w *SessionBuildWorker) execWarmups(ctx context.Context, session *models.Se
 				return ErrWrongSessionState
 			}
 
-			success, url, err := w.execWarmup(ctx, session, conf, warmup, warmups)
+			warmupCtx, span := w.tracer.StartChild(ctx, "warmup")
+			success, url, err := w.execWarmup(warmupCtx, session, conf, warmup, warmups)
+			span.SetAttribute("http.method", warmup.Method)
+			span.SetAttribute("http.url", url)
+			if !success {
+				span.SetError(fmt.Errorf("Warmup of URL %s failed", url))
+			}
+			span.End()
 			if !success {
 				if err != nil {
 					session.LogError(fmt.Sprintf("Cannot execute warmup of URL %s: %s", url, err.Error()))
@@ -450,7 +785,9 @@ func (w *SessionBuildWorker) execWarmup(ctx context.Context, session *models.Ses
 			return false, url, err
 		}
 		req.WithContext(reqCtx)
-		err = conf.Headers.ApplyTo(req)
+		tracing.Inject(tracing.SpanFromContext(ctx), req.Header)
+		headers := conf.Headers.WithVariables(session.Variables.WithSecrets(conf.Secrets))
+		err = headers.ApplyTo(req)
 		if err != nil {
 			return false, url, err
 		}
@@ -7,21 +7,26 @@ import (
 	"sync"
 	"time"
 
+	"github.com/wufe/polo/pkg/metrics"
 	"github.com/wufe/polo/pkg/models"
 	"github.com/wufe/polo/pkg/storage"
 )
 
 type SessionCleanWorker struct {
 	sessionStorage          *storage.Session
+	logStorage              *storage.SessionLog
 	mediator                *Mediator
 	sessionCommandExecution SessionCommandExecution
+	metrics                 *metrics.Metrics
 }
 
-func NewSessionCleanWorker(sessionStorage *storage.Session, mediator *Mediator, sessionCommandExecution SessionCommandExecution) *SessionCleanWorker {
+func NewSessionCleanWorker(sessionStorage *storage.Session, logStorage *storage.SessionLog, mediator *Mediator, sessionCommandExecution SessionCommandExecution, metrics *metrics.Metrics) *SessionCleanWorker {
 	worker := &SessionCleanWorker{
 		sessionStorage:          sessionStorage,
+		logStorage:              logStorage,
 		mediator:                mediator,
 		sessionCommandExecution: sessionCommandExecution,
+		metrics:                 metrics,
 	}
 	return worker
 }
@@ -48,6 +53,8 @@ func (w *SessionCleanWorker) startAcceptingSessionCleanRequests() {
 			if _, cancel, ok := session.Context.TryGet(models.SessionBuildContextKey); ok {
 				cancel()
 			}
+			stopSessionRuntime(session)
+			endSessionTrace(session)
 
 			appCleanCommands := conf.Commands.Clean
 			var wg sync.WaitGroup
@@ -100,6 +107,7 @@ func (w *SessionCleanWorker) startAcceptingSessionCleanRequests() {
 			sessionFailed := false
 
 			if killReason == models.KillReasonBuildFailed || killReason == models.KillReasonHealthcheckFailed {
+				w.metrics.IncSessionBuildFailure(session.ApplicationName, string(killReason))
 				maxRetries := appStartupRetries
 				if maxRetries > 0 {
 					retriesCount := session.GetStartupRetriesCount()
@@ -162,6 +170,7 @@ func (w *SessionCleanWorker) startAcceptingSessionCleanRequests() {
 			if !sessionGetsRecycled {
 				bus := session.GetEventBus()
 				bus.Close()
+				w.logStorage.Forget(session.UUID)
 			}
 
 			session.Application.GetEventBus().PublishEvent(models.ApplicationEventTypeSessionCleaned, session.Application)
@@ -13,21 +13,27 @@ import (
 	"github.com/wufe/polo/pkg/execution"
 	"github.com/wufe/polo/pkg/http/net"
 	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/tracing"
 )
 
 type SessionCommandExecution interface {
 	ExecCommand(ctx context.Context, command *models.Command, session *models.Session) error
+	// ExecRuntimeCommand executes a command whose output
+	// goes into the runtime logs of the session
+	ExecRuntimeCommand(ctx context.Context, command *models.Command, session *models.Session) error
 }
 
 type sessionCommandExecutionImpl struct {
 	portRetriever net.PortRetriever
 	commandRunner execution.CommandRunner
+	tracer        *tracing.Tracer
 }
 
-func NewSessionCommandExecution(portRetriever net.PortRetriever, commandRunner execution.CommandRunner) SessionCommandExecution {
+func NewSessionCommandExecution(portRetriever net.PortRetriever, commandRunner execution.CommandRunner, tracer *tracing.Tracer) SessionCommandExecution {
 	return &sessionCommandExecutionImpl{
 		portRetriever: portRetriever,
 		commandRunner: commandRunner,
+		tracer:        tracer,
 	}
 }
 
@@ -38,20 +44,21 @@ func (ce *sessionCommandExecutionImpl) ExecCommand(ctx context.Context, command
 	}
 	session.LogStdin(builtCommand)
 
+	// FEATURE: Tracing
+	ctx, span := ce.tracer.StartChild(ctx, "command")
+	span.SetAttribute("polo.command", session.GetConfiguration().Secrets.Redact(builtCommand))
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	cmdCtx := ctx
 	if command.Timeout > 0 {
 		timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(command.Timeout)*time.Second)
 		defer cancel()
 		cmdCtx = timeoutCtx
 	}
-	cmds := ParseCommandContext(cmdCtx, builtCommand)
-	for _, cmd := range cmds {
-		cmd.Env = append(
-			os.Environ(),
-			command.Environment...,
-		)
-		cmd.Dir = getWorkingDir(session.Folder, command.WorkingDir)
-	}
+	cmds := ce.prepareCmds(cmdCtx, builtCommand, command, session)
 
 	err = ce.commandRunner.ExecCmds(ctx, func(line *execution.StdLine) {
 		if line.Type == execution.StdTypeOut {
@@ -65,9 +72,48 @@ func (ce *sessionCommandExecutionImpl) ExecCommand(ctx context.Context, command
 	return err
 }
 
+// ExecRuntimeCommand executes a command with no timeout,
+// until it exits or the context gets cancelled.
+// Its output is not parsed for variables
+func (ce *sessionCommandExecutionImpl) ExecRuntimeCommand(ctx context.Context, command *models.Command, session *models.Session) error {
+	builtCommand, err := ce.buildCommand(command.Command, session)
+	if err != nil {
+		return err
+	}
+	session.LogStdin(builtCommand)
+
+	cmds := ce.prepareCmds(ctx, builtCommand, command, session)
+
+	return ce.commandRunner.ExecCmds(ctx, func(line *execution.StdLine) {
+		if line.Type == execution.StdTypeOut {
+			session.LogRuntime(line.Line, models.LogTypeStdout)
+		} else {
+			session.LogRuntime(line.Line, models.LogTypeStderr)
+		}
+	}, cmds...)
+}
+
+func (ce *sessionCommandExecutionImpl) prepareCmds(ctx context.Context, builtCommand string, command *models.Command, session *models.Session) []*exec.Cmd {
+	// FEATURE: Secrets
+	variables := session.Variables.WithSecrets(session.GetConfiguration().Secrets)
+	environment := make([]string, 0, len(command.Environment))
+	for _, variable := range command.Environment {
+		environment = append(environment, variables.ApplyTo(variable))
+	}
+	cmds := ParseCommandContext(ctx, builtCommand)
+	for _, cmd := range cmds {
+		cmd.Env = append(
+			os.Environ(),
+			environment...,
+		)
+		cmd.Dir = getWorkingDir(session.Folder, command.WorkingDir)
+	}
+	return cmds
+}
+
 func (ce *sessionCommandExecutionImpl) buildCommand(command string, session *models.Session) (string, error) {
 	ce.addPortsOnDemand(command, session)
-	command = session.Variables.ApplyTo(command)
+	command = session.Variables.WithSecrets(session.GetConfiguration().Secrets).ApplyTo(command)
 	return strings.TrimSpace(command), nil
 }
 
@@ -30,12 +30,13 @@ func (w *SessionDestroyWorker) startAcceptingDestroyRequests() {
 
 			sessionDestroyInput := <-w.mediator.DestroySession.Chan
 			w.DestroySession(sessionDestroyInput.Session, sessionDestroyInput.Callback)
+			close(sessionDestroyInput.Stopping)
 		}
 	}()
 }
 
 func (w *SessionDestroyWorker) DestroySession(session *models.Session, callback func(*models.Session)) {
-	if !session.Status.IsAlive() {
+	if !session.GetStatus().IsAlive() {
 		return
 	}
 
@@ -46,6 +47,8 @@ func (w *SessionDestroyWorker) DestroySession(session *models.Session, callback
 	if _, cancel, ok := session.Context.TryGet(models.SessionBuildContextKey); ok {
 		cancel()
 	}
+	stopSessionRuntime(session)
+	endSessionTrace(session)
 	done := make(chan struct{})
 
 	go func(done chan struct{}) {
@@ -71,12 +71,18 @@ func (w *SessionFilesystemWorker) buildStructureCopying(session *models.Session,
 		}
 	}
 
+	conf := session.GetConfiguration()
+	gitClient := w.gitClient.WithAuth(conf.GetAuth()).WithCheckout(conf.Checkout.Contents())
+
 	session.LogInfo("Performing an hard reset to the selected commit")
-	err := w.gitClient.HardReset(applicationBaseFolder, sessionCommit)
+	err := gitClient.HardReset(applicationBaseFolder, sessionCommit)
 	if err != nil {
 		session.LogError(fmt.Sprintf("Error while performing hard reset: %s", err.Error()))
 		return "", err
 	}
+	if err := w.checkoutContents(session, gitClient, applicationBaseFolder, conf.Checkout); err != nil {
+		return "", err
+	}
 
 	// Copy directories except .git folder
 	session.LogInfo(fmt.Sprintf("Copying files from %s to %s", applicationBaseFolder, sessionCommitFolder))
@@ -100,13 +106,15 @@ func (w *SessionFilesystemWorker) buildStructureCloning(session *models.Session,
 	})
 	conf := session.GetConfiguration()
 	appRemote := conf.Remote
+	// FEATURE: Shallow checkouts
+	gitClient := w.gitClient.WithAuth(conf.GetAuth()).WithCheckout(conf.Checkout)
 
 	sessionCommitFolder := filepath.Join(appFolder, checkout)
 	sessionCommit := session.CommitID
 
 	if _, err := os.Stat(sessionCommitFolder); os.IsNotExist(err) {
 		session.LogInfo(fmt.Sprintf("Cloning from remote %s into %s", appRemote, sessionCommitFolder))
-		err := w.gitClient.Clone(appFolder, checkout, appRemote)
+		err := gitClient.Clone(appFolder, checkout, appRemote)
 		if err != nil {
 			session.LogError(fmt.Sprintf("Error while cloning: %s", err.Error()))
 			return "", err
@@ -114,18 +122,41 @@ func (w *SessionFilesystemWorker) buildStructureCloning(session *models.Session,
 	}
 
 	session.LogInfo("Fetching from remote")
-	err := w.gitClient.FetchAll(sessionCommitFolder)
+	err := gitClient.FetchAll(sessionCommitFolder)
 	if err != nil {
 		session.LogError(fmt.Sprintf("Error while fetching from remote: %s", err.Error()))
 		return "", err
 	}
 
 	session.LogInfo("Performing an hard reset to the selected commit")
-	err = w.gitClient.HardReset(sessionCommitFolder, sessionCommit)
+	err = gitClient.HardReset(sessionCommitFolder, sessionCommit)
 	if err != nil {
 		session.LogError(fmt.Sprintf("Error while performing hard reset: %s", err.Error()))
 		return "", err
 	}
+	if err := w.checkoutContents(session, gitClient, sessionCommitFolder, conf.Checkout); err != nil {
+		return "", err
+	}
 
 	return sessionCommitFolder, nil
 }
+
+// FEATURE: Submodules and LFS
+// checkoutContents checks out the submodules and the LFS objects of the commit, if configured
+func (w *SessionFilesystemWorker) checkoutContents(session *models.Session, gitClient versioning.GitClient, folder string, checkout models.CheckoutConfiguration) error {
+	if checkout.Submodules {
+		session.LogInfo("Updating the submodules")
+		if err := gitClient.UpdateSubmodules(folder); err != nil {
+			session.LogError(fmt.Sprintf("Error while updating the submodules: %s", err.Error()))
+			return fmt.Errorf("could not update the submodules: %s", err.Error())
+		}
+	}
+	if checkout.LFS {
+		session.LogInfo("Checking out the LFS objects")
+		if err := gitClient.CheckoutLFS(folder); err != nil {
+			session.LogError(fmt.Sprintf("Error while checking out the LFS objects: %s", err.Error()))
+			return fmt.Errorf("could not check out the LFS objects: %s", err.Error())
+		}
+	}
+	return nil
+}
@@ -10,18 +10,24 @@ import (
 
 	"github.com/wufe/polo/pkg/background/queues"
 	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/metrics"
 	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/tracing"
 	"github.com/wufe/polo/pkg/utils"
 )
 
 type SessionHealthcheckWorker struct {
 	sessions *utils.ThreadSafeSlice
 	mediator *Mediator
+	metrics  *metrics.Metrics
+	tracer   *tracing.Tracer
 	log      logging.Logger
 }
 
 func NewSessionHealthcheckWorker(
 	mediator *Mediator,
+	metrics *metrics.Metrics,
+	tracer *tracing.Tracer,
 	logger logging.Logger,
 ) *SessionHealthcheckWorker {
 	worker := &SessionHealthcheckWorker{
@@ -29,6 +35,8 @@ func NewSessionHealthcheckWorker(
 			Elements: []interface{}{},
 		},
 		mediator: mediator,
+		metrics:  metrics,
+		tracer:   tracer,
 		log:      logger,
 	}
 	return worker
@@ -97,7 +105,20 @@ func (w *SessionHealthcheckWorker) startHealthchecking(session *models.Session)
 			}
 			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(healthcheck.Timeout)*time.Second)
 			req.WithContext(ctx)
-			err = headers.ApplyTo(req)
+
+			// FEATURE: Tracing
+			// The attempts made while the session is starting are part of the trace of its build
+			var span *tracing.Span
+			if traceContext, ok := getTraceContext(session); ok {
+				_, span = w.tracer.StartChild(traceContext, "healthcheck")
+				span.SetAttribute("http.method", healthcheck.Method)
+				span.SetAttribute("http.url", target.String())
+				span.SetAttribute("polo.healthcheck.attempt", retryCount+1)
+				tracing.Inject(span, req.Header)
+			}
+
+			sessionHeaders := headers.WithVariables(session.Variables.WithSecrets(conf.Secrets))
+			err = sessionHeaders.ApplyTo(req)
 			if err != nil {
 				w.log.Errorf("Error applying headers to the request: %s", err.Error())
 			}
@@ -107,7 +128,16 @@ func (w *SessionHealthcheckWorker) startHealthchecking(session *models.Session)
 			}
 			response, err := client.Do(req)
 			cancel()
-			if err != nil || response.StatusCode != healthcheck.Status {
+			succeeded := err == nil && response.StatusCode == healthcheck.Status
+			w.metrics.ObserveHealthcheck(conf.Name, succeeded)
+			if err == nil {
+				span.SetAttribute("http.status_code", response.StatusCode)
+			}
+			if !succeeded {
+				span.SetError(fmt.Errorf("Session healthcheck failed"))
+			}
+			span.End()
+			if !succeeded {
 				retryCount++
 
 				if session.Status == models.SessionStatusStarted {
@@ -120,6 +150,7 @@ func (w *SessionHealthcheckWorker) startHealthchecking(session *models.Session)
 						session.SetKillReason(models.KillReasonHealthcheckFailed)
 					}
 
+					endSessionTrace(session)
 					session.LogError("Session healthcheck failed. Destroying session")
 					w.mediator.DestroySession.Enqueue(session, nil)
 					w.sessions.Remove(session)
@@ -132,6 +163,7 @@ func (w *SessionHealthcheckWorker) startHealthchecking(session *models.Session)
 			} else {
 				status := session.GetStatus()
 				if status == models.SessionStatusStarting {
+					endSessionTrace(session)
 					session.LogInfo("Session available")
 					session.GetEventBus().PublishEvent(models.SessionEventTypeHealthcheckSucceded, session)
 					session.GetEventBus().PublishEvent(models.SessionEventTypeSessionAvailable, session)
@@ -0,0 +1,184 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wufe/polo/pkg/models"
+)
+
+const (
+	// Interval between the scans of the runtime log files
+	runtimeLogFilesPollInterval = time.Second
+	// Maximum amount of bytes read from a log file found when the tailing starts
+	runtimeLogFilesInitialRead = 1024 * 1024
+)
+
+// SessionRuntimeLogs collects the output of the sessions once started
+// into their runtime logs: the supervised start commands
+// and the log sources configured for the application
+type SessionRuntimeLogs interface {
+	// Supervise starts a command in background, until the session gets stopped
+	Supervise(session *models.Session, command *models.Command)
+	// Tail starts following the log sources, until the session gets stopped
+	Tail(session *models.Session, sources []models.LogSource)
+}
+
+type sessionRuntimeLogsImpl struct {
+	sessionCommandExecution SessionCommandExecution
+}
+
+func NewSessionRuntimeLogs(sessionCommandExecution SessionCommandExecution) SessionRuntimeLogs {
+	return &sessionRuntimeLogsImpl{
+		sessionCommandExecution: sessionCommandExecution,
+	}
+}
+
+func (r *sessionRuntimeLogsImpl) Supervise(session *models.Session, command *models.Command) {
+	session.LogInfo("Supervising command; its output goes into the runtime logs")
+	go r.follow(getRuntimeContext(session), session, command)
+}
+
+func (r *sessionRuntimeLogsImpl) Tail(session *models.Session, sources []models.LogSource) {
+	if len(sources) == 0 {
+		return
+	}
+	ctx := getRuntimeContext(session)
+	for _, source := range sources {
+		if source.File != "" {
+			session.LogInfo(fmt.Sprintf("Tailing runtime logs from files %s", source.File))
+			go r.tailFiles(ctx, session, source.File)
+		} else {
+			session.LogInfo("Tailing runtime logs from command")
+			go r.follow(ctx, session, &models.Command{
+				Command:     source.Command,
+				Environment: source.Environment,
+				WorkingDir:  source.WorkingDir,
+			})
+		}
+	}
+}
+
+func (r *sessionRuntimeLogsImpl) follow(ctx context.Context, session *models.Session, command *models.Command) {
+	err := r.sessionCommandExecution.ExecRuntimeCommand(ctx, command, session)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		session.LogRuntime(fmt.Sprintf("Command exited: %s", err.Error()), models.LogTypeError)
+	} else {
+		session.LogRuntime("Command exited", models.LogTypeWarn)
+	}
+}
+
+// tailFiles polls the files matching the pattern inside the session folder,
+// logging the lines appended to them
+func (r *sessionRuntimeLogsImpl) tailFiles(ctx context.Context, session *models.Session, pattern string) {
+	// Files matched by a glob are told apart by their name
+	prefixed := strings.ContainsAny(pattern, "*?[")
+	tails := make(map[string]*runtimeLogFile)
+	ticker := time.NewTicker(runtimeLogFilesPollInterval)
+	defer ticker.Stop()
+	for {
+		matches, _ := filepath.Glob(filepath.Join(session.Folder, pattern))
+		for _, path := range matches {
+			tail, ok := tails[path]
+			if !ok {
+				name, _ := filepath.Rel(session.Folder, path)
+				tail = &runtimeLogFile{path: path, name: name, offset: -1}
+				tails[path] = tail
+			}
+			for _, line := range tail.readLines() {
+				if prefixed {
+					line = tail.name + ": " + line
+				}
+				session.LogRuntime(line, models.LogTypeStdout)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type runtimeLogFile struct {
+	path    string
+	name    string
+	offset  int64
+	partial string
+}
+
+// readLines retrieves the complete lines appended to the file since the last read.
+// A file being truncated or rotated gets read again from the start
+func (f *runtimeLogFile) readLines() []string {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	size := info.Size()
+	skipFirstLine := false
+	if f.offset < 0 {
+		f.offset = 0
+		if size > runtimeLogFilesInitialRead {
+			f.offset = size - runtimeLogFilesInitialRead
+			skipFirstLine = true
+		}
+	}
+	if size < f.offset {
+		f.offset = 0
+		f.partial = ""
+	}
+	if size == f.offset {
+		return nil
+	}
+	if _, err := file.Seek(f.offset, io.SeekStart); err != nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(file, size-f.offset))
+	if err != nil {
+		return nil
+	}
+	f.offset += int64(len(data))
+
+	lines := strings.Split(f.partial+string(data), "\n")
+	f.partial = lines[len(lines)-1]
+	lines = lines[:len(lines)-1]
+	if skipFirstLine && len(lines) > 0 {
+		lines = lines[1:]
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// getRuntimeContext retrieves the runtime context of the session,
+// creating it if this is the first runtime task of the session
+func getRuntimeContext(session *models.Session) context.Context {
+	ctx, _ := session.Context.GetOrAdd(models.SessionRuntimeContextKey, func() (context.Context, context.CancelFunc) {
+		return context.WithCancel(context.Background())
+	})
+	return ctx
+}
+
+// stopSessionRuntime stops the supervised commands
+// and the tailing of the runtime logs of the session
+func stopSessionRuntime(session *models.Session) {
+	if _, cancel, ok := session.Context.TryGet(models.SessionRuntimeContextKey); ok {
+		cancel()
+		session.Context.Del(models.SessionRuntimeContextKey)
+	}
+}
@@ -1,8 +1,10 @@
 package background
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/wufe/polo/pkg/background/queues"
 	"github.com/wufe/polo/pkg/logging"
 	"github.com/wufe/polo/pkg/models"
 	"github.com/wufe/polo/pkg/storage"
@@ -70,6 +72,30 @@ func (w *SessionStartWorker) MarkSessionAsStarted(session *models.Session) {
 	w.sessionStorage.Update(session)
 
 	session.GetEventBus().PublishEvent(models.SessionEventTypeSessionStarted, session)
+
+	// FEATURE: Stacks
+	// The whole stack gets hot-swapped together:
+	// when a member gets replaced, the entry is rebuilt against the new member
+	if stack := session.GetStack(); len(replaces) > 0 && stack.IsSet() && !stack.Entry {
+		go w.replaceStackEntry(session, stack)
+	}
+}
+
+func (w *SessionStartWorker) replaceStackEntry(member *models.Session, stack models.SessionStack) {
+	for _, entry := range w.sessionStorage.GetAliveStackSessions(stack.Name, stack.Checkout) {
+		entryStack := entry.GetStack()
+		if !entryStack.Entry || entry.GetReplacedBy() != nil || entry.GetStatus() != models.SessionStatusStarted {
+			continue
+		}
+		entry.LogInfo(fmt.Sprintf("Stack member %s has been hot-swapped: replacing the entry", member.UUID))
+		w.mediator.BuildSession.EnqueueInput(&queues.SessionBuildInput{
+			Checkout:             entry.Checkout,
+			Application:          entry.Application,
+			PreviousSession:      entry,
+			SessionsToBeReplaced: []*models.Session{entry},
+			Stack:                entryStack,
+		})
+	}
 }
 
 func (w *SessionStartWorker) startSessionInactivityTimer(session *models.Session) {
@@ -0,0 +1,22 @@
+package background
+
+import (
+	"context"
+
+	"github.com/wufe/polo/pkg/models"
+)
+
+// getTraceContext retrieves the context carrying the trace of the session build,
+// if the session is being traced and not available yet
+func getTraceContext(session *models.Session) (context.Context, bool) {
+	ctx, _, ok := session.Context.TryGet(models.SessionTraceContextKey)
+	return ctx, ok
+}
+
+// endSessionTrace stops adding spans to the trace of the session build
+func endSessionTrace(session *models.Session) {
+	if _, cancel, ok := session.Context.TryGet(models.SessionTraceContextKey); ok {
+		cancel()
+		session.Context.Del(models.SessionTraceContextKey)
+	}
+}
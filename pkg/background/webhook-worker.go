@@ -0,0 +1,243 @@
+package background
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/utils"
+)
+
+const (
+	// Timeout of each delivery attempt of a webhook
+	webhookTimeout = 10 * time.Second
+	// Maximum interval between two delivery attempts of a webhook
+	webhookMaxRetryInterval = 5 * time.Minute
+)
+
+// WebhookWorker notifies the webhooks configured in the applications
+// of the events published by the applications and by their sessions
+type WebhookWorker struct {
+	utils.RWLocker
+	configuration *models.RootConfiguration
+	applications  []*models.Application
+	sessions      map[string]bool
+	client        *http.Client
+	log           logging.Logger
+}
+
+func NewWebhookWorker(
+	configuration *models.RootConfiguration,
+	applications []*models.Application,
+	mutexBuilder utils.MutexBuilder,
+	logger logging.Logger,
+) *WebhookWorker {
+	return &WebhookWorker{
+		RWLocker:      mutexBuilder(),
+		configuration: configuration,
+		applications:  applications,
+		sessions:      make(map[string]bool),
+		client:        &http.Client{Timeout: webhookTimeout},
+		log:           logger,
+	}
+}
+
+func (w *WebhookWorker) Start() {
+	for _, application := range w.applications {
+		w.WatchApplication(application)
+	}
+}
+
+// WatchApplication starts notifying the events of the application
+func (w *WebhookWorker) WatchApplication(application *models.Application) {
+	events, _ := application.GetEventBus().Subscribe()
+	go func() {
+		for event := range events {
+			w.notify(application, event.EventType.String(), payloadSession(event.EventPayload))
+		}
+	}()
+}
+
+// WatchSession starts notifying the events of the session, until it is not alive anymore.
+// It is called by the session build worker before the build of the session starts,
+// so the first events of the build do not get missed
+func (w *WebhookWorker) WatchSession(session *models.Session) {
+	w.Lock()
+	if w.sessions[session.UUID] {
+		w.Unlock()
+		return
+	}
+	w.sessions[session.UUID] = true
+	w.Unlock()
+
+	events, unsubscribe := session.GetEventBus().Subscribe()
+	go func() {
+		defer unsubscribe()
+		for event := range events {
+			switch event.Type {
+			case models.SessionStreamEventTypeEvent:
+				w.notify(session.Application, event.Event.String(), session)
+			case models.SessionStreamEventTypeStatus:
+				if !event.Status.IsAlive() && w.unwatchSession(session) {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// unwatchSession stops following a session which is not alive anymore.
+// A session failing to start may have already been recycled to be built again:
+// in that case it keeps being followed
+func (w *WebhookWorker) unwatchSession(session *models.Session) bool {
+	w.Lock()
+	defer w.Unlock()
+	if session.GetStatus().IsAlive() {
+		return false
+	}
+	delete(w.sessions, session.UUID)
+	return true
+}
+
+// payloadSession retrieves the session an application event refers to, if any
+func payloadSession(payload interface{}) *models.Session {
+	objects, ok := payload.([]interface{})
+	if !ok {
+		return nil
+	}
+	for _, object := range objects {
+		if session, ok := object.(*models.Session); ok {
+			return session
+		}
+	}
+	return nil
+}
+
+func (w *WebhookWorker) notify(application *models.Application, event string, session *models.Session) {
+	if application == nil {
+		return
+	}
+	conf := application.GetConfiguration()
+	var variables models.Variables
+	for _, webhook := range conf.Webhooks {
+		if !webhook.Matches(event) {
+			continue
+		}
+		if variables == nil {
+			variables = models.WebhookVariables(event, conf.Name, session, w.configuration.GetPublicURL(), time.Now().UTC().Format(time.RFC3339))
+		}
+		body, err := buildWebhookPayload(webhook, variables, session != nil)
+		if err != nil {
+			w.log.Errorf("[APP:%s] Could not build the payload of the webhook %s: %s", conf.Name, webhook.URL, err.Error())
+			continue
+		}
+		go w.deliver(conf.Name, webhook, event, body)
+	}
+}
+
+// buildWebhookPayload applies the variables to the payload template of the webhook,
+// escaping them to be placed into JSON strings.
+// Without a template, the payload is a JSON document describing the event
+func buildWebhookPayload(webhook models.Webhook, variables models.Variables, withSession bool) ([]byte, error) {
+	template := webhook.GetPayloadTemplate()
+	if template != "" {
+		escaped := models.Variables{}
+		for key, value := range variables {
+			encoded, _ := json.Marshal(value)
+			escaped[key] = string(encoded[1 : len(encoded)-1])
+		}
+		return []byte(escaped.ApplyTo(template)), nil
+	}
+	payload := map[string]interface{}{
+		"event":       variables["event"],
+		"application": variables["application"],
+		"message":     variables["message"],
+		"timestamp":   variables["timestamp"],
+	}
+	if withSession {
+		payload["session"] = map[string]string{
+			"uuid":        variables["session.uuid"],
+			"name":        variables["session.name"],
+			"checkout":    variables["session.checkout"],
+			"displayName": variables["session.display_name"],
+			"commit":      variables["session.commit"],
+			"variant":     variables["session.variant"],
+			"status":      variables["session.status"],
+			"killReason":  variables["session.kill_reason"],
+			"target":      variables["session.target"],
+			"url":         variables["session.url"],
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// deliver sends the payload to the webhook,
+// retrying with an exponential backoff if the delivery fails
+func (w *WebhookWorker) deliver(application string, webhook models.Webhook, event string, body []byte) {
+	deliveryID := uuid.NewString()
+	interval := time.Duration(webhook.RetryInterval) * time.Second
+	for attempt := 0; ; attempt++ {
+		err := w.send(webhook, event, deliveryID, body)
+		if err == nil {
+			w.log.Debugf("[APP:%s] Webhook %s notified of %s", application, webhook.URL, event)
+			return
+		}
+		if attempt >= webhook.MaxRetries {
+			w.log.Errorf("[APP:%s] Could not notify webhook %s of %s: %s", application, webhook.URL, event, err.Error())
+			return
+		}
+		w.log.Warnf("[APP:%s] Could not notify webhook %s of %s: %s. Retrying in %s", application, webhook.URL, event, err.Error(), interval)
+		time.Sleep(interval)
+		interval *= 2
+		if interval > webhookMaxRetryInterval {
+			interval = webhookMaxRetryInterval
+		}
+	}
+}
+
+func (w *WebhookWorker) send(webhook models.Webhook, event string, deliveryID string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Polo-Webhook")
+	req.Header.Set("X-Polo-Event", event)
+	req.Header.Set("X-Polo-Delivery", deliveryID)
+	if webhook.Secret != "" {
+		req.Header.Set("X-Polo-Signature", SignWebhookPayload(webhook.Secret, body))
+	}
+	for _, header := range webhook.Headers {
+		if name, value, err := header.Parse(); err == nil {
+			req.Header.Set(name, value)
+		}
+	}
+	response, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("received status code %d", response.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhookPayload computes the signature of a webhook payload,
+// sent in the X-Polo-Signature header (i.e. "sha256=<hex HMAC>")
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/wufe/polo/pkg/client"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/storage"
+)
+
+const usage = `Usage: polo [-host URL] [-dir FOLDER] [-json] <command> [arguments]
+       polo -schema
+
+The -schema flag prints the JSON Schema of the configuration files.
+
+The running instance is found through the -host flag, the POLO_HOST environment variable
+or the .host file written by the server in -dir, in the folder of the executable
+or in the working directory.
+
+Commands:
+  apps                              List the applications
+  sessions [-app NAME]              List the alive sessions
+  start [-variant V] [-param K=V] <app> <checkout>
+                                    Build a new session
+  stop <uuid|alias>                 Destroy a session
+  restart <uuid|alias>              Destroy a session and build it again
+  logs [-f] [-runtime] <uuid|alias> Print the logs of a session
+  fetch [-watch] [-wait] [-status] <app>
+                                    Fetch the remote of an application; -watch builds the new
+                                    commits of the watched branches, -wait prints the outcome
+                                    of the fetch and -status the one of the last fetch
+  config <app>                      Print the configured values of an application
+                                    and the template or application defining them
+  open [-print] <uuid|alias>        Open a session in the browser
+  validate [-strict] [-remote] [file|folder|glob...]
+                                    Validate the configuration files (by default those listed
+                                    by POLO_CONFIG, in -dir or in the working directory) or,
+                                    with -remote, those of the running instance;
+                                    -strict fails on warnings too
+`
+
+var (
+	errUsage                = errors.New("invalid usage")
+	errInvalidConfiguration = errors.New("the configuration is not valid")
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	client *client.Client
+	dir    string
+	json   bool
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Run executes the CLI with the given arguments, returning the exit code
+func Run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("polo", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	host := flags.String("host", os.Getenv("POLO_HOST"), "URL of the running instance")
+	dir := flags.String("dir", "", "Folder containing the .host file of the running instance")
+	jsonOutput := flags.Bool("json", false, "Print the output as JSON")
+	schema := flags.Bool("schema", false, "Print the JSON Schema of the configuration files")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *schema {
+		c := &command{stdout: stdout}
+		c.printJSON(models.ConfigurationSchema())
+		return 0
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	name, commandArgs := flags.Arg(0), flags.Args()[1:]
+
+	// Validating local files does not require a running instance
+	if *host == "" && name != "validate" {
+		instance, err := detectInstance(*dir)
+		if err != nil {
+			fmt.Fprintln(stderr, "No running instance found; use -host or POLO_HOST to point to one")
+			return 1
+		}
+		*host = instance.Host
+	}
+
+	c := &command{
+		dir:    *dir,
+		json:   *jsonOutput,
+		stdout: stdout,
+		stderr: stderr,
+	}
+	if *host != "" {
+		c.client = client.NewClient(*host)
+	}
+
+	commands := map[string]func([]string) error{
+		"apps":     c.apps,
+		"sessions": c.sessions,
+		"start":    c.start,
+		"stop":     c.stop,
+		"restart":  c.restart,
+		"logs":     c.logs,
+		"fetch":    c.fetch,
+		"config":   c.config,
+		"open":     c.open,
+		"validate": c.validate,
+	}
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q\n\n", name)
+		flags.Usage()
+		return 2
+	}
+	if err := run(commandArgs); err != nil {
+		if err == errUsage {
+			flags.Usage()
+			return 2
+		}
+		fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+// detectInstance looks for the .host file in the given folder,
+// in the folder of the executable and in the working directory
+func detectInstance(dir string) (*storage.Instance, error) {
+	folders := []string{}
+	if dir != "" {
+		folders = append(folders, dir)
+	} else {
+		if executable, err := os.Executable(); err == nil {
+			folders = append(folders, filepath.Dir(executable))
+		}
+		if wd, err := os.Getwd(); err == nil {
+			folders = append(folders, wd)
+		}
+	}
+	var err error
+	for _, folder := range folders {
+		var instance *storage.Instance
+		if instance, err = storage.DetectInstanceInFolder(folder); err == nil {
+			return instance, nil
+		}
+	}
+	return nil, err
+}
+
+func (c *command) printJSON(obj interface{}) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(obj)
+}
+
+func (c *command) printTable(header []string, rows [][]string) {
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// parseFlags parses the flags of a subcommand, requiring the given number of arguments
+func parseFlags(flags *flag.FlagSet, args []string, required int) error {
+	flags.SetOutput(ioutil.Discard)
+	if err := flags.Parse(args); err != nil || flags.NArg() != required {
+		return errUsage
+	}
+	return nil
+}
+
+// parameters collects the repeated -param flags
+type parameters map[string]string
+
+func (p parameters) String() string {
+	keys := []string{}
+	for key, value := range p {
+		keys = append(keys, key+"="+value)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func (p parameters) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("%q is not in the form name=value", value)
+	}
+	p[kv[0]] = kv[1]
+	return nil
+}
@@ -0,0 +1,439 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wufe/polo/pkg/client"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/models/output"
+	"github.com/wufe/polo/pkg/storage"
+	"github.com/wufe/polo/pkg/utils"
+)
+
+// fetchPollInterval is the interval the state of a fetch gets checked at, waiting for its outcome
+const fetchPollInterval = 500 * time.Millisecond
+
+func (c *command) apps(args []string) error {
+	if err := parseFlags(flag.NewFlagSet("apps", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	status, err := c.client.GetStatus()
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(status.Applications)
+	}
+	rows := [][]string{}
+	for _, application := range status.Applications {
+		sessions := 0
+		for _, session := range status.Sessions {
+			if session.ApplicationName == application.Configuration.Name {
+				sessions++
+			}
+		}
+		isDefault := ""
+		if application.Configuration.IsDefault {
+			isDefault = "*"
+		}
+		rows = append(rows, []string{
+			application.Configuration.Name,
+			application.Status,
+			isDefault,
+			strconv.Itoa(sessions),
+			application.Configuration.Remote,
+			application.Filename,
+		})
+	}
+	c.printTable([]string{"NAME", "STATUS", "DEFAULT", "SESSIONS", "REMOTE", "FILE"}, rows)
+	return nil
+}
+
+func (c *command) sessions(args []string) error {
+	flags := flag.NewFlagSet("sessions", flag.ContinueOnError)
+	app := flags.String("app", "", "Application name")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	status, err := c.client.GetStatus()
+	if err != nil {
+		return err
+	}
+	sessions := []output.Session{}
+	for _, session := range status.Sessions {
+		if *app == "" || session.ApplicationName == *app {
+			sessions = append(sessions, session)
+		}
+	}
+	if c.json {
+		return c.printJSON(sessions)
+	}
+	rows := [][]string{}
+	for _, session := range sessions {
+		rows = append(rows, []string{
+			shortUUID(session.UUID),
+			session.Alias,
+			session.ApplicationName,
+			session.Checkout,
+			session.Variant,
+			session.Status,
+			c.client.URL(sessionURL(session)),
+		})
+	}
+	c.printTable([]string{"UUID", "ALIAS", "APPLICATION", "CHECKOUT", "VARIANT", "STATUS", "URL"}, rows)
+	return nil
+}
+
+func (c *command) start(args []string) error {
+	flags := flag.NewFlagSet("start", flag.ContinueOnError)
+	variant := flags.String("variant", "", "Variant of the session")
+	params := parameters{}
+	flags.Var(params, "param", "Build parameter, as name=value (repeatable)")
+	if err := parseFlags(flags, args, 2); err != nil {
+		return err
+	}
+	session, err := c.client.StartSession(client.StartSessionInput{
+		ApplicationName: flags.Arg(0),
+		Checkout:        flags.Arg(1),
+		Variant:         *variant,
+		Parameters:      params,
+	})
+	if err == client.ErrNotFound {
+		return fmt.Errorf("application %s not found", flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	return c.printSession(session)
+}
+
+func (c *command) stop(args []string) error {
+	flags := flag.NewFlagSet("stop", flag.ContinueOnError)
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	session, err := c.client.FindSession(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := c.client.StopSession(session.UUID); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(map[string]string{"uuid": session.UUID, "status": "stopping"})
+	}
+	fmt.Fprintf(c.stdout, "Stopping %s (%s)\n", session.Alias, session.UUID)
+	return nil
+}
+
+func (c *command) restart(args []string) error {
+	flags := flag.NewFlagSet("restart", flag.ContinueOnError)
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	session, err := c.client.FindSession(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := c.client.StopSession(session.UUID); err != nil {
+		return err
+	}
+	restarted, err := c.client.StartSession(client.StartSessionInput{
+		ApplicationName: session.ApplicationName,
+		Checkout:        session.Checkout,
+		Variant:         session.Variant,
+		Parameters:      session.Parameters,
+	})
+	if err != nil {
+		return err
+	}
+	return c.printSession(restarted)
+}
+
+func (c *command) logs(args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	follow := flags.Bool("f", false, "Follow the logs until the session is alive")
+	runtimeLogs := flags.Bool("runtime", false, "Print the output of the application instead of the build")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	session, err := c.client.FindSession(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	channel := "build"
+	if *runtimeLogs {
+		channel = "runtime"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return c.client.StreamSession(ctx, session.UUID, channel, *follow, func(event client.StreamEvent) {
+		if c.json {
+			c.printJSON(streamEventOutput(event))
+			return
+		}
+		switch event.Type {
+		case "log":
+			fmt.Fprintf(c.stdout, "%s [%s] %s\n", event.Log.When.Local().Format(time.RFC3339), event.Log.Type, event.Log.Message)
+		case "status":
+			if *follow {
+				fmt.Fprintf(c.stderr, "Session is %s\n", event.Status)
+			}
+		}
+	})
+}
+
+// fetch requests a fetch of the remote of an application, waiting for its outcome with -wait,
+// or prints the outcome of the last one with -status
+func (c *command) fetch(args []string) error {
+	flags := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	watch := flags.Bool("watch", false, "Build the new commits of the watched branches too")
+	wait := flags.Bool("wait", false, "Wait for the fetch to complete and print its outcome")
+	statusOnly := flags.Bool("status", false, "Print the outcome of the last fetch")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	app := flags.Arg(0)
+	previous, err := c.client.GetApplicationFetch(app)
+	if err == client.ErrNotFound {
+		return fmt.Errorf("application %s not found", app)
+	}
+	if err != nil {
+		return err
+	}
+	if *statusOnly {
+		return c.printFetch(app, previous)
+	}
+	if err := c.client.FetchApplication(app, *watch); err != nil {
+		return err
+	}
+	if !*wait {
+		if c.json {
+			return c.printJSON(map[string]string{"application": app, "fetch": "requested"})
+		}
+		fmt.Fprintf(c.stdout, "Fetch of %s requested\n", app)
+		return nil
+	}
+	for {
+		time.Sleep(fetchPollInterval)
+		status, err := c.client.GetApplicationFetch(app)
+		if err != nil {
+			return err
+		}
+		if status.State == string(models.ApplicationFetchStateIdle) && status.Last != nil &&
+			(previous.Last == nil || status.Last.StartedAt.After(previous.Last.StartedAt)) {
+			return c.printFetch(app, status)
+		}
+	}
+}
+
+func (c *command) printFetch(app string, status *output.ApplicationFetchStatus) error {
+	if c.json {
+		return c.printJSON(status)
+	}
+	last := status.Last
+	if last == nil {
+		fmt.Fprintf(c.stdout, "%s has not been fetched yet (%s)\n", app, status.State)
+		return nil
+	}
+	fmt.Fprintf(c.stdout, "Last fetch of %s %s at %s in %s, %d new commits (%s)\n",
+		app, last.Result, last.StartedAt.Local().Format(time.RFC3339), time.Duration(last.Duration)*time.Millisecond, last.NewCommitsCount, status.State)
+	for _, commit := range last.NewCommits {
+		fmt.Fprintf(c.stdout, "  %s %s (%s)\n", shortUUID(commit.Hash), firstLine(commit.Message), commit.Author)
+	}
+	for _, err := range last.Errors {
+		fmt.Fprintf(c.stdout, "Error: %s\n", firstLine(err))
+	}
+	return nil
+}
+
+// config prints the configured values of an application along with
+// the template or the application defining them
+func (c *command) config(args []string) error {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	app := flags.Arg(0)
+	status, err := c.client.GetStatus()
+	if err != nil {
+		return err
+	}
+	for _, application := range status.Applications {
+		if application.Configuration.Name != app {
+			continue
+		}
+		provenance := application.Configuration.Provenance
+		if c.json {
+			return c.printJSON(provenance)
+		}
+		paths := make([]string, 0, len(provenance))
+		for path := range provenance {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		rows := [][]string{}
+		for _, path := range paths {
+			rows = append(rows, []string{path, provenance[path].Value, provenance[path].Source})
+		}
+		c.printTable([]string{"KEY", "VALUE", "SOURCE"}, rows)
+		fmt.Fprintln(c.stdout, "The keys not listed have their default value")
+		return nil
+	}
+	return fmt.Errorf("application %s not found", app)
+}
+
+func (c *command) open(args []string) error {
+	flags := flag.NewFlagSet("open", flag.ContinueOnError)
+	printOnly := flags.Bool("print", false, "Print the URL without opening the browser")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	session, err := c.client.FindSession(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	url := c.client.URL(sessionURL(*session))
+	if c.json {
+		return c.printJSON(map[string]string{"uuid": session.UUID, "url": url})
+	}
+	if *printOnly {
+		fmt.Fprintln(c.stdout, url)
+		return nil
+	}
+	return openBrowser(url)
+}
+
+func (c *command) validate(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	strict := flags.Bool("strict", false, "Fail on warnings too")
+	remote := flags.Bool("remote", false, "Validate the configuration files of the running instance")
+	if err := flags.Parse(args); err != nil || (*remote && flags.NArg() > 0) {
+		return errUsage
+	}
+
+	var validation models.ConfigurationValidation
+	if *remote {
+		if c.client == nil {
+			instance, err := detectInstance(c.dir)
+			if err != nil {
+				return errors.New("no running instance found; use -host or POLO_HOST to point to one")
+			}
+			c.client = client.NewClient(instance.Host)
+		}
+		remoteValidation, err := c.client.ValidateConfiguration()
+		if err != nil {
+			return err
+		}
+		validation = *remoteValidation
+	} else {
+		paths := flags.Args()
+		if len(paths) == 0 {
+			paths = utils.ConfigurationPathsFromEnvironment()
+		}
+		folder := c.dir
+		if folder == "" {
+			folder = "."
+		}
+		files, issues := storage.ReadConfigurationFiles(paths, folder)
+		if len(files) == 0 && len(issues) == 0 {
+			if len(paths) == 0 {
+				paths = []string{folder}
+			}
+			return fmt.Errorf("no configuration file found in %s", strings.Join(paths, ", "))
+		}
+		validation = models.NewConfigurationValidation(append(issues, storage.ValidateConfigurations(files, nil)...))
+	}
+
+	if c.json {
+		if err := c.printJSON(validation); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range validation.Issues {
+			fmt.Fprintln(c.stdout, issue.String())
+		}
+		fmt.Fprintf(c.stdout, "%d errors, %d warnings\n", validation.Errors, validation.Warnings)
+	}
+	if !validation.Valid || (*strict && validation.Warnings > 0) {
+		return errInvalidConfiguration
+	}
+	return nil
+}
+
+func (c *command) printSession(session *output.Session) error {
+	if c.json {
+		return c.printJSON(session)
+	}
+	fmt.Fprintf(c.stdout, "Session %s (%s) of %s on %s is %s\n", session.Alias, session.UUID, session.ApplicationName, session.Checkout, session.Status)
+	fmt.Fprintln(c.stdout, c.client.URL(sessionURL(*session)))
+	return nil
+}
+
+// sessionURL retrieves the path a session is reachable at:
+// its smart URL if its application is the default one, its permalink otherwise
+func sessionURL(session output.Session) string {
+	if session.SmartURL != "" {
+		return session.SmartURL + "/"
+	}
+	return session.Permalink
+}
+
+func firstLine(text string) string {
+	return strings.SplitN(strings.TrimSpace(text), "\n", 2)[0]
+}
+
+func shortUUID(uuid string) string {
+	if len(uuid) > 8 {
+		return uuid[:8]
+	}
+	return uuid
+}
+
+func streamEventOutput(event client.StreamEvent) interface{} {
+	switch event.Type {
+	case "log":
+		return map[string]interface{}{"type": event.Type, "log": event.Log}
+	case "event":
+		return map[string]string{"type": event.Type, "event": event.Event, "status": event.Status}
+	}
+	return map[string]string{"type": event.Type, "status": event.Status}
+}
+
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
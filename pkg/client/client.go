@@ -0,0 +1,265 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/models/output"
+)
+
+const requestTimeout = 30 * time.Second
+
+var (
+	ErrNotFound        error = errors.New("Not found")
+	ErrSessionNotFound error = errors.New("Session not found")
+)
+
+// Client is a client of the REST API of a running Polo instance
+type Client struct {
+	host string
+	http *http.Client
+}
+
+func NewClient(host string) *Client {
+	return &Client{
+		host: strings.TrimSuffix(host, "/"),
+		http: &http.Client{},
+	}
+}
+
+// Status is the state of the applications and of the sessions of the instance
+type Status struct {
+	Applications []output.Application `json:"applications"`
+	Sessions     []output.Session     `json:"sessions"`
+	Failures     struct {
+		Acknowledged   []output.Session `json:"acknowledged"`
+		Unacknowledged []output.Session `json:"unacknowledged"`
+	} `json:"failures"`
+}
+
+// StartSessionInput describes the session to be built
+type StartSessionInput struct {
+	Checkout        string            `json:"checkout"`
+	ApplicationName string            `json:"applicationName"`
+	Parameters      map[string]string `json:"parameters,omitempty"`
+	Variant         string            `json:"variant,omitempty"`
+}
+
+// StreamEvent is an event of the stream of a session:
+// a log, a status transition or a lifetime event
+type StreamEvent struct {
+	Type   string
+	Log    output.SessionLog
+	Status string
+	Event  string
+}
+
+type response struct {
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+	Reason  json.RawMessage `json:"reason"`
+}
+
+// URL builds the absolute URL of a path of the instance
+func (c *Client) URL(path string) string {
+	return c.host + path
+}
+
+func (c *Client) GetStatus() (*Status, error) {
+	status := &Status{}
+	if err := c.do(http.MethodGet, "/_polo_/api/status", nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (c *Client) StartSession(input StartSessionInput) (*output.Session, error) {
+	session := &output.Session{}
+	if err := c.do(http.MethodPost, "/_polo_/api/session/", input, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (c *Client) StopSession(uuid string) error {
+	return c.do(http.MethodDelete, "/_polo_/api/session/"+url.PathEscape(uuid), nil, nil)
+}
+
+// FetchApplication requests for the remote of an application to be fetched;
+// with watch, the watched branches get evaluated too
+func (c *Client) FetchApplication(name string, watch bool) error {
+	path := "/_polo_/api/applications/" + url.PathEscape(name) + "/fetch"
+	if watch {
+		path += "?watch=true"
+	}
+	return c.do(http.MethodPost, path, nil, nil)
+}
+
+// GetApplicationFetch retrieves the state of the fetches of an application and the outcome of the last one
+func (c *Client) GetApplicationFetch(name string) (*output.ApplicationFetchStatus, error) {
+	status := &output.ApplicationFetchStatus{}
+	if err := c.do(http.MethodGet, "/_polo_/api/applications/"+url.PathEscape(name)+"/fetch", nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// ValidateConfiguration validates the configuration files of the instance
+func (c *Client) ValidateConfiguration() (*models.ConfigurationValidation, error) {
+	validation := &models.ConfigurationValidation{}
+	if err := c.do(http.MethodGet, "/_polo_/api/configuration/validate", nil, validation); err != nil {
+		return nil, err
+	}
+	return validation, nil
+}
+
+// FindSession looks for an alive session by UUID, alias or UUID prefix
+func (c *Client) FindSession(identifier string) (*output.Session, error) {
+	status, err := c.GetStatus()
+	if err != nil {
+		return nil, err
+	}
+	var found *output.Session
+	for i, session := range status.Sessions {
+		if session.UUID == identifier || session.Alias == identifier {
+			return &status.Sessions[i], nil
+		}
+		if strings.HasPrefix(session.UUID, identifier) {
+			if found != nil {
+				return nil, fmt.Errorf("%q matches more than one session", identifier)
+			}
+			found = &status.Sessions[i]
+		}
+	}
+	if found == nil {
+		return nil, ErrSessionNotFound
+	}
+	return found, nil
+}
+
+// StreamSession follows the logs of a channel ("build" or "runtime") and the status of a session,
+// calling onEvent for each of them until the session is not alive anymore,
+// if follow is set, or until the stored logs have been read otherwise
+func (c *Client) StreamSession(ctx context.Context, uuid string, channel string, follow bool, onEvent func(StreamEvent)) error {
+	query := url.Values{}
+	if channel != "" {
+		query.Set("channel", channel)
+	}
+	req, err := http.NewRequest(http.MethodGet, c.URL("/_polo_/api/session/"+url.PathEscape(uuid)+"/stream?"+query.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return c.parseError(res)
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var eventType string
+	var data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && eventType != "":
+			event := StreamEvent{Type: eventType}
+			var payload struct {
+				Status string `json:"status"`
+				Type   string `json:"type"`
+			}
+			switch eventType {
+			case "log":
+				json.Unmarshal([]byte(data), &event.Log)
+			case "status":
+				json.Unmarshal([]byte(data), &payload)
+				event.Status = payload.Status
+			case "event":
+				json.Unmarshal([]byte(data), &payload)
+				event.Event = payload.Type
+				event.Status = payload.Status
+			}
+			onEvent(event)
+			// The first status follows the stored logs
+			if eventType == "status" && !follow {
+				return nil
+			}
+			eventType, data = "", ""
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Client) do(method string, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, c.URL(path), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	res, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return c.parseError(res)
+	}
+	if result == nil {
+		return nil
+	}
+	decoded := &response{}
+	if err := json.NewDecoder(res.Body).Decode(decoded); err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded.Result, result)
+}
+
+func (c *Client) parseError(res *http.Response) error {
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	decoded := &response{}
+	if err := json.NewDecoder(res.Body).Decode(decoded); err != nil {
+		return fmt.Errorf("received status code %d", res.StatusCode)
+	}
+	var reason string
+	if json.Unmarshal(decoded.Reason, &reason) == nil && reason != "" {
+		return errors.New(reason)
+	}
+	if decoded.Message != "" {
+		return errors.New(decoded.Message)
+	}
+	return fmt.Errorf("received status code %d", res.StatusCode)
+}
@@ -0,0 +1,148 @@
+package forge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/wufe/polo/pkg/models"
+)
+
+const (
+	CommitStatePending CommitState = "pending"
+	CommitStateSuccess CommitState = "success"
+	CommitStateFailure CommitState = "failure"
+
+	requestTimeout = 10 * time.Second
+)
+
+// CommitState is the state of a commit status, as named by GitHub and Gitea
+type CommitState string
+
+// CommitStatus is the status of a commit shown by the forge next to the pull request
+type CommitStatus struct {
+	State       CommitState
+	TargetURL   string
+	Description string
+	Context     string
+}
+
+// Client is a client of the API of a forge (GitHub, GitLab, Gitea)
+type Client interface {
+	SetCommitStatus(commit string, status CommitStatus) error
+}
+
+// NewClient builds the client of the forge configured to receive the commit statuses
+func NewClient(configuration models.CommitStatusConfiguration) (Client, error) {
+	base := &client{
+		http:       &http.Client{Timeout: requestTimeout},
+		url:        configuration.URL,
+		token:      configuration.Token,
+		repository: configuration.Repository,
+	}
+	switch configuration.Provider {
+	case models.CommitStatusProviderGitHub:
+		return &gitHubClient{base}, nil
+	case models.CommitStatusProviderGitLab:
+		return &gitLabClient{base}, nil
+	case models.CommitStatusProviderGitea:
+		return &giteaClient{base}, nil
+	}
+	return nil, fmt.Errorf("unknown forge provider %q", configuration.Provider)
+}
+
+type client struct {
+	http       *http.Client
+	url        string
+	token      string
+	repository string
+}
+
+func (c *client) post(path string, body interface{}, authorization func(*http.Request)) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.url+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		authorization(req)
+	}
+	response, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with status code %d", req.URL.Host, response.StatusCode)
+	}
+	return nil
+}
+
+type statusRequest struct {
+	State       CommitState `json:"state"`
+	TargetURL   string      `json:"target_url,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Context     string      `json:"context"`
+}
+
+type gitHubClient struct {
+	*client
+}
+
+func (c *gitHubClient) SetCommitStatus(commit string, status CommitStatus) error {
+	return c.post(
+		fmt.Sprintf("/repos/%s/statuses/%s", c.repository, commit),
+		statusRequest{status.State, status.TargetURL, status.Description, status.Context},
+		func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		},
+	)
+}
+
+type giteaClient struct {
+	*client
+}
+
+func (c *giteaClient) SetCommitStatus(commit string, status CommitStatus) error {
+	return c.post(
+		fmt.Sprintf("/api/v1/repos/%s/statuses/%s", c.repository, commit),
+		statusRequest{status.State, status.TargetURL, status.Description, status.Context},
+		func(req *http.Request) {
+			req.Header.Set("Authorization", "token "+c.token)
+		},
+	)
+}
+
+type gitLabClient struct {
+	*client
+}
+
+func (c *gitLabClient) SetCommitStatus(commit string, status CommitStatus) error {
+	state := string(status.State)
+	if status.State == CommitStateFailure {
+		state = "failed"
+	}
+	return c.post(
+		fmt.Sprintf("/api/v4/projects/%s/statuses/%s", url.PathEscape(c.repository), commit),
+		map[string]string{
+			"state":       state,
+			"target_url":  status.TargetURL,
+			"description": status.Description,
+			"name":        status.Context,
+		},
+		func(req *http.Request) {
+			req.Header.Set("PRIVATE-TOKEN", c.token)
+		},
+	)
+}
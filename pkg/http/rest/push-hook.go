@@ -0,0 +1,303 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/wufe/polo/pkg/services"
+)
+
+// Maximum size of the body of a push hook
+const pushHookMaxBodySize = 5 << 20
+
+const zeroCommit = "0000000000000000000000000000000000000000"
+
+var (
+	errPushHookUnknownProvider  = errors.New("Unknown push hook provider")
+	errPushHookInvalidSignature = errors.New("Invalid signature")
+	errPushHookUnsupportedEvent = errors.New("Unsupported event")
+	errPushHookMalformedPayload = errors.New("Malformed payload")
+	errPushHookNotEnabled       = errors.New("Push hooks are not enabled for this application")
+)
+
+type pushHookProvider string
+
+const (
+	pushHookProviderGitHub    pushHookProvider = "github"
+	pushHookProviderGitLab    pushHookProvider = "gitlab"
+	pushHookProviderGitea     pushHookProvider = "gitea"
+	pushHookProviderBitbucket pushHookProvider = "bitbucket"
+)
+
+// pushHook is a push notification received from a git provider
+type pushHook struct {
+	provider pushHookProvider
+	event    string
+	body     []byte
+	header   http.Header
+}
+
+// PushHookResponseObject describes the fetch triggered by a push hook
+type PushHookResponseObject struct {
+	Provider string   `json:"provider"`
+	Event    string   `json:"event"`
+	Fetched  bool     `json:"fetched"`
+	Refs     []string `json:"refs"` // Empty if all the refs are fetched
+}
+
+// receivePushHook handles the push notifications sent by GitHub, GitLab, Gitea and Bitbucket,
+// triggering an immediate fetch of the application.
+// The provider is detected by its event header; the payload must be signed
+// with the secret set in application.fetch.hook.secret
+// (GitLab sends the secret itself as token).
+// If application.fetch.hook.only_pushed_ref is set, only the pushed refs get fetched,
+// unless one of them has been deleted
+func (h *Handler) receivePushHook(query *services.QueryService, req *services.RequestService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+
+		app := p.ByName("app")
+		application := query.GetApplication(app)
+		if application == nil {
+			write(h.notFound())
+			return
+		}
+		conf := application.GetConfiguration().Fetch.Hook
+		if !conf.IsEnabled() {
+			write(h.buildResponse(ResponseObjectWithFailingReason{
+				ResponseObject{"Not found"},
+				errPushHookNotEnabled.Error(),
+			}, 404))
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, pushHookMaxBodySize))
+		if err != nil {
+			write(h.badRequestWithReason(err.Error()))
+			return
+		}
+		hook, err := newPushHook(r.Header, body)
+		if err != nil {
+			write(h.badRequestWithReason(err.Error()))
+			return
+		}
+		if !hook.verify(conf.Secret) {
+			h.log.Warnf("[APP:%s] Received a %s push hook with an invalid signature", app, hook.provider)
+			write(h.buildResponse(ResponseObjectWithFailingReason{
+				ResponseObject{"Unauthorized"},
+				errPushHookInvalidSignature.Error(),
+			}, 401))
+			return
+		}
+
+		response := PushHookResponseObject{
+			Provider: string(hook.provider),
+			Event:    hook.event,
+			Refs:     []string{},
+		}
+		// The test notification sent when the hook gets created
+		if hook.isPing() {
+			write(h.ok(response))
+			return
+		}
+		refs, deleted, err := hook.pushedRefs()
+		if err == errPushHookUnsupportedEvent {
+			// Other events may be sent by a hook subscribed to all of them
+			write(h.ok(response))
+			return
+		}
+		if err != nil {
+			write(h.badRequestWithReason(err.Error()))
+			return
+		}
+		// A deleted ref has to be pruned, so everything gets fetched
+		if !conf.OnlyPushedRef || deleted {
+			refs = nil
+		}
+
+		h.log.Infof("[APP:%s] Received a %s push hook: fetching", app, hook.provider)
+		if err := req.FetchApplication(app, refs, true); err != nil {
+			switch err {
+			case services.ErrApplicationNotFound:
+				write(h.notFound())
+			case services.ErrApplicationNotReady:
+				write(h.buildResponse(ResponseObjectWithFailingReason{
+					ResponseObject{"Service unavailable"},
+					err.Error(),
+				}, 503))
+			default:
+				write(h.serverError(err.Error()))
+			}
+			return
+		}
+
+		response.Fetched = true
+		if refs != nil {
+			response.Refs = refs
+		}
+		write(h.buildResponse(ResponseObjectWithResult{
+			ResponseObject{"Accepted"},
+			response,
+		}, 202))
+	}
+}
+
+func newPushHook(header http.Header, body []byte) (*pushHook, error) {
+	hook := &pushHook{body: body, header: header}
+	// Gitea sends the GitHub headers too, so it has to be detected first
+	switch {
+	case header.Get("X-Gitea-Event") != "":
+		hook.provider = pushHookProviderGitea
+		hook.event = header.Get("X-Gitea-Event")
+	case header.Get("X-GitHub-Event") != "":
+		hook.provider = pushHookProviderGitHub
+		hook.event = header.Get("X-GitHub-Event")
+	case header.Get("X-Gitlab-Event") != "":
+		hook.provider = pushHookProviderGitLab
+		hook.event = header.Get("X-Gitlab-Event")
+	case header.Get("X-Event-Key") != "":
+		hook.provider = pushHookProviderBitbucket
+		hook.event = header.Get("X-Event-Key")
+	default:
+		return nil, errPushHookUnknownProvider
+	}
+	return hook, nil
+}
+
+// verify checks the signature of the payload against the secret
+func (hook *pushHook) verify(secret string) bool {
+	switch hook.provider {
+	case pushHookProviderGitLab:
+		token := hook.header.Get("X-Gitlab-Token")
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	case pushHookProviderGitea:
+		return verifyHMAC(secret, hook.body, hook.header.Get("X-Gitea-Signature"))
+	case pushHookProviderGitHub:
+		return verifyHMAC(secret, hook.body, strings.TrimPrefix(hook.header.Get("X-Hub-Signature-256"), "sha256="))
+	case pushHookProviderBitbucket:
+		return verifyHMAC(secret, hook.body, strings.TrimPrefix(hook.header.Get("X-Hub-Signature"), "sha256="))
+	}
+	return false
+}
+
+func verifyHMAC(secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// isPing tells whether the hook is the test notification sent when the webhook gets created
+func (hook *pushHook) isPing() bool {
+	return (hook.provider == pushHookProviderGitHub && hook.event == "ping") ||
+		(hook.provider == pushHookProviderBitbucket && hook.event == "diagnostics:ping")
+}
+
+// pushedRefs retrieves the full names of the refs pushed (e.g. refs/heads/main)
+// and whether any of them has been deleted
+func (hook *pushHook) pushedRefs() (refs []string, deleted bool, err error) {
+	switch hook.provider {
+	case pushHookProviderGitHub, pushHookProviderGitea:
+		if hook.event != "push" {
+			return nil, false, errPushHookUnsupportedEvent
+		}
+		return parseRefPush(hook.body)
+	case pushHookProviderGitLab:
+		if hook.event != "Push Hook" && hook.event != "Tag Push Hook" {
+			return nil, false, errPushHookUnsupportedEvent
+		}
+		return parseRefPush(hook.body)
+	case pushHookProviderBitbucket:
+		switch hook.event {
+		case "repo:push":
+			return parseBitbucketCloudPush(hook.body)
+		case "repo:refs_changed":
+			return parseBitbucketServerPush(hook.body)
+		}
+		return nil, false, errPushHookUnsupportedEvent
+	}
+	return nil, false, errPushHookUnknownProvider
+}
+
+// parseRefPush parses the push payloads of GitHub, GitLab and Gitea
+func parseRefPush(body []byte) ([]string, bool, error) {
+	var payload struct {
+		Ref     string `json:"ref"`
+		After   string `json:"after"`
+		Deleted bool   `json:"deleted"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Ref == "" {
+		return nil, false, errPushHookMalformedPayload
+	}
+	deleted := payload.Deleted || payload.After == zeroCommit
+	return []string{payload.Ref}, deleted, nil
+}
+
+func parseBitbucketCloudPush(body []byte) ([]string, bool, error) {
+	var payload struct {
+		Push struct {
+			Changes []struct {
+				New *struct {
+					Type string `json:"type"`
+					Name string `json:"name"`
+				} `json:"new"`
+			} `json:"changes"`
+		} `json:"push"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, false, errPushHookMalformedPayload
+	}
+	refs := []string{}
+	deleted := false
+	for _, change := range payload.Push.Changes {
+		if change.New == nil {
+			deleted = true
+			continue
+		}
+		switch change.New.Type {
+		case "branch":
+			refs = append(refs, "refs/heads/"+change.New.Name)
+		case "tag":
+			refs = append(refs, "refs/tags/"+change.New.Name)
+		}
+	}
+	if len(refs) == 0 && !deleted {
+		return nil, false, errPushHookMalformedPayload
+	}
+	return refs, deleted, nil
+}
+
+func parseBitbucketServerPush(body []byte) ([]string, bool, error) {
+	var payload struct {
+		Changes []struct {
+			Ref struct {
+				ID string `json:"id"`
+			} `json:"ref"`
+			Type string `json:"type"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || len(payload.Changes) == 0 {
+		return nil, false, errPushHookMalformedPayload
+	}
+	refs := []string{}
+	deleted := false
+	for _, change := range payload.Changes {
+		if change.Type == "DELETE" {
+			deleted = true
+			continue
+		}
+		refs = append(refs, change.Ref.ID)
+	}
+	return refs, deleted, nil
+}
@@ -3,7 +3,10 @@ package rest
 import (
 	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,6 +14,7 @@ import (
 	"github.com/wufe/polo/pkg/http/proxy"
 	"github.com/wufe/polo/pkg/http/routing"
 	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/metrics"
 	"github.com/wufe/polo/pkg/models"
 	"github.com/wufe/polo/pkg/models/output"
 	"github.com/wufe/polo/pkg/services"
@@ -30,6 +34,8 @@ func NewHandler(
 	proxy *proxy.Handler,
 	query *services.QueryService,
 	request *services.RequestService,
+	configuration *services.ConfigurationService,
+	metrics *metrics.Metrics,
 	logger logging.Logger,
 ) *Handler {
 	router := httprouter.New()
@@ -44,6 +50,8 @@ func NewHandler(
 	router.GET("/_polo_/session/*catchall", h.getManager(static, proxy))
 	router.GET("/_polo_/api/status", h.getStatusData(query))
 	router.POST("/_polo_/api/session/", h.addSession(request))
+	router.GET("/_polo_/api/stacks", h.getStacks(query))
+	router.POST("/_polo_/api/stack/", h.addStackSession(request))
 	// TODO: Updated these routes to /sessions/failed/... after this PR gets merged
 	// https://github.com/julienschmidt/httprouter/pull/329
 	router.GET("/_polo_/api/failed/:uuid", h.getFailedSession(query))
@@ -56,7 +64,21 @@ func NewHandler(
 	router.POST("/_polo_/api/session/:uuid/track", h.trackSession(query))
 	router.DELETE("/_polo_/api/session/:uuid/track", h.untrackSession())
 	router.GET("/_polo_/api/session/:uuid/logs/:last_log", h.getSessionLogsAndStatus(query))
+	router.GET("/_polo_/api/logs/:uuid", h.getSessionLogs(query))
+	router.GET("/_polo_/api/session/:uuid/stream", h.streamSession(query))
+	router.GET("/_polo_/api/applications", h.getApplications(configuration))
+	router.POST("/_polo_/api/applications", h.addApplication(configuration))
+	router.GET("/_polo_/api/applications/:name", h.getApplication(configuration))
+	router.PUT("/_polo_/api/applications/:name", h.updateApplication(configuration))
+	router.DELETE("/_polo_/api/applications/:name", h.deleteApplication(configuration))
+	router.POST("/_polo_/api/applications/:name/fetch", h.fetchApplication(request))
+	router.GET("/_polo_/api/applications/:name/fetch", h.getApplicationFetch(query))
+	router.POST("/_polo_/api/hooks/:app", h.receivePushHook(query, request))
+	router.GET("/_polo_/api/schema", h.getConfigurationSchema())
+	router.GET("/_polo_/api/configuration/validate", h.validateConfiguration(query))
+	router.POST("/_polo_/api/configuration/validate", h.validateConfigurationContent(query))
 	router.GET("/_polo_/api/ping", h.ping())
+	router.GET("/_polo_/metrics", h.getMetrics(metrics))
 	if !environment.IsDev() {
 		router.GET("/_polo_/public/*filepath", h.serveStatic(static))
 	}
@@ -192,6 +214,34 @@ func (h *Handler) getSessionLogsAndStatus(query *services.QueryService) httprout
 	}
 }
 
+// getSessionLogs pages through the persisted logs of any session,
+// including destroyed ones.
+// Query parameters: "channel" ("build", the default, or "runtime"),
+// "after" (UUID of the last log already retrieved), "limit"
+func (h *Handler) getSessionLogs(query *services.QueryService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+
+		limit := 0
+		if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+			var err error
+			limit, err = strconv.Atoi(rawLimit)
+			if err != nil {
+				write(h.badRequest())
+				return
+			}
+		}
+
+		channel, ok := models.ParseLogChannel(r.URL.Query().Get("channel"))
+		if !ok {
+			write(h.badRequest())
+			return
+		}
+
+		write(h.ok(query.GetSessionLogs(p.ByName("uuid"), channel, r.URL.Query().Get("after"), limit)))
+	}
+}
+
 func (h *Handler) trackSession(query *services.QueryService) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		uuid := p.ByName("uuid")
@@ -233,8 +283,10 @@ func (h *Handler) addSession(req *services.RequestService) httprouter.Handle {
 
 		// Decoding body
 		input := &struct {
-			Checkout        string `json:"checkout"`
-			ApplicationName string `json:"applicationName"`
+			Checkout        string            `json:"checkout"`
+			ApplicationName string            `json:"applicationName"`
+			Parameters      map[string]string `json:"parameters"`
+			Variant         string            `json:"variant"`
 		}{}
 		err := json.NewDecoder(r.Body).Decode(input)
 		if err != nil {
@@ -242,12 +294,58 @@ func (h *Handler) addSession(req *services.RequestService) httprouter.Handle {
 			return
 		}
 
-		response, err := req.NewSession(input.Checkout, input.ApplicationName, false)
+		response, err := req.NewSessionVariant(input.Checkout, input.ApplicationName, false, input.Variant, input.Parameters)
 		if err != nil {
 			if err == services.ErrApplicationNotFound {
 				write(h.notFound())
 				return
 			}
+			if errors.Is(err, services.ErrInvalidParameters) || errors.Is(err, services.ErrInvalidVariant) {
+				write(h.badRequestWithReason(err.Error()))
+				return
+			}
+
+			write(h.serverError(err.Error()))
+			return
+		}
+		write(h.ok(response.Session.ToOutput()))
+	}
+}
+
+func (h *Handler) getStacks(query *services.QueryService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+
+		stacks := []output.Stack{}
+		for _, stack := range query.GetStacks() {
+			stacks = append(stacks, models.MapStack(stack, query.GetAliveStackSessions(stack.Name)))
+		}
+		write(h.ok(stacks))
+	}
+}
+
+func (h *Handler) addStackSession(req *services.RequestService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+
+		write := h.write(w)
+
+		// Decoding body
+		input := &struct {
+			Stack    string `json:"stack"`
+			Checkout string `json:"checkout"`
+		}{}
+		err := json.NewDecoder(r.Body).Decode(input)
+		if err != nil {
+			write(h.badRequest())
+			return
+		}
+
+		response, err := req.NewStackSession(input.Stack, input.Checkout)
+		if err != nil {
+			if err == services.ErrStackNotFound {
+				write(h.notFound())
+				return
+			}
 
 			write(h.serverError(err.Error()))
 			return
@@ -278,6 +376,184 @@ func (h *Handler) deleteSession(req *services.RequestService) httprouter.Handle
 	}
 }
 
+// fetchApplication requests for an immediate fetch of the remote of an application;
+// with ?watch=true, the watched branches get evaluated too, as by the periodic fetch
+func (h *Handler) fetchApplication(req *services.RequestService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+
+		watch, _ := strconv.ParseBool(r.URL.Query().Get("watch"))
+		err := req.FetchApplication(p.ByName("name"), nil, watch)
+		if err != nil {
+			switch err {
+			case services.ErrApplicationNotFound:
+				write(h.notFound())
+			case services.ErrApplicationNotReady:
+				write(h.buildResponse(ResponseObjectWithFailingReason{
+					ResponseObject{"Service unavailable"},
+					err.Error(),
+				}, 503))
+			default:
+				write(h.serverError(err.Error()))
+			}
+			return
+		}
+
+		write(h.buildResponse(ResponseObject{"Accepted"}, 202))
+	}
+}
+
+// getApplicationFetch retrieves whether a fetch of an application is in progress
+// along with the time, duration, result, errors and new commits of the last one
+func (h *Handler) getApplicationFetch(query *services.QueryService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+		status, err := query.GetApplicationFetchStatus(p.ByName("name"))
+		if err != nil {
+			write(h.notFound())
+			return
+		}
+		write(h.ok(status))
+	}
+}
+
+// getApplications retrieves the applications as written in the configuration files
+func (h *Handler) getApplications(configuration *services.ConfigurationService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+		write(h.ok(configuration.GetApplications()))
+	}
+}
+
+// getApplication retrieves an application as written in its configuration file;
+// the ETag is the hash of its configuration, required to change it
+func (h *Handler) getApplication(configuration *services.ConfigurationService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+		application, err := configuration.GetApplication(p.ByName("name"))
+		if err != nil {
+			write(h.applicationChangeError(err))
+			return
+		}
+		w.Header().Set("ETag", `"`+application.Hash+`"`)
+		write(h.ok(application))
+	}
+}
+
+// addApplication adds an application, sent in JSON or YAML, to the managed configuration file
+func (h *Handler) addApplication(configuration *services.ConfigurationService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			write(h.badRequestWithReason(err.Error()))
+			return
+		}
+		application, err := configuration.CreateApplication(content)
+		if err != nil {
+			write(h.applicationChangeError(err))
+			return
+		}
+		w.Header().Set("ETag", `"`+application.Hash+`"`)
+		write(h.buildResponse(ResponseObjectWithResult{ResponseObject{"Created"}, application}, 201))
+	}
+}
+
+// updateApplication replaces a managed application;
+// the If-Match header must be the hash of its current configuration
+func (h *Handler) updateApplication(configuration *services.ConfigurationService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			write(h.badRequestWithReason(err.Error()))
+			return
+		}
+		application, err := configuration.UpdateApplication(p.ByName("name"), ifMatch(r), content)
+		if err != nil {
+			write(h.applicationChangeError(err))
+			return
+		}
+		w.Header().Set("ETag", `"`+application.Hash+`"`)
+		write(h.ok(application))
+	}
+}
+
+// deleteApplication removes a managed application, retiring its sessions;
+// the If-Match header must be the hash of its current configuration
+func (h *Handler) deleteApplication(configuration *services.ConfigurationService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+		if err := configuration.DeleteApplication(p.ByName("name"), ifMatch(r)); err != nil {
+			write(h.applicationChangeError(err))
+			return
+		}
+		write(h.ok(nil))
+	}
+}
+
+func (h *Handler) applicationChangeError(err error) ([]byte, int) {
+	var invalid *services.InvalidConfigurationError
+	switch {
+	case errors.Is(err, services.ErrApplicationNotFound):
+		return h.notFound()
+	case errors.As(err, &invalid):
+		return h.badRequestWithReason(invalid.Validation)
+	case errors.Is(err, services.ErrInvalidApplication):
+		return h.badRequestWithReason(err.Error())
+	case errors.Is(err, services.ErrApplicationAlreadyExists), errors.Is(err, services.ErrApplicationNotManaged):
+		return h.buildResponse(ResponseObjectWithFailingReason{ResponseObject{"Conflict"}, err.Error()}, 409)
+	case errors.Is(err, services.ErrConfigurationHashMismatch):
+		return h.buildResponse(ResponseObjectWithFailingReason{ResponseObject{"Precondition failed"}, err.Error()}, 412)
+	case errors.Is(err, services.ErrConfigurationHashRequired):
+		return h.buildResponse(ResponseObjectWithFailingReason{ResponseObject{"Precondition required"}, err.Error()}, 428)
+	}
+	return h.serverError(err.Error())
+}
+
+// ifMatch retrieves the entity tag of the If-Match header, without the quotes
+func ifMatch(r *http.Request) string {
+	return strings.Trim(strings.TrimPrefix(r.Header.Get("If-Match"), "W/"), `"`)
+}
+
+// getConfigurationSchema serves the JSON Schema of the configuration files,
+// for the editors to use it as is
+func (h *Handler) getConfigurationSchema() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		w.Header().Add("Content-Type", "application/schema+json")
+		w.WriteHeader(200)
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(models.ConfigurationSchema())
+	}
+}
+
+// validateConfiguration reports the issues of the configuration files of the instance
+func (h *Handler) validateConfiguration(query *services.QueryService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+		write(h.ok(query.ValidateConfiguration()))
+	}
+}
+
+// validateConfigurationContent reports the issues of the configuration file sent as body
+func (h *Handler) validateConfigurationContent(query *services.QueryService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		write := h.write(w)
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			write(h.badRequestWithReason(err.Error()))
+			return
+		}
+		name := r.URL.Query().Get("file")
+		if name == "" {
+			name = "request"
+		}
+		write(h.ok(query.ValidateConfigurationContent(name, content)))
+	}
+}
+
 func (h *Handler) getFailedSession(query *services.QueryService) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		uuid := p.ByName("uuid")
@@ -324,6 +600,15 @@ func (h *Handler) ping() func(w http.ResponseWriter, r *http.Request, _ httprout
 	}
 }
 
+// getMetrics exposes the metrics in the Prometheus text format
+func (h *Handler) getMetrics(metrics *metrics.Metrics) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.Header().Add("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(200)
+		metrics.Write(w)
+	}
+}
+
 func (h *Handler) serveStatic(st *services.StaticService) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		if !h.isDev {
@@ -385,6 +670,13 @@ func (h *Handler) badRequest() ([]byte, int) {
 	return h.buildResponse(ResponseObject{"Bad request"}, 400)
 }
 
+func (h *Handler) badRequestWithReason(reason interface{}) ([]byte, int) {
+	return h.buildResponse(ResponseObjectWithFailingReason{
+		ResponseObject{"Bad request"},
+		reason,
+	}, 400)
+}
+
 func (h *Handler) buildResponse(response interface{}, status int) ([]byte, int) {
 	responseString, err := json.Marshal(response)
 	if err != nil {
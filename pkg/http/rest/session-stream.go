@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/services"
+)
+
+// Interval between the keep-alive comments sent through the stream
+const streamHeartbeatInterval = 15 * time.Second
+
+// streamSession tails the logs and the status transitions of a session
+// using Server-Sent Events.
+// The "channel" query parameter selects the logs to be tailed:
+// "build" (default) or "runtime", the output of the application once started.
+// The stream can be resumed from a log UUID, given through the "last_log" query parameter
+// or through the "Last-Event-ID" header sent by the browsers on reconnection.
+// Events:
+// - "log": a log line, whose id is the UUID of the log
+// - "status": a status transition
+// - "event": a session lifetime event (e.g. "healthcheck_started")
+// The stream gets closed when the session is not alive anymore.
+func (h *Handler) streamSession(query *services.QueryService) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		session := query.GetSession(p.ByName("uuid"))
+		if session == nil {
+			h.write(w)(h.notFound())
+			return
+		}
+		channel, ok := models.ParseLogChannel(r.URL.Query().Get("channel"))
+		if !ok {
+			h.write(w)(h.badRequest())
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			h.write(w)(h.serverError("Streaming not supported"))
+			return
+		}
+
+		lastLogUUID := r.URL.Query().Get("last_log")
+		if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+			lastLogUUID = lastEventID
+		}
+
+		// Subscribing before retrieving the stored logs
+		// so no log gets lost in between
+		events, unsubscribe := session.GetEventBus().Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		sent := make(map[string]bool)
+		for _, log := range session.GetChannelLogsAfter(channel, lastLogUUID, 0) {
+			sent[log.UUID] = true
+			h.writeStreamLog(w, log)
+		}
+		status := session.GetStatus()
+		h.writeStreamEvent(w, "status", "", map[string]string{"status": string(status)})
+		flusher.Flush()
+		if !status.IsAlive() {
+			return
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case event := <-events:
+				switch event.Type {
+				case models.SessionStreamEventTypeLog:
+					if event.Channel != channel || sent[event.Log.UUID] {
+						continue
+					}
+					h.writeStreamLog(w, event.Log)
+				case models.SessionStreamEventTypeStatus:
+					h.writeStreamEvent(w, "status", "", map[string]string{"status": string(event.Status)})
+				case models.SessionStreamEventTypeEvent:
+					h.writeStreamEvent(w, "event", "", map[string]string{"type": string(event.Event), "status": string(event.Status)})
+				}
+				if event.Type == models.SessionStreamEventTypeStatus && !event.Status.IsAlive() {
+					flusher.Flush()
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *Handler) writeStreamLog(w http.ResponseWriter, log models.Log) {
+	h.writeStreamEvent(w, "log", log.UUID, models.MapSessionLog(log))
+}
+
+func (h *Handler) writeStreamEvent(w http.ResponseWriter, event string, id string, data interface{}) {
+	serialized, err := json.Marshal(data)
+	if err != nil {
+		h.log.Errorln("Could not serialize stream event", err)
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, serialized)
+}
@@ -34,7 +34,8 @@ func BuildDefaultForwardRules(conf *models.ApplicationConfiguration, variables m
 			r.Host = conf.Host
 		}
 
-		err := conf.Headers.ApplyTo(r)
+		headers := conf.Headers.WithVariables(variables.WithSecrets(conf.Secrets))
+		err := headers.ApplyTo(r)
 		if err != nil {
 			log.Errorf("Error applying headers to the request: %s", err.Error())
 		}
@@ -89,7 +90,8 @@ func BuildForwardRules(r *http.Request, pattern models.CompiledForwardPattern, c
 			r.Host = pattern.Forward.Host
 		}
 
-		err := pattern.Forward.Headers.ApplyTo(r)
+		headers := pattern.Forward.Headers.WithVariables(variables.WithSecrets(conf.Secrets))
+		err := headers.ApplyTo(r)
 		if err != nil {
 			log.Errorf("Error applying headers to the request: %s", err.Error())
 		}
@@ -0,0 +1,49 @@
+package routing
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// meteredResponseWriter records the status code and the size
+// of the responses proxied from the sessions
+type meteredResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func newMeteredResponseWriter(w http.ResponseWriter) *meteredResponseWriter {
+	return &meteredResponseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+}
+
+func (w *meteredResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *meteredResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush allows streamed responses to be flushed through the reverse proxy
+func (w *meteredResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack allows upgraded connections (i.e. websockets) through the reverse proxy
+func (w *meteredResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("hijacking not supported")
+}
@@ -12,12 +12,15 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/wufe/polo/pkg/http/proxy"
 	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/metrics"
 	"github.com/wufe/polo/pkg/models"
 	"github.com/wufe/polo/pkg/services"
 	"github.com/wufe/polo/pkg/storage"
+	"github.com/wufe/polo/pkg/tracing"
 	"github.com/wufe/polo/pkg/utils"
 )
 
@@ -29,11 +32,13 @@ type Handler struct {
 	query              *services.QueryService
 	request            *services.RequestService
 	static             *services.StaticService
+	metrics            *metrics.Metrics
+	tracer             *tracing.Tracer
 	logger             logging.Logger
 }
 
 // NewHandler creates new routing handler
-func NewHandler(environment utils.Environment, proxy *proxy.Handler, sessionStorage *storage.Session, applicationStorage *storage.Application, query *services.QueryService, request *services.RequestService, static *services.StaticService, logger logging.Logger) *Handler {
+func NewHandler(environment utils.Environment, proxy *proxy.Handler, sessionStorage *storage.Session, applicationStorage *storage.Application, query *services.QueryService, request *services.RequestService, static *services.StaticService, metrics *metrics.Metrics, tracer *tracing.Tracer, logger logging.Logger) *Handler {
 	return &Handler{
 		isDev:              environment.IsDev(),
 		proxy:              proxy,
@@ -42,6 +47,8 @@ func NewHandler(environment utils.Environment, proxy *proxy.Handler, sessionStor
 		query:              query,
 		request:            request,
 		static:             static,
+		metrics:            metrics,
+		tracer:             tracer,
 		logger:             logger,
 	}
 }
@@ -58,7 +65,7 @@ func NewHandler(environment utils.Environment, proxy *proxy.Handler, sessionStor
 // serves the backend service.
 //
 // If a request URL has a special "smart url" pattern
-// (i.e. /s/<checkout>/<path>)
+// (i.e. /s/<checkout>/<path> or /s/<checkout>~<variant>/<path>)
 // the request is considered to be a redirect to a specific session
 // identified by its checkout, in a specific path.
 // The session tracking cookie value is thus skipped.
@@ -108,7 +115,27 @@ func (h *Handler) RouteReverseProxyRequests() http.Handler {
 						temporaryRedirect(w, fmt.Sprintf("/%s", path))
 					} else {
 						forward := h.findForwardRules(r, session)
-						h.serveRev(forward, builder)(w, r)
+						start := time.Now()
+						metered := newMeteredResponseWriter(w)
+						// FEATURE: Tracing
+						// The trace context gets propagated to the session
+						ctx, span := h.tracer.StartServer(r.Context(), fmt.Sprintf("%s %s", r.Method, session.ApplicationName), tracing.Extract(r.Header))
+						if span != nil {
+							span.SetAttribute("http.method", r.Method)
+							span.SetAttribute("http.target", r.URL.RequestURI())
+							span.SetAttribute("polo.session.uuid", session.UUID)
+							span.SetAttribute("polo.application", session.ApplicationName)
+							span.SetAttribute("polo.checkout", session.Checkout)
+							r = r.WithContext(ctx)
+							tracing.Inject(span, r.Header)
+						}
+						h.serveRev(forward, builder)(metered, r)
+						h.metrics.ObserveProxiedRequest(session.ApplicationName, session.UUID, metered.status, time.Since(start), metered.bytes)
+						span.SetAttribute("http.status_code", metered.status)
+						if metered.status >= http.StatusInternalServerError {
+							span.SetError(fmt.Errorf("Session responded with status %d", metered.status))
+						}
+						span.End()
 					}
 					break
 				case models.SessionStatusStarting, models.SessionStatusDegraded:
@@ -239,11 +266,28 @@ func (h *Handler) buildSessionEnhancerProxy(session *models.Session) proxy.Build
 
 func (h *Handler) tryGetSessionByRequestURL(req *http.Request) (foundSession *models.Session, path string, redirect bool) {
 	if strings.HasPrefix(req.URL.Path, "/s/") {
-		if checkout, application, path, found, foundSession := h.query.GetMatchingCheckoutBySmartUrl(req.URL.Path[3:]); found {
-			if foundSession != nil {
+		// FEATURE: Stacks
+		// Stack urls (i.e. /s/<stack>/<checkout>/<path>) are served
+		// by the session of the entry application of the stack
+		if stack, checkout, path, found, entrySession := h.query.GetMatchingStackBySmartUrl(req.URL.Path[3:]); found {
+			if entrySession != nil && entrySession.GetStatus() == models.SessionStatusStarted {
+				return entrySession, path, true
+			}
+			result, err := h.request.NewStackSession(stack, checkout)
+			if err != nil {
+				return nil, "", false
+			}
+			if req.URL.RawQuery != "" {
+				path = path + "?" + req.URL.RawQuery
+			}
+			return result.Session, path, true
+		}
+		if checkout, application, variant, path, found, foundSession := h.query.GetMatchingCheckoutBySmartUrl(req.URL.Path[3:]); found {
+			parameters := extractParameters(req)
+			if foundSession != nil && foundSession.Parameters.Contains(parameters) {
 				return foundSession, path, true
 			}
-			result, err := h.request.NewSession(checkout, application, false)
+			result, err := h.request.NewSessionVariant(checkout, application, false, variant, parameters)
 			if err != nil {
 				return nil, "", false
 			}
@@ -254,7 +298,7 @@ func (h *Handler) tryGetSessionByRequestURL(req *http.Request) (foundSession *mo
 		}
 	} else if strings.HasPrefix(req.URL.Path, "/p/") {
 		if checkout, application, path, found := h.query.GetMatchingCheckoutByPermalink(req.URL.Path[3:]); found {
-			result, err := h.request.NewSession(checkout, application, true)
+			result, err := h.request.NewSessionWithParameters(checkout, application, true, extractParameters(req))
 			if err != nil {
 				return nil, "", false
 			}
@@ -265,7 +309,7 @@ func (h *Handler) tryGetSessionByRequestURL(req *http.Request) (foundSession *mo
 		}
 	} else if strings.HasPrefix(req.URL.Path, "/f/") {
 		if checkout, application, path, found := h.query.GetMatchingCheckoutByForwardLink(req.URL.Path[3:]); found {
-			result, err := h.request.NewSession(checkout, application, false)
+			result, err := h.request.NewSessionWithParameters(checkout, application, false, extractParameters(req))
 			if err != nil {
 				return nil, "", false
 			}
@@ -280,6 +324,27 @@ func (h *Handler) tryGetSessionByRequestURL(req *http.Request) (foundSession *mo
 	return nil, "", false
 }
 
+// extractParameters retrieves the build parameters
+// supplied in the query string (i.e. ?param.locale=it)
+// and removes them from the request
+func extractParameters(req *http.Request) map[string]string {
+	query := req.URL.Query()
+	var parameters map[string]string
+	for key, values := range query {
+		if strings.HasPrefix(key, "param.") && len(values) > 0 {
+			if parameters == nil {
+				parameters = make(map[string]string)
+			}
+			parameters[strings.TrimPrefix(key, "param.")] = values[0]
+			query.Del(key)
+		}
+	}
+	if parameters != nil {
+		req.URL.RawQuery = query.Encode()
+	}
+	return parameters
+}
+
 func (h *Handler) findForwardRules(req *http.Request, session *models.Session) ForwardRules {
 	conf := session.GetConfiguration()
 
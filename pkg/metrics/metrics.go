@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/wufe/polo/pkg/models"
+)
+
+var (
+	// Buckets of the build stages and fetches durations, in seconds
+	durationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+	// Buckets of the time spent waiting in the mediator queues, in seconds
+	queueWaitBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}
+	// Buckets of the latency of the proxied requests, in seconds
+	requestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+// SessionsSource retrieves the sessions counted when the metrics get collected
+type SessionsSource interface {
+	GetAllAliveSessions() []*models.Session
+}
+
+// Metrics collects the metrics of Polo,
+// exposed in the Prometheus text format
+type Metrics struct {
+	registry           *Registry
+	sessions           SessionsSource
+	buildStageDuration *HistogramVec
+	buildFailures      *CounterVec
+	healthchecks       *CounterVec
+	fetchDuration      *HistogramVec
+	fetchErrors        *CounterVec
+	queueWait          *HistogramVec
+	proxyRequests      *CounterVec
+	proxyDuration      *HistogramVec
+	proxyBytes         *CounterVec
+}
+
+func NewMetrics() *Metrics {
+	registry := NewRegistry()
+	m := &Metrics{
+		registry: registry,
+	}
+	registry.NewGaugeFunc(
+		"polo_sessions",
+		"Number of sessions by application and status.",
+		m.collectSessions,
+		"application", "status",
+	)
+	m.buildStageDuration = registry.NewHistogramVec(
+		"polo_session_build_stage_duration_seconds",
+		"Duration of the stages of the session builds.",
+		durationBuckets,
+		"application", "stage",
+	)
+	m.buildFailures = registry.NewCounterVec(
+		"polo_session_build_failures_total",
+		"Number of failed session builds by kill reason.",
+		"application", "reason",
+	)
+	m.healthchecks = registry.NewCounterVec(
+		"polo_session_healthchecks_total",
+		"Number of session healthchecks by result.",
+		"application", "result",
+	)
+	m.fetchDuration = registry.NewHistogramVec(
+		"polo_application_fetch_duration_seconds",
+		"Duration of the fetches of the application repositories.",
+		durationBuckets,
+		"application",
+	)
+	m.fetchErrors = registry.NewCounterVec(
+		"polo_application_fetch_errors_total",
+		"Number of failed fetches of the application repositories.",
+		"application",
+	)
+	m.queueWait = registry.NewHistogramVec(
+		"polo_mediator_queue_wait_seconds",
+		"Time spent by the requests waiting to be accepted by the workers.",
+		queueWaitBuckets,
+		"queue",
+	)
+	m.proxyRequests = registry.NewCounterVec(
+		"polo_proxy_requests_total",
+		"Number of requests proxied to the sessions.",
+		"application", "session", "code",
+	)
+	m.proxyDuration = registry.NewHistogramVec(
+		"polo_proxy_request_duration_seconds",
+		"Latency of the requests proxied to the sessions.",
+		requestBuckets,
+		"application", "session",
+	)
+	m.proxyBytes = registry.NewCounterVec(
+		"polo_proxy_response_bytes_total",
+		"Bytes of the responses proxied from the sessions.",
+		"application", "session",
+	)
+	return m
+}
+
+// SetSessionsSource sets the storage of the sessions counted by application and status
+func (m *Metrics) SetSessionsSource(sessions SessionsSource) {
+	m.sessions = sessions
+}
+
+func (m *Metrics) collectSessions(emit func(value float64, labelValues ...string)) {
+	if m.sessions == nil {
+		return
+	}
+	counts := make(map[[2]string]int)
+	for _, session := range m.sessions.GetAllAliveSessions() {
+		counts[[2]string{session.ApplicationName, string(session.GetStatus())}]++
+	}
+	for labels, count := range counts {
+		emit(float64(count), labels[0], labels[1])
+	}
+}
+
+// Write writes the metrics in the Prometheus text exposition format
+func (m *Metrics) Write(w io.Writer) {
+	m.registry.Write(w)
+}
+
+// ObserveSessionMetric records the duration of a stage of a session build
+func (m *Metrics) ObserveSessionMetric(application string, stage string, duration time.Duration) {
+	m.buildStageDuration.Observe(duration.Seconds(), application, stage)
+}
+
+// IncSessionBuildFailure counts a failed session build
+func (m *Metrics) IncSessionBuildFailure(application string, reason string) {
+	m.buildFailures.Inc(application, reason)
+}
+
+// ObserveHealthcheck counts the result of a session healthcheck
+func (m *Metrics) ObserveHealthcheck(application string, succeeded bool) {
+	result := "failed"
+	if succeeded {
+		result = "succeeded"
+	}
+	m.healthchecks.Inc(application, result)
+}
+
+// ObserveApplicationFetch records the duration of a fetch of an application repository
+// and whether it failed
+func (m *Metrics) ObserveApplicationFetch(application string, duration time.Duration, failed bool) {
+	m.fetchDuration.Observe(duration.Seconds(), application)
+	if failed {
+		m.fetchErrors.Inc(application)
+	}
+}
+
+// ObserveQueueWait records the time spent by a request in a mediator queue
+func (m *Metrics) ObserveQueueWait(queue string, wait time.Duration) {
+	m.queueWait.Observe(wait.Seconds(), queue)
+}
+
+// ObserveProxiedRequest records a request proxied to a session
+func (m *Metrics) ObserveProxiedRequest(application string, session string, code int, duration time.Duration, bytes int64) {
+	m.proxyRequests.Inc(application, session, strconv.Itoa(code))
+	m.proxyDuration.Observe(duration.Seconds(), application, session)
+	m.proxyBytes.Add(float64(bytes), application, session)
+}
@@ -27,6 +27,11 @@ func (a *ApplicationConfiguration) WithStartCommand(command string) *Application
 	return a
 }
 
+func (a *ApplicationConfiguration) WithSupervisedStartCommand(command string) *ApplicationConfiguration {
+	a.Commands.Start = append(a.Commands.Start, Command{Command: command, Supervise: true})
+	return a
+}
+
 func (a *ApplicationConfiguration) WithStopCommand(command string) *ApplicationConfiguration {
 	a.Commands.Stop = append(a.Commands.Stop, Command{Command: command})
 	return a
@@ -61,3 +66,61 @@ func (a *ApplicationConfiguration) WithBranch(branch *BranchConfigurationMatch)
 	a.Branches = append(a.Branches, *branch)
 	return a
 }
+
+func (a *ApplicationConfiguration) WithDependency(application string, fallback string) *ApplicationConfiguration {
+	a.Dependencies = append(a.Dependencies, Dependency{Application: application, Fallback: fallback})
+	return a
+}
+
+func (a *ApplicationConfiguration) WithLogFile(glob string) *ApplicationConfiguration {
+	a.Logs = append(a.Logs, LogSource{File: glob})
+	return a
+}
+
+func (a *ApplicationConfiguration) WithLogCommand(command string) *ApplicationConfiguration {
+	a.Logs = append(a.Logs, LogSource{Command: command})
+	return a
+}
+
+func (a *ApplicationConfiguration) WithWebhook(url string, secret string, events ...string) *ApplicationConfiguration {
+	a.Webhooks = append(a.Webhooks, Webhook{URL: url, Secret: secret, Events: events})
+	return a
+}
+
+func (a *ApplicationConfiguration) WithPushHook(secret string, onlyPushedRef bool) *ApplicationConfiguration {
+	a.Fetch.Hook = PushHook{Secret: secret, OnlyPushedRef: onlyPushedRef}
+	return a
+}
+
+func (a *ApplicationConfiguration) WithCommitStatus(provider CommitStatusProvider, url string, token string, repository string) *ApplicationConfiguration {
+	a.PullRequests.CommitStatus = CommitStatusConfiguration{Provider: provider, URL: url, Token: token, Repository: repository}
+	return a
+}
+
+func (a *ApplicationConfiguration) WithHTTPSAuth(username string, token string) *ApplicationConfiguration {
+	a.Auth.HTTPS = GitHTTPSAuth{Username: username, Token: token}
+	return a
+}
+
+func (a *ApplicationConfiguration) WithSSHAuth(key string, passphrase string, knownHosts GitAuthKnownHosts) *ApplicationConfiguration {
+	a.Auth.SSH = GitSSHAuth{Key: key, Passphrase: passphrase, KnownHosts: knownHosts}
+	return a
+}
+
+func (a *ApplicationConfiguration) WithParameter(name string, parameterType ParameterType, values ...string) *ApplicationConfiguration {
+	a.Parameters = append(a.Parameters, Parameter{Name: name, Type: parameterType, Values: values})
+	return a
+}
+
+func (a *ApplicationConfiguration) WithOutputVariableStartCommand(command string, variable string) *ApplicationConfiguration {
+	a.Commands.Start = append(a.Commands.Start, Command{Command: command, OutputVariable: variable})
+	return a
+}
+
+func (a *ApplicationConfiguration) WithSecret(name string, value string) *ApplicationConfiguration {
+	if a.Secrets == nil {
+		a.Secrets = Secrets{}
+	}
+	a.Secrets[name] = value
+	return a
+}
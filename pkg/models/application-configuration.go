@@ -4,9 +4,12 @@ import (
 	"crypto/sha1"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jxskiss/base62"
 	"github.com/kennygrant/sanitize"
@@ -20,21 +23,38 @@ import (
 type ApplicationConfiguration struct {
 	SharedConfiguration   `yaml:",inline"` // Base configuration, common for branches and root application configuration
 	utils.RWLocker        `json:"-"`
-	ID                    string   `json:"id"`
-	Name                  string   `json:"name"`
-	Hash                  string   `json:"hash"`
-	Fetch                 Fetch    `json:"fetch"`
-	IsDefault             bool     `yaml:"is_default" json:"isDefault"`
-	MaxConcurrentSessions int      `yaml:"max_concurrent_sessions" json:"maxConcurrentSessions"`
-	Branches              Branches `yaml:"branches"`
-	UseFolderCopy         bool     `yaml:"use_folder_copy" json:"useFolderCopy"`
-	CleanOnExit           *bool    `yaml:"clean_on_exit" json:"cleanOnExit" default:"true"`
+	ID                    string       `json:"id"`
+	Name                  string       `json:"name"`
+	Hash                  string       `json:"hash"`
+	Extends               string       `yaml:"extends" json:"extends"`
+	Fetch                 Fetch        `json:"fetch"`
+	IsDefault             bool         `yaml:"is_default" json:"isDefault"`
+	MaxConcurrentSessions int          `yaml:"max_concurrent_sessions" json:"maxConcurrentSessions"`
+	Branches              Branches     `yaml:"branches"`
+	UseFolderCopy         bool         `yaml:"use_folder_copy" json:"useFolderCopy"`
+	CleanOnExit           *bool        `yaml:"clean_on_exit" json:"cleanOnExit" default:"true"`
+	Dependencies          []Dependency `yaml:"dependencies" json:"dependencies"`
+	Parameters            Parameters   `yaml:"parameters" json:"parameters"`
+	Logs                  []LogSource  `yaml:"logs" json:"logs"`
+	Webhooks              []Webhook    `yaml:"webhooks" json:"webhooks"`
+	// FEATURE: Pull request previews
+	PullRequests PullRequestsConfiguration `yaml:"pull_requests" json:"pullRequests"`
+	// FEATURE: Git credentials
+	Auth GitAuth `yaml:"auth" json:"auth"`
+	// FEATURE: Shallow checkouts
+	Checkout CheckoutConfiguration `yaml:"checkout" json:"checkout"`
+	// FEATURE: Application templates
+	Provenance ConfigurationProvenance `yaml:"-" json:"provenance"`
+	// FEATURE: Secrets
+	Secrets Secrets `yaml:"-" json:"-"`
 }
 
 func NewApplicationConfiguration(configuration *ApplicationConfiguration, mutexBuilder utils.MutexBuilder) (*ApplicationConfiguration, error) {
 	configuration.RWLocker = mutexBuilder()
+	// All the errors are collected, for them to be reported at once
+	errs := ConfigurationErrors{}
 	if configuration.Name == "" {
-		return nil, errors.New("application.name (required) not defined")
+		errs.Add(errors.New("application.name (required) not defined"))
 	}
 	configuration.ID = sanitize.Name(configuration.Name)
 
@@ -46,21 +66,20 @@ func NewApplicationConfiguration(configuration *ApplicationConfiguration, mutexB
 		configuration.CleanOnExit = &cleanOnExit
 	}
 	if configuration.Remote == "" {
-		return nil, errors.New("application.remote (required) not defined; put the git repository URL")
+		errs.Add(errors.New("application.remote (required) not defined; put the git repository URL"))
 	}
 	if configuration.Forwards == nil {
 		configuration.Forwards = make([]Forward, 0)
 	}
 	for i, forward := range configuration.Forwards {
 		if forward.Pattern == "" {
-			return nil, fmt.Errorf("application.forwards[%d].pattern not defined", i)
+			errs.Add(fmt.Errorf("application.forwards[%d].pattern not defined", i))
 		}
-		_, err := regexp.Compile(forward.Pattern)
-		if err != nil {
-			return nil, fmt.Errorf("application.forwards[%d].pattern is not a valid regex: %s", i, err.Error())
+		if _, err := regexp.Compile(forward.Pattern); err != nil {
+			errs.Add(fmt.Errorf("application.forwards[%d].pattern is not a valid regex: %s", i, err.Error()))
 		}
 		if forward.To == "" {
-			return nil, fmt.Errorf("application.forwards[%d].to not defined", i)
+			errs.Add(fmt.Errorf("application.forwards[%d].to not defined", i))
 		}
 		if forward.Headers.Add == nil {
 			forward.Headers.Add = []Header{}
@@ -78,6 +97,18 @@ func NewApplicationConfiguration(configuration *ApplicationConfiguration, mutexB
 	if configuration.Fetch.Interval <= 0 {
 		configuration.Fetch.Interval = 60
 	}
+	if configuration.Fetch.Hook.DisablePolling && !configuration.Fetch.Hook.IsEnabled() {
+		errs.Add(fmt.Errorf("application.fetch.hook.disable_polling requires application.fetch.hook.secret to be set"))
+	}
+	if configuration.Fetch.History.Since == "" {
+		configuration.Fetch.History.Since = DefaultFetchHistorySince
+	}
+	if _, err := configuration.Fetch.History.SinceTime(time.Now()); err != nil {
+		errs.Add(fmt.Errorf("application.fetch.history.since is not valid: %s", err.Error()))
+	}
+	if configuration.Fetch.History.MaxCommits < 0 {
+		errs.Add(fmt.Errorf("application.fetch.history.max_commits cannot be negative"))
+	}
 	if configuration.Target == "" {
 		configuration.Target = "http://127.0.0.1:{{port}}"
 	}
@@ -142,7 +173,7 @@ func NewApplicationConfiguration(configuration *ApplicationConfiguration, mutexB
 		configuration.Recycle.InactivityTimeout = 3600 // 1 hour
 	}
 	if configuration.Commands.Start == nil {
-		return nil, errors.New("application.commands.start (required) not defined; put commands required for starting the application; commands accept placeholders")
+		errs.Add(errors.New("application.commands.start (required) not defined; put commands required for starting the application; commands accept placeholders"))
 	}
 	for _, command := range configuration.Commands.Start {
 		if command.Environment == nil {
@@ -150,12 +181,20 @@ func NewApplicationConfiguration(configuration *ApplicationConfiguration, mutexB
 		}
 	}
 	if configuration.Commands.Stop == nil {
-		return nil, errors.New("application.commands.stop (required) not defined; put commands required for stopping the application; commands accept placeholders")
+		errs.Add(errors.New("application.commands.stop (required) not defined; put commands required for stopping the application; commands accept placeholders"))
 	}
-	for _, command := range configuration.Commands.Stop {
+	for i, command := range configuration.Commands.Stop {
 		if command.Environment == nil {
 			command.Environment = []string{}
 		}
+		if command.Supervise {
+			errs.Add(fmt.Errorf("application.commands.stop[%d].supervise is allowed for start commands only", i))
+		}
+	}
+	for i, command := range configuration.Commands.Clean {
+		if command.Supervise {
+			errs.Add(fmt.Errorf("application.commands.clean[%d].supervise is allowed for start commands only", i))
+		}
 	}
 	if configuration.MaxConcurrentSessions == 0 {
 		configuration.MaxConcurrentSessions = 5
@@ -163,6 +202,69 @@ func NewApplicationConfiguration(configuration *ApplicationConfiguration, mutexB
 	if configuration.Port.Except == nil {
 		configuration.Port.Except = []int{}
 	}
+	if configuration.Dependencies == nil {
+		configuration.Dependencies = []Dependency{}
+	}
+	for i, dependency := range configuration.Dependencies {
+		if dependency.Application == "" {
+			errs.Add(fmt.Errorf("application.dependencies[%d].application (required) not defined", i))
+		} else if strings.EqualFold(dependency.Application, configuration.Name) {
+			errs.Add(fmt.Errorf("application.dependencies[%d].application cannot be the application itself", i))
+		}
+		if dependency.Name == "" {
+			configuration.Dependencies[i].Name = sanitize.Name(dependency.Application)
+		}
+		if dependency.Checkout == "" {
+			configuration.Dependencies[i].Checkout = "{{checkout}}"
+		}
+	}
+	if parameters, err := newParameters(configuration.Parameters); err != nil {
+		errs.Add(err)
+	} else {
+		configuration.Parameters = parameters
+	}
+	if configuration.Logs == nil {
+		configuration.Logs = []LogSource{}
+	}
+	for i, source := range configuration.Logs {
+		if (source.File == "") == (source.Command == "") {
+			errs.Add(fmt.Errorf("application.logs[%d]: either file or command must be defined", i))
+		}
+		if source.File != "" {
+			if filepath.IsAbs(source.File) || strings.HasPrefix(filepath.Clean(source.File), "..") {
+				errs.Add(fmt.Errorf("application.logs[%d].file must be relative to the session folder", i))
+			}
+			if _, err := filepath.Match(source.File, ""); err != nil {
+				errs.Add(fmt.Errorf("application.logs[%d].file is not a valid glob: %s", i, err.Error()))
+			}
+		}
+		if source.Environment == nil {
+			configuration.Logs[i].Environment = []string{}
+		}
+	}
+	if webhooks, err := newWebhooks(configuration.Webhooks); err != nil {
+		errs.Add(err)
+	} else {
+		configuration.Webhooks = webhooks
+	}
+	if pullRequests, err := newPullRequestsConfiguration(configuration.PullRequests); err != nil {
+		errs.Add(err)
+	} else {
+		configuration.PullRequests = pullRequests
+	}
+	if auth, err := newGitAuth(configuration.Auth); err != nil {
+		errs.Add(err)
+	} else {
+		configuration.Auth = auth
+	}
+	if checkout, err := newCheckoutConfiguration(configuration.Checkout); err != nil {
+		errs.Add(err)
+	} else {
+		configuration.Checkout = checkout
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
 	return configuration, nil
 }
 
@@ -250,8 +352,80 @@ type Forward struct {
 	Headers Headers `json:"headers"`
 }
 
+// Dependency describes another application whose session
+// must be available before a session of this application gets built.
+// The dependency session is looked up (or built) using Checkout
+// and, if it does not exist in the dependency repository, using Fallback.
+// If Fallback is empty, the branch flagged as "main" in the dependency is used.
+type Dependency struct {
+	Name        string `json:"name"`
+	Application string `json:"application"`
+	Checkout    string `json:"checkout"`
+	Fallback    string `json:"fallback"`
+}
+
+// LogSource is a source of the runtime logs of the sessions,
+// tailed once the start commands have returned.
+// File is a glob relative to the session folder (e.g. "logs/*.log"),
+// Command is a command to be followed (e.g. "docker logs -f {{container_id}}").
+type LogSource struct {
+	File        string   `json:"file"`
+	Command     string   `json:"command"`
+	Environment []string `yaml:"environment,omitempty" json:"environment"`
+	WorkingDir  string   `yaml:"working_dir" json:"workingDir"`
+}
+
 type Fetch struct {
-	Interval int `json:"interval"`
+	Interval int          `json:"interval"`
+	Hook     PushHook     `yaml:"hook" json:"hook"`
+	History  FetchHistory `yaml:"history" json:"history"`
+}
+
+// DefaultFetchHistorySince is the start of the history indexed by default
+const DefaultFetchHistorySince = "2018-01-01"
+
+// FetchHistory is the window of the history indexed at each fetch, whose commits can be checked out by hash:
+// the commits since a date or an age, up to a number of commits per ref, or only the ones the refs point to.
+// The commits the refs point to are always indexed
+type FetchHistory struct {
+	Since      string `yaml:"since" json:"since"`
+	MaxCommits int    `yaml:"max_commits" json:"maxCommits"`
+	RefsOnly   bool   `yaml:"refs_only" json:"refsOnly"`
+}
+
+// SinceTime retrieves the start of the window: Since is a date (2006-01-02 or RFC 3339)
+// or an age, in days (e.g. 90d) or as a duration (e.g. 720h)
+func (h FetchHistory) SinceTime(now time.Time) (time.Time, error) {
+	since := strings.TrimSpace(h.Since)
+	if since == "" {
+		since = DefaultFetchHistorySince
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if date, err := time.Parse(layout, since); err == nil {
+			return date, nil
+		}
+	}
+	if strings.HasSuffix(since, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(since, "d")); err == nil && days >= 0 {
+			return now.AddDate(0, 0, -days), nil
+		}
+	} else if age, err := time.ParseDuration(since); err == nil && age >= 0 {
+		return now.Add(-age), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a date (e.g. 2018-01-01) nor an age (e.g. 90d)", h.Since)
+}
+
+// PushHook configures the incoming push webhooks (GitHub, GitLab, Gitea, Bitbucket)
+// received at /_polo_/api/hooks/<application>, which trigger an immediate fetch.
+// They are enabled by setting the secret used to verify their signature
+type PushHook struct {
+	Secret         string `yaml:"secret" json:"-"`
+	OnlyPushedRef  bool   `yaml:"only_pushed_ref" json:"onlyPushedRef"`  // Fetches only the ref that got pushed
+	DisablePolling bool   `yaml:"disable_polling" json:"disablePolling"` // Fetches only when a push hook is received
+}
+
+func (h PushHook) IsEnabled() bool {
+	return h.Secret != ""
 }
 
 type Helper struct {
@@ -2,6 +2,7 @@ package models
 
 import (
 	"github.com/asaskevich/EventBus"
+	"github.com/google/uuid"
 	"github.com/wufe/polo/pkg/logging"
 	"github.com/wufe/polo/pkg/utils"
 )
@@ -18,6 +19,7 @@ const (
 	ApplicationEventTypeSessionBuildFailed      ApplicationEventType = "session_build_failed"
 	ApplicationEventTypeSessionBuildSucceeded   ApplicationEventType = "session_build_succeeded"
 	ApplicationEventTypeSessionCleaned          ApplicationEventType = "session_cleaned"
+	ApplicationEventTypePullRequestClosed       ApplicationEventType = "pull_request_closed"
 
 	eventsBuffer = 100
 )
@@ -42,10 +44,16 @@ type ApplicationEvent struct {
 
 type ApplicationEventBus struct {
 	utils.RWLocker
-	bus     EventBus.Bus
-	ch      chan ApplicationEvent
-	history []ApplicationEvent
-	log     logging.Logger
+	bus         EventBus.Bus
+	ch          chan ApplicationEvent
+	history     []ApplicationEvent
+	log         logging.Logger
+	subscribers *applicationEventSubscribers
+}
+
+type applicationEventSubscribers struct {
+	utils.RWLocker
+	channels map[string]chan ApplicationEvent
 }
 
 func NewApplicationEventBus(mutexBuilder utils.MutexBuilder, logger logging.Logger) *ApplicationEventBus {
@@ -54,6 +62,10 @@ func NewApplicationEventBus(mutexBuilder utils.MutexBuilder, logger logging.Logg
 		bus:      EventBus.New(),
 		ch:       make(chan ApplicationEvent, eventsBuffer),
 		log:      logger,
+		subscribers: &applicationEventSubscribers{
+			RWLocker: mutexBuilder(),
+			channels: make(map[string]chan ApplicationEvent),
+		},
 	}
 	eventBus.start()
 	return eventBus
@@ -71,6 +83,7 @@ func (b *ApplicationEventBus) start() {
 			defer b.Unlock()
 			history = append(history, appEv)
 			b.ch <- appEv
+			b.publishToSubscribers(appEv)
 			eventsCount++
 
 			// Hack to prevent saturation of the receiving channel
@@ -94,6 +107,33 @@ func (b *ApplicationEventBus) GetChan() <-chan ApplicationEvent {
 	return b.ch
 }
 
+// Subscribe registers a subscriber to the events of the application.
+// The returned function must be called to unsubscribe.
+// Events are dropped if the subscriber does not keep up with them
+func (b *ApplicationEventBus) Subscribe() (<-chan ApplicationEvent, func()) {
+	id := uuid.NewString()
+	ch := make(chan ApplicationEvent, eventsBuffer)
+	b.subscribers.Lock()
+	b.subscribers.channels[id] = ch
+	b.subscribers.Unlock()
+	return ch, func() {
+		b.subscribers.Lock()
+		delete(b.subscribers.channels, id)
+		b.subscribers.Unlock()
+	}
+}
+
+func (b *ApplicationEventBus) publishToSubscribers(event ApplicationEvent) {
+	b.subscribers.RLock()
+	defer b.subscribers.RUnlock()
+	for _, ch := range b.subscribers.channels {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
 func (b *ApplicationEventBus) PublishEvent(eventType ApplicationEventType, application *Application, payloadObjects ...interface{}) {
 	b.log.Tracef("Publishing event %q", eventType)
 	var payload interface{} = nil
@@ -110,7 +150,16 @@ func (b *ApplicationEventBus) PublishEvent(eventType ApplicationEventType, appli
 	})
 }
 
-func (b *ApplicationEventBus) Close() {}
+// Close closes the channels of the subscribers,
+// which do not get the events published from then on
+func (b *ApplicationEventBus) Close() {
+	b.subscribers.Lock()
+	defer b.subscribers.Unlock()
+	for id, ch := range b.subscribers.channels {
+		close(ch)
+		delete(b.subscribers.channels, id)
+	}
+}
 
 func (b *ApplicationEventBus) convertHistoryEntries(entries []interface{}) []ApplicationEvent {
 	applicationEvents := []ApplicationEvent{}
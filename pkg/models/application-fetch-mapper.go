@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/wufe/polo/pkg/models/output"
+)
+
+// MapApplicationFetchStatus converts the state of the fetches of an application into its output model
+func MapApplicationFetchStatus(model ApplicationFetchStatus) output.ApplicationFetchStatus {
+	return output.ApplicationFetchStatus{
+		State: string(model.State),
+		Last:  mapApplicationFetch(model.Last),
+	}
+}
+
+func mapApplicationFetch(model *ApplicationFetch) *output.ApplicationFetch {
+	if model == nil {
+		return nil
+	}
+	newCommits := []output.CheckoutObject{}
+	for _, commit := range model.NewCommits {
+		newCommits = append(newCommits, MapCheckoutObject(commit))
+	}
+	return &output.ApplicationFetch{
+		StartedAt:       model.StartedAt,
+		Duration:        int(model.Duration / time.Millisecond),
+		Result:          string(model.Result),
+		Errors:          append([]string{}, model.Errors...),
+		Refs:            append([]string{}, model.Refs...),
+		Watch:           model.WatchObjects,
+		NewCommits:      newCommits,
+		NewCommitsCount: model.NewCommitsCount,
+	}
+}
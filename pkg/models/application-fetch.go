@@ -0,0 +1,108 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ApplicationFetchState tells whether a fetch of the remote of an application is in progress
+type ApplicationFetchState string
+
+const (
+	ApplicationFetchStateIdle ApplicationFetchState = "idle"
+	// ApplicationFetchStatePending - The fetch has been requested and waits for the previous ones
+	ApplicationFetchStatePending ApplicationFetchState = "pending"
+	ApplicationFetchStateRunning ApplicationFetchState = "running"
+)
+
+type ApplicationFetchResult string
+
+const (
+	ApplicationFetchResultSucceeded ApplicationFetchResult = "succeeded"
+	// ApplicationFetchResultPartial - The objects have been read but some errors occurred,
+	// e.g. the remote was not reachable and the refs are the ones fetched previously
+	ApplicationFetchResultPartial ApplicationFetchResult = "partial"
+	ApplicationFetchResultFailed  ApplicationFetchResult = "failed"
+)
+
+// maxFetchNewCommits is the maximum number of new commits listed for a fetch
+const maxFetchNewCommits = 50
+
+// ApplicationFetch is the outcome of a fetch of the remote of an application
+type ApplicationFetch struct {
+	StartedAt       time.Time
+	Duration        time.Duration
+	Result          ApplicationFetchResult
+	Errors          []string
+	Refs            []string
+	WatchObjects    bool
+	NewCommits      []CheckoutObject
+	NewCommitsCount int
+}
+
+// ApplicationFetchStatus is the state of the fetches of an application along with the last one
+type ApplicationFetchStatus struct {
+	State ApplicationFetchState
+	Last  *ApplicationFetch
+}
+
+// RequestFetch marks a fetch as requested, until it gets started
+func (a *Application) RequestFetch() {
+	a.Lock()
+	defer a.Unlock()
+	if a.fetch.State == ApplicationFetchStateIdle {
+		a.fetch.State = ApplicationFetchStatePending
+	}
+}
+
+// StartFetch marks a fetch as running
+func (a *Application) StartFetch() {
+	a.Lock()
+	defer a.Unlock()
+	a.fetch.State = ApplicationFetchStateRunning
+}
+
+// CompleteFetch records the outcome of the fetch just completed
+func (a *Application) CompleteFetch(fetch ApplicationFetch) {
+	a.Lock()
+	defer a.Unlock()
+	a.fetch.State = ApplicationFetchStateIdle
+	a.fetch.Last = &fetch
+}
+
+// GetFetchStatus retrieves the state of the fetches and the outcome of the last one
+func (a *Application) GetFetchStatus() ApplicationFetchStatus {
+	a.RLock()
+	defer a.RUnlock()
+	return a.fetch
+}
+
+// NewFetchCommits lists the commits which were not known before a fetch, in the order of the log,
+// up to a maximum; the count is the one of all the new commits
+func NewFetchCommits(previous []string, current []string, commits map[string]*object.Commit) ([]CheckoutObject, int) {
+	known := make(map[string]bool, len(previous))
+	for _, hash := range previous {
+		known[hash] = true
+	}
+	ret := []CheckoutObject{}
+	count := 0
+	for _, hash := range current {
+		if known[hash] {
+			continue
+		}
+		count++
+		if len(ret) < maxFetchNewCommits {
+			newCommit := CheckoutObject{Name: hash, Hash: hash}
+			if commit, ok := commits[hash]; ok && commit != nil {
+				newCommit.Author = commit.Author.Name
+				newCommit.AuthorEmail = commit.Author.Email
+				newCommit.Date = commit.Author.When
+				newCommit.Message = strings.TrimSpace(commit.Message)
+			}
+			ret = append(ret, newCommit)
+		}
+	}
+	return ret, count
+}
@@ -1,7 +1,7 @@
 package models
 
 import (
-	"path"
+	"net/url"
 
 	"github.com/wufe/polo/pkg/models/output"
 )
@@ -14,14 +14,16 @@ func mapApplication(model *Application) *output.Application {
 	model.RLock()
 	defer model.RUnlock()
 	return &output.Application{
-		Status:        string(model.Status),
-		Filename:      path.Base(model.Filename),
-		Configuration: mapApplicationConfiguration(conf),
-		Folder:        model.Folder,
-		BaseFolder:    model.BaseFolder,
-		BranchesMap:   mapBranches(model.BranchesMap),
-		TagsMap:       mapTags(model.TagsMap),
-		Notifications: mapApplicationNotifications(model.notifications),
+		Status:          string(model.Status),
+		Filename:        model.Filename,
+		Configuration:   mapApplicationConfiguration(conf),
+		Folder:          model.Folder,
+		BaseFolder:      model.BaseFolder,
+		BranchesMap:     mapBranches(model.BranchesMap),
+		TagsMap:         mapTags(model.TagsMap),
+		PullRequestsMap: mapPullRequests(model.PullRequestsMap),
+		Notifications:   mapApplicationNotifications(model.notifications),
+		Fetch:           MapApplicationFetchStatus(model.fetch),
 	}
 }
 
@@ -47,9 +49,103 @@ func mapApplicationConfiguration(model ApplicationConfiguration) output.Applicat
 		UseFolderCopy:         model.UseFolderCopy,
 		CleanOnExit:           *model.CleanOnExit,
 		Warmup:                mapWarmups(model.Warmup),
+		Dependencies:          mapDependencies(model.Dependencies),
+		Parameters:            mapParameters(model.Parameters),
+		Logs:                  mapLogSources(model.Logs),
+		Webhooks:              mapWebhooks(model.Webhooks),
+		PullRequests:          mapPullRequestsConfiguration(model.PullRequests),
+		Auth:                  mapGitAuth(model.Auth),
+		Checkout:              mapCheckout(model.Checkout),
+		Extends:               model.Extends,
+		Provenance:            mapProvenance(model.Provenance),
 	}
 }
 
+func mapProvenance(model ConfigurationProvenance) map[string]output.ConfigurationSource {
+	ret := make(map[string]output.ConfigurationSource)
+	for path, source := range model {
+		ret[path] = output.ConfigurationSource{
+			Value:  source.Value,
+			Source: source.Source,
+		}
+	}
+	return ret
+}
+
+func mapDependencies(models []Dependency) []output.Dependency {
+	ret := []output.Dependency{}
+	for _, d := range models {
+		ret = append(ret, output.Dependency{
+			Name:        d.Name,
+			Application: d.Application,
+			Checkout:    d.Checkout,
+			Fallback:    d.Fallback,
+		})
+	}
+	return ret
+}
+
+func mapParameters(models Parameters) []output.Parameter {
+	ret := []output.Parameter{}
+	for _, p := range models {
+		values := p.Values
+		if values == nil {
+			values = []string{}
+		}
+		ret = append(ret, output.Parameter{
+			Name:        p.Name,
+			Type:        string(p.Type),
+			Default:     p.Default,
+			Values:      values,
+			Description: p.Description,
+		})
+	}
+	return ret
+}
+
+func mapLogSources(models []LogSource) []output.LogSource {
+	ret := []output.LogSource{}
+	for _, s := range models {
+		ret = append(ret, output.LogSource{
+			File:        s.File,
+			Command:     s.Command,
+			Environment: s.Environment,
+			WorkingDir:  s.WorkingDir,
+		})
+	}
+	return ret
+}
+
+// mapWebhooks maps the webhooks leaving their secrets out
+func mapWebhooks(models []Webhook) []output.Webhook {
+	ret := []output.Webhook{}
+	for _, w := range models {
+		ret = append(ret, output.Webhook{
+			URL:           redactWebhookURL(w.URL),
+			Events:        w.Events,
+			Signed:        w.Secret != "",
+			Preset:        string(w.Preset),
+			Payload:       w.Payload,
+			MaxRetries:    w.MaxRetries,
+			RetryInterval: w.RetryInterval,
+		})
+	}
+	return ret
+}
+
+// redactWebhookURL keeps only the scheme and the host of the URL,
+// given that many services put the credentials in the path of their webhooks
+func redactWebhookURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if u.Path == "" && u.RawQuery == "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return u.Scheme + "://" + u.Host + "/..."
+}
+
 func mapWarmups(model Warmups) output.Warmups {
 	urls := []output.Warmup{}
 	for _, u := range model.URLs {
@@ -79,6 +175,16 @@ func MapApplications(models []*Application) []output.Application {
 func mapFetch(model Fetch) output.Fetch {
 	return output.Fetch{
 		Interval: model.Interval,
+		Hook: output.PushHook{
+			Enabled:        model.Hook.IsEnabled(),
+			OnlyPushedRef:  model.Hook.OnlyPushedRef,
+			DisablePolling: model.Hook.DisablePolling,
+		},
+		History: output.FetchHistory{
+			Since:      model.History.Since,
+			MaxCommits: model.History.MaxCommits,
+			RefsOnly:   model.History.RefsOnly,
+		},
 	}
 }
 
@@ -159,6 +265,7 @@ func MapCommand(model Command) output.Command {
 		WorkingDir:          model.WorkingDir,
 		StartHealthchecking: model.StartHealthchecking,
 		Timeout:             model.Timeout,
+		Supervise:           model.Supervise,
 	}
 }
 
@@ -226,3 +333,57 @@ func mapTags(model map[string]*Tag) map[string]output.Tag {
 	}
 	return ret
 }
+
+func MapPullRequest(model PullRequest) output.PullRequest {
+	return output.PullRequest{
+		CheckoutObject: MapCheckoutObject(model.CheckoutObject),
+		Number:         model.Number,
+		Ref:            model.Ref,
+	}
+}
+
+func mapPullRequests(model map[string]*PullRequest) map[string]output.PullRequest {
+	ret := make(map[string]output.PullRequest)
+	for k, v := range model {
+		ret[k] = MapPullRequest(*v)
+	}
+	return ret
+}
+
+func mapCheckout(model CheckoutConfiguration) output.Checkout {
+	return output.Checkout{
+		Depth:      model.Depth,
+		Filter:     model.Filter,
+		Sparse:     model.Sparse,
+		Submodules: model.Submodules,
+		LFS:        model.LFS,
+	}
+}
+
+// mapGitAuth maps the credentials leaving the passphrase and the token out
+func mapGitAuth(model GitAuth) output.GitAuth {
+	return output.GitAuth{
+		SSH: output.GitSSHAuth{
+			Key:            model.SSH.Key,
+			HasPassphrase:  model.SSH.Passphrase != "",
+			KnownHosts:     string(model.SSH.KnownHosts),
+			KnownHostsFile: model.SSH.KnownHostsFile,
+		},
+		HTTPS: output.GitHTTPSAuth{
+			Username: model.HTTPS.Username,
+			HasToken: model.HTTPS.Token != "",
+		},
+	}
+}
+
+// mapPullRequestsConfiguration maps the configuration leaving the forge token out
+func mapPullRequestsConfiguration(model PullRequestsConfiguration) output.PullRequests {
+	return output.PullRequests{
+		CommitStatus: output.CommitStatus{
+			Provider:   string(model.CommitStatus.Provider),
+			URL:        model.CommitStatus.URL,
+			Repository: model.CommitStatus.Repository,
+			Context:    model.CommitStatus.Context,
+		},
+	}
+}
@@ -16,6 +16,8 @@ import (
 var (
 	ApplicationStatusLoading ApplicationStatus = "loading"
 	ApplicationStatusReady   ApplicationStatus = "ready"
+	// ApplicationStatusRetired - The application has been removed from the configuration
+	ApplicationStatusRetired ApplicationStatus = "retired"
 )
 
 type Application struct {
@@ -29,10 +31,12 @@ type Application struct {
 	HashToObjectsMap        map[string]*RemoteObject  `json:"-"`
 	BranchesMap             map[string]*Branch        `json:"branchesMap"`
 	TagsMap                 map[string]*Tag           `json:"tagsMap"`
+	PullRequestsMap         map[string]*PullRequest   `json:"pullRequestsMap"`
 	Commits                 []string                  `json:"-"`
 	CommitMap               map[string]*object.Commit `json:"-"`
 	CompiledForwardPatterns []CompiledForwardPattern  `json:"-"`
 	notifications           []ApplicationNotification
+	fetch                   ApplicationFetchStatus
 	bus                     *ApplicationEventBus
 	log                     logging.Logger
 }
@@ -55,8 +59,9 @@ type ApplicationCommandOutput struct {
 }
 
 type RemoteObject struct {
-	Branches []string
-	Tags     []string
+	Branches     []string
+	Tags         []string
+	PullRequests []string
 }
 
 type CheckoutObject struct {
@@ -102,8 +107,10 @@ func newApplication(
 	application.HashToObjectsMap = make(map[string]*RemoteObject)
 	application.BranchesMap = make(map[string]*Branch)
 	application.TagsMap = make(map[string]*Tag)
+	application.PullRequestsMap = make(map[string]*PullRequest)
 	application.Commits = []string{}
 	application.CommitMap = make(map[string]*object.Commit)
+	application.fetch = ApplicationFetchStatus{State: ApplicationFetchStateIdle}
 	if application.notifications == nil {
 		application.notifications = []ApplicationNotification{}
 	}
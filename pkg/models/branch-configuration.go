@@ -2,6 +2,7 @@ package models
 
 import (
 	"regexp"
+	"sort"
 
 	"github.com/wufe/polo/pkg/logging"
 )
@@ -29,16 +30,42 @@ func (branches Branches) BranchIsMain(branch string, logger logging.Logger) bool
 	return ok && foundBranch.Main
 }
 
+// FirstMainBranch retrieves the branch flagged as "main" among the given ones,
+// preferring the branch matched by the first of the branch rules, and then by name,
+// for the same branch to be chosen whatever the order of the given ones
+func (branches Branches) FirstMainBranch(names []string, logger logging.Logger) (string, bool) {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	found := ""
+	foundIndex := len(branches)
+	for _, name := range sorted {
+		index := branches.findBranchConfigurationIndex(name, logger)
+		if index >= 0 && index < foundIndex && branches[index].Main {
+			found = name
+			foundIndex = index
+		}
+	}
+	return found, found != ""
+}
+
 func (branches Branches) findBranchConfiguration(name string, logger logging.Logger) (BranchConfigurationMatch, bool) {
-	for _, b := range branches {
+	index := branches.findBranchConfigurationIndex(name, logger)
+	if index < 0 {
+		return BranchConfigurationMatch{}, false
+	}
+	return branches[index], true
+}
+
+func (branches Branches) findBranchConfigurationIndex(name string, logger logging.Logger) int {
+	for i, b := range branches {
 		test, err := regexp.Compile(b.Test)
 		if err != nil {
 			logger.Errorf("Could not compile branch test regexp: %s", err.Error())
 			continue
 		}
 		if test.MatchString(name) {
-			return b, true
+			return i
 		}
 	}
-	return BranchConfigurationMatch{}, false
+	return -1
 }
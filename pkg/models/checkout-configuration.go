@@ -0,0 +1,56 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var checkoutFilterRegex = regexp.MustCompile(`^(blob:none|blob:limit=\d+[kmg]?|tree:\d+|object:type=(blob|tree|commit|tag)|sparse:oid=\S+|combine:\S+)$`)
+
+// CheckoutConfiguration are the options of the clones of the session folders,
+// for them not to hold the whole repository (e.g. a monorepo),
+// and of the contents checked out along with the commit (submodules and LFS objects).
+// The base folder of the application, fetched to find the commits, keeps the whole history
+type CheckoutConfiguration struct {
+	Depth      int      `yaml:"depth" json:"depth"`   // Commits of history cloned; 0 for all of them
+	Filter     string   `yaml:"filter" json:"filter"` // Partial clone filter (e.g. blob:none)
+	Sparse     []string `yaml:"sparse" json:"sparse"` // Patterns of the paths checked out, as in .gitignore
+	Submodules bool     `yaml:"submodules" json:"submodules"`
+	LFS        bool     `yaml:"lfs" json:"lfs"`
+}
+
+// IsPartial tells whether the session folders are not full clones
+func (c CheckoutConfiguration) IsPartial() bool {
+	return c.Depth > 0 || c.Filter != "" || len(c.Sparse) > 0
+}
+
+// Contents retrieves the options of the contents checked out along with the commit,
+// which apply to the base folder copied into the session folders (use_folder_copy) too
+func (c CheckoutConfiguration) Contents() CheckoutConfiguration {
+	return CheckoutConfiguration{
+		Sparse:     []string{},
+		Submodules: c.Submodules,
+		LFS:        c.LFS,
+	}
+}
+
+func newCheckoutConfiguration(configuration CheckoutConfiguration) (CheckoutConfiguration, error) {
+	errs := ConfigurationErrors{}
+	if configuration.Depth < 0 {
+		errs.Add(errors.New("application.checkout.depth cannot be negative"))
+	}
+	if configuration.Filter != "" && !checkoutFilterRegex.MatchString(configuration.Filter) {
+		errs.Add(fmt.Errorf("application.checkout.filter %q is not a valid filter (e.g. blob:none, blob:limit=1m, tree:0)", configuration.Filter))
+	}
+	if configuration.Sparse == nil {
+		configuration.Sparse = []string{}
+	}
+	for i, pattern := range configuration.Sparse {
+		if strings.TrimSpace(pattern) == "" {
+			errs.Add(fmt.Errorf("application.checkout.sparse[%d] is empty", i))
+		}
+	}
+	return configuration, errs.Err()
+}
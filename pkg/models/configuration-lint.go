@@ -0,0 +1,304 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	"github.com/kennygrant/sanitize"
+)
+
+const (
+	ConfigurationIssueSeverityError   ConfigurationIssueSeverity = "error"
+	ConfigurationIssueSeverityWarning ConfigurationIssueSeverity = "warning"
+)
+
+// ConfigurationIssueSeverity tells whether an issue prevents the configuration
+// from working (error) or is likely a mistake (warning)
+type ConfigurationIssueSeverity string
+
+// ConfigurationIssue is a problem found validating a configuration file.
+// Path locates the offending key (e.g. applications[0].branches[1].test)
+type ConfigurationIssue struct {
+	File     string                     `json:"file"`
+	Line     int                        `json:"line"`
+	Column   int                        `json:"column"`
+	Path     string                     `json:"path"`
+	Severity ConfigurationIssueSeverity `json:"severity"`
+	Message  string                     `json:"message"`
+}
+
+func (i ConfigurationIssue) String() string {
+	position := i.File
+	if i.Line > 0 {
+		position = fmt.Sprintf("%s:%d:%d", i.File, i.Line, i.Column)
+	}
+	if i.Path != "" {
+		return fmt.Sprintf("%s: %s: %s (%s)", position, i.Severity, i.Message, i.Path)
+	}
+	return fmt.Sprintf("%s: %s: %s", position, i.Severity, i.Message)
+}
+
+// ConfigurationValidation is the outcome of the validation of a set of configuration files;
+// they are valid if no error has been found
+type ConfigurationValidation struct {
+	Valid    bool                 `json:"valid"`
+	Errors   int                  `json:"errors"`
+	Warnings int                  `json:"warnings"`
+	Issues   []ConfigurationIssue `json:"issues"`
+}
+
+func NewConfigurationValidation(issues []ConfigurationIssue) ConfigurationValidation {
+	validation := ConfigurationValidation{Issues: issues}
+	for _, issue := range issues {
+		if issue.Severity == ConfigurationIssueSeverityError {
+			validation.Errors++
+		} else {
+			validation.Warnings++
+		}
+	}
+	validation.Valid = validation.Errors == 0
+	return validation
+}
+
+var (
+	placeholderRegex    = regexp.MustCompile(`{{([^{}]*)}}`)
+	outputMarkerRegex   = regexp.MustCompile(`polo\[([^\]=]+?)=`)
+	onDemandPortRegex   = regexp.MustCompile(`^port\d*$`)
+	dependencyVariables = []string{"target", "port", "uuid", "checkout", "commit"}
+	// Branch names a rule matching everything surely matches
+	branchProbes = []string{"", "a", "main", "dev", "feature/ABC-123", "release/1.0.0", "pr-1", "v1.0.0", "_"}
+)
+
+// LintApplicationConfiguration looks for the problems of an application configuration
+// which do not prevent it from being built: invalid regexes in the branch rules,
+// branch rules which can never be reached and placeholders which can never be resolved.
+// Stack members are the names of the applications sharing a stack with this one,
+// whose variables are available as {{deps.<id>.*}}.
+// Paths are relative to the application (e.g. branches[1].test)
+func LintApplicationConfiguration(configuration *ApplicationConfiguration, stackMembers []string) []ConfigurationIssue {
+	issues := []ConfigurationIssue{}
+	addIssue := func(severity ConfigurationIssueSeverity, path string, format string, args ...interface{}) {
+		issues = append(issues, ConfigurationIssue{
+			Path:     path,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	// Branch rules
+	tests := make([]*regexp.Regexp, len(configuration.Branches))
+	for i, branch := range configuration.Branches {
+		path := fmt.Sprintf("branches[%d]", i)
+		test, err := regexp.Compile(branch.Test)
+		if err != nil {
+			addIssue(ConfigurationIssueSeverityError, path+".test", "%q is not a valid regex: %s", branch.Test, err.Error())
+		} else {
+			tests[i] = test
+		}
+		for j, forward := range branch.Forwards {
+			if _, err := regexp.Compile(forward.Pattern); err != nil {
+				addIssue(ConfigurationIssueSeverityError, fmt.Sprintf("%s.forwards[%d].pattern", path, j), "%q is not a valid regex: %s", forward.Pattern, err.Error())
+			}
+		}
+	}
+	for i, test := range tests {
+		if test == nil {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if tests[j] != nil && shadowsBranchTest(tests[j], test) {
+				addIssue(ConfigurationIssueSeverityWarning, fmt.Sprintf("branches[%d].test", i), "branch rule %q is unreachable: every branch it matches is matched by branches[%d] (%q) first", test.String(), j, tests[j].String())
+				break
+			}
+		}
+	}
+
+	// Placeholders
+	known := knownVariables(configuration, stackMembers)
+	checkPlaceholders := func(path string, value string, extra ...string) {
+		for _, match := range placeholderRegex.FindAllStringSubmatch(value, -1) {
+			name := match[1]
+			if known[name] || onDemandPortRegex.MatchString(name) || containsString(extra, name) {
+				continue
+			}
+			// Without the secrets, their names cannot be checked
+			if strings.HasPrefix(name, SecretPlaceholderPrefix) && configuration.Secrets == nil {
+				continue
+			}
+			if strings.HasPrefix(name, SecretPlaceholderPrefix) {
+				addIssue(ConfigurationIssueSeverityWarning, path, "placeholder {{%s}} is never set; secret %s is not defined by the secrets source", name, strings.TrimPrefix(name, SecretPlaceholderPrefix))
+				continue
+			}
+			if trimmed := strings.TrimSpace(name); trimmed != name {
+				addIssue(ConfigurationIssueSeverityWarning, path, "placeholder {{%s}} is never replaced: placeholders cannot contain spaces; use {{%s}}", name, trimmed)
+				continue
+			}
+			addIssue(ConfigurationIssueSeverityWarning, path, "placeholder {{%s}} is never set; declare it as output_variable of a command or print polo[%s=<value>] from a command", name, name)
+		}
+	}
+	checkShared := func(prefix string, shared SharedConfiguration) {
+		checkPlaceholders(prefix+"target", shared.Target)
+		checkHeaders := func(path string, headers Headers) {
+			for kind, values := range map[string][]Header{"add": headers.Add, "set": headers.Set, "replace": headers.Replace} {
+				for i, header := range values {
+					checkPlaceholders(fmt.Sprintf("%s.%s[%d]", path, kind, i), string(header))
+				}
+			}
+		}
+		checkHeaders(prefix+"headers", shared.Headers)
+		for i, forward := range shared.Forwards {
+			path := fmt.Sprintf("%sforwards[%d]", prefix, i)
+			checkPlaceholders(path+".to", forward.To)
+			checkHeaders(path+".headers", forward.Headers)
+		}
+		for i, warmup := range shared.Warmup.URLs {
+			checkPlaceholders(fmt.Sprintf("%swarmup.urls[%d].url", prefix, i), warmup.URL)
+		}
+		for kind, commands := range map[string][]Command{"start": shared.Commands.Start, "stop": shared.Commands.Stop, "clean": shared.Commands.Clean} {
+			for i, command := range commands {
+				path := fmt.Sprintf("%scommands.%s[%d]", prefix, kind, i)
+				checkPlaceholders(path+".command", command.Command)
+				for j, variable := range command.Environment {
+					checkPlaceholders(fmt.Sprintf("%s.environment[%d]", path, j), variable)
+				}
+			}
+		}
+	}
+	checkShared("", configuration.SharedConfiguration)
+	for i, branch := range configuration.Branches {
+		checkShared(fmt.Sprintf("branches[%d].", i), branch.SharedConfiguration)
+	}
+	for i, source := range configuration.Logs {
+		path := fmt.Sprintf("logs[%d]", i)
+		checkPlaceholders(path+".command", source.Command)
+		for j, variable := range source.Environment {
+			checkPlaceholders(fmt.Sprintf("%s.environment[%d]", path, j), variable)
+		}
+	}
+	for i, dependency := range configuration.Dependencies {
+		checkPlaceholders(fmt.Sprintf("dependencies[%d].checkout", i), dependency.Checkout, "checkout")
+	}
+	webhookVariables := []string{"event", "application", "message", "timestamp"}
+	for _, name := range []string{"uuid", "name", "checkout", "display_name", "commit", "variant", "status", "kill_reason", "target", "url"} {
+		webhookVariables = append(webhookVariables, "session."+name)
+	}
+	for i, webhook := range configuration.Webhooks {
+		for _, match := range placeholderRegex.FindAllStringSubmatch(webhook.Payload, -1) {
+			if !containsString(webhookVariables, match[1]) {
+				addIssue(ConfigurationIssueSeverityWarning, fmt.Sprintf("webhooks[%d].payload", i), "placeholder {{%s}} is not a webhook variable; use one of %s", match[1], strings.Join(webhookVariables, ", "))
+			}
+		}
+	}
+
+	// Checkout
+	if configuration.UseFolderCopy && configuration.Checkout.IsPartial() {
+		addIssue(ConfigurationIssueSeverityWarning, "checkout", "depth, filter and sparse are ignored with use_folder_copy: the session folders are copied from the base folder")
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Path < issues[j].Path
+	})
+	return issues
+}
+
+// knownVariables retrieves the names of the variables a session may be given:
+// the built-in ones, the build parameters, the secrets, the output variables of the commands,
+// the variables printed by the commands (polo[name=value]) and those of the dependencies
+func knownVariables(configuration *ApplicationConfiguration, stackMembers []string) map[string]bool {
+	known := map[string]bool{
+		"uuid":        true,
+		"name":        true,
+		"port":        true,
+		"commit":      true,
+		"variant":     true,
+		"last_output": true,
+	}
+	for _, parameter := range configuration.Parameters {
+		known["param."+parameter.Name] = true
+	}
+	for name := range configuration.Secrets {
+		known[SecretPlaceholderPrefix+name] = true
+	}
+	addCommands := func(commands Commands) {
+		for _, list := range [][]Command{commands.Start, commands.Stop, commands.Clean} {
+			for _, command := range list {
+				if command.OutputVariable != "" {
+					known[command.OutputVariable] = true
+				}
+				for _, match := range outputMarkerRegex.FindAllStringSubmatch(command.Command, -1) {
+					known[match[1]] = true
+				}
+			}
+		}
+	}
+	addCommands(configuration.Commands)
+	for _, branch := range configuration.Branches {
+		addCommands(branch.Commands)
+	}
+	dependencies := []string{}
+	for _, dependency := range configuration.Dependencies {
+		dependencies = append(dependencies, dependency.Name)
+	}
+	for _, member := range stackMembers {
+		dependencies = append(dependencies, sanitize.Name(member))
+	}
+	for _, dependency := range dependencies {
+		for _, variable := range dependencyVariables {
+			known[fmt.Sprintf("deps.%s.%s", dependency, variable)] = true
+		}
+	}
+	return known
+}
+
+// shadowsBranchTest tells whether every branch name matched by the later test
+// is surely matched by the earlier one: it happens when the tests are the same,
+// when the earlier one matches everything or when the later one
+// matches a single name (e.g. ^main$) which the earlier one matches too
+func shadowsBranchTest(earlier *regexp.Regexp, later *regexp.Regexp) bool {
+	if earlier.String() == later.String() {
+		return true
+	}
+	matchesEverything := true
+	for _, probe := range branchProbes {
+		if !earlier.MatchString(probe) {
+			matchesEverything = false
+			break
+		}
+	}
+	if matchesEverything {
+		return true
+	}
+	if literal, ok := exactLiteral(later.String()); ok {
+		return earlier.MatchString(literal)
+	}
+	return false
+}
+
+// exactLiteral retrieves the only string matched by an anchored literal regex (e.g. ^main$)
+func exactLiteral(expression string) (string, bool) {
+	parsed, err := syntax.Parse(expression, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	parsed = parsed.Simplify()
+	if parsed.Op != syntax.OpConcat || len(parsed.Sub) != 3 {
+		return "", false
+	}
+	begin, literal, end := parsed.Sub[0], parsed.Sub[1], parsed.Sub[2]
+	if begin.Op != syntax.OpBeginText || end.Op != syntax.OpEndText || literal.Op != syntax.OpLiteral || literal.Flags&syntax.FoldCase != 0 {
+		return "", false
+	}
+	return string(literal.Rune), true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,370 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// JSONSchema is a JSON Schema (draft-07) describing the configuration files
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+}
+
+// YAMLField is a field of a configuration struct as named by the YAML decoder:
+// the name in the yaml tag or the lowercased field name.
+// Owner is the struct declaring the field, which differs from the decoded one
+// for the fields of the inlined structs
+type YAMLField struct {
+	Name  string
+	Owner reflect.Type
+	reflect.StructField
+}
+
+// YAMLFields retrieves the fields of a configuration struct decoded from YAML,
+// in order of declaration, inlining the ",inline" fields
+func YAMLFields(t reflect.Type) []YAMLField {
+	fields := []YAMLField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Type.Kind() == reflect.Interface {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		inline := false
+		for _, option := range tag[1:] {
+			inline = inline || option == "inline"
+		}
+		if inline {
+			fields = append(fields, YAMLFields(field.Type)...)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields = append(fields, YAMLField{Name: name, Owner: t, StructField: field})
+	}
+	return fields
+}
+
+// schemaDoc documents a field of the configuration.
+// Enum applies to the items of the lists
+type schemaDoc struct {
+	description string
+	def         interface{}
+	enum        []interface{}
+	required    bool
+}
+
+// schemaComputedFields are decoded but always overwritten, hence not documented
+var schemaComputedFields = map[string]bool{
+	"ApplicationConfiguration.ID":   true,
+	"ApplicationConfiguration.Hash": true,
+}
+
+// schemaEnums are the values allowed for the enumerated types
+var schemaEnums = map[reflect.Type][]interface{}{
+	reflect.TypeOf(HelperPosition("")):       {"bottom-left", "left-bottom", "bottom-right", "right-bottom", "top-left", "left-top", "top-right", "right-top"},
+	reflect.TypeOf(ParameterType("")):        {string(ParameterTypeString), string(ParameterTypeEnum), string(ParameterTypeBool)},
+	reflect.TypeOf(WebhookPreset("")):        {string(WebhookPresetSlack), string(WebhookPresetTeams)},
+	reflect.TypeOf(CommitStatusProvider("")): {string(CommitStatusProviderGitHub), string(CommitStatusProviderGitLab), string(CommitStatusProviderGitea)},
+	reflect.TypeOf(GitAuthKnownHosts("")):    {string(GitAuthKnownHostsStrict), string(GitAuthKnownHostsAcceptNew), string(GitAuthKnownHostsInsecure)},
+}
+
+// schemaDocs documents the fields of the configuration, by "<struct>.<field>".
+// The fields of inlined structs are looked up by the decoded struct first
+// (e.g. Healthcheck.URL), then by the declaring one (e.g. RequestConfiguration.URL)
+var schemaDocs = map[string]schemaDoc{
+	// Root
+	"RootConfiguration.Global":                    {description: "Settings of the Polo server; if defined in more than one file, the last one wins"},
+	"RootConfiguration.Include":                   {description: "Further configuration files, folders or globs to load, relative to this file"},
+	"RootConfiguration.Templates":                 {description: "Partial applications, by name, extended by the applications of any configuration file"},
+	"RootConfiguration.ApplicationConfigurations": {description: "Applications served by Polo"},
+	"RootConfiguration.Stacks":                    {description: "Sets of applications started together for the same checkout, reachable at /s/<stack>/<checkout>"},
+
+	// Global
+	"GlobalConfiguration.Port":                  {description: "Port the server listens on", def: 8888},
+	"GlobalConfiguration.TLSCertFile":           {description: "Path of the TLS certificate; HTTPS is enabled if both certificate and key are set"},
+	"GlobalConfiguration.TLSKeyFile":            {description: "Path of the TLS private key"},
+	"GlobalConfiguration.SessionsFolder":        {description: "Folder the sessions are checked out into", def: "./.sessions"},
+	"GlobalConfiguration.MaxConcurrentSessions": {description: "Maximum number of sessions alive at the same time, for all the applications", def: 10},
+	"GlobalConfiguration.PublicURL":             {description: "Base URL Polo is reachable at, used in the links sent by the webhooks and the commit statuses; defaults to http://localhost:<port>"},
+	"GlobalConfiguration.Logs":                  {description: "Storage of the session logs"},
+	"GlobalConfiguration.Tracing":               {description: "OpenTelemetry traces of the session builds and of the proxied requests"},
+	"GlobalConfiguration.Secrets":               {description: "Source of the secrets, available to commands, headers and environment variables as {{secret.<name>}} and redacted from logs and outputs"},
+	"SecretsConfiguration.File":                 {description: "Dotenv file of NAME=value lines; relative to the folder of the configuration file"},
+	"SecretsConfiguration.Folder":               {description: "Folder containing a file per secret, named after it (e.g. /run/secrets); its files override the secrets of the dotenv file"},
+	"TracingConfiguration.Endpoint":             {description: "Base URL of the OTLP/HTTP collector (e.g. http://localhost:4318); tracing is disabled if empty"},
+	"TracingConfiguration.ServiceName":          {description: "Name of the service in the traces", def: "polo"},
+	"LogsConfiguration.Retention":               {description: "How long and how many session logs are kept after the session has been destroyed"},
+	"LogsRetention.MaxAge":                      {description: "Hours the logs are kept for; -1 to keep them forever", def: 168},
+	"LogsRetention.MaxSize":                     {description: "Kilobytes of logs kept per session, removing the oldest first; -1 for no limit", def: 1024},
+
+	// Application
+	"ApplicationConfiguration.Name":                  {description: "Name of the application", required: true},
+	"ApplicationConfiguration.Extends":               {description: "Template the application is deep-merged over: mappings are merged, values and lists are replaced and null removes the inherited value; the branch rules apply last"},
+	"ApplicationConfiguration.Fetch":                 {description: "Fetch of the remote repository"},
+	"ApplicationConfiguration.IsDefault":             {description: "Makes the application reachable at /s/<checkout>"},
+	"ApplicationConfiguration.MaxConcurrentSessions": {description: "Maximum number of sessions of the application alive at the same time", def: 5},
+	"ApplicationConfiguration.Branches":              {description: "Rules applied to the branches (and tags) whose name matches their test; the first matching rule wins"},
+	"ApplicationConfiguration.UseFolderCopy":         {description: "Copies the files of the repository instead of cloning it for each session"},
+	"ApplicationConfiguration.CleanOnExit":           {description: "Removes the folders of the sessions when Polo exits", def: true},
+	"ApplicationConfiguration.Dependencies":          {description: "Sessions of other applications built (or reused) before each session of this one"},
+	"ApplicationConfiguration.Parameters":            {description: "Build parameters supplied when requesting a session, available as {{param.<name>}}"},
+	"ApplicationConfiguration.Logs":                  {description: "Sources of the runtime logs, tailed once the start commands have returned"},
+	"ApplicationConfiguration.Webhooks":              {description: "HTTP endpoints notified with a POST request of the session and application events"},
+	"ApplicationConfiguration.PullRequests":          {description: "Previews of the pull requests, checked out as pr-<number>"},
+	"ApplicationConfiguration.Remote":                {required: true},
+	"ApplicationConfiguration.Commands":              {required: true},
+	"ApplicationConfiguration.Target":                {def: "http://127.0.0.1:{{port}}"},
+
+	// Shared by the application and the branch rules
+	"SharedConfiguration.Commands":    {description: "Commands run to start, stop and clean the sessions; they accept placeholders"},
+	"SharedConfiguration.Forwards":    {description: "Requests forwarded to other targets, by path"},
+	"SharedConfiguration.Headers":     {description: "Headers of the proxied requests; values accept placeholders"},
+	"SharedConfiguration.Healthcheck": {description: "Request checking that a session is up and running"},
+	"SharedConfiguration.Helper":      {description: "Overlay shown in the pages of the sessions"},
+	"SharedConfiguration.Host":        {description: "Host the requests to the sessions are proxied with"},
+	"SharedConfiguration.Port":        {description: "Ports assigned to the sessions ({{port}})"},
+	"SharedConfiguration.Recycle":     {description: "Destruction of the inactive sessions"},
+	"SharedConfiguration.Remote":      {description: "URL of the git repository"},
+	"SharedConfiguration.Startup":     {description: "Startup of the sessions"},
+	"SharedConfiguration.Target":      {description: "URL the requests are proxied to; accepts placeholders"},
+	"SharedConfiguration.Warmup":      {description: "Requests made to a session once it is up, before it gets available"},
+	"Warmups.MaxRetries":              {description: "Attempts of each warmup request"},
+	"Warmups.RetryInterval":           {description: "Seconds between the attempts; -1 for no delay", def: 5},
+	"Warmups.URLs":                    {description: "Warmup requests"},
+	"Warmup.URL":                      {description: "Path (or URL) requested; accepts placeholders", required: true},
+	"Healthcheck.URL":                 {description: "Path (or URL) requested", def: "/"},
+	"Healthcheck.MaxRetries":          {description: "Attempts before considering the session failed", def: 5},
+	"Healthcheck.RetryInterval":       {description: "Seconds between the attempts", def: 30},
+	"RequestConfiguration.Method":     {description: "HTTP method", def: "GET"},
+	"RequestConfiguration.URL":        {description: "Path (or URL) requested"},
+	"RequestConfiguration.Status":     {description: "Expected status code", def: 200},
+	"RequestConfiguration.Timeout":    {description: "Seconds each attempt may last", def: 20},
+	"Recycle.InactivityTimeout":       {description: "Seconds without requests after which a session gets destroyed", def: 3600},
+	"Startup.Timeout":                 {description: "Seconds a session may take to start", def: 300},
+	"Startup.Retries":                 {description: "Attempts of building a session"},
+	"PortConfiguration.Except":        {description: "Ports never assigned to the sessions"},
+	"Helper.Position":                 {description: "Corner of the page the overlay is shown in; defaults to bottom-left"},
+	"Headers.Add":                     {description: "Headers added to the request, as Name=value"},
+	"Headers.Set":                     {description: "Headers set on the request, as Name=value"},
+	"Headers.Del":                     {description: "Names of the headers removed from the request"},
+	"Headers.Replace":                 {description: "Headers replaced if present in the request, as Name=value"},
+	"Forward.Pattern":                 {description: "Regex matched against the path of the request", required: true},
+	"Forward.To":                      {description: "URL the matching requests are forwarded to; accepts placeholders and the groups of the pattern ($1)", required: true},
+	"Forward.Host":                    {description: "Host the matching requests are forwarded with"},
+	"Forward.Headers":                 {description: "Headers of the forwarded requests"},
+
+	// Commands
+	"Commands.Start":              {description: "Commands starting a session; at least one is required for the application"},
+	"Commands.Stop":               {description: "Commands stopping a session; at least one is required for the application"},
+	"Commands.Clean":              {description: "Commands run after a session has failed or has been destroyed"},
+	"Command.Command":             {description: "Command line; accepts placeholders such as {{port}} and {{param.<name>}}", required: true},
+	"Command.Environment":         {description: "Environment variables, as NAME=value; they accept placeholders"},
+	"Command.OutputVariable":      {description: "Variable the output of the command is stored into"},
+	"Command.ContinueOnError":     {description: "Keeps executing the next commands if this one fails"},
+	"Command.WorkingDir":          {description: "Working directory, relative to the session folder"},
+	"Command.StartHealthchecking": {description: "Starts the healthchecks once this command has returned"},
+	"Command.Timeout":             {description: "Seconds the command may last"},
+	"Command.Supervise":           {description: "Keeps the command running in background, its output going into the runtime logs; start commands only"},
+
+	// Fetch
+	"Fetch.Interval":          {description: "Seconds between the fetches of the remote", def: 60},
+	"Fetch.Hook":              {description: "Push webhooks received at /_polo_/api/hooks/<application>, triggering an immediate fetch"},
+	"PushHook.Secret":         {description: "Secret verifying the signature of the push webhooks; they are enabled if set"},
+	"PushHook.OnlyPushedRef":  {description: "Fetches only the ref that got pushed"},
+	"PushHook.DisablePolling": {description: "Fetches only when a push webhook is received; requires the secret"},
+	"Fetch.History":           {description: "Window of the history indexed at each fetch, whose commits can be checked out by hash; the commits the refs point to are always indexed"},
+	"FetchHistory.Since":      {description: "Indexes the commits since a date (e.g. 2018-01-01) or an age (e.g. 90d or 720h)", def: DefaultFetchHistorySince},
+	"FetchHistory.MaxCommits": {description: "Maximum number of commits indexed for each ref; 0 for no limit"},
+	"FetchHistory.RefsOnly":   {description: "Indexes only the commits the refs point to"},
+
+	// Branches
+	"BranchConfigurationMatch.Test": {description: "Regex matched against the name of the branch (or tag)"},
+	"BranchConfiguration.Main":      {description: "Flags the main branch, used as fallback by the dependencies"},
+	"BranchConfiguration.Watch":     {description: "Starts a session of the branch automatically, replacing it at each new commit"},
+
+	// Dependencies
+	"Dependency.Name":        {description: "Name used in the placeholders (e.g. {{deps.<name>.target}}); defaults to the sanitized application name"},
+	"Dependency.Application": {description: "Name of the other application", required: true},
+	"Dependency.Checkout":    {description: "Checkout of the dependency; accepts placeholders", def: "{{checkout}}"},
+	"Dependency.Fallback":    {description: "Checkout used if the requested one does not exist; defaults to the branch flagged as main"},
+
+	// Parameters
+	"Parameter.Name":        {description: "Name of the parameter, available as {{param.<name>}}", required: true},
+	"Parameter.Type":        {description: "Type of the values", def: string(ParameterTypeString)},
+	"Parameter.Default":     {description: "Default value; defaults to the first value for enums and to false for booleans"},
+	"Parameter.Values":      {description: "Allowed values; required for enums"},
+	"Parameter.Description": {description: "Description of the parameter"},
+
+	// Runtime logs
+	"LogSource.File":        {description: "Glob of the files tailed, relative to the session folder; either file or command is required"},
+	"LogSource.Command":     {description: "Command followed until the session gets destroyed; accepts placeholders"},
+	"LogSource.Environment": {description: "Environment variables of the command, as NAME=value"},
+	"LogSource.WorkingDir":  {description: "Working directory of the command, relative to the session folder"},
+
+	// Webhooks
+	"Webhook.URL":           {description: "HTTP(S) URL notified", required: true},
+	"Webhook.Events":        {description: "Events notified; \"*\" for all of them", required: true, enum: webhookEventNames()},
+	"Webhook.Secret":        {description: "Signs the payload with HMAC-SHA256 in the X-Polo-Signature header"},
+	"Webhook.Preset":        {description: "Payload of a chat service; without preset nor payload a JSON document describing the event is sent"},
+	"Webhook.Payload":       {description: "Template of the payload, accepting {{event}}, {{application}}, {{message}}, {{timestamp}} and {{session.*}}"},
+	"Webhook.Headers":       {description: "Headers of the request, as Name=value"},
+	"Webhook.MaxRetries":    {description: "Retries of the failed deliveries, with exponential backoff; -1 not to retry", def: defaultWebhookMaxRetries},
+	"Webhook.RetryInterval": {description: "Seconds before the first retry, doubled at each one", def: defaultWebhookRetryInterval},
+
+	// Pull requests
+	"PullRequestsConfiguration.CommitStatus": {description: "Posts the URL of the previews as commit status; disabled if no provider is set"},
+	"CommitStatusConfiguration.Provider":     {description: "Forge the statuses are posted to"},
+	"CommitStatusConfiguration.URL":          {description: "Base URL of the forge; defaults to the public API for GitHub and GitLab, required for Gitea"},
+	"CommitStatusConfiguration.Token":        {description: "API token of the forge"},
+	"CommitStatusConfiguration.Repository":   {description: "Repository, as owner/name, or the project ID on GitLab"},
+	"CommitStatusConfiguration.Context":      {description: "Name of the status", def: "polo"},
+
+	// Git credentials
+	"ApplicationConfiguration.Auth": {description: "Credentials used to clone and fetch the remote, instead of the ones of the user running Polo"},
+	"GitAuth.SSH":                   {description: "Credentials of the SSH remotes"},
+	"GitAuth.HTTPS":                 {description: "Credentials of the HTTPS remotes"},
+	"GitSSHAuth.Key":                {description: "Path of the private key; the SSH agent is used if not set"},
+	"GitSSHAuth.Passphrase":         {description: "Passphrase of the private key; may be a secret ({{secret.<name>}})"},
+	"GitSSHAuth.KnownHosts":         {description: "Check of the host keys: strict accepts only the known hosts, accept-new adds the unknown ones to the known hosts file, insecure does not check them; defaults to strict"},
+	"GitSSHAuth.KnownHostsFile":     {description: "Known hosts file; defaults to ~/.ssh/known_hosts"},
+	"GitHTTPSAuth.Username":         {description: "Username; defaults to git, as any is accepted along with a token by GitHub, GitLab and Gitea"},
+	"GitHTTPSAuth.Token":            {description: "Token or password; may be a secret ({{secret.<name>}})"},
+
+	// Checkout
+	"ApplicationConfiguration.Checkout": {description: "Options of the clones of the session folders, e.g. for monorepos, and of the contents checked out along with the commits; the base folder keeps the whole history and use_folder_copy ignores depth, filter and sparse"},
+	"CheckoutConfiguration.Depth":       {description: "Commits of history cloned; 0 for all of them"},
+	"CheckoutConfiguration.Filter":      {description: "Partial clone filter, e.g. blob:none to download the files of the checked out commit only"},
+	"CheckoutConfiguration.Sparse":      {description: "Patterns of the paths checked out, as in .gitignore (e.g. /services/api/); all of them if empty"},
+	"CheckoutConfiguration.Submodules":  {description: "Syncs and updates the submodules recursively after checking out a commit"},
+	"CheckoutConfiguration.LFS":         {description: "Fetches and checks out the LFS objects, of the submodules too, after checking out a commit; requires git-lfs"},
+
+	// Stacks
+	"StackConfiguration.Name":          {description: "Name of the stack, used in its URL", required: true},
+	"StackConfiguration.DefaultBranch": {description: "Checkout used by the applications missing the requested one"},
+	"StackConfiguration.Applications":  {description: "Applications of the stack", required: true},
+	"StackApplication.Application":     {description: "Name of the application", required: true},
+	"StackApplication.Fallback":        {description: "Checkout used if the requested one does not exist; defaults to the default branch of the stack"},
+	"StackApplication.Entry":           {description: "Serves the requests to the stack; defaults to the first application"},
+}
+
+// ConfigurationSchema builds the JSON Schema of the configuration files
+func ConfigurationSchema() *JSONSchema {
+	schema := newJSONSchema(reflect.TypeOf(RootConfiguration{}))
+	schema.Schema = jsonSchemaDraft
+	schema.Title = "Polo configuration"
+	schema.Description = "Configuration file of Polo, a git-based reverse proxy serving the branches of the applications"
+
+	// The applications extending a template may inherit the required fields
+	application := schema.Properties["applications"].Items
+	required, inherited := []string{}, []string{}
+	for _, name := range application.Required {
+		if name == "name" {
+			required = append(required, name)
+		} else {
+			inherited = append(inherited, name)
+		}
+	}
+	application.Required = required
+	application.AnyOf = []*JSONSchema{{Required: []string{"extends"}}, {Required: inherited}}
+	// The templates are partial applications, named by the applications extending them
+	if template, ok := schema.Properties["templates"].AdditionalProperties.(*JSONSchema); ok {
+		template.Required = nil
+		delete(template.Properties, "name")
+	}
+	return schema
+}
+
+func newJSONSchema(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if enum, ok := schemaEnums[t]; ok {
+		return &JSONSchema{Type: "string", Enum: enum}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: newJSONSchema(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: newJSONSchema(t.Elem())}
+	case reflect.Struct:
+		schema := &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}, AdditionalProperties: false}
+		for _, field := range YAMLFields(t) {
+			if schemaComputedFields[field.Owner.Name()+"."+field.StructField.Name] {
+				continue
+			}
+			property := newJSONSchema(field.Type)
+			doc := lookupSchemaDoc(t, field)
+			if doc.description != "" {
+				property.Description = doc.description
+			}
+			property.Default = doc.def
+			if doc.enum != nil {
+				if property.Items != nil {
+					property.Items.Enum = doc.enum
+				} else {
+					property.Enum = doc.enum
+				}
+			}
+			if doc.required {
+				schema.Required = append(schema.Required, field.Name)
+			}
+			schema.Properties[field.Name] = property
+		}
+		return schema
+	}
+	return &JSONSchema{}
+}
+
+// lookupSchemaDoc merges the documentation of a field found by the decoded struct
+// with the one found by the declaring struct
+func lookupSchemaDoc(t reflect.Type, field YAMLField) schemaDoc {
+	doc := schemaDocs[field.Owner.Name()+"."+field.StructField.Name]
+	if override, ok := schemaDocs[t.Name()+"."+field.StructField.Name]; ok && t != field.Owner {
+		if override.description != "" {
+			doc.description = override.description
+		}
+		if override.def != nil {
+			doc.def = override.def
+		}
+		if override.enum != nil {
+			doc.enum = override.enum
+		}
+		doc.required = doc.required || override.required
+	}
+	return doc
+}
+
+func webhookEventNames() []interface{} {
+	names := []interface{}{WebhookAllEvents}
+	for _, event := range webhookEvents {
+		names = append(names, event.String())
+	}
+	return names
+}
@@ -5,15 +5,117 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 var (
 	ErrMalformedHeader error = errors.New("Malformed header; the format should be key=value")
 )
 
+// ConfigurationErrors are all the errors found validating a configuration
+type ConfigurationErrors []error
+
+func (e ConfigurationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add collects an error, flattening the ConfigurationErrors
+func (e *ConfigurationErrors) Add(err error) {
+	if errs, ok := err.(ConfigurationErrors); ok {
+		*e = append(*e, errs...)
+		return
+	}
+	*e = append(*e, err)
+}
+
+// Err returns the collected errors, or nil if there are none
+func (e ConfigurationErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
 type RootConfiguration struct {
 	Global                    GlobalConfiguration
-	ApplicationConfigurations []*ApplicationConfiguration `yaml:"applications"`
+	Include                   []string                             `yaml:"include"`
+	Templates                 map[string]*ApplicationConfiguration `yaml:"templates"`
+	ApplicationConfigurations []*ApplicationConfiguration          `yaml:"applications"`
+	Stacks                    []*StackConfiguration                `yaml:"stacks"`
+	// FEATURE: Configuration discovery
+	// Files are the configuration files loaded, in order
+	Files []string `yaml:"-"`
+	// FEATURE: Configuration reload
+	// reload guards the settings which can change at runtime
+	reload sync.RWMutex
+}
+
+// ConfigurationProvenance tells where the values of an application configuration come from,
+// by path (e.g. healthcheck.url); the values not listed are the defaults
+type ConfigurationProvenance map[string]ConfigurationSource
+
+// ConfigurationSource is a configured value, encoded as JSON, along with the template
+// or the application which defined it (e.g. "template web (/etc/polo/base.yml)")
+type ConfigurationSource struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// GetStacks retrieves the stacks configuration
+func (c *RootConfiguration) GetStacks() []*StackConfiguration {
+	c.reload.RLock()
+	defer c.reload.RUnlock()
+	return c.Stacks
+}
+
+// GetFiles retrieves the configuration files loaded, in order
+func (c *RootConfiguration) GetFiles() []string {
+	c.reload.RLock()
+	defer c.reload.RUnlock()
+	return c.Files
+}
+
+// GetMaxConcurrentSessions retrieves the maximum number of sessions
+// alive at the same time, for all the applications
+func (c *RootConfiguration) GetMaxConcurrentSessions() int {
+	c.reload.RLock()
+	defer c.reload.RUnlock()
+	return c.Global.MaxConcurrentSessions
+}
+
+// GetPublicURL retrieves the base URL Polo is reachable at
+func (c *RootConfiguration) GetPublicURL() string {
+	c.reload.RLock()
+	defer c.reload.RUnlock()
+	return c.Global.GetPublicURL()
+}
+
+// Reload applies the settings of a configuration loaded again which can change at runtime:
+// the stacks, the templates, the files loaded and the global
+// max_concurrent_sessions, public_url and secrets
+func (c *RootConfiguration) Reload(reloaded *RootConfiguration) {
+	c.reload.Lock()
+	defer c.reload.Unlock()
+	c.Global.MaxConcurrentSessions = reloaded.Global.MaxConcurrentSessions
+	c.Global.PublicURL = reloaded.Global.PublicURL
+	c.Global.Secrets = reloaded.Global.Secrets
+	c.Stacks = reloaded.Stacks
+	c.Templates = reloaded.Templates
+	c.Files = reloaded.Files
+}
+
+// GetStack retrieves a stack configuration by its name
+func (c *RootConfiguration) GetStack(name string) *StackConfiguration {
+	for _, stack := range c.GetStacks() {
+		if stack.Name == name {
+			return stack
+		}
+	}
+	return nil
 }
 
 type GlobalConfiguration struct {
@@ -22,6 +124,66 @@ type GlobalConfiguration struct {
 	TLSKeyFile            string `yaml:"tls_key,omitempty"`
 	SessionsFolder        string `yaml:"sessions_folder"`
 	MaxConcurrentSessions int    `yaml:"max_concurrent_sessions" json:"maxConcurrentSessions"`
+	PublicURL             string `yaml:"public_url" json:"publicUrl"` // Used to build the links sent by the webhooks; defaults to http://localhost:<port>
+	Logs                  LogsConfiguration
+	Tracing               TracingConfiguration
+	Secrets               SecretsConfiguration `yaml:"secrets" json:"-"`
+}
+
+// NewGlobalConfiguration applies the defaults to the global configuration
+func NewGlobalConfiguration(configuration *GlobalConfiguration) *GlobalConfiguration {
+	if configuration.Port == 0 {
+		configuration.Port = 8888
+	}
+
+	if configuration.SessionsFolder == "" {
+		configuration.SessionsFolder = "./.sessions"
+	}
+
+	if configuration.MaxConcurrentSessions == 0 {
+		configuration.MaxConcurrentSessions = 10
+	}
+
+	// A negative value disables the retention limit
+	if configuration.Logs.Retention.MaxAge == 0 {
+		configuration.Logs.Retention.MaxAge = 168 // 7 days
+	}
+
+	if configuration.Logs.Retention.MaxSize == 0 {
+		configuration.Logs.Retention.MaxSize = 1024 // 1 MB
+	}
+
+	if configuration.Tracing.ServiceName == "" {
+		configuration.Tracing.ServiceName = "polo"
+	}
+	return configuration
+}
+
+// GetPublicURL retrieves the base URL Polo is reachable at
+func (c *GlobalConfiguration) GetPublicURL() string {
+	if c.PublicURL != "" {
+		return strings.TrimSuffix(c.PublicURL, "/")
+	}
+	return fmt.Sprintf("http://localhost:%d", c.Port)
+}
+
+// TracingConfiguration contains the configuration of the traces
+// exported using the OpenTelemetry protocol (OTLP over HTTP).
+// Tracing is disabled if no endpoint is set
+type TracingConfiguration struct {
+	Endpoint    string `yaml:"endpoint" json:"endpoint"` // Base URL of the collector (e.g. http://localhost:4318)
+	ServiceName string `yaml:"service_name" json:"serviceName"`
+}
+
+// LogsConfiguration contains the configuration of the session logs storage
+type LogsConfiguration struct {
+	Retention LogsRetention
+}
+
+// LogsRetention states how long and how many session logs are kept
+type LogsRetention struct {
+	MaxAge  int `yaml:"max_age" json:"maxAge"`   // Hours; logs older than this are removed
+	MaxSize int `yaml:"max_size" json:"maxSize"` // Kilobytes per session; oldest logs are removed first
 }
 
 type Header string
@@ -41,6 +203,24 @@ type Headers struct {
 	Replace []Header `json:"replace"`
 }
 
+// WithVariables returns a copy of the headers
+// whose values have their placeholders replaced by the given variables
+func (h Headers) WithVariables(variables Variables) Headers {
+	applyToHeaders := func(headers []Header) []Header {
+		ret := make([]Header, 0, len(headers))
+		for _, header := range headers {
+			ret = append(ret, Header(variables.ApplyTo(string(header))))
+		}
+		return ret
+	}
+	return Headers{
+		Add:     applyToHeaders(h.Add),
+		Set:     applyToHeaders(h.Set),
+		Del:     h.Del,
+		Replace: applyToHeaders(h.Replace),
+	}
+}
+
 func (h *Headers) ApplyTo(r *http.Request) error {
 	var err error
 	var k string
@@ -100,6 +280,9 @@ type Command struct {
 	WorkingDir          string   `yaml:"working_dir" json:"workingDir"`
 	StartHealthchecking bool     `yaml:"start_healthchecking" json:"startHealthchecking"`
 	Timeout             int      `json:"timeout"`
+	// A supervised start command keeps running in background;
+	// its output goes into the runtime logs and it gets killed with the session
+	Supervise bool `yaml:"supervise" json:"supervise"`
 }
 
 type PortConfiguration struct {
@@ -51,6 +51,22 @@ func (s *contextStore) Add(key string, ctx context.Context, cancel context.Cance
 	}
 }
 
+// GetOrAdd retrieves the named context,
+// adding the one returned by create if it does not exist yet
+func (s *contextStore) GetOrAdd(key string, create func() (context.Context, context.CancelFunc)) (context.Context, context.CancelFunc) {
+	s.Lock()
+	defer s.Unlock()
+	if ctx, exists := s.contexts[key]; exists {
+		return ctx.Context, ctx.Cancel
+	}
+	ctx, cancel := create()
+	s.contexts[key] = &contextAndCancel{
+		Context: ctx,
+		Cancel:  cancel,
+	}
+	return ctx, cancel
+}
+
 func (s *contextStore) Del(key string) {
 	s.Lock()
 	defer s.Unlock()
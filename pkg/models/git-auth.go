@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GitAuthKnownHosts is the policy checking the keys of the SSH hosts
+type GitAuthKnownHosts string
+
+const (
+	// GitAuthKnownHostsStrict - Only the hosts in the known_hosts file are accepted
+	GitAuthKnownHostsStrict GitAuthKnownHosts = "strict"
+	// GitAuthKnownHostsAcceptNew - The unknown hosts get added to the known_hosts file,
+	// while the ones whose key changed are rejected
+	GitAuthKnownHostsAcceptNew GitAuthKnownHosts = "accept-new"
+	// GitAuthKnownHostsInsecure - The keys of the hosts are not checked
+	GitAuthKnownHostsInsecure GitAuthKnownHosts = "insecure"
+)
+
+// GitAuth are the credentials used to reach the remote of an application,
+// instead of the ones of the user running Polo.
+// The passphrase and the token may be secrets ({{secret.<name>}})
+type GitAuth struct {
+	SSH   GitSSHAuth   `yaml:"ssh" json:"ssh"`
+	HTTPS GitHTTPSAuth `yaml:"https" json:"https"`
+}
+
+type GitSSHAuth struct {
+	Key            string            `yaml:"key" json:"key"` // Path of the private key
+	Passphrase     string            `yaml:"passphrase" json:"-"`
+	KnownHosts     GitAuthKnownHosts `yaml:"known_hosts" json:"knownHosts"`
+	KnownHostsFile string            `yaml:"known_hosts_file" json:"knownHostsFile"` // Defaults to ~/.ssh/known_hosts
+}
+
+type GitHTTPSAuth struct {
+	Username string `yaml:"username" json:"username"`
+	Token    string `yaml:"token" json:"-"`
+}
+
+// IsSet tells whether any credentials have been configured
+func (a GitAuth) IsSet() bool {
+	return a.SSH.IsSet() || a.HTTPS.IsSet()
+}
+
+// IsSet tells whether a key or a policy for the known hosts has been configured
+func (a GitSSHAuth) IsSet() bool {
+	return a.Key != "" || a.KnownHosts != "" || a.KnownHostsFile != ""
+}
+
+func (a GitHTTPSAuth) IsSet() bool {
+	return a.Token != ""
+}
+
+// WithSecrets replaces the {{secret.<name>}} placeholders of the passphrase and of the token
+func (a GitAuth) WithSecrets(secrets Secrets) GitAuth {
+	variables := Variables{}.WithSecrets(secrets)
+	a.SSH.Passphrase = variables.ApplyTo(a.SSH.Passphrase)
+	a.HTTPS.Token = variables.ApplyTo(a.HTTPS.Token)
+	return a
+}
+
+// GetAuth retrieves the credentials of the remote, with the secrets replaced
+func (a *ApplicationConfiguration) GetAuth() GitAuth {
+	return a.Auth.WithSecrets(a.Secrets)
+}
+
+// Redact replaces the passphrase and the token found in a string (e.g. the output of git) with ****
+func (a GitAuth) Redact(str string) string {
+	return Secrets{
+		"passphrase": a.SSH.Passphrase,
+		"token":      a.HTTPS.Token,
+	}.Redact(str)
+}
+
+func newGitAuth(auth GitAuth) (GitAuth, error) {
+	errs := ConfigurationErrors{}
+	if auth.SSH.Passphrase != "" && auth.SSH.Key == "" {
+		errs.Add(errors.New("application.auth.ssh.passphrase requires application.auth.ssh.key to be set"))
+	}
+	switch auth.SSH.KnownHosts {
+	case "":
+		if auth.SSH.IsSet() {
+			auth.SSH.KnownHosts = GitAuthKnownHostsStrict
+		}
+	case GitAuthKnownHostsStrict, GitAuthKnownHostsAcceptNew, GitAuthKnownHostsInsecure:
+	default:
+		errs.Add(fmt.Errorf("application.auth.ssh.known_hosts must be one of strict, accept-new, insecure"))
+	}
+	auth.SSH.Key = expandHome(auth.SSH.Key)
+	auth.SSH.KnownHostsFile = expandHome(auth.SSH.KnownHostsFile)
+	if auth.HTTPS.Username != "" && auth.HTTPS.Token == "" {
+		errs.Add(errors.New("application.auth.https.token (required) not defined"))
+	}
+	if auth.HTTPS.Token != "" && auth.HTTPS.Username == "" {
+		// Any username is accepted along with a token by GitHub, GitLab and Gitea
+		auth.HTTPS.Username = "git"
+	}
+	return auth, errs.Err()
+}
+
+// expandHome expands a leading ~ to the home folder of the user running Polo
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
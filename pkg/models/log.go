@@ -15,6 +15,29 @@ const (
 	LogTypeCritical LogType = "critical"
 )
 
+const (
+	// LogChannelBuild holds the logs of the lifetime of the session:
+	// build, commands, healthchecks and destruction
+	LogChannelBuild LogChannel = "build"
+	// LogChannelRuntime holds the output of the application
+	// once the start commands have returned
+	LogChannelRuntime LogChannel = "runtime"
+)
+
+type LogChannel string
+
+// ParseLogChannel converts a raw channel name into a log channel.
+// An empty name defaults to the build channel
+func ParseLogChannel(raw string) (LogChannel, bool) {
+	switch LogChannel(raw) {
+	case "", LogChannelBuild:
+		return LogChannelBuild, true
+	case LogChannelRuntime:
+		return LogChannelRuntime, true
+	}
+	return "", false
+}
+
 type Log struct {
 	When    time.Time `json:"when"`
 	UUID    string    `json:"uuid"`
@@ -1,31 +1,127 @@
 package output
 
 type ApplicationConfiguration struct {
-	Name                  string            `json:"name"`
-	Hash                  string            `json:"hash"`
-	ID                    string            `json:"id"`
-	Remote                string            `json:"remote"`
-	Target                string            `json:"target"`
-	Host                  string            `json:"host"`
-	Fetch                 Fetch             `json:"fetch"`
-	Watch                 []string          `json:"watch"`
-	Helper                Helper            `json:"helper"`
-	IsDefault             bool              `json:"isDefault"`
-	Forwards              []Forward         `json:"forwards"`
-	Headers               Headers           `json:"headers"`
-	Healthcheck           Healthcheck       `json:"healthCheck"`
-	Startup               Startup           `json:"startup"`
-	Recycle               Recycle           `json:"recycle"`
-	Commands              Commands          `json:"commands"`
-	MaxConcurrentSessions int               `json:"maxConcurrentSessions"`
-	Port                  PortConfiguration `json:"port"`
-	UseFolderCopy         bool              `json:"useFolderCopy"`
-	CleanOnExit           bool              `json:"cleanOnExit"`
-	Warmup                Warmups           `json:"warmups"`
+	Name                  string                         `json:"name"`
+	Hash                  string                         `json:"hash"`
+	ID                    string                         `json:"id"`
+	Remote                string                         `json:"remote"`
+	Target                string                         `json:"target"`
+	Host                  string                         `json:"host"`
+	Fetch                 Fetch                          `json:"fetch"`
+	Watch                 []string                       `json:"watch"`
+	Helper                Helper                         `json:"helper"`
+	IsDefault             bool                           `json:"isDefault"`
+	Forwards              []Forward                      `json:"forwards"`
+	Headers               Headers                        `json:"headers"`
+	Healthcheck           Healthcheck                    `json:"healthCheck"`
+	Startup               Startup                        `json:"startup"`
+	Recycle               Recycle                        `json:"recycle"`
+	Commands              Commands                       `json:"commands"`
+	MaxConcurrentSessions int                            `json:"maxConcurrentSessions"`
+	Port                  PortConfiguration              `json:"port"`
+	UseFolderCopy         bool                           `json:"useFolderCopy"`
+	CleanOnExit           bool                           `json:"cleanOnExit"`
+	Warmup                Warmups                        `json:"warmups"`
+	Dependencies          []Dependency                   `json:"dependencies"`
+	Parameters            []Parameter                    `json:"parameters"`
+	Logs                  []LogSource                    `json:"logs"`
+	Webhooks              []Webhook                      `json:"webhooks"`
+	PullRequests          PullRequests                   `json:"pullRequests"`
+	Auth                  GitAuth                        `json:"auth"`
+	Checkout              Checkout                       `json:"checkout"`
+	Extends               string                         `json:"extends"`
+	Provenance            map[string]ConfigurationSource `json:"provenance"`
+}
+
+type ConfigurationSource struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+type PullRequests struct {
+	CommitStatus CommitStatus `json:"commitStatus"`
+}
+
+type CommitStatus struct {
+	Provider   string `json:"provider"`
+	URL        string `json:"url"`
+	Repository string `json:"repository"`
+	Context    string `json:"context"`
+}
+
+type Checkout struct {
+	Depth      int      `json:"depth"`
+	Filter     string   `json:"filter"`
+	Sparse     []string `json:"sparse"`
+	Submodules bool     `json:"submodules"`
+	LFS        bool     `json:"lfs"`
+}
+
+type GitAuth struct {
+	SSH   GitSSHAuth   `json:"ssh"`
+	HTTPS GitHTTPSAuth `json:"https"`
+}
+
+type GitSSHAuth struct {
+	Key            string `json:"key"`
+	HasPassphrase  bool   `json:"hasPassphrase"`
+	KnownHosts     string `json:"knownHosts"`
+	KnownHostsFile string `json:"knownHostsFile"`
+}
+
+type GitHTTPSAuth struct {
+	Username string `json:"username"`
+	HasToken bool   `json:"hasToken"`
+}
+
+type Webhook struct {
+	URL           string   `json:"url"`
+	Events        []string `json:"events"`
+	Signed        bool     `json:"signed"`
+	Preset        string   `json:"preset"`
+	Payload       string   `json:"payload"`
+	MaxRetries    int      `json:"maxRetries"`
+	RetryInterval int      `json:"retryInterval"`
+}
+
+type LogSource struct {
+	File        string   `json:"file"`
+	Command     string   `json:"command"`
+	Environment []string `json:"environment"`
+	WorkingDir  string   `json:"workingDir"`
+}
+
+type Parameter struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Default     string   `json:"default"`
+	Values      []string `json:"values"`
+	Description string   `json:"description"`
+}
+
+type Dependency struct {
+	Name        string `json:"name"`
+	Application string `json:"application"`
+	Checkout    string `json:"checkout"`
+	Fallback    string `json:"fallback"`
 }
 
 type Fetch struct {
-	Interval int `json:"interval"`
+	Interval int          `json:"interval"`
+	Hook     PushHook     `json:"hook"`
+	History  FetchHistory `json:"history"`
+}
+
+type FetchHistory struct {
+	Since      string `json:"since"`
+	MaxCommits int    `json:"maxCommits"`
+	RefsOnly   bool   `json:"refsOnly"`
+}
+
+type PushHook struct {
+	Enabled        bool `json:"enabled"`
+	OnlyPushedRef  bool `json:"onlyPushedRef"`
+	DisablePolling bool `json:"disablePolling"`
 }
 
 type Helper struct {
@@ -78,6 +174,7 @@ type Command struct {
 	WorkingDir          string   `json:"workingDir"`
 	StartHealthchecking bool     `json:"startHealthchecking"`
 	Timeout             int      `json:"timeout"`
+	Supervise           bool     `json:"supervise"`
 }
 
 type PortConfiguration struct {
@@ -96,3 +193,13 @@ type Warmup struct {
 	Status  int    `json:"status"`
 	Timeout int    `json:"timeout"`
 }
+
+// ConfiguredApplication is an application as written in a configuration file.
+// Only the managed ones can be changed through the API, providing their hash
+type ConfiguredApplication struct {
+	Name          string                 `json:"name"`
+	Filename      string                 `json:"filename"`
+	Managed       bool                   `json:"managed"`
+	Hash          string                 `json:"hash"`
+	Configuration map[string]interface{} `json:"configuration"`
+}
@@ -3,14 +3,16 @@ package output
 import "time"
 
 type Application struct {
-	Status        string                    `json:"status"`
-	Filename      string                    `json:"filename"`
-	Configuration ApplicationConfiguration  `json:"configuration"`
-	Folder        string                    `json:"folder"`
-	BaseFolder    string                    `json:"baseFolder"`
-	BranchesMap   map[string]Branch         `json:"branchesMap"`
-	TagsMap       map[string]Tag            `json:"tagsMap"`
-	Notifications []ApplicationNotification `json:"notifications"`
+	Status          string                    `json:"status"`
+	Filename        string                    `json:"filename"`
+	Configuration   ApplicationConfiguration  `json:"configuration"`
+	Folder          string                    `json:"folder"`
+	BaseFolder      string                    `json:"baseFolder"`
+	BranchesMap     map[string]Branch         `json:"branchesMap"`
+	TagsMap         map[string]Tag            `json:"tagsMap"`
+	PullRequestsMap map[string]PullRequest    `json:"pullRequestsMap"`
+	Notifications   []ApplicationNotification `json:"notifications"`
+	Fetch           ApplicationFetchStatus    `json:"fetch"`
 }
 
 type ApplicationNotification struct {
@@ -22,6 +24,22 @@ type ApplicationNotification struct {
 	CreatedAt   time.Time `json:"createdAt"`
 }
 
+type ApplicationFetchStatus struct {
+	State string            `json:"state"`
+	Last  *ApplicationFetch `json:"last"`
+}
+
+type ApplicationFetch struct {
+	StartedAt       time.Time        `json:"startedAt"`
+	Duration        int              `json:"duration"`
+	Result          string           `json:"result"`
+	Errors          []string         `json:"errors"`
+	Refs            []string         `json:"refs"`
+	Watch           bool             `json:"watch"`
+	NewCommits      []CheckoutObject `json:"newCommits"`
+	NewCommitsCount int              `json:"newCommitsCount"`
+}
+
 type CheckoutObject struct {
 	Name        string    `json:"name"`
 	Hash        string    `json:"hash"`
@@ -38,3 +56,9 @@ type Branch struct {
 type Tag struct {
 	CheckoutObject
 }
+
+type PullRequest struct {
+	CheckoutObject
+	Number int    `json:"number"`
+	Ref    string `json:"ref"`
+}
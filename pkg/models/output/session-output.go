@@ -26,6 +26,9 @@ type Session struct {
 	ForwardLink       string               `json:"forwardLink"`
 	Permalink         string               `json:"permalink"`
 	SmartURL          string               `json:"smartURL"`
+	Stack             *SessionStack        `json:"stack,omitempty"`
+	Parameters        map[string]string    `json:"parameters"`
+	Variant           string               `json:"variant"`
 }
 
 type SessionConfiguration struct {
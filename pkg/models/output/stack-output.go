@@ -0,0 +1,26 @@
+package output
+
+type Stack struct {
+	Name          string             `json:"name"`
+	DefaultBranch string             `json:"defaultBranch"`
+	Applications  []StackApplication `json:"applications"`
+	Instances     []StackInstance    `json:"instances"`
+}
+
+type StackApplication struct {
+	Application string `json:"application"`
+	Fallback    string `json:"fallback"`
+	Entry       bool   `json:"entry"`
+}
+
+type StackInstance struct {
+	Checkout string    `json:"checkout"`
+	SmartURL string    `json:"smartURL"`
+	Sessions []Session `json:"sessions"`
+}
+
+type SessionStack struct {
+	Name     string `json:"name"`
+	Checkout string `json:"checkout"`
+	Entry    bool   `json:"entry"`
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ParameterTypeString ParameterType = "string"
+	ParameterTypeEnum   ParameterType = "enum"
+	ParameterTypeBool   ParameterType = "bool"
+)
+
+// ParameterType is the type of the value accepted by a build parameter
+type ParameterType string
+
+// Parameter is a build-time parameter which can be supplied
+// when requesting a session. Its value is available
+// in commands and environment as {{param.<name>}}
+type Parameter struct {
+	Name        string        `json:"name"`
+	Type        ParameterType `json:"type"`
+	Default     string        `json:"default"`
+	Values      []string      `json:"values"` // Allowed values, for enum parameters
+	Description string        `json:"description"`
+}
+
+// Parameters is the list of build parameters declared by an application
+type Parameters []Parameter
+
+// SessionParameters are the values of the build parameters of a session
+type SessionParameters map[string]string
+
+func newParameters(parameters Parameters) (Parameters, error) {
+	if parameters == nil {
+		return Parameters{}, nil
+	}
+	errs := ConfigurationErrors{}
+	names := make(map[string]bool)
+	for i, parameter := range parameters {
+		if parameter.Name == "" {
+			errs.Add(fmt.Errorf("application.parameters[%d].name (required) not defined", i))
+		} else if names[parameter.Name] {
+			errs.Add(fmt.Errorf("application.parameters[%d].name %q already defined", i, parameter.Name))
+		}
+		names[parameter.Name] = true
+		if parameter.Type == "" {
+			parameters[i].Type = ParameterTypeString
+		}
+		switch parameters[i].Type {
+		case ParameterTypeString:
+		case ParameterTypeEnum:
+			if len(parameter.Values) == 0 {
+				errs.Add(fmt.Errorf("application.parameters[%d].values (required) not defined for enum parameters", i))
+				continue
+			}
+			if parameter.Default == "" {
+				parameters[i].Default = parameter.Values[0]
+			}
+		case ParameterTypeBool:
+			if parameter.Default == "" {
+				parameters[i].Default = "false"
+			}
+		default:
+			errs.Add(fmt.Errorf("application.parameters[%d].type %q not valid; use one of string, enum, bool", i, parameter.Type))
+			continue
+		}
+		if _, err := parameters[i].normalize(parameters[i].Default); err != nil {
+			errs.Add(fmt.Errorf("application.parameters[%d].default %s", i, err.Error()))
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return parameters, nil
+}
+
+// normalize validates a value against the parameter type
+// and returns its canonical form
+func (p Parameter) normalize(value string) (string, error) {
+	switch p.Type {
+	case ParameterTypeEnum:
+		for _, allowed := range p.Values {
+			if value == allowed {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("%q is not one of %s", value, strings.Join(p.Values, ", "))
+	case ParameterTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a boolean", value)
+		}
+		return strconv.FormatBool(b), nil
+	}
+	return value, nil
+}
+
+// Resolve validates the supplied values and fills the missing ones
+// with their defaults
+func (p Parameters) Resolve(supplied map[string]string) (SessionParameters, error) {
+	resolved := make(SessionParameters)
+	for name := range supplied {
+		if p.get(name) == nil {
+			return nil, fmt.Errorf("parameter %s not declared", name)
+		}
+	}
+	for _, parameter := range p {
+		value, ok := supplied[parameter.Name]
+		if !ok {
+			value = parameter.Default
+		}
+		normalized, err := parameter.normalize(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %s", parameter.Name, err.Error())
+		}
+		resolved[parameter.Name] = normalized
+	}
+	return resolved, nil
+}
+
+// Has checks if a parameter has been declared
+func (p Parameters) Has(name string) bool {
+	return p.get(name) != nil
+}
+
+func (p Parameters) get(name string) *Parameter {
+	for i := range p {
+		if p[i].Name == name {
+			return &p[i]
+		}
+	}
+	return nil
+}
+
+// Equals checks if two sets of parameters hold the same values
+func (p SessionParameters) Equals(other SessionParameters) bool {
+	if len(p) != len(other) {
+		return false
+	}
+	for name, value := range p {
+		if otherValue, ok := other[name]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains checks if the parameters hold all the given values
+func (p SessionParameters) Contains(values map[string]string) bool {
+	for name, value := range values {
+		if current, ok := p[name]; !ok || current != value {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a stable representation of the parameters
+func (p SessionParameters) String() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+p[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ApplyTo sets the parameters as session variables (e.g. {{param.locale}})
+func (p SessionParameters) ApplyTo(variables Variables) {
+	for name, value := range p {
+		variables["param."+name] = value
+	}
+}
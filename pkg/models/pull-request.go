@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PullRequestCheckoutPrefix is the prefix of the checkout names of the pull requests (e.g. pr-123)
+	PullRequestCheckoutPrefix = "pr-"
+
+	CommitStatusProviderGitHub CommitStatusProvider = "github"
+	CommitStatusProviderGitLab CommitStatusProvider = "gitlab"
+	CommitStatusProviderGitea  CommitStatusProvider = "gitea"
+)
+
+// pullRequestRefPattern matches the refs of the pull requests (GitHub, Gitea),
+// merge requests (GitLab) and pull requests of Bitbucket Server
+var pullRequestRefPattern = regexp.MustCompile(`^refs/(?:pull|merge-requests|pull-requests)/(\d+)/(?:head|from)$`)
+
+// PullRequest is an open pull request (or merge request) of the remote,
+// checked out with the name pr-<number>
+type PullRequest struct {
+	CheckoutObject
+	Number int    `json:"number"`
+	Ref    string `json:"ref"`
+}
+
+// ParsePullRequestRef retrieves the number of the pull request a ref points to
+// (e.g. refs/pull/123/head, refs/merge-requests/123/head)
+func ParsePullRequestRef(ref string) (int, bool) {
+	matches := pullRequestRefPattern.FindStringSubmatch(ref)
+	if matches == nil {
+		return 0, false
+	}
+	number, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+// PullRequestCheckout builds the checkout name of a pull request
+func PullRequestCheckout(number int) string {
+	return fmt.Sprintf("%s%d", PullRequestCheckoutPrefix, number)
+}
+
+// IsPullRequestCheckout tells whether a checkout name refers to a pull request
+func IsPullRequestCheckout(checkout string) bool {
+	if !strings.HasPrefix(checkout, PullRequestCheckoutPrefix) {
+		return false
+	}
+	_, err := strconv.Atoi(strings.TrimPrefix(checkout, PullRequestCheckoutPrefix))
+	return err == nil
+}
+
+// PullRequestsConfiguration configures the preview environments of the pull requests.
+// The pull requests are checked out as pr-<number>: the branch rules matching
+// their names (e.g. "^pr-") make them start automatically and get hot-swapped;
+// their sessions get destroyed when the pull request ref disappears.
+type PullRequestsConfiguration struct {
+	CommitStatus CommitStatusConfiguration `yaml:"commit_status" json:"commitStatus"`
+}
+
+type CommitStatusProvider string
+
+// CommitStatusConfiguration configures the forge API client
+// posting the URL of the sessions of the pull requests as commit status.
+// Disabled if no provider is set
+type CommitStatusConfiguration struct {
+	Provider   CommitStatusProvider `yaml:"provider" json:"provider"`
+	URL        string               `yaml:"url" json:"url"` // Base URL of the forge (e.g. http://localhost:3000 for Gitea)
+	Token      string               `yaml:"token" json:"-"`
+	Repository string               `yaml:"repository" json:"repository"` // owner/name, or the project ID on GitLab
+	Context    string               `yaml:"context" json:"context"`       // Name of the status; defaults to "polo"
+}
+
+func (c CommitStatusConfiguration) IsEnabled() bool {
+	return c.Provider != ""
+}
+
+func newPullRequestsConfiguration(configuration PullRequestsConfiguration) (PullRequestsConfiguration, error) {
+	status := &configuration.CommitStatus
+	if !status.IsEnabled() {
+		return configuration, nil
+	}
+	switch status.Provider {
+	case CommitStatusProviderGitHub:
+		if status.URL == "" {
+			status.URL = "https://api.github.com"
+		}
+	case CommitStatusProviderGitLab:
+		if status.URL == "" {
+			status.URL = "https://gitlab.com"
+		}
+	case CommitStatusProviderGitea:
+		if status.URL == "" {
+			return configuration, fmt.Errorf("application.pull_requests.commit_status.url (required) not defined")
+		}
+	default:
+		return configuration, fmt.Errorf("application.pull_requests.commit_status.provider must be one of github, gitlab, gitea")
+	}
+	if status.Repository == "" {
+		return configuration, fmt.Errorf("application.pull_requests.commit_status.repository (required) not defined")
+	}
+	if status.Context == "" {
+		status.Context = "polo"
+	}
+	status.URL = strings.TrimSuffix(status.URL, "/")
+	return configuration, nil
+}
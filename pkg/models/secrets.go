@@ -0,0 +1,70 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// SecretPlaceholderPrefix prefixes the names of the secrets in the placeholders (e.g. {{secret.registry_token}})
+	SecretPlaceholderPrefix = "secret."
+	// RedactedSecret replaces the values of the secrets in the logs and in the outputs
+	RedactedSecret = "****"
+)
+
+// SecretsConfiguration is the source of the secrets:
+// a dotenv file (NAME=value lines) and/or a folder
+// containing a file per secret, named after it (e.g. Docker secrets).
+// Relative paths are relative to the folder of the configuration files
+type SecretsConfiguration struct {
+	File   string `yaml:"file" json:"file"`
+	Folder string `yaml:"folder" json:"folder"`
+}
+
+// IsSet tells whether a source of the secrets has been configured
+func (c SecretsConfiguration) IsSet() bool {
+	return c.File != "" || c.Folder != ""
+}
+
+// Secrets are the values read from the secrets source, by name,
+// available to commands, headers and environment variables as {{secret.<name>}}.
+// They are never stored in the session variables and get redacted
+// wherever they appear in the logs and in the outputs
+type Secrets map[string]string
+
+// Redact replaces the values of the secrets found in a string with ****.
+// Longer values are redacted first, for a secret containing another one to be redacted as a whole
+func (s Secrets) Redact(str string) string {
+	if len(s) == 0 {
+		return str
+	}
+	values := make([]string, 0, len(s))
+	for _, value := range s {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	for _, value := range values {
+		str = strings.ReplaceAll(str, value, RedactedSecret)
+	}
+	return str
+}
+
+// WithSecrets returns a copy of the variables
+// along with the secrets, named secret.<name>
+func (v Variables) WithSecrets(secrets Secrets) Variables {
+	if len(secrets) == 0 {
+		return v
+	}
+	ret := make(Variables, len(v)+len(secrets))
+	for key, value := range v {
+		ret[key] = value
+	}
+	for name, value := range secrets {
+		ret[SecretPlaceholderPrefix+name] = value
+	}
+	return ret
+}
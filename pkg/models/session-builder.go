@@ -8,16 +8,20 @@ import (
 type SessionBuilder struct {
 	mutexBuilder utils.MutexBuilder
 	logger       logging.Logger
+	logStore     SessionLogStore
+	observer     SessionMetricsObserver
 }
 
-func NewSessionBuilder(mutexBuilder utils.MutexBuilder, logger logging.Logger) *SessionBuilder {
+func NewSessionBuilder(mutexBuilder utils.MutexBuilder, logger logging.Logger, logStore SessionLogStore, observer SessionMetricsObserver) *SessionBuilder {
 	return &SessionBuilder{
 		mutexBuilder: mutexBuilder,
 		logger:       logger,
+		logStore:     logStore,
+		observer:     observer,
 	}
 }
 
 func (b *SessionBuilder) Build(session *Session) *Session {
 	b.logger.Trace("Building new session")
-	return newSession(session, b.mutexBuilder, b.logger)
+	return newSession(session, b.mutexBuilder, b.logger, b.logStore, b.observer)
 }
@@ -8,6 +8,8 @@ import (
 
 const (
 	SessionEventTypeBuildStarted             SessionEventType = "build_started"
+	SessionEventTypeDependenciesStarted      SessionEventType = "dependencies_started"
+	SessionEventTypeDependenciesFailed       SessionEventType = "dependencies_failed"
 	SessionEventTypePreparingFolders         SessionEventType = "preparing_folders"
 	SessionEventTypePreparingFoldersFailed   SessionEventType = "preparing_folders_failed"
 	SessionEventTypeCommandsExecutionStarted SessionEventType = "commands_execution_started"
@@ -33,14 +35,43 @@ func (t SessionEventType) String() string {
 type SessionBuildEvent struct {
 	EventType SessionEventType
 	Session   *Session
+	// Status of the session when the event got published
+	Status SessionStatus
+}
+
+// Number of stream events buffered for each subscriber
+const streamEventsBuffer = 1000
+
+const (
+	SessionStreamEventTypeEvent  SessionStreamEventType = "event"
+	SessionStreamEventTypeLog    SessionStreamEventType = "log"
+	SessionStreamEventTypeStatus SessionStreamEventType = "status"
+)
+
+type SessionStreamEventType string
+
+// SessionStreamEvent is delivered to the subscribers of the session stream:
+// lifetime events, new log lines and status transitions
+type SessionStreamEvent struct {
+	Type    SessionStreamEventType
+	Event   SessionEventType
+	Channel LogChannel
+	Log     Log
+	Status  SessionStatus
 }
 
 type SessionLifetimeEventBus struct {
 	utils.RWLocker
-	bus     EventBus.Bus
-	ch      chan SessionBuildEvent
-	history []SessionBuildEvent
-	id      string
+	bus         EventBus.Bus
+	ch          chan SessionBuildEvent
+	history     []SessionBuildEvent
+	id          string
+	subscribers *sessionStreamSubscribers
+}
+
+type sessionStreamSubscribers struct {
+	utils.RWLocker
+	channels map[string]chan SessionStreamEvent
 }
 
 func NewSessionBuildEventBus(mutexBuilder utils.MutexBuilder) *SessionLifetimeEventBus {
@@ -51,6 +82,10 @@ func NewSessionBuildEventBus(mutexBuilder utils.MutexBuilder) *SessionLifetimeEv
 		bus:      bus,
 		ch:       make(chan SessionBuildEvent, eventsBuffer),
 		id:       uuid.NewString(),
+		subscribers: &sessionStreamSubscribers{
+			RWLocker: mutexBuilder(),
+			channels: make(map[string]chan SessionStreamEvent),
+		},
 	}
 	eventBus.start()
 	return eventBus
@@ -68,6 +103,11 @@ func (b *SessionLifetimeEventBus) start() {
 			defer b.Unlock()
 			history = append(history, sessionEv)
 			b.ch <- sessionEv
+			b.publishStreamEvent(SessionStreamEvent{
+				Type:   SessionStreamEventTypeEvent,
+				Event:  sessionEv.EventType,
+				Status: sessionEv.Status,
+			})
 			eventsCount++
 
 			// Hack to prevent saturation of the receiving channel
@@ -92,9 +132,59 @@ func (b *SessionLifetimeEventBus) GetChan() <-chan SessionBuildEvent {
 }
 
 func (b *SessionLifetimeEventBus) PublishEvent(eventType SessionEventType, session *Session) {
-	b.bus.Publish("session:"+b.id, SessionBuildEvent{
+	event := SessionBuildEvent{
 		EventType: eventType,
 		Session:   session,
+	}
+	// The status is read here, by the publisher, since the lock of the session
+	// gets replaced when the session is recycled for a retry
+	if session != nil {
+		event.Status = session.GetStatus()
+	}
+	b.bus.Publish("session:"+b.id, event)
+}
+
+// Subscribe registers a subscriber to the session stream.
+// The returned function must be called to unsubscribe.
+// Events are dropped if the subscriber does not keep up with them
+func (b *SessionLifetimeEventBus) Subscribe() (<-chan SessionStreamEvent, func()) {
+	id := uuid.NewString()
+	ch := make(chan SessionStreamEvent, streamEventsBuffer)
+	b.subscribers.Lock()
+	b.subscribers.channels[id] = ch
+	b.subscribers.Unlock()
+	return ch, func() {
+		b.subscribers.Lock()
+		delete(b.subscribers.channels, id)
+		b.subscribers.Unlock()
+	}
+}
+
+func (b *SessionLifetimeEventBus) publishStreamEvent(event SessionStreamEvent) {
+	b.subscribers.RLock()
+	defer b.subscribers.RUnlock()
+	for _, ch := range b.subscribers.channels {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// PublishLog delivers a new log line of a channel to the stream subscribers
+func (b *SessionLifetimeEventBus) PublishLog(channel LogChannel, log Log) {
+	b.publishStreamEvent(SessionStreamEvent{
+		Type:    SessionStreamEventTypeLog,
+		Channel: channel,
+		Log:     log,
+	})
+}
+
+// PublishStatus delivers a status transition to the stream subscribers
+func (b *SessionLifetimeEventBus) PublishStatus(status SessionStatus) {
+	b.publishStreamEvent(SessionStreamEvent{
+		Type:   SessionStreamEventTypeStatus,
+		Status: status,
 	})
 }
 
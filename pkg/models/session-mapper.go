@@ -26,7 +26,7 @@ func MapSession(model *Session) *output.Session {
 		CommitDate:        model.Commit.Author.When,
 		Checkout:          model.Checkout,
 		Folder:            model.Folder,
-		Variables:         model.Variables,
+		Variables:         mapSessionVariables(model.Variables, conf.Secrets),
 		Logs:              mapSessionLogs(model.logs),
 		Metrics:           mapMetrics(model.Metrics),
 		Configuration:     mapConfiguration(conf),
@@ -34,6 +34,9 @@ func MapSession(model *Session) *output.Session {
 		ForwardLink:       mapForwardLink(model, conf),
 		Permalink:         mapPermalink(model, conf),
 		SmartURL:          mapSmartURL(model, conf),
+		Stack:             mapSessionStack(model.Stack),
+		Parameters:        mapSessionParameters(model.Parameters),
+		Variant:           model.Variant,
 	}
 	model.RUnlock()
 	session.ReplacesSessions = mapReplaces(model.GetReplaces())
@@ -59,6 +62,23 @@ func mapReplaces(model []*Session) []string {
 	return ids
 }
 
+// mapSessionVariables maps the variables redacting the secrets they contain
+func mapSessionVariables(model Variables, secrets Secrets) map[string]string {
+	ret := make(map[string]string, len(model))
+	for name, value := range model {
+		ret[name] = secrets.Redact(value)
+	}
+	return ret
+}
+
+func mapSessionParameters(model SessionParameters) map[string]string {
+	ret := make(map[string]string, len(model))
+	for name, value := range model {
+		ret[name] = value
+	}
+	return ret
+}
+
 func mapConfiguration(model ApplicationConfiguration) output.SessionConfiguration {
 	return output.SessionConfiguration{
 		IsDefault: model.IsDefault,
@@ -140,8 +160,14 @@ func mapPermalink(session *Session, conf ApplicationConfiguration) string {
 }
 
 func mapSmartURL(session *Session, conf ApplicationConfiguration) string {
+	if session.Stack.Entry {
+		return "/s/" + session.Stack.Name + "/" + session.Stack.Checkout
+	}
 	if !conf.IsDefault {
 		return ""
 	}
+	if session.Variant != "" {
+		return "/s/" + session.Checkout + "~" + session.Variant
+	}
 	return "/s/" + session.Checkout
 }
@@ -33,6 +33,11 @@ func (m *Metrics) ToSlice() []*Metric {
 	return ret
 }
 
+// SessionMetricsObserver gets notified of the metrics of the sessions
+type SessionMetricsObserver interface {
+	ObserveSessionMetric(application string, object string, duration time.Duration)
+}
+
 type Metric struct {
 	Object   string
 	Duration time.Duration
@@ -46,9 +51,14 @@ func NewMetricsForSession(session *Session) func(string) func() {
 			if !stopped {
 				end := time.Since(start)
 				session.Lock()
-				defer session.Unlock()
 				session.Metrics = append(session.Metrics, Metric{Object: object, Duration: end})
+				observer := session.metricsObserver
+				application := session.ApplicationName
 				stopped = true
+				session.Unlock()
+				if observer != nil {
+					observer.ObserveSessionMetric(application, object, end)
+				}
 			}
 
 		}
@@ -48,10 +48,21 @@ const (
 	KillReasonHealthcheckFailed KillReason = "healthcheck_failed"
 	// KillReasonReplaced - Means the session is going to be replaced with an updated one
 	KillReasonReplaced KillReason = "replaced"
+	// KillReasonPullRequestClosed - The pull request the session was built from is not available anymore
+	KillReasonPullRequestClosed KillReason = "pull_request_closed"
+	// KillReasonApplicationRemoved - The application of the session has been removed from the configuration
+	KillReasonApplicationRemoved KillReason = "application_removed"
 
 	// SessionBuildContextKey is the name of the shared BUILD context.
 	// It is shared to allow an early session destruction to stop a running build of a session
 	SessionBuildContextKey string = "build"
+	// SessionRuntimeContextKey is the name of the shared RUNTIME context.
+	// It lasts as long as the session and stops the supervised commands
+	// and the tailing of the runtime logs
+	SessionRuntimeContextKey string = "runtime"
+	// SessionTraceContextKey is the name of the context carrying the trace of the session build.
+	// It lasts until the session gets available, so that the healthchecks are part of the trace
+	SessionTraceContextKey string = "trace"
 )
 
 // SessionStatus is the status of the session
@@ -91,15 +102,21 @@ type Session struct {
 	ApplicationName string       `json:"applicationName"`
 	Application     *Application `json:"-"`
 	configuration   ApplicationConfiguration
-	Status          SessionStatus `json:"status"`
-	CommitID        string        `json:"commitID"` // The object to be checked out (branch/tag/commit id)
-	Checkout        string        `json:"checkout"`
-	Commit          object.Commit `json:"commit"`
-	Folder          string        `json:"folder"`
-	Variables       Variables     `json:"variables"`
-	Metrics         []Metric      `json:"metrics"`
-	Context         *contextStore `json:"-"`
+	Status          SessionStatus     `json:"status"`
+	CommitID        string            `json:"commitID"` // The object to be checked out (branch/tag/commit id)
+	Checkout        string            `json:"checkout"`
+	Commit          object.Commit     `json:"commit"`
+	Folder          string            `json:"folder"`
+	Variables       Variables         `json:"variables"`
+	Metrics         []Metric          `json:"metrics"`
+	Stack           SessionStack      `json:"stack"`
+	Parameters      SessionParameters `json:"parameters"`
+	Variant         string            `json:"variant"`
+	Context         *contextStore     `json:"-"`
 	logs            []Log
+	runtimeLogs     []Log
+	logStore        SessionLogStore
+	metricsObserver SessionMetricsObserver
 	shortUUID       string
 	createdAt       time.Time
 	inactiveAt      time.Time
@@ -107,11 +124,46 @@ type Session struct {
 	startupRetries  int
 	killReason      KillReason
 	// If set, states that this session replaces a previous one
-	replaces    []*Session
-	replacedBy  *Session
-	diagnostics []DiagnosticsData
-	bus         *SessionLifetimeEventBus
-	log         logging.Logger
+	replaces   []*Session
+	replacedBy *Session
+	// Sessions of other applications this session depends on
+	dependencies []*Session
+	diagnostics  []DiagnosticsData
+	bus          *SessionLifetimeEventBus
+	log          logging.Logger
+}
+
+// SessionStack identifies the stack instance a session belongs to.
+// Sessions of the same stack instance share the stack name
+// and the checkout requested for the stack.
+type SessionStack struct {
+	Name     string `json:"name"`
+	Checkout string `json:"checkout"`
+	Entry    bool   `json:"entry"`
+}
+
+// IsSet states whether the session belongs to a stack
+func (s SessionStack) IsSet() bool {
+	return s.Name != ""
+}
+
+var variantPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ValidateVariant checks if a variant name can be used
+// (e.g. in smart urls like /s/<checkout>~<variant>)
+func ValidateVariant(variant string) error {
+	if variant != "" && !variantPattern.MatchString(variant) {
+		return fmt.Errorf("variant %q not valid; use letters, digits, dots, dashes and underscores", variant)
+	}
+	return nil
+}
+
+// VariantKey identifies the variant of the session among the sessions of the same commit.
+// Sessions with different variant names or different build parameters are distinct
+func (session *Session) VariantKey() string {
+	session.RLock()
+	defer session.RUnlock()
+	return session.Variant + "|" + session.Parameters.String()
 }
 
 // Variables are those variables used by a single session.
@@ -134,8 +186,12 @@ func newSession(
 	session *Session,
 	mutexBuilder utils.MutexBuilder,
 	logger logging.Logger,
+	logStore SessionLogStore,
+	metricsObserver SessionMetricsObserver,
 ) *Session {
 	session.log = logger
+	session.logStore = logStore
+	session.metricsObserver = metricsObserver
 	session.shortUUID = strings.Split(session.UUID, "-")[0]
 	session.RWLocker = mutexBuilder()
 	if session.bus == nil {
@@ -148,6 +204,9 @@ func newSession(
 	if session.logs == nil {
 		session.logs = []Log{}
 	}
+	if session.runtimeLogs == nil {
+		session.runtimeLogs = []Log{}
+	}
 	if len(session.Variables) == 0 {
 		session.Variables = make(map[string]string)
 	}
@@ -166,6 +225,9 @@ func newSession(
 	if session.replaces == nil {
 		session.replaces = []*Session{}
 	}
+	if session.dependencies == nil {
+		session.dependencies = []*Session{}
+	}
 	return session
 }
 
@@ -217,6 +279,54 @@ func (session *Session) GetReplacedBy() *Session {
 	return session.replacedBy
 }
 
+// SetDependencies thread-safely sets the sessions of other applications
+// this session depends on
+func (session *Session) SetDependencies(dependencies []*Session) {
+	session.Lock()
+	defer session.Unlock()
+	if dependencies == nil {
+		session.dependencies = []*Session{}
+	} else {
+		session.dependencies = dependencies
+	}
+}
+
+// GetDependencies thread-safely retrieves the sessions of other applications
+// this session depends on
+func (session *Session) GetDependencies() []*Session {
+	session.RLock()
+	defer session.RUnlock()
+	return session.dependencies
+}
+
+// SetStack thread-safely sets the stack instance this session belongs to
+func (session *Session) SetStack(stack SessionStack) {
+	session.Lock()
+	defer session.Unlock()
+	session.Stack = stack
+}
+
+// GetStack thread-safely retrieves the stack instance this session belongs to
+func (session *Session) GetStack() SessionStack {
+	session.RLock()
+	defer session.RUnlock()
+	return session.Stack
+}
+
+// SetCommitID thread-safely sets the commit the session is built from
+func (session *Session) SetCommitID(commitID string) {
+	session.Lock()
+	defer session.Unlock()
+	session.CommitID = commitID
+}
+
+// GetCommitID thread-safely retrieves the commit the session is built from
+func (session *Session) GetCommitID() string {
+	session.RLock()
+	defer session.RUnlock()
+	return session.CommitID
+}
+
 // GetConfiguration allows to retrieve the CURRENT configuration in a thread-safe manner.
 // This configuration gets replaced whenever there's an update by the user.
 // So it is advisable to not store indefinitely this configuration, but to ask for it when needed
@@ -262,103 +372,121 @@ func (session *Session) getMatchingConfiguration() ApplicationConfiguration {
 	return baseConfig
 }
 
+// SessionLogStore persists the logs of the sessions
+type SessionLogStore interface {
+	Append(sessionUUID string, channel LogChannel, log Log)
+	GetLogs(sessionUUID string, channel LogChannel, afterLogUUID string, limit int) []Log
+}
+
+// appendLog stores a build log entry.
+// Must be called holding the lock
+func (session *Session) appendLog(log Log) {
+	session.appendChannelLog(LogChannelBuild, log)
+}
+
+// appendChannelLog stores a log entry into the log store, if any,
+// or into the in-memory logs slice of the channel.
+// Must be called holding the lock
+func (session *Session) appendChannelLog(channel LogChannel, log Log) {
+	if session.logStore != nil {
+		session.logStore.Append(session.UUID, channel, log)
+	} else if channel == LogChannelRuntime {
+		session.runtimeLogs = append(session.runtimeLogs, log)
+	} else {
+		session.logs = append(session.logs, log)
+	}
+	session.bus.PublishLog(channel, log)
+}
+
 // LogCritical logs a message to stdout and stores it in the session logs slice
 func (session *Session) LogCritical(message string) {
 	session.Lock()
+	message = session.configuration.Secrets.Redact(message)
 	session.log.Errorf(fmt.Sprintf("\t[%s]: %s", session.shortUUID, message))
 	defer session.Unlock()
-	session.logs = append(
-		session.logs,
-		NewLog(message, LogTypeCritical),
-	)
+	session.appendLog(NewLog(message, LogTypeCritical))
 }
 
 // LogError logs a message to stdout and stores it in the session logs slice
 func (session *Session) LogError(message string) {
 	session.Lock()
+	message = session.configuration.Secrets.Redact(message)
 	session.log.Errorf(fmt.Sprintf("\t[%s]: %s", session.shortUUID, message))
 	defer session.Unlock()
-	session.logs = append(
-		session.logs,
-		NewLog(message, LogTypeError),
-	)
+	session.appendLog(NewLog(message, LogTypeError))
 }
 
 // LogWarn logs a message to stdout and stores it in the session logs slice
 func (session *Session) LogWarn(message string) {
 	session.Lock()
+	message = session.configuration.Secrets.Redact(message)
 	session.log.Warnf(fmt.Sprintf("\t[%s]: %s", session.shortUUID, message))
 	defer session.Unlock()
-	session.logs = append(
-		session.logs,
-		NewLog(message, LogTypeWarn),
-	)
+	session.appendLog(NewLog(message, LogTypeWarn))
 }
 
 // LogInfo logs a message to stdout and stores it in the session logs slice
 func (session *Session) LogInfo(message string) {
 	session.Lock()
+	message = session.configuration.Secrets.Redact(message)
 	session.log.Infof(fmt.Sprintf("\t[%s]: %s", session.shortUUID, message))
 	defer session.Unlock()
-	session.logs = append(
-		session.logs,
-		NewLog(message, LogTypeInfo),
-	)
+	session.appendLog(NewLog(message, LogTypeInfo))
 }
 
 // LogDebug logs a message to stdout and stores it in the session logs slice
 func (session *Session) LogDebug(message string) {
 	session.Lock()
+	message = session.configuration.Secrets.Redact(message)
 	session.log.Debugf(fmt.Sprintf("\t[%s]: %s", session.shortUUID, message))
 	defer session.Unlock()
-	session.logs = append(
-		session.logs,
-		NewLog(message, LogTypeDebug),
-	)
+	session.appendLog(NewLog(message, LogTypeDebug))
 }
 
 // LogTrace logs a message to stdout and stores it in the session logs slice
 func (session *Session) LogTrace(message string) {
 	session.Lock()
+	message = session.configuration.Secrets.Redact(message)
 	session.log.Tracef(fmt.Sprintf("\t[%s]: %s", session.shortUUID, message))
 	defer session.Unlock()
-	session.logs = append(
-		session.logs,
-		NewLog(message, LogTypeTrace),
-	)
+	session.appendLog(NewLog(message, LogTypeTrace))
 }
 
 // LogStdin logs a message to stdout and stores it in the session logs slice
 func (session *Session) LogStdin(message string) {
 	session.Lock()
+	message = session.configuration.Secrets.Redact(message)
 	session.log.Infof(fmt.Sprintf("\t\t[%s (stdin)>]: %s", session.shortUUID, message))
 	defer session.Unlock()
-	session.logs = append(
-		session.logs,
-		NewLog(message, LogTypeStdin),
-	)
+	session.appendLog(NewLog(message, LogTypeStdin))
 }
 
 // LogStdout logs a message to stdout and stores it in the session logs slice
 func (session *Session) LogStdout(message string) {
 	session.Lock()
+	message = session.configuration.Secrets.Redact(message)
 	session.log.Infof(fmt.Sprintf("\t\t[%s (stdout)>]: %s", session.shortUUID, message))
 	defer session.Unlock()
-	session.logs = append(
-		session.logs,
-		NewLog(message, LogTypeStdout),
-	)
+	session.appendLog(NewLog(message, LogTypeStdout))
 }
 
 // LogStderr logs a message to stdout and stores it in the session logs slice
 func (session *Session) LogStderr(message string) {
 	session.Lock()
+	message = session.configuration.Secrets.Redact(message)
 	session.log.Infof(fmt.Sprintf("\t\t[%s (stderr)>]: %s", session.shortUUID, message))
 	defer session.Unlock()
-	session.logs = append(
-		session.logs,
-		NewLog(message, LogTypeStderr),
-	)
+	session.appendLog(NewLog(message, LogTypeStderr))
+}
+
+// LogRuntime stores a line printed by the application once started
+// in the runtime logs of the session
+func (session *Session) LogRuntime(message string, logType LogType) {
+	session.Lock()
+	message = session.configuration.Secrets.Redact(message)
+	session.log.Tracef(fmt.Sprintf("\t\t[%s (runtime %s)>]: %s", session.shortUUID, logType, message))
+	defer session.Unlock()
+	session.appendChannelLog(LogChannelRuntime, NewLog(message, logType))
 }
 
 // MarkAsBeingRequested informs the session that it has been used by a proxy
@@ -370,6 +498,12 @@ func (session *Session) MarkAsBeingRequested() {
 		session.SetInactiveAt(time.Now().Add(time.Second * time.Duration(conf.Recycle.InactivityTimeout)))
 		session.SetMaxAge(conf.Recycle.InactivityTimeout)
 	}
+	// Keeps alive the sessions this one depends on
+	for _, dependency := range session.GetDependencies() {
+		if dependency.GetStatus() == SessionStatusStarted {
+			dependency.MarkAsBeingRequested()
+		}
+	}
 }
 
 // SetStatus allows to set the session status thread-safely
@@ -388,6 +522,9 @@ func (session *Session) SetStatus(status SessionStatus) {
 			Next:     status.String(),
 		},
 	})
+	if previousStatus != status {
+		session.bus.PublishStatus(status)
+	}
 }
 
 // GetStatus allows to get the session status thread-safely
@@ -493,15 +630,64 @@ func (session *Session) IsAlive() bool {
 }
 
 func (session *Session) GetLogs() []Log {
+	return session.GetLogsAfter("", 0)
+}
+
+// GetLogsAfter retrieves at most limit logs following the one identified by lastLogUUID.
+// An empty lastLogUUID retrieves the logs from the start,
+// a limit lower or equal to zero retrieves all of them
+func (session *Session) GetLogsAfter(lastLogUUID string, limit int) []Log {
+	return session.GetChannelLogsAfter(LogChannelBuild, lastLogUUID, limit)
+}
+
+// GetChannelLogsAfter retrieves at most limit logs of a channel following the one identified by lastLogUUID
+func (session *Session) GetChannelLogsAfter(channel LogChannel, lastLogUUID string, limit int) []Log {
 	session.RLock()
 	defer session.RUnlock()
-	return session.logs
+	if session.logStore != nil {
+		return session.logStore.GetLogs(session.UUID, channel, lastLogUUID, limit)
+	}
+	allLogs := session.logs
+	if channel == LogChannelRuntime {
+		allLogs = session.runtimeLogs
+	}
+	logs := allLogs
+	if lastLogUUID != "" {
+		logs = []Log{}
+		afterLastLog := false
+		for _, log := range allLogs {
+			if afterLastLog {
+				logs = append(logs, log)
+			}
+			if log.UUID == lastLogUUID {
+				afterLastLog = true
+			}
+		}
+	}
+	if limit > 0 && len(logs) > limit {
+		logs = logs[:limit]
+	}
+	return logs
 }
 
 func (session *Session) GetDiagnosticsData() []DiagnosticsData {
 	session.RLock()
 	defer session.RUnlock()
-	return session.diagnostics
+	secrets := session.configuration.Secrets
+	if len(secrets) == 0 {
+		return session.diagnostics
+	}
+	diagnostics := make([]DiagnosticsData, 0, len(session.diagnostics))
+	for _, data := range session.diagnostics {
+		switch value := data.Value.(type) {
+		case PrevNextDiagnosticsValue:
+			data.Value = PrevNextDiagnosticsValue{Previous: secrets.Redact(value.Previous), Next: secrets.Redact(value.Next)}
+		case string:
+			data.Value = secrets.Redact(value)
+		}
+		diagnostics = append(diagnostics, data)
+	}
+	return diagnostics
 }
 
 func (session *Session) GetEventBus() *SessionLifetimeEventBus {
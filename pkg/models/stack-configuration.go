@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kennygrant/sanitize"
+)
+
+// StackConfiguration describes a set of applications
+// started together for the same checkout name.
+// A stack is reachable through a single entry point (/s/<stack>/<checkout>),
+// which proxies to the session of its entry application.
+type StackConfiguration struct {
+	Name          string             `json:"name"`
+	DefaultBranch string             `yaml:"default_branch" json:"defaultBranch"`
+	Applications  []StackApplication `json:"applications"`
+}
+
+// StackApplication is an application taking part in a stack
+type StackApplication struct {
+	Application string `json:"application"`
+	// Fallback is the checkout used if the requested one does not exist.
+	// Defaults to the stack default branch
+	Fallback string `json:"fallback"`
+	// Entry states that requests to the stack are served by this application.
+	// Defaults to the first application of the stack
+	Entry bool `json:"entry"`
+}
+
+func NewStackConfiguration(configuration *StackConfiguration) (*StackConfiguration, error) {
+	if configuration.Name == "" {
+		return nil, errors.New("stack.name (required) not defined")
+	}
+	if strings.Contains(configuration.Name, "/") {
+		return nil, fmt.Errorf("stack.name %q cannot contain slashes", configuration.Name)
+	}
+	if len(configuration.Applications) == 0 {
+		return nil, fmt.Errorf("stack %s: stack.applications (required) not defined", configuration.Name)
+	}
+	entries := 0
+	for i, application := range configuration.Applications {
+		if application.Application == "" {
+			return nil, fmt.Errorf("stack %s: stack.applications[%d].application (required) not defined", configuration.Name, i)
+		}
+		if application.Fallback == "" {
+			configuration.Applications[i].Fallback = configuration.DefaultBranch
+		}
+		if application.Entry {
+			entries++
+		}
+	}
+	if entries > 1 {
+		return nil, fmt.Errorf("stack %s: only one application can be the entry", configuration.Name)
+	}
+	if entries == 0 {
+		configuration.Applications[0].Entry = true
+	}
+	return configuration, nil
+}
+
+// ValidateStackName checks that the stack is not named like one of the applications
+// or like one of the checkouts of the default application (e.g. "feature" of "feature/login"),
+// since the stack urls (/s/<stack>/...) are matched before the ones of the applications
+func ValidateStackName(name string, applications []string, defaultCheckouts []string) error {
+	for _, application := range applications {
+		if strings.EqualFold(name, application) || strings.EqualFold(name, sanitize.Name(application)) {
+			return fmt.Errorf("stack.name %q is the name of application %s", name, application)
+		}
+	}
+	for _, checkout := range defaultCheckouts {
+		if name == checkout || strings.HasPrefix(checkout, name+"/") {
+			return fmt.Errorf("stack.name %q is the name of checkout %s of the default application", name, checkout)
+		}
+	}
+	return nil
+}
+
+// GetEntry retrieves the application serving the requests to the stack
+func (s *StackConfiguration) GetEntry() StackApplication {
+	for _, application := range s.Applications {
+		if application.Entry {
+			return application
+		}
+	}
+	return s.Applications[0]
+}
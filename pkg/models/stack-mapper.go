@@ -0,0 +1,49 @@
+package models
+
+import (
+	"sort"
+
+	"github.com/wufe/polo/pkg/models/output"
+)
+
+// MapStack maps a stack configuration and the alive sessions of its instances,
+// grouped by checkout, to a stack output model
+func MapStack(model *StackConfiguration, instances map[string][]*Session) output.Stack {
+	stack := output.Stack{
+		Name:          model.Name,
+		DefaultBranch: model.DefaultBranch,
+		Applications:  []output.StackApplication{},
+		Instances:     []output.StackInstance{},
+	}
+	for _, application := range model.Applications {
+		stack.Applications = append(stack.Applications, output.StackApplication{
+			Application: application.Application,
+			Fallback:    application.Fallback,
+			Entry:       application.Entry,
+		})
+	}
+	checkouts := make([]string, 0, len(instances))
+	for checkout := range instances {
+		checkouts = append(checkouts, checkout)
+	}
+	sort.Strings(checkouts)
+	for _, checkout := range checkouts {
+		stack.Instances = append(stack.Instances, output.StackInstance{
+			Checkout: checkout,
+			SmartURL: "/s/" + model.Name + "/" + checkout,
+			Sessions: MapSessions(instances[checkout]),
+		})
+	}
+	return stack
+}
+
+func mapSessionStack(model SessionStack) *output.SessionStack {
+	if !model.IsSet() {
+		return nil
+	}
+	return &output.SessionStack{
+		Name:     model.Name,
+		Checkout: model.Checkout,
+		Entry:    model.Entry,
+	}
+}
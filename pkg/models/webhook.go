@@ -0,0 +1,216 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	WebhookPresetNone  WebhookPreset = ""
+	WebhookPresetSlack WebhookPreset = "slack"
+	WebhookPresetTeams WebhookPreset = "teams"
+
+	// WebhookAllEvents matches all the session and application events
+	WebhookAllEvents = "*"
+
+	defaultWebhookMaxRetries    = 3
+	defaultWebhookRetryInterval = 1
+)
+
+type WebhookPreset string
+
+// Webhook is an HTTP endpoint notified with a POST request
+// when one of the selected session or application events gets published.
+// The payload is a template whose variables are described by WebhookVariables;
+// if empty, it is defined by the preset or, by default, it is a JSON document
+// describing the event.
+// If a secret is set, the payload is signed with HMAC-SHA256
+// and the signature is sent in the X-Polo-Signature header.
+// Failed deliveries are retried with an exponential backoff,
+// starting from RetryInterval seconds
+type Webhook struct {
+	URL           string        `yaml:"url" json:"url"`
+	Events        []string      `yaml:"events" json:"events"`
+	Secret        string        `yaml:"secret" json:"-"`
+	Preset        WebhookPreset `yaml:"preset" json:"preset"`
+	Payload       string        `yaml:"payload" json:"payload"`
+	Headers       []Header      `yaml:"headers" json:"headers"`
+	MaxRetries    int           `yaml:"max_retries" json:"maxRetries"` // Defaults to 3; -1 not to retry
+	RetryInterval int           `yaml:"retry_interval" json:"retryInterval"`
+}
+
+// Matches tells whether the webhook has to be notified of the event
+func (w Webhook) Matches(event string) bool {
+	for _, e := range w.Events {
+		if e == WebhookAllEvents || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPayloadTemplate retrieves the template of the payload,
+// or an empty string if the default JSON document has to be sent
+func (w Webhook) GetPayloadTemplate() string {
+	if w.Payload != "" {
+		return w.Payload
+	}
+	switch w.Preset {
+	case WebhookPresetSlack:
+		return `{"text": "{{message}}"}`
+	case WebhookPresetTeams:
+		return `{"@type": "MessageCard", "@context": "https://schema.org/extensions", "summary": "{{message}}", "text": "{{message}}"}`
+	}
+	return ""
+}
+
+func newWebhooks(webhooks []Webhook) ([]Webhook, error) {
+	if webhooks == nil {
+		return []Webhook{}, nil
+	}
+	errs := ConfigurationErrors{}
+	for i, webhook := range webhooks {
+		if webhook.URL == "" {
+			errs.Add(fmt.Errorf("application.webhooks[%d].url (required) not defined", i))
+		} else if u, err := url.Parse(webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs.Add(fmt.Errorf("application.webhooks[%d].url is not a valid HTTP URL", i))
+		}
+		if len(webhook.Events) == 0 {
+			errs.Add(fmt.Errorf("application.webhooks[%d].events (required) not defined; use \"*\" for all the events", i))
+		}
+		for _, event := range webhook.Events {
+			if !isWebhookEvent(event) {
+				errs.Add(fmt.Errorf("application.webhooks[%d].events: unknown event %q", i, event))
+			}
+		}
+		switch webhook.Preset {
+		case WebhookPresetNone, WebhookPresetSlack, WebhookPresetTeams:
+		default:
+			errs.Add(fmt.Errorf("application.webhooks[%d].preset must be one of slack, teams", i))
+		}
+		for _, header := range webhook.Headers {
+			if _, _, err := header.Parse(); err != nil {
+				errs.Add(fmt.Errorf("application.webhooks[%d].headers: %q is not in the form \"Name=value\"", i, header))
+			}
+		}
+		if webhook.Headers == nil {
+			webhooks[i].Headers = []Header{}
+		}
+		if webhook.MaxRetries == 0 {
+			webhooks[i].MaxRetries = defaultWebhookMaxRetries
+		}
+		if webhook.RetryInterval <= 0 {
+			webhooks[i].RetryInterval = defaultWebhookRetryInterval
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// webhookEvents are the events the webhooks can be notified of
+var webhookEvents = []fmt.Stringer{
+	ApplicationEventTypeInitializationStarted,
+	ApplicationEventTypeInitializationCompleted,
+	ApplicationEventTypeFetchStarted,
+	ApplicationEventTypeFetchCompleted,
+	ApplicationEventTypeHotSwap,
+	ApplicationEventTypeAutoStart,
+	ApplicationEventTypeSessionBuild,
+	ApplicationEventTypeSessionBuildFailed,
+	ApplicationEventTypeSessionBuildSucceeded,
+	ApplicationEventTypeSessionCleaned,
+	ApplicationEventTypePullRequestClosed,
+	SessionEventTypeBuildStarted,
+	SessionEventTypeDependenciesStarted,
+	SessionEventTypeDependenciesFailed,
+	SessionEventTypePreparingFolders,
+	SessionEventTypePreparingFoldersFailed,
+	SessionEventTypeCommandsExecutionStarted,
+	SessionEventTypeCommandsExecutionFailed,
+	SessionEventTypeWarmupStarted,
+	SessionEventTypeWarmupFailed,
+	SessionEventTypeHealthcheckStarted,
+	SessionEventTypeHealthcheckFailed,
+	SessionEventTypeHealthcheckSucceded,
+	SessionEventTypeBuildGettingRetried,
+	SessionEventTypeFolderClean,
+	SessionEventTypeCleanCommandExecution,
+	SessionEventTypeSessionAvailable,
+	SessionEventTypeSessionStarted,
+}
+
+func isWebhookEvent(event string) bool {
+	if event == WebhookAllEvents {
+		return true
+	}
+	for _, e := range webhookEvents {
+		if e.String() == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookMessages are the human readable descriptions of the main events,
+// used by the presets as {{message}}
+var webhookMessages = map[string]string{
+	SessionEventTypeSessionAvailable.String():          "{{session.checkout}} of {{application}} is ready at {{session.url}}",
+	SessionEventTypeSessionStarted.String():            "{{session.checkout}} of {{application}} is running at {{session.url}}",
+	SessionEventTypeHealthcheckFailed.String():         "{{session.checkout}} of {{application}} failed its healthcheck",
+	SessionEventTypeCommandsExecutionFailed.String():   "{{session.checkout}} of {{application}}: a command failed",
+	SessionEventTypeWarmupFailed.String():              "{{session.checkout}} of {{application}}: warmup failed",
+	ApplicationEventTypeSessionBuild.String():          "{{session.checkout}} of {{application}} is being built",
+	ApplicationEventTypeSessionBuildFailed.String():    "{{session.checkout}} build of {{application}} failed",
+	ApplicationEventTypeSessionBuildSucceeded.String(): "{{session.checkout}} build of {{application}} succeeded",
+	ApplicationEventTypeHotSwap.String():               "{{session.checkout}} of {{application}} is being replaced by a new build",
+	ApplicationEventTypeAutoStart.String():             "A session of {{application}} is being started automatically",
+	ApplicationEventTypePullRequestClosed.String():     "{{session.checkout}} of {{application}} has been closed",
+}
+
+// SmartURL builds the smart URL of a checkout (e.g. http://localhost:8888/s/main~alice/)
+func SmartURL(publicURL string, checkout string, variant string) string {
+	smartURL := strings.TrimSuffix(publicURL, "/") + "/s/" + checkout
+	if variant != "" {
+		smartURL += "~" + variant
+	}
+	return smartURL + "/"
+}
+
+// WebhookVariables builds the variables of the payload template of an event:
+// {{event}}, {{application}}, {{message}}, {{timestamp}} and, if the event
+// concerns a session, {{session.uuid}}, {{session.name}}, {{session.checkout}},
+// {{session.display_name}}, {{session.commit}}, {{session.variant}}, {{session.status}},
+// {{session.kill_reason}}, {{session.target}} and {{session.url}} (the smart URL)
+func WebhookVariables(event string, application string, session *Session, publicURL string, timestamp string) Variables {
+	variables := Variables{
+		"event":       event,
+		"application": application,
+		"timestamp":   timestamp,
+	}
+	if session != nil {
+		session.RLock()
+		variables["session.uuid"] = session.UUID
+		variables["session.name"] = session.Alias
+		variables["session.checkout"] = session.Checkout
+		variables["session.display_name"] = session.DisplayName
+		variables["session.commit"] = session.CommitID
+		variables["session.variant"] = session.Variant
+		session.RUnlock()
+		variables["session.status"] = string(session.GetStatus())
+		variables["session.kill_reason"] = string(session.GetKillReason())
+		variables["session.target"] = session.GetTarget()
+		variables["session.url"] = SmartURL(publicURL, variables["session.checkout"], variables["session.variant"])
+	}
+	message, ok := webhookMessages[event]
+	if !ok {
+		message = "{{application}}: {{event}}"
+		if session != nil {
+			message = "{{session.checkout}} of {{application}}: {{event}}"
+		}
+	}
+	variables["message"] = variables.ApplyTo(message)
+	return variables
+}
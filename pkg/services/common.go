@@ -6,4 +6,14 @@ var (
 	ErrApplicationNotFound error = errors.New("Application not found")
 	ErrSessionNotFound     error = errors.New("Session not found")
 	ErrSessionIsNotAlive   error = errors.New("Session is not alive")
+	ErrStackNotFound       error = errors.New("Stack not found")
+	ErrInvalidParameters   error = errors.New("Invalid build parameters")
+	ErrInvalidVariant      error = errors.New("Invalid variant")
+	ErrApplicationNotReady error = errors.New("Application not ready")
+
+	ErrApplicationAlreadyExists  error = errors.New("Application already exists")
+	ErrApplicationNotManaged     error = errors.New("Application not managed through the API")
+	ErrInvalidApplication        error = errors.New("Invalid application")
+	ErrConfigurationHashRequired error = errors.New("The hash of the current configuration is required")
+	ErrConfigurationHashMismatch error = errors.New("The configuration has been changed in the meantime")
 )
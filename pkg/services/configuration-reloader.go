@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/wufe/polo/pkg/background"
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/storage"
+	"github.com/wufe/polo/pkg/utils"
+)
+
+// reloadDelay groups the changes of the files saved together (or in more steps, by some editors)
+const reloadDelay = 500 * time.Millisecond
+
+// ConfigurationReloader applies the changes of the configuration files at runtime:
+// the applications added get initialized, the ones removed get retired along with their sessions,
+// the ones changed get the new configuration and so do the stacks and the global settings
+// which do not require a restart
+type ConfigurationReloader struct {
+	utils.RWLocker
+	environment        utils.Environment
+	configuration      *models.RootConfiguration
+	appStorage         *storage.Application
+	sesStorage         *storage.Session
+	commitIndex        *storage.CommitIndex
+	mediator           *background.Mediator
+	applicationBuilder *models.ApplicationBuilder
+	webhookWorker      *background.WebhookWorker
+	commitStatusWorker *background.CommitStatusWorker
+	log                logging.Logger
+	watchedFolders     map[string]bool
+}
+
+func NewConfigurationReloader(
+	environment utils.Environment,
+	configuration *models.RootConfiguration,
+	appStorage *storage.Application,
+	sesStorage *storage.Session,
+	commitIndex *storage.CommitIndex,
+	mediator *background.Mediator,
+	applicationBuilder *models.ApplicationBuilder,
+	mutexBuilder utils.MutexBuilder,
+	webhookWorker *background.WebhookWorker,
+	commitStatusWorker *background.CommitStatusWorker,
+	logger logging.Logger,
+) *ConfigurationReloader {
+	return &ConfigurationReloader{
+		RWLocker:           mutexBuilder(),
+		environment:        environment,
+		configuration:      configuration,
+		appStorage:         appStorage,
+		sesStorage:         sesStorage,
+		commitIndex:        commitIndex,
+		mediator:           mediator,
+		applicationBuilder: applicationBuilder,
+		webhookWorker:      webhookWorker,
+		commitStatusWorker: commitStatusWorker,
+		log:                logger,
+		watchedFolders:     map[string]bool{},
+	}
+}
+
+// Watch reloads the configuration whenever a configuration file
+// gets created, changed or removed, until the context is done
+func (r *ConfigurationReloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	r.watchFolders(watcher)
+	go func() {
+		defer watcher.Close()
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod || !r.isConfigurationFile(event.Name) {
+					continue
+				}
+				r.log.Debugf("Configuration file %s changed (%s)", event.Name, event.Op)
+				reload = time.After(reloadDelay)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.log.Errorf("Error watching the configuration files: %s", err.Error())
+			case <-reload:
+				reload = nil
+				if err := r.Reload(); err != nil {
+					r.log.Errorf("Configuration not reloaded: %s", err.Error())
+				}
+				// New folders may have been included
+				r.watchFolders(watcher)
+			}
+		}
+	}()
+	return nil
+}
+
+// watchFolders starts watching the folders of the configuration not watched yet
+func (r *ConfigurationReloader) watchFolders(watcher *fsnotify.Watcher) {
+	files := r.configuration.GetFiles()
+	for _, folder := range storage.ConfigurationFolders(r.environment.GetConfigurationPaths(), r.environment.GetExecutableFolder(), files) {
+		if r.watchedFolders[folder] {
+			continue
+		}
+		if err := watcher.Add(folder); err != nil {
+			r.log.Warnf("Could not watch the configuration folder %s: %s", folder, err.Error())
+			continue
+		}
+		r.log.Infof("Watching the configuration folder %s", folder)
+		r.watchedFolders[folder] = true
+	}
+}
+
+func (r *ConfigurationReloader) isConfigurationFile(name string) bool {
+	if storage.IsConfigurationFile(name) {
+		return true
+	}
+	for _, file := range r.configuration.GetFiles() {
+		if file == filepath.Clean(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload loads the configuration files again and applies the differences
+// with the running configuration. Nothing is applied if the configuration is not valid
+func (r *ConfigurationReloader) Reload() error {
+	r.Lock()
+	defer r.Unlock()
+
+	files, issues := storage.ReadConfigurationFiles(r.environment.GetConfigurationPaths(), r.environment.GetExecutableFolder())
+	if len(files) == 0 && len(issues) == 0 {
+		return errors.New("no configuration file found")
+	}
+	validation := models.NewConfigurationValidation(append(issues, storage.ValidateConfigurations(files, r.appStorage.GetCheckouts())...))
+	if !validation.Valid {
+		for _, issue := range validation.Issues {
+			if issue.Severity == models.ConfigurationIssueSeverityError {
+				r.log.Errorln(issue.String())
+			}
+		}
+		return fmt.Errorf("%d errors found in the configuration files", validation.Errors)
+	}
+	filenames := make([]string, 0, len(files))
+	for _, file := range files {
+		filenames = append(filenames, file.Name)
+	}
+	configuration, applications := storage.UnmarshalConfigurations(filenames, r.applicationBuilder, r.log)
+
+	current := map[string]*models.Application{}
+	for _, application := range r.appStorage.GetAll() {
+		current[application.GetConfiguration().Name] = application
+	}
+	for _, application := range applications {
+		name := application.GetConfiguration().Name
+		if existing, ok := current[name]; ok {
+			delete(current, name)
+			r.updateApplication(existing, application)
+		} else {
+			r.addApplication(application)
+		}
+	}
+	for _, application := range current {
+		r.retireApplication(application)
+	}
+
+	r.warnGlobal(configuration.Global)
+	r.configuration.Reload(configuration)
+	return nil
+}
+
+// addApplication stores and initializes an application added to the configuration
+func (r *ConfigurationReloader) addApplication(application *models.Application) {
+	r.log.Infof("[APP:%s] Application added", application.GetConfiguration().Name)
+	r.appStorage.Add(application)
+	r.webhookWorker.WatchApplication(application)
+	r.commitStatusWorker.WatchApplication(application)
+	go func() {
+		if err := r.mediator.ApplicationInit.Enqueue(application); err != nil {
+			r.log.Errorf("Error while loading application: %s", err.Error())
+		}
+	}()
+}
+
+// updateApplication applies the configuration of the application built from the files
+// to the running one, whose sessions get the new configuration too
+func (r *ConfigurationReloader) updateApplication(application *models.Application, reloaded *models.Application) {
+	conf := application.GetConfiguration()
+	newConf := reloaded.GetConfiguration()
+	application.WithLock(func(a *models.Application) {
+		a.Filename = reloaded.Filename
+	})
+	if models.ConfigurationAreEqual(conf, newConf) {
+		return
+	}
+	r.log.Infof("[APP:%s] Configuration changed", newConf.Name)
+	application.WithLock(func(a *models.Application) {
+		a.CompiledForwardPatterns = reloaded.CompiledForwardPatterns
+	})
+	application.SetConfiguration(newConf)
+	for _, session := range r.sesStorage.GetByApplicationName(conf.Name) {
+		session.InitializeConfiguration()
+	}
+}
+
+// retireApplication removes an application from the configuration,
+// destroying its sessions: it is not reachable and not fetched anymore
+func (r *ConfigurationReloader) retireApplication(application *models.Application) {
+	conf := application.GetConfiguration()
+	r.log.Infof("[APP:%s] Application removed", conf.Name)
+	r.appStorage.Remove(application)
+	application.SetStatus(models.ApplicationStatusRetired)
+	for _, session := range r.sesStorage.GetAllAliveApplicationSessions(conf.ID) {
+		session.SetKillReason(models.KillReasonApplicationRemoved)
+		r.mediator.DestroySession.Enqueue(session, nil)
+	}
+	r.commitIndex.Delete(conf.ID)
+	application.GetEventBus().Close()
+}
+
+// warnGlobal reports the changes of the global settings: the ones which can change
+// at runtime get applied along with the configuration, the others on the next restart
+func (r *ConfigurationReloader) warnGlobal(global models.GlobalConfiguration) {
+	current := &r.configuration.Global
+	if current.Port != global.Port || current.TLSCertFile != global.TLSCertFile || current.TLSKeyFile != global.TLSKeyFile {
+		r.log.Warnln("The server settings (port, tls_cert, tls_key) changed: restart Polo to apply them")
+	}
+	if current.SessionsFolder != global.SessionsFolder {
+		r.log.Warnln("The sessions_folder changed: restart Polo to apply it")
+	}
+	if current.Logs != global.Logs || current.Tracing != global.Tracing {
+		r.log.Warnln("The logs or the tracing settings changed: restart Polo to apply them")
+	}
+	if current.MaxConcurrentSessions != global.MaxConcurrentSessions {
+		r.log.Infof("The max_concurrent_sessions changed from %d to %d", current.MaxConcurrentSessions, global.MaxConcurrentSessions)
+	}
+}
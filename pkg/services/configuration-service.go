@@ -0,0 +1,241 @@
+package services
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/models/output"
+	"github.com/wufe/polo/pkg/storage"
+	"github.com/wufe/polo/pkg/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// InvalidConfigurationError is a change of the configuration
+// which has not been applied because of the issues it would introduce
+type InvalidConfigurationError struct {
+	Validation models.ConfigurationValidation
+}
+
+func (e *InvalidConfigurationError) Error() string {
+	return fmt.Sprintf("the configuration would not be valid: %d errors", e.Validation.Errors)
+}
+
+// ConfigurationService manages the applications through the API.
+// They are written in the managed configuration file and applied
+// by the configuration reloader, as for the changes made to the files
+type ConfigurationService struct {
+	utils.RWLocker
+	environment utils.Environment
+	reloader    *ConfigurationReloader
+	managedFile string
+	log         logging.Logger
+}
+
+func NewConfigurationService(environment utils.Environment, reloader *ConfigurationReloader, mutexBuilder utils.MutexBuilder, logger logging.Logger) *ConfigurationService {
+	managedFile := filepath.Join(environment.GetExecutableFolder(), storage.ManagedConfigurationFilename)
+	if abs, err := filepath.Abs(managedFile); err == nil {
+		managedFile = abs
+	}
+	return &ConfigurationService{
+		RWLocker:    mutexBuilder(),
+		environment: environment,
+		reloader:    reloader,
+		managedFile: managedFile,
+		log:         logger,
+	}
+}
+
+// GetApplications retrieves the applications as written in the configuration files
+func (s *ConfigurationService) GetApplications() []output.ConfiguredApplication {
+	s.RLock()
+	defer s.RUnlock()
+	_, applications := s.readApplications()
+	ret := make([]output.ConfiguredApplication, 0, len(applications))
+	for _, application := range applications {
+		ret = append(ret, mapConfiguredApplication(application))
+	}
+	return ret
+}
+
+// GetApplication retrieves an application as written in its configuration file
+func (s *ConfigurationService) GetApplication(name string) (*output.ConfiguredApplication, error) {
+	s.RLock()
+	defer s.RUnlock()
+	_, applications := s.readApplications()
+	application := findConfiguredApplication(applications, name)
+	if application == nil {
+		return nil, ErrApplicationNotFound
+	}
+	ret := mapConfiguredApplication(*application)
+	return &ret, nil
+}
+
+// CreateApplication adds an application, in YAML or JSON, to the managed configuration file
+func (s *ConfigurationService) CreateApplication(content []byte) (*output.ConfiguredApplication, error) {
+	application, err := decodeConfiguredApplication(content, "")
+	if err != nil {
+		return nil, err
+	}
+	name := application["name"].(string)
+	return s.change(name, "", func(managed *storage.ManagedConfiguration, current *storage.ConfiguredApplication) {
+		managed.Set(name, application)
+	})
+}
+
+// UpdateApplication replaces a managed application; hash must be the one of its current configuration.
+// The values left redacted keep their current value
+func (s *ConfigurationService) UpdateApplication(name string, hash string, content []byte) (*output.ConfiguredApplication, error) {
+	application, err := decodeConfiguredApplication(content, name)
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		return nil, ErrConfigurationHashRequired
+	}
+	return s.change(name, hash, func(managed *storage.ManagedConfiguration, current *storage.ConfiguredApplication) {
+		storage.RestoreRedactedValues(application, current.Content)
+		managed.Set(name, application)
+	})
+}
+
+// DeleteApplication removes a managed application; hash must be the one of its current configuration
+func (s *ConfigurationService) DeleteApplication(name string, hash string) error {
+	if hash == "" {
+		return ErrConfigurationHashRequired
+	}
+	_, err := s.change(name, hash, func(managed *storage.ManagedConfiguration, current *storage.ConfiguredApplication) {
+		managed.Remove(name)
+	})
+	return err
+}
+
+// change applies a change to the managed configuration file and reloads the configuration,
+// if it stays valid. Without a hash, the change creates the application;
+// otherwise the hash must be the one of the current configuration of the managed application
+func (s *ConfigurationService) change(name string, hash string, apply func(*storage.ManagedConfiguration, *storage.ConfiguredApplication)) (*output.ConfiguredApplication, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	files, applications := s.readApplications()
+	current := findConfiguredApplication(applications, name)
+	switch {
+	case hash == "" && current != nil:
+		return nil, ErrApplicationAlreadyExists
+	case hash == "":
+	case current == nil:
+		return nil, ErrApplicationNotFound
+	case !current.Managed:
+		return nil, fmt.Errorf("%w: it is defined in %s", ErrApplicationNotManaged, current.File)
+	case hash != current.Hash:
+		return nil, ErrConfigurationHashMismatch
+	}
+
+	managed, err := storage.ReadManagedConfiguration(s.managedFile)
+	if err != nil {
+		return nil, err
+	}
+	apply(managed, current)
+	content, err := managed.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	// The whole configuration is validated with the new content of the managed file
+	candidate := []storage.ConfigurationFile{}
+	for _, file := range files {
+		if file.Name != s.managedFile {
+			candidate = append(candidate, file)
+		}
+	}
+	candidate = append(candidate, storage.ConfigurationFile{Name: s.managedFile, Content: content})
+	validation := models.NewConfigurationValidation(storage.ValidateConfigurations(candidate, s.reloader.appStorage.GetCheckouts()))
+	if !validation.Valid {
+		return nil, &InvalidConfigurationError{Validation: validation}
+	}
+
+	previous, err := ioutil.ReadFile(s.managedFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	existed := err == nil
+	if err := storage.WriteConfigurationFile(s.managedFile, content); err != nil {
+		return nil, err
+	}
+	s.log.Infof("[APP:%s] Configuration written to %s", name, s.managedFile)
+	if err := s.reloader.Reload(); err != nil {
+		// The change not applied is not left in the managed file,
+		// where the next reload would apply it
+		s.restoreManagedFile(previous, existed)
+		return nil, err
+	}
+
+	_, applications = s.readApplications()
+	if application := findConfiguredApplication(applications, name); application != nil {
+		ret := mapConfiguredApplication(*application)
+		return &ret, nil
+	}
+	return nil, nil
+}
+
+// restoreManagedFile writes back the previous content of the managed file,
+// removing it if it did not exist
+func (s *ConfigurationService) restoreManagedFile(previous []byte, existed bool) {
+	var err error
+	if existed {
+		err = storage.WriteConfigurationFile(s.managedFile, previous)
+	} else {
+		err = os.Remove(s.managedFile)
+	}
+	if err != nil {
+		s.log.Errorf("Could not restore %s: %s", s.managedFile, err.Error())
+		return
+	}
+	s.log.Warnf("Configuration change not applied: %s restored", s.managedFile)
+}
+
+func (s *ConfigurationService) readApplications() ([]storage.ConfigurationFile, []storage.ConfiguredApplication) {
+	files, _ := storage.ReadConfigurationFiles(s.environment.GetConfigurationPaths(), s.environment.GetExecutableFolder())
+	return files, storage.ReadConfiguredApplications(files, s.managedFile)
+}
+
+// decodeConfiguredApplication decodes an application sent in YAML or JSON (a subset of YAML).
+// If the name is given, the application gets it
+func decodeConfiguredApplication(content []byte, name string) (map[interface{}]interface{}, error) {
+	application := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(content, &application); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidApplication, err.Error())
+	}
+	applicationName, ok := application["name"].(string)
+	if name != "" {
+		if ok && applicationName != name {
+			return nil, fmt.Errorf("%w: the name cannot be changed", ErrInvalidApplication)
+		}
+		application["name"] = name
+	} else if !ok || applicationName == "" {
+		return nil, fmt.Errorf("%w: the name is required", ErrInvalidApplication)
+	}
+	return application, nil
+}
+
+func findConfiguredApplication(applications []storage.ConfiguredApplication, name string) *storage.ConfiguredApplication {
+	for i := range applications {
+		if applications[i].Name == name {
+			return &applications[i]
+		}
+	}
+	return nil
+}
+
+func mapConfiguredApplication(application storage.ConfiguredApplication) output.ConfiguredApplication {
+	return output.ConfiguredApplication{
+		Name:          application.Name,
+		Filename:      application.File,
+		Managed:       application.Managed,
+		Hash:          application.Hash,
+		Configuration: storage.RedactConfiguration(application.Content),
+	}
+}
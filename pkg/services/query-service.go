@@ -13,18 +13,26 @@ import (
 )
 
 type QueryService struct {
-	isDev              bool
-	sessionStorage     *storage.Session
-	applicationStorage *storage.Application
-	log                logging.Logger
+	isDev               bool
+	configurationFolder string
+	configurationPaths  []string
+	configuration       *models.RootConfiguration
+	sessionStorage      *storage.Session
+	applicationStorage  *storage.Application
+	logStorage          *storage.SessionLog
+	log                 logging.Logger
 }
 
-func NewQueryService(environment utils.Environment, storage *storage.Session, applicationStorage *storage.Application, log logging.Logger) *QueryService {
+func NewQueryService(environment utils.Environment, configuration *models.RootConfiguration, storage *storage.Session, applicationStorage *storage.Application, logStorage *storage.SessionLog, log logging.Logger) *QueryService {
 	s := &QueryService{
-		isDev:              environment.IsDev(),
-		sessionStorage:     storage,
-		applicationStorage: applicationStorage,
-		log:                log,
+		isDev:               environment.IsDev(),
+		configurationFolder: environment.GetExecutableFolder(),
+		configurationPaths:  environment.GetConfigurationPaths(),
+		configuration:       configuration,
+		sessionStorage:      storage,
+		applicationStorage:  applicationStorage,
+		logStorage:          logStorage,
+		log:                 log,
 	}
 	return s
 }
@@ -33,6 +41,31 @@ func (s *QueryService) GetAllApplications() []*models.Application {
 	return s.applicationStorage.GetAll()
 }
 
+func (s *QueryService) GetApplication(name string) *models.Application {
+	return s.applicationStorage.Get(name)
+}
+
+// GetApplicationFetchStatus retrieves whether a fetch of the remote of an application
+// is in progress along with the outcome of the last one
+func (s *QueryService) GetApplicationFetchStatus(name string) (output.ApplicationFetchStatus, error) {
+	application := s.applicationStorage.Get(name)
+	if application == nil {
+		return output.ApplicationFetchStatus{}, ErrApplicationNotFound
+	}
+	return models.MapApplicationFetchStatus(application.GetFetchStatus()), nil
+}
+
+// ValidateConfiguration validates the configuration files of the instance
+func (s *QueryService) ValidateConfiguration() models.ConfigurationValidation {
+	files, issues := storage.ReadConfigurationFiles(s.configurationPaths, s.configurationFolder)
+	return models.NewConfigurationValidation(append(issues, storage.ValidateConfigurations(files, s.applicationStorage.GetCheckouts())...))
+}
+
+// ValidateConfigurationContent validates the content of a configuration file
+func (s *QueryService) ValidateConfigurationContent(name string, content []byte) models.ConfigurationValidation {
+	return models.NewConfigurationValidation(storage.ValidateConfigurations([]storage.ConfigurationFile{{Name: name, Content: content}}, s.applicationStorage.GetCheckouts()))
+}
+
 func (s *QueryService) GetAllAliveSessions() []*models.Session {
 	return s.sessionStorage.GetAllAliveSessions()
 }
@@ -48,6 +81,11 @@ func (s *QueryService) GetAliveSession(uuid string) *models.Session {
 	return foundSession
 }
 
+// GetSession retrieves a session by its UUID, whatever its status
+func (s *QueryService) GetSession(uuid string) *models.Session {
+	return s.sessionStorage.GetByUUID(uuid)
+}
+
 func (s *QueryService) GetSessionStatus(uuid string) (output.SessionStatus, error) {
 	session := s.sessionStorage.GetByUUID(uuid)
 	if session == nil {
@@ -70,27 +108,24 @@ func (s *QueryService) GetSessionLogsAndStatus(uuid string, lastLogUUID string)
 		return nil, models.SessionStatusStarting, ErrSessionNotFound
 	}
 
-	sessionLogs := session.GetLogs()
-	retLogs := sessionLogs
-	if lastLogUUID != "" && lastLogUUID != "<none>" {
-		retLogs = []models.Log{}
-		afterLastLog := false
-		for _, log := range sessionLogs {
-			if afterLastLog {
-				retLogs = append(retLogs, log)
-			}
-			if log.UUID == lastLogUUID {
-				afterLastLog = true
-			}
-		}
+	if lastLogUUID == "<none>" {
+		lastLogUUID = ""
 	}
 
-	return retLogs, session.Status, nil
+	return session.GetLogsAfter(lastLogUUID, 0), session.GetStatus(), nil
+}
+
+// GetSessionLogs pages through the persisted logs of a session channel,
+// even if it has been destroyed, for post-mortem analysis
+func (s *QueryService) GetSessionLogs(uuid string, channel models.LogChannel, lastLogUUID string, limit int) []models.Log {
+	return s.logStorage.GetLogs(uuid, channel, lastLogUUID, limit)
 }
 
 // GetMatchingCheckoutBySmartUrl
 // The rawInput parameter is without prefix "/s/"
-func (s *QueryService) GetMatchingCheckoutBySmartUrl(rawInput string) (checkout string, application string, path string, found bool, foundSession *models.Session) {
+// A variant of the session can be selected appending "~<variant>"
+// to the checkout (i.e. <branch>~<variant>/<path>)
+func (s *QueryService) GetMatchingCheckoutBySmartUrl(rawInput string) (checkout string, application string, variant string, path string, found bool, foundSession *models.Session) {
 	var defaultApp *models.Application
 	apps := s.applicationStorage.GetAll()
 	for _, app := range apps {
@@ -101,20 +136,22 @@ func (s *QueryService) GetMatchingCheckoutBySmartUrl(rawInput string) (checkout
 		}
 	}
 	if defaultApp == nil {
-		return "", "", "", false, nil
+		return "", "", "", "", false, nil
 	}
 
+	rawInput, variant = splitSmartUrlVariant(rawInput)
+
 	// First of all, we check for a RUNNING (started) session with the same checkout
 	sessions := s.sessionStorage.GetAliveApplicationSession(defaultApp)
 	for _, session := range sessions {
-		if session.Status == models.SessionStatusStarted {
+		if session.Status == models.SessionStatusStarted && session.Variant == variant {
 			if session.Checkout == rawInput {
 				// In case the url is formed like /s/<branch>
-				return rawInput, defaultApp.GetConfiguration().Name, "", true, session
+				return rawInput, defaultApp.GetConfiguration().Name, variant, "", true, session
 			} else if strings.HasPrefix(rawInput, session.Checkout+"/") {
 				// In case the url is formed like /s/<branch>/<path>
 				path := strings.Replace(rawInput, fmt.Sprintf(`%s/`, session.Checkout), "", 1)
-				return session.Checkout, defaultApp.GetConfiguration().Name, path, true, session
+				return session.Checkout, defaultApp.GetConfiguration().Name, variant, path, true, session
 			}
 		}
 	}
@@ -128,14 +165,98 @@ func (s *QueryService) GetMatchingCheckoutBySmartUrl(rawInput string) (checkout
 	for k := range objectsToHashMap {
 		if k == rawInput {
 			// In case the url is formed like /s/<branch>
-			return rawInput, defaultApp.GetConfiguration().Name, "", true, nil
+			return rawInput, defaultApp.GetConfiguration().Name, variant, "", true, nil
 		} else if strings.HasPrefix(rawInput, k+"/") {
 			// In case the url is formed like /s/<branch>/<path>
 			path := strings.Replace(rawInput, fmt.Sprintf(`%s/`, k), "", 1)
-			return k, defaultApp.GetConfiguration().Name, path, true, nil
+			return k, defaultApp.GetConfiguration().Name, variant, path, true, nil
+		}
+	}
+	return "", "", "", "", false, nil
+}
+
+// splitSmartUrlVariant removes the "~<variant>" suffix
+// from the checkout segment of a smart url
+func splitSmartUrlVariant(rawInput string) (string, string) {
+	index := strings.Index(rawInput, "~")
+	if index <= 0 || rawInput[index-1] == '/' {
+		return rawInput, ""
+	}
+	rest := rawInput[index+1:]
+	end := strings.Index(rest, "/")
+	if end == -1 {
+		return rawInput[:index], rest
+	}
+	return rawInput[:index] + rest[end:], rest[:end]
+}
+
+func (s *QueryService) GetStacks() []*models.StackConfiguration {
+	return s.configuration.GetStacks()
+}
+
+// GetAliveStackSessions retrieves the alive sessions of all the instances of a stack,
+// grouped by the checkout name the instances have been requested with
+func (s *QueryService) GetAliveStackSessions(stack string) map[string][]*models.Session {
+	instances := make(map[string][]*models.Session)
+	for _, session := range s.sessionStorage.GetAllAliveSessions() {
+		if sessionStack := session.GetStack(); sessionStack.Name == stack {
+			instances[sessionStack.Checkout] = append(instances[sessionStack.Checkout], session)
+		}
+	}
+	return instances
+}
+
+// GetMatchingStackBySmartUrl
+// The rawInput parameter is without prefix "/s/"
+// and is formed like <stack>/<checkout>/<path>
+// The checkout is matched against the running instances of the stack
+// and against the objects of all its applications, preferring the longest one
+func (s *QueryService) GetMatchingStackBySmartUrl(rawInput string) (stack string, checkout string, path string, found bool, entrySession *models.Session) {
+	chunks := strings.SplitN(rawInput, "/", 2)
+	stackConfiguration := s.configuration.GetStack(chunks[0])
+	if stackConfiguration == nil || len(chunks) < 2 {
+		return "", "", "", false, nil
+	}
+	rawInput = chunks[1]
+
+	candidates := []string{}
+	for instanceCheckout := range s.GetAliveStackSessions(stackConfiguration.Name) {
+		candidates = append(candidates, instanceCheckout)
+	}
+	for _, member := range stackConfiguration.Applications {
+		app := s.applicationStorage.Get(member.Application)
+		if app == nil {
+			continue
+		}
+		app.WithRLock(func(a *models.Application) {
+			for k := range a.ObjectsToHashMap {
+				candidates = append(candidates, k)
+			}
+		})
+	}
+
+	for _, candidate := range candidates {
+		if len(candidate) <= len(checkout) {
+			continue
+		}
+		if candidate == rawInput {
+			// In case the url is formed like /s/<stack>/<branch>
+			checkout, path, found = candidate, "", true
+		} else if strings.HasPrefix(rawInput, candidate+"/") {
+			// In case the url is formed like /s/<stack>/<branch>/<path>
+			checkout, path, found = candidate, strings.TrimPrefix(rawInput, candidate+"/"), true
+		}
+	}
+	if !found {
+		return "", "", "", false, nil
+	}
+
+	for _, session := range s.sessionStorage.GetAliveStackSessions(stackConfiguration.Name, checkout) {
+		if session.GetStack().Entry && session.GetReplacedBy() == nil {
+			entrySession = session
 		}
 	}
-	return "", "", "", false, nil
+	return stackConfiguration.Name, checkout, path, true, entrySession
 }
 
 func (s *QueryService) GetMatchingCheckoutByPermalink(rawInput string) (checkout string, application string, path string, found bool) {
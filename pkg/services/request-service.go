@@ -12,6 +12,7 @@ import (
 
 type RequestService struct {
 	isDev              bool
+	configuration      *models.RootConfiguration
 	sessionStorage     *storage.Session
 	applicationStorage *storage.Application
 	mediator           *background.Mediator
@@ -19,11 +20,13 @@ type RequestService struct {
 
 func NewRequestService(
 	environment utils.Environment,
+	configuration *models.RootConfiguration,
 	sessionStorage *storage.Session,
 	applicationStorage *storage.Application,
 	mediator *background.Mediator) *RequestService {
 	return &RequestService{
 		isDev:              environment.IsDev(),
+		configuration:      configuration,
 		sessionStorage:     sessionStorage,
 		applicationStorage: applicationStorage,
 		mediator:           mediator,
@@ -36,26 +39,146 @@ func NewRequestService(
 // If detectBranchOrTag is set to true, if the checkout is a commit ID,
 // the builder will try to detect if the commit belongs to a branch or a tag
 func (s *RequestService) NewSession(checkout string, app string, detectBranchOrTag bool) (*queues.SessionBuildResult, error) {
+	return s.NewSessionWithParameters(checkout, app, detectBranchOrTag, nil)
+}
+
+// NewSessionWithParameters requests for a new session to be built
+// supplying the values of the build parameters declared by the app.
+// Sessions of the same checkout with different parameters are distinct sessions
+func (s *RequestService) NewSessionWithParameters(checkout string, app string, detectBranchOrTag bool, parameters map[string]string) (*queues.SessionBuildResult, error) {
+	return s.NewSessionVariant(checkout, app, detectBranchOrTag, "", parameters)
+}
+
+// NewSessionVariant requests for a new session to be built
+// as a named variant (e.g. one instance per QA engineer).
+// Sessions of the same checkout with different variants are distinct sessions
+func (s *RequestService) NewSessionVariant(checkout string, app string, detectBranchOrTag bool, variant string, parameters map[string]string) (*queues.SessionBuildResult, error) {
+	if err := models.ValidateVariant(variant); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVariant, err.Error())
+	}
 	a := s.applicationStorage.Get(app)
 	if a == nil {
 		return nil, ErrApplicationNotFound
 	}
-	response := s.mediator.BuildSession.Enqueue(checkout, a, nil, nil, detectBranchOrTag)
+	conf := a.GetConfiguration()
+	if _, err := conf.Parameters.Resolve(parameters); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidParameters, err.Error())
+	}
+	response := s.mediator.BuildSession.EnqueueInput(&queues.SessionBuildInput{
+		Checkout:          checkout,
+		Application:       a,
+		DetectBranchOrTag: detectBranchOrTag,
+		Parameters:        parameters,
+		Variant:           variant,
+	})
 	if response.Result == queues.SessionBuildResultFailed {
 		return nil, fmt.Errorf("Error requesting new session: %s", response.FailingReason)
 	}
 	return response, nil
 }
 
+// NewStackSession requests for all the sessions of a stack to be built
+// for the given checkout name.
+// Each application of the stack uses the checkout if it exists,
+// its fallback otherwise.
+// The other members are requested before the entry application,
+// whose session waits for them and gets returned
+func (s *RequestService) NewStackSession(stackName string, checkout string) (*queues.SessionBuildResult, error) {
+	stack := s.configuration.GetStack(stackName)
+	if stack == nil {
+		return nil, ErrStackNotFound
+	}
+
+	var entryApplication *models.Application
+	var entryCheckout string
+	for _, member := range stack.Applications {
+		a := s.applicationStorage.Get(member.Application)
+		if a == nil {
+			return nil, fmt.Errorf("Error requesting new stack session: application %s: %w", member.Application, ErrApplicationNotFound)
+		}
+		memberCheckout, err := resolveStackCheckout(a, checkout, member.Fallback)
+		if err != nil {
+			return nil, fmt.Errorf("Error requesting new stack session: application %s: %s", member.Application, err.Error())
+		}
+		if member.Entry {
+			entryApplication = a
+			entryCheckout = memberCheckout
+			continue
+		}
+		response := s.mediator.BuildSession.EnqueueInput(&queues.SessionBuildInput{
+			Checkout:    memberCheckout,
+			Application: a,
+			Stack:       models.SessionStack{Name: stack.Name, Checkout: checkout},
+		})
+		if response.Result == queues.SessionBuildResultFailed {
+			return nil, fmt.Errorf("Error requesting new stack session: application %s: %s", member.Application, response.FailingReason)
+		}
+	}
+
+	response := s.mediator.BuildSession.EnqueueInput(&queues.SessionBuildInput{
+		Checkout:    entryCheckout,
+		Application: entryApplication,
+		Stack:       models.SessionStack{Name: stack.Name, Checkout: checkout, Entry: true},
+	})
+	if response.Result == queues.SessionBuildResultFailed {
+		return nil, fmt.Errorf("Error requesting new stack session: %s", response.FailingReason)
+	}
+	return response, nil
+}
+
+func resolveStackCheckout(application *models.Application, checkout string, fallback string) (string, error) {
+	var objectsToHashMap map[string]string
+	application.WithRLock(func(a *models.Application) {
+		objectsToHashMap = a.ObjectsToHashMap
+	})
+	if _, ok := objectsToHashMap[checkout]; ok {
+		return checkout, nil
+	}
+	if _, ok := objectsToHashMap[fallback]; ok && fallback != "" {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("neither %s nor fallback %q found", checkout, fallback)
+}
+
+// FetchApplication requests for the remote of an application to be fetched immediately;
+// with watchObjects, the watched branches and the alive sessions get their new commits built.
+// If refs are given (e.g. the ones just pushed), only those get fetched and the objects are always watched
+func (s *RequestService) FetchApplication(app string, refs []string, watchObjects bool) error {
+	a := s.applicationStorage.Get(app)
+	if a == nil {
+		return ErrApplicationNotFound
+	}
+	if a.GetStatus() != models.ApplicationStatusReady {
+		return ErrApplicationNotReady
+	}
+	a.RequestFetch()
+	go func() {
+		if len(refs) > 0 {
+			s.mediator.ApplicationFetch.EnqueueRefs(a, refs)
+		} else {
+			s.mediator.ApplicationFetch.Enqueue(a, watchObjects)
+		}
+	}()
+	return nil
+}
+
+// SessionDeletion destroys a session.
+// If the session is part of a stack, the whole stack gets destroyed
 func (s *RequestService) SessionDeletion(uuid string) error {
 	session := s.sessionStorage.GetByUUID(uuid)
 	if session == nil {
 		return ErrSessionNotFound
 	}
-	if !session.Status.IsAlive() {
+	if !session.GetStatus().IsAlive() {
 		return ErrSessionIsNotAlive
 	}
-	session.SetKillReason(models.KillReasonStopped)
-	s.mediator.DestroySession.Enqueue(session, nil)
+	sessions := []*models.Session{session}
+	if stack := session.GetStack(); stack.IsSet() {
+		sessions = s.sessionStorage.GetAliveStackSessions(stack.Name, stack.Checkout)
+	}
+	for _, session := range sessions {
+		session.SetKillReason(models.KillReasonStopped)
+		s.mediator.DestroySession.Enqueue(session, nil)
+	}
 	return nil
 }
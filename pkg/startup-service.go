@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/wufe/polo/pkg/background"
 	"github.com/wufe/polo/pkg/background/queues"
@@ -26,6 +25,7 @@ type Startup struct {
 	mediator           *background.Mediator
 	applicationBuilder *models.ApplicationBuilder
 	sessionBuilder     *models.SessionBuilder
+	reloader           *services.ConfigurationReloader
 	log                logging.Logger
 
 	sessionBuildWorker       *background.SessionBuildWorker
@@ -36,6 +36,8 @@ type Startup struct {
 	sessionHealthcheckWorker *background.SessionHealthcheckWorker
 	applicationInitWorker    *background.ApplicationInitWorker
 	applicationFetchWorker   *background.ApplicationFetchWorker
+	webhookWorker            *background.WebhookWorker
+	commitStatusWorker       *background.CommitStatusWorker
 }
 
 type StartupParams struct {
@@ -49,6 +51,7 @@ type StartupParams struct {
 	Mediator           *background.Mediator
 	ApplicationBuilder *models.ApplicationBuilder
 	SessionBuilder     *models.SessionBuilder
+	Reloader           *services.ConfigurationReloader
 	Logger             logging.Logger
 
 	SessionBuildWorker       *background.SessionBuildWorker
@@ -59,6 +62,8 @@ type StartupParams struct {
 	SessionHealthcheckWorker *background.SessionHealthcheckWorker
 	ApplicationInitWorker    *background.ApplicationInitWorker
 	ApplicationFetchWorker   *background.ApplicationFetchWorker
+	WebhookWorker            *background.WebhookWorker
+	CommitStatusWorker       *background.CommitStatusWorker
 }
 
 type StartupOptions struct {
@@ -78,6 +83,7 @@ func NewStartup(params StartupParams) *Startup {
 		mediator:           params.Mediator,
 		applicationBuilder: params.ApplicationBuilder,
 		sessionBuilder:     params.SessionBuilder,
+		reloader:           params.Reloader,
 		log:                params.Logger,
 
 		sessionBuildWorker:       params.SessionBuildWorker,
@@ -88,6 +94,8 @@ func NewStartup(params StartupParams) *Startup {
 		sessionHealthcheckWorker: params.SessionHealthcheckWorker,
 		applicationInitWorker:    params.ApplicationInitWorker,
 		applicationFetchWorker:   params.ApplicationFetchWorker,
+		webhookWorker:            params.WebhookWorker,
+		commitStatusWorker:       params.CommitStatusWorker,
 	}
 }
 
@@ -108,6 +116,8 @@ func (s *Startup) Start(options *StartupOptions) {
 	s.sessionHealthcheckWorker.Start()
 	s.applicationInitWorker.Start()
 	s.applicationFetchWorker.Start()
+	s.webhookWorker.Start()
+	s.commitStatusWorker.Start()
 
 	s.loadApplications()
 	s.storeApplications()
@@ -141,44 +151,10 @@ func (s *Startup) storeApplications() {
 	}
 }
 
+// watchApplications applies the changes of the configuration files at runtime
 func (s *Startup) watchApplications(ctx context.Context) {
-	for _, application := range s.applications {
-		var filename string
-		application.WithRLock(func(a *models.Application) {
-			filename = a.Filename
-		})
-		conf := application.GetConfiguration()
-		s.log.Infof("Watching file %s for app %s", filename, conf.Name)
-		go func(filename string, application *models.Application, conf models.ApplicationConfiguration) {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					time.Sleep(2 * time.Second)
-					rootConfig, err := storage.UnmarshalConfiguration(filename, s.applicationBuilder, s.log)
-					if err != nil {
-						continue
-					}
-					if rootConfig.ApplicationConfigurations != nil {
-						for _, c := range rootConfig.ApplicationConfigurations {
-							if c.Name == conf.Name {
-								newConf := *c
-								if !models.ConfigurationAreEqual(conf, newConf) {
-									s.log.Infof(fmt.Sprintf("[APP:%s] Configuration changed", newConf.Name))
-									application.SetConfiguration(newConf)
-									conf = newConf
-									sessions := s.sesStorage.GetByApplicationName(conf.Name)
-									for _, session := range sessions {
-										session.InitializeConfiguration()
-									}
-								}
-							}
-						}
-					}
-				}
-			}
-		}(filename, application, conf)
+	if err := s.reloader.Watch(ctx); err != nil {
+		s.log.Errorf("Could not watch the configuration files: %s", err.Error())
 	}
 }
 
@@ -188,6 +164,7 @@ func (s *Startup) loadSessions() {
 
 func (s *Startup) startSessions() {
 	for _, session := range s.sesStorage.GetAllAliveSessions() {
+		s.webhookWorker.WatchSession(session)
 		s.mediator.HealthcheckSession.Enqueue(queues.SessionHealthcheckInput{
 			Session: session,
 		})
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"sort"
 	"strings"
 
 	"github.com/wufe/polo/pkg/models"
@@ -14,6 +15,9 @@ type Application struct {
 	applications []*models.Application
 }
 
+// ApplicationCheckouts are the branches and the tags of the applications, by application name
+type ApplicationCheckouts map[string][]string
+
 // NewApplication builds new application storage
 func NewApplication(environment utils.Environment) *Application {
 	return &Application{
@@ -29,6 +33,19 @@ func (a *Application) Add(application *models.Application) {
 	a.applications = append(a.applications, application)
 }
 
+// Remove removes an application from the storage
+func (a *Application) Remove(application *models.Application) {
+	a.Lock()
+	defer a.Unlock()
+	applications := make([]*models.Application, 0, len(a.applications))
+	for _, app := range a.applications {
+		if app != application {
+			applications = append(applications, app)
+		}
+	}
+	a.applications = applications
+}
+
 // Get retrieves an application by its name.
 // If name is an empty string, the "default" application is returned
 func (a *Application) Get(name string) *models.Application {
@@ -53,3 +70,22 @@ func (a *Application) GetAll() []*models.Application {
 	defer a.RUnlock()
 	return a.applications
 }
+
+// GetCheckouts retrieves the branches and the tags of all the applications
+func (a *Application) GetCheckouts() ApplicationCheckouts {
+	checkouts := ApplicationCheckouts{}
+	for _, application := range a.GetAll() {
+		names := []string{}
+		application.WithRLock(func(app *models.Application) {
+			for name := range app.BranchesMap {
+				names = append(names, name)
+			}
+			for name := range app.TagsMap {
+				names = append(names, name)
+			}
+		})
+		sort.Strings(names)
+		checkouts[application.GetConfiguration().Name] = names
+	}
+	return checkouts
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+)
+
+// CommitIndex is the storage of the commits indexed by the fetches of the applications.
+// They are persisted one per key, under "commit-index/<application id>/",
+// so that a restart does not read the whole history of the repositories again
+// and each fetch writes only the commits it added or removed.
+// The tips the commits have been indexed from are persisted under "commit-index-tips/<application id>",
+// along with the window of the history, for the next fetch to stop walking at the indexed commits
+type CommitIndex struct {
+	database Database
+	log      logging.Logger
+}
+
+type indexedCommit struct {
+	Hash      string           `json:"hash"`
+	Tree      string           `json:"tree"`
+	Parents   []string         `json:"parents"`
+	Author    object.Signature `json:"author"`
+	Committer object.Signature `json:"committer"`
+	Message   string           `json:"message"`
+}
+
+type indexedTips struct {
+	History models.FetchHistory `json:"history"`
+	Tips    []string            `json:"tips"`
+}
+
+// NewCommitIndex creates new commit index storage
+func NewCommitIndex(db Database, logger logging.Logger) *CommitIndex {
+	return &CommitIndex{
+		database: db,
+		log:      logger,
+	}
+}
+
+func commitIndexPrefix(applicationID string) []byte {
+	return []byte(fmt.Sprintf("commit-index/%s/", applicationID))
+}
+
+func commitIndexTipsKey(applicationID string) []byte {
+	return []byte(fmt.Sprintf("commit-index-tips/%s", applicationID))
+}
+
+// Get retrieves the commits indexed for an application, keyed as in its CommitMap
+func (s *CommitIndex) Get(applicationID string) map[string]*object.Commit {
+	commits := map[string]*object.Commit{}
+	prefix := commitIndexPrefix(applicationID)
+	err := s.database.GetDB().View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = prefix
+		it := txn.NewIterator(options)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			var commit indexedCommit
+			if err := item.Value(func(v []byte) error {
+				return json.Unmarshal(v, &commit)
+			}); err != nil {
+				return err
+			}
+			commits[string(item.Key()[len(prefix):])] = mapIndexedCommit(commit)
+		}
+		return nil
+	})
+	if err != nil {
+		s.log.Errorf("Error while loading the commit index of %s: %s", applicationID, err.Error())
+		return map[string]*object.Commit{}
+	}
+	return commits
+}
+
+// Update persists the changes of the commits indexed for an application
+func (s *CommitIndex) Update(applicationID string, previous map[string]*object.Commit, current map[string]*object.Commit) {
+	prefix := commitIndexPrefix(applicationID)
+	batch := s.database.GetDB().NewWriteBatch()
+	defer batch.Cancel()
+	changes := 0
+	for key, commit := range current {
+		if _, ok := previous[key]; ok {
+			continue
+		}
+		value, err := json.Marshal(indexedCommit{
+			Hash:      commit.Hash.String(),
+			Tree:      commit.TreeHash.String(),
+			Parents:   hashesToStrings(commit.ParentHashes),
+			Author:    commit.Author,
+			Committer: commit.Committer,
+			Message:   commit.Message,
+		})
+		if err != nil {
+			s.log.Errorf("Error while serializing commit %s: %s", key, err.Error())
+			continue
+		}
+		if err := batch.Set(append(append([]byte{}, prefix...), key...), value); err != nil {
+			s.log.Errorf("Error while persisting the commit index of %s: %s", applicationID, err.Error())
+			return
+		}
+		changes++
+	}
+	for key := range previous {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		if err := batch.Delete(append(append([]byte{}, prefix...), key...)); err != nil {
+			s.log.Errorf("Error while persisting the commit index of %s: %s", applicationID, err.Error())
+			return
+		}
+		changes++
+	}
+	if changes == 0 {
+		return
+	}
+	if err := batch.Flush(); err != nil {
+		s.log.Errorf("Error while persisting the commit index of %s: %s", applicationID, err.Error())
+	}
+}
+
+// GetTips retrieves the tips the commits of an application have been indexed from,
+// if they have been indexed with the given window of the history
+func (s *CommitIndex) GetTips(applicationID string, history models.FetchHistory) []string {
+	var tips indexedTips
+	err := s.database.GetDB().View(func(txn *badger.Txn) error {
+		item, err := txn.Get(commitIndexTipsKey(applicationID))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			return json.Unmarshal(v, &tips)
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		s.log.Errorf("Error while loading the commit index of %s: %s", applicationID, err.Error())
+		return nil
+	}
+	if tips.History != history {
+		return nil
+	}
+	return tips.Tips
+}
+
+// UpdateTips persists the tips the commits of an application have been indexed from
+func (s *CommitIndex) UpdateTips(applicationID string, history models.FetchHistory, tips []string) {
+	value, err := json.Marshal(indexedTips{History: history, Tips: tips})
+	if err != nil {
+		s.log.Errorf("Error while serializing the tips of %s: %s", applicationID, err.Error())
+		return
+	}
+	err = s.database.GetDB().Update(func(txn *badger.Txn) error {
+		return txn.Set(commitIndexTipsKey(applicationID), value)
+	})
+	if err != nil {
+		s.log.Errorf("Error while persisting the commit index of %s: %s", applicationID, err.Error())
+	}
+}
+
+// Delete removes the commits indexed for an application, along with their tips
+func (s *CommitIndex) Delete(applicationID string) {
+	prefix := commitIndexPrefix(applicationID)
+	batch := s.database.GetDB().NewWriteBatch()
+	defer batch.Cancel()
+	err := s.database.GetDB().View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = prefix
+		options.PrefetchValues = false
+		it := txn.NewIterator(options)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := batch.Delete(it.Item().KeyCopy(nil)); err != nil {
+				return err
+			}
+		}
+		return batch.Delete(commitIndexTipsKey(applicationID))
+	})
+	if err == nil {
+		err = batch.Flush()
+	}
+	if err != nil {
+		s.log.Errorf("Error while deleting the commit index of %s: %s", applicationID, err.Error())
+	}
+}
+
+func mapIndexedCommit(commit indexedCommit) *object.Commit {
+	parents := make([]plumbing.Hash, 0, len(commit.Parents))
+	for _, parent := range commit.Parents {
+		parents = append(parents, plumbing.NewHash(parent))
+	}
+	return &object.Commit{
+		Hash:         plumbing.NewHash(commit.Hash),
+		TreeHash:     plumbing.NewHash(commit.Tree),
+		ParentHashes: parents,
+		Author:       commit.Author,
+		Committer:    commit.Committer,
+		Message:      commit.Message,
+	}
+}
+
+func hashesToStrings(hashes []plumbing.Hash) []string {
+	ret := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		ret = append(ret, hash.String())
+	}
+	return ret
+}
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+)
+
+var configurationExtensions = []string{".yml", ".yaml"}
+
+// ConfigurationPathError is a configuration path which cannot be resolved;
+// Path is the including file for the paths of the include directive
+type ConfigurationPathError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigurationPathError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// DiscoverConfigurationFiles resolves the configuration files to be loaded.
+// The paths may be files, folders, read recursively skipping the hidden folders,
+// or globs; with no paths, the .yml and .yaml files directly in the default folder are loaded.
+// The files listed by the include directive of a file, resolved the same way
+// relatively to its folder, follow it, while the managed configuration file comes last.
+// Each file is loaded once
+func DiscoverConfigurationFiles(paths []string, defaultFolder string) ([]string, error) {
+	discovery := &configurationDiscovery{visited: map[string]bool{}, files: []string{}}
+	managed := filepath.Join(defaultFolder, ManagedConfigurationFilename)
+	if len(paths) == 0 {
+		files, err := listConfigurationFiles(defaultFolder, false)
+		if err != nil {
+			discovery.errs.Add(&ConfigurationPathError{Path: defaultFolder, Err: err})
+		}
+		for _, file := range files {
+			if file != managed {
+				discovery.add(file)
+			}
+		}
+	}
+	for _, path := range paths {
+		discovery.resolve(path, "")
+	}
+	// The applications managed through the API are loaded whatever the paths
+	if _, err := os.Stat(managed); err == nil {
+		discovery.add(managed)
+	}
+	return discovery.files, discovery.errs.Err()
+}
+
+type configurationDiscovery struct {
+	visited map[string]bool
+	files   []string
+	errs    models.ConfigurationErrors
+}
+
+// resolve adds the files matched by a path; source is the file including it, if any
+func (d *configurationDiscovery) resolve(path string, source string) {
+	reportPath := path
+	if source != "" {
+		reportPath = source
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(source), path)
+		}
+	}
+	matches := []string{path}
+	if strings.ContainsAny(path, "*?[") {
+		var err error
+		matches, err = filepath.Glob(path)
+		if err == nil && len(matches) == 0 {
+			err = os.ErrNotExist
+		}
+		if err != nil {
+			d.errs.Add(&ConfigurationPathError{Path: reportPath, Err: errorWithPath(path, err)})
+			return
+		}
+		sort.Strings(matches)
+	}
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			d.errs.Add(&ConfigurationPathError{Path: reportPath, Err: errorWithPath(match, err)})
+			continue
+		}
+		if !info.IsDir() {
+			d.add(match)
+			continue
+		}
+		files, err := listConfigurationFiles(match, true)
+		if err != nil {
+			d.errs.Add(&ConfigurationPathError{Path: reportPath, Err: errorWithPath(match, err)})
+		}
+		for _, file := range files {
+			d.add(file)
+		}
+	}
+}
+
+// add adds a file, followed by the files it includes
+func (d *configurationDiscovery) add(file string) {
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	if d.visited[file] {
+		return
+	}
+	d.visited[file] = true
+	d.files = append(d.files, file)
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		// Reported reading the configuration
+		return
+	}
+	content, _ = interpolateEnvironment(content)
+	for _, include := range parseRawConfiguration(content).Include {
+		d.resolve(include, file)
+	}
+}
+
+// ConfigurationFolders retrieves the folders whose changes may affect the configuration:
+// those of the configuration files, the folders given as paths along with their subfolders,
+// the folders the globs are rooted at and, with no paths, the default folder
+func ConfigurationFolders(paths []string, defaultFolder string, files []string) []string {
+	folders := []string{}
+	visited := map[string]bool{}
+	add := func(folder string) {
+		if abs, err := filepath.Abs(folder); err == nil {
+			folder = abs
+		}
+		if !visited[folder] {
+			visited[folder] = true
+			folders = append(folders, folder)
+		}
+	}
+	if len(paths) == 0 {
+		add(defaultFolder)
+	}
+	for _, path := range paths {
+		// The folder a glob is rooted at is the one preceding its first pattern
+		for strings.ContainsAny(path, "*?[") {
+			path = filepath.Dir(path)
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			add(filepath.Dir(path))
+			continue
+		}
+		filepath.Walk(path, func(folder string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			if folder != path && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			add(folder)
+			return nil
+		})
+	}
+	for _, file := range files {
+		add(filepath.Dir(file))
+	}
+	return folders
+}
+
+// IsConfigurationFile tells whether a file is a configuration file, by its extension
+func IsConfigurationFile(name string) bool {
+	for _, extension := range configurationExtensions {
+		if strings.HasSuffix(name, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// listConfigurationFiles lists the configuration files of a folder, sorted by path
+func listConfigurationFiles(folder string, recursive bool) ([]string, error) {
+	files := []string{}
+	if !recursive {
+		infos, err := ioutil.ReadDir(folder)
+		if err != nil {
+			return files, err
+		}
+		for _, info := range infos {
+			if !info.IsDir() && IsConfigurationFile(info.Name()) {
+				files = append(files, filepath.Join(folder, info.Name()))
+			}
+		}
+		return files, nil
+	}
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != folder && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if IsConfigurationFile(info.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func errorWithPath(path string, err error) error {
+	if _, ok := err.(*os.PathError); ok {
+		return err
+	}
+	return &os.PathError{Op: "open", Path: path, Err: err}
+}
+
+// logConfigurationPathErrors logs the paths which cannot be resolved
+func logConfigurationPathErrors(err error, logger logging.Logger) {
+	if errs, ok := err.(models.ConfigurationErrors); ok {
+		for _, err := range errs {
+			logger.Errorf("Could not load the configuration: %s", err.Error())
+		}
+	} else if err != nil {
+		logger.Errorf("Could not load the configuration: %s", err.Error())
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+
+	"github.com/wufe/polo/pkg/logging"
+)
+
+// environmentVariableRegex matches ${NAME}, ${NAME:-default} and the escaped $${
+var environmentVariableRegex = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}\n]*))?\}`)
+
+// unsetEnvironmentVariable is an environment variable referenced
+// by a configuration file which is not set and has no default
+type unsetEnvironmentVariable struct {
+	Name   string
+	Line   int
+	Column int
+}
+
+// interpolateEnvironment replaces ${NAME} and ${NAME:-default} with the value of the environment variables;
+// the default is used when the variable is unset or empty, while $${ stands for a literal ${.
+// The variables which are unset and have no default are replaced with an empty string and returned
+func interpolateEnvironment(content []byte) ([]byte, []unsetEnvironmentVariable) {
+	unset := []unsetEnvironmentVariable{}
+	matches := environmentVariableRegex.FindAllSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return content, unset
+	}
+	interpolated := make([]byte, 0, len(content))
+	last := 0
+	for _, match := range matches {
+		interpolated = append(interpolated, content[last:match[0]]...)
+		last = match[1]
+		if match[2] < 0 {
+			interpolated = append(interpolated, "${"...)
+			continue
+		}
+		name := string(content[match[2]:match[3]])
+		value, ok := os.LookupEnv(name)
+		if value == "" && match[4] >= 0 {
+			value, ok = string(content[match[4]:match[5]]), true
+		}
+		if !ok {
+			lineStart := bytes.LastIndexByte(content[:match[0]], '\n') + 1
+			unset = append(unset, unsetEnvironmentVariable{
+				Name:   name,
+				Line:   bytes.Count(content[:match[0]], []byte("\n")) + 1,
+				Column: match[0] - lineStart + 1,
+			})
+		}
+		interpolated = append(interpolated, value...)
+	}
+	return append(interpolated, content[last:]...), unset
+}
+
+// readConfigurationFile reads a configuration file, interpolating the environment variables
+func readConfigurationFile(file string, logger logging.Logger) ([]byte, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	content, unset := interpolateEnvironment(content)
+	for _, variable := range unset {
+		logger.Warnf("%s:%d:%d: %s", file, variable.Line, variable.Column, variable)
+	}
+	return content, nil
+}
+
+func (v unsetEnvironmentVariable) String() string {
+	return fmt.Sprintf("environment variable %s is not set; it is replaced with an empty string", v.Name)
+}
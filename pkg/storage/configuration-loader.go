@@ -2,9 +2,7 @@ package storage
 
 import (
 	"fmt"
-	"io/ioutil"
 	"path/filepath"
-	"strings"
 
 	"github.com/wufe/polo/pkg/logging"
 	"github.com/wufe/polo/pkg/models"
@@ -12,43 +10,33 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// LoadConfigurations loads the configuration from the paths of the environment
+// or, if none is given, from the configuration files of the executable folder
 func LoadConfigurations(environment utils.Environment, applicationBuilder *models.ApplicationBuilder, logger logging.Logger) (*models.RootConfiguration, []*models.Application) {
 	dir := environment.GetExecutableFolder()
 
-	files := getYamlFiles(dir, logger)
-
-	return unmarshalConfigurations(files, applicationBuilder, logger)
-
-}
-
-func getYamlFiles(root string, logger logging.Logger) []string {
-	files := []string{}
-
-	fileInfos, err := ioutil.ReadDir(root)
-	if err != nil {
-		logger.Fatalln("Error reading from directory", err)
-	}
-	for _, info := range fileInfos {
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".yml") {
-			abs, err := filepath.Abs(info.Name())
-			if err != nil {
-				logger.Fatalln("Error resolving configuration file", err)
-			}
-			files = append(files, abs)
-		}
+	files, err := DiscoverConfigurationFiles(environment.GetConfigurationPaths(), dir)
+	logConfigurationPathErrors(err, logger)
+	if len(files) == 0 {
+		logger.Fatalln("No configuration file found")
 	}
 
-	return files
+	return UnmarshalConfigurations(files, applicationBuilder, logger)
+
 }
 
-func unmarshalConfigurations(files []string, applicationBuilder *models.ApplicationBuilder, logger logging.Logger) (*models.RootConfiguration, []*models.Application) {
+// UnmarshalConfigurations decodes the configuration files into the root configuration
+// and builds their applications; the applications defined twice are ignored
+func UnmarshalConfigurations(files []string, applicationBuilder *models.ApplicationBuilder, logger logging.Logger) (*models.RootConfiguration, []*models.Application) {
 	rootConfiguration := &models.RootConfiguration{
 		ApplicationConfigurations: []*models.ApplicationConfiguration{},
 	}
 	applications := []*models.Application{}
+	definedIn := map[string]string{}
+	sources := LoadConfigurationSources(files, logger)
 	for _, file := range files {
 		logger.Infof("Found configuration file %s", file)
-		root, err := UnmarshalConfiguration(file, applicationBuilder, logger)
+		root, err := UnmarshalConfiguration(file, sources, applicationBuilder, logger)
 		if err != nil {
 			continue
 		}
@@ -57,49 +45,116 @@ func unmarshalConfigurations(files []string, applicationBuilder *models.Applicat
 		}
 		if root.ApplicationConfigurations != nil {
 			for _, conf := range root.ApplicationConfigurations {
+				if previous, ok := definedIn[conf.Name]; ok {
+					logger.Errorf("Application %s defined in %s is already defined in %s; it is ignored", conf.Name, file, previous)
+					continue
+				}
 
 				builtApplication, err := applicationBuilder.Build(conf, file)
 				if err != nil {
 					logger.Errorf("Application %s configuration error: %s", conf.Name, err.Error())
 				} else {
+					logger.Infof("Application %s defined in %s", conf.Name, file)
+					definedIn[conf.Name] = file
 					applications = append(applications, builtApplication)
 					conf := builtApplication.GetConfiguration()
 					root.ApplicationConfigurations = append(root.ApplicationConfigurations, &conf)
 				}
 			}
 		}
+		for _, stack := range root.Stacks {
+			if rootConfiguration.GetStack(stack.Name) != nil {
+				logger.Errorf("Stack %s already defined", stack.Name)
+				continue
+			}
+			rootConfiguration.Stacks = append(rootConfiguration.Stacks, stack)
+		}
 	}
 
-	// Default global configurations
-	if rootConfiguration.Global.Port == 0 {
-		rootConfiguration.Global.Port = 8888
+	// Stacks named like an application would hide its urls
+	names := make([]string, 0, len(applications))
+	for _, application := range applications {
+		names = append(names, application.GetConfiguration().Name)
 	}
-
-	if rootConfiguration.Global.SessionsFolder == "" {
-		rootConfiguration.Global.SessionsFolder = "./.sessions"
+	var stacks []*models.StackConfiguration
+	for _, stack := range rootConfiguration.Stacks {
+		if err := models.ValidateStackName(stack.Name, names, nil); err != nil {
+			logger.Errorf("Stack %s configuration error: %s; it is ignored", stack.Name, err.Error())
+			continue
+		}
+		stacks = append(stacks, stack)
 	}
+	rootConfiguration.Stacks = stacks
 
-	if rootConfiguration.Global.MaxConcurrentSessions == 0 {
-		rootConfiguration.Global.MaxConcurrentSessions = 10
-	}
+	rootConfiguration.Files = files
+	models.NewGlobalConfiguration(&rootConfiguration.Global)
 
 	return rootConfiguration, applications
 }
 
-func UnmarshalConfiguration(file string, applicationBuilder *models.ApplicationBuilder, logger logging.Logger) (models.RootConfiguration, error) {
-	content, err := ioutil.ReadFile(file)
+// ConfigurationSources are the data shared by all the configuration files:
+// the templates extended by the applications and the secrets
+type ConfigurationSources struct {
+	// FEATURE: Application templates
+	Templates ConfigurationTemplates
+	// FEATURE: Secrets
+	Secrets models.Secrets
+}
+
+// LoadConfigurationSources collects the templates declared by the configuration files
+// and reads the secrets from the source declared in the global configuration;
+// as for the global configuration, the last file declaring it wins
+func LoadConfigurationSources(files []string, logger logging.Logger) *ConfigurationSources {
+	sources := &ConfigurationSources{Templates: ConfigurationTemplates{}}
+	var secrets models.SecretsConfiguration
+	var secretsFile string
+	for _, file := range files {
+		content, err := readConfigurationFile(file, logger)
+		if err != nil {
+			logger.Errorln(fmt.Sprintf("Could not retrieve content of file %s", file), err)
+			continue
+		}
+		raw := parseRawConfiguration(content)
+		if err := sources.Templates.collect(file, raw); err != nil {
+			logger.Errorln(fmt.Sprintf("Error in configuration file %s", file), err)
+		}
+		if raw.Global.Secrets.IsSet() {
+			secrets, secretsFile = raw.Global.Secrets, file
+		}
+	}
+	if secrets.IsSet() {
+		loaded, err := LoadSecrets(secrets, filepath.Dir(secretsFile))
+		if err != nil {
+			logger.Errorln(fmt.Sprintf("Could not read the secrets declared in %s", secretsFile), err)
+		}
+		sources.Secrets = loaded
+	}
+	return sources
+}
+
+// UnmarshalConfiguration decodes a configuration file, interpolating the environment variables
+// and merging its applications with the templates they extend
+func UnmarshalConfiguration(file string, sources *ConfigurationSources, applicationBuilder *models.ApplicationBuilder, logger logging.Logger) (*models.RootConfiguration, error) {
+	content, err := readConfigurationFile(file, logger)
 	if err != nil {
 		logger.Errorln(fmt.Sprintf("Could not retrieve content of file %s", file), err)
-		return models.RootConfiguration{}, err
+		return &models.RootConfiguration{}, err
 	}
 
-	var root models.RootConfiguration
-	err = yaml.Unmarshal(content, &root)
+	root := &models.RootConfiguration{}
+	err = yaml.Unmarshal(content, root)
 	if err != nil {
 		logger.Errorln(fmt.Sprintf("Error in configuration file %s", file), err)
 	}
+	for i, err := range sources.Templates.extendApplications(file, parseRawConfiguration(content), root) {
+		logger.Errorln(fmt.Sprintf("Error in configuration file %s, application %d", file, i), err)
+		return root, err
+	}
 	if root.ApplicationConfigurations != nil {
 		for i, c := range root.ApplicationConfigurations {
+			if c != nil {
+				c.Secrets = sources.Secrets
+			}
 			root.ApplicationConfigurations[i], err = applicationBuilder.BuildConfiguration(c)
 			if err != nil {
 				logger.Errorln(err)
@@ -107,6 +162,15 @@ func UnmarshalConfiguration(file string, applicationBuilder *models.ApplicationB
 			}
 		}
 	}
+	if root.Stacks != nil {
+		for i, s := range root.Stacks {
+			root.Stacks[i], err = models.NewStackConfiguration(s)
+			if err != nil {
+				logger.Errorln(err)
+				return root, err
+			}
+		}
+	}
 
 	return root, nil
 }
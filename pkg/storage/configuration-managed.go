@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ManagedConfigurationFilename is the configuration file, in the executable folder,
+// of the applications managed through the API; it is loaded along with the other files
+const ManagedConfigurationFilename = "polo.managed.yml"
+
+const managedConfigurationHeader = "# Applications managed through the Polo API (/_polo_/api/applications).\n" +
+	"# This file is rewritten by Polo: the comments are not preserved.\n"
+
+// ConfiguredApplication is an application as written in a configuration file.
+// Hash identifies its content, for the changes made through the API
+// not to overwrite the ones made in the meantime
+type ConfiguredApplication struct {
+	Name    string
+	File    string
+	Managed bool
+	Hash    string
+	Content map[interface{}]interface{}
+}
+
+// ReadConfiguredApplications lists the applications written in the configuration files,
+// in order; the ones written in the managed file are the ones editable through the API
+func ReadConfiguredApplications(files []ConfigurationFile, managedFile string) []ConfiguredApplication {
+	applications := []ConfiguredApplication{}
+	for _, file := range files {
+		for _, content := range parseRawConfiguration(file.Content).Applications {
+			name, _ := content["name"].(string)
+			applications = append(applications, ConfiguredApplication{
+				Name:    name,
+				File:    file.Name,
+				Managed: file.Name == managedFile,
+				Hash:    ConfigurationHash(content),
+				Content: content,
+			})
+		}
+	}
+	return applications
+}
+
+// ConfigurationHash hashes the content of an application;
+// the keys are sorted, so the hash does not depend on their order
+func ConfigurationHash(content map[interface{}]interface{}) string {
+	encoded, err := yaml.Marshal(content)
+	if err != nil {
+		encoded = []byte(fmt.Sprint(content))
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// RedactConfiguration converts the content of an application to be encoded as JSON,
+// replacing the values of the secret and token keys with ***
+func RedactConfiguration(content map[interface{}]interface{}) map[string]interface{} {
+	return toJSONValue(content, "").(map[string]interface{})
+}
+
+// RestoreRedactedValues replaces the values of an application left redacted (***),
+// as returned by RedactConfiguration, with the current ones
+func RestoreRedactedValues(content map[interface{}]interface{}, current map[interface{}]interface{}) {
+	for key, value := range content {
+		currentValue, ok := current[key]
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			if v == "***" && redactedKeys[fmt.Sprint(key)] {
+				content[key] = currentValue
+			}
+		case map[interface{}]interface{}:
+			if currentMap, ok := currentValue.(map[interface{}]interface{}); ok {
+				RestoreRedactedValues(v, currentMap)
+			}
+		case []interface{}:
+			currentList, ok := currentValue.([]interface{})
+			if !ok {
+				continue
+			}
+			for i, item := range v {
+				itemMap, ok := item.(map[interface{}]interface{})
+				if !ok || i >= len(currentList) {
+					continue
+				}
+				if currentMap, ok := currentList[i].(map[interface{}]interface{}); ok {
+					RestoreRedactedValues(itemMap, currentMap)
+				}
+			}
+		}
+	}
+}
+
+// ManagedConfiguration is the content of the managed configuration file
+type ManagedConfiguration struct {
+	Applications []map[interface{}]interface{} `yaml:"applications"`
+}
+
+// ReadManagedConfiguration reads the managed configuration file, if it exists
+func ReadManagedConfiguration(file string) (*ManagedConfiguration, error) {
+	configuration := &ManagedConfiguration{Applications: []map[interface{}]interface{}{}}
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return configuration, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, configuration); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	return configuration, nil
+}
+
+// Set adds an application or replaces the one with the same name
+func (c *ManagedConfiguration) Set(name string, content map[interface{}]interface{}) {
+	for i, application := range c.Applications {
+		if application["name"] == name {
+			c.Applications[i] = content
+			return
+		}
+	}
+	c.Applications = append(c.Applications, content)
+}
+
+// Get retrieves an application by its name
+func (c *ManagedConfiguration) Get(name string) map[interface{}]interface{} {
+	for _, application := range c.Applications {
+		if application["name"] == name {
+			return application
+		}
+	}
+	return nil
+}
+
+// Remove removes an application by its name
+func (c *ManagedConfiguration) Remove(name string) {
+	applications := make([]map[interface{}]interface{}, 0, len(c.Applications))
+	for _, application := range c.Applications {
+		if application["name"] != name {
+			applications = append(applications, application)
+		}
+	}
+	c.Applications = applications
+}
+
+// Marshal encodes the managed configuration file
+func (c *ManagedConfiguration) Marshal() ([]byte, error) {
+	content, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(managedConfigurationHeader), content...), nil
+}
+
+// WriteConfigurationFile replaces the content of a configuration file at once,
+// for it not to be read while partially written
+func WriteConfigurationFile(file string, content []byte) error {
+	temp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), file)
+}
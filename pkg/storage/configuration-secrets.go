@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wufe/polo/pkg/models"
+)
+
+var secretNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// LoadSecrets reads the secrets from their source: first the dotenv file,
+// then the folder, whose files override the secrets with the same name.
+// Relative paths are resolved against the given folder
+func LoadSecrets(configuration models.SecretsConfiguration, folder string) (models.Secrets, error) {
+	secrets := models.Secrets{}
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(folder, path)
+	}
+	if configuration.File != "" {
+		content, err := ioutil.ReadFile(resolve(configuration.File))
+		if err != nil {
+			return nil, err
+		}
+		if err := parseDotenv(content, secrets); err != nil {
+			return nil, fmt.Errorf("%s: %s", configuration.File, err.Error())
+		}
+	}
+	if configuration.Folder != "" {
+		infos, err := ioutil.ReadDir(resolve(configuration.Folder))
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			// Hidden entries are skipped, as the ..data links of the Kubernetes secret volumes
+			if info.IsDir() || strings.HasPrefix(info.Name(), ".") || !secretNameRegex.MatchString(info.Name()) {
+				continue
+			}
+			content, err := ioutil.ReadFile(filepath.Join(resolve(configuration.Folder), info.Name()))
+			if err != nil {
+				return nil, err
+			}
+			secrets[info.Name()] = strings.TrimRight(string(content), "\r\n")
+		}
+	}
+	return secrets, nil
+}
+
+// parseDotenv parses the NAME=value lines of a dotenv file;
+// blank lines, comments and the export keyword are ignored
+// and the quoted values are unquoted
+func parseDotenv(content []byte, secrets models.Secrets) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		kv := strings.SplitN(text, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || !secretNameRegex.MatchString(name) {
+			return fmt.Errorf("line %d is not in the form NAME=value", line)
+		}
+		value := strings.TrimSpace(kv[1])
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return fmt.Errorf("line %d: %s", line, err.Error())
+			}
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		secrets[name] = value
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wufe/polo/pkg/models"
+	"gopkg.in/yaml.v2"
+)
+
+// redactedKeys are the keys whose values are left out of the provenance
+var redactedKeys = map[string]bool{
+	"secret":     true,
+	"token":      true,
+	"passphrase": true,
+}
+
+// ConfigurationTemplates are the application templates declared in the templates section
+// of a set of configuration files, by name; an application may extend a template
+// declared in another file (e.g. a shared base.yml)
+type ConfigurationTemplates map[string]*configurationTemplate
+
+type configurationTemplate struct {
+	file    string
+	content map[interface{}]interface{}
+}
+
+// rawConfiguration is a configuration file decoded as plain YAML,
+// used to merge the applications with their templates
+type rawConfiguration struct {
+	Global struct {
+		Secrets models.SecretsConfiguration `yaml:"secrets"`
+	} `yaml:"global"`
+	Include      []string                               `yaml:"include"`
+	Templates    map[string]map[interface{}]interface{} `yaml:"templates"`
+	Applications []map[interface{}]interface{}          `yaml:"applications"`
+}
+
+// configurationLayer is a template or an application taking part in the merge
+type configurationLayer struct {
+	source  string
+	content map[interface{}]interface{}
+}
+
+func parseRawConfiguration(content []byte) rawConfiguration {
+	var raw rawConfiguration
+	// The errors are reported decoding the configuration
+	yaml.Unmarshal(content, &raw)
+	return raw
+}
+
+// collect adds the templates declared by a configuration file
+func (t ConfigurationTemplates) collect(file string, raw rawConfiguration) error {
+	errs := models.ConfigurationErrors{}
+	names := make([]string, 0, len(raw.Templates))
+	for name := range raw.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := raw.Templates[name]
+		if previous, ok := t[name]; ok {
+			errs.Add(fmt.Errorf("templates.%s already defined in %s", name, previous.file))
+			continue
+		}
+		if _, ok := content["name"]; ok {
+			errs.Add(fmt.Errorf("templates.%s.name is not allowed; the name is given by the applications", name))
+			delete(content, "name")
+		}
+		t[name] = &configurationTemplate{file: file, content: content}
+	}
+	return errs.Err()
+}
+
+// extend merges an application with the templates it extends, recording where each value comes from.
+// The merge starts from the farthest template (a template may extend another one)
+// and ends with the application: mappings are merged key by key, while values and lists
+// replace the inherited ones and a null value removes the inherited one.
+// The branch rules are applied over the result when a checkout gets built
+func (t ConfigurationTemplates) extend(file string, content map[interface{}]interface{}) (map[interface{}]interface{}, models.ConfigurationProvenance, error) {
+	layers := []configurationLayer{{source: fmt.Sprintf("application %v (%s)", content["name"], file), content: content}}
+	chain := []string{}
+	for extends := content["extends"]; extends != nil; {
+		name, ok := extends.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("application.extends must be the name of a template")
+		}
+		for i, visited := range chain {
+			if visited == name {
+				if i == len(chain)-1 {
+					return nil, nil, fmt.Errorf("application.extends: template %s extends itself", name)
+				}
+				return nil, nil, fmt.Errorf("application.extends: templates %s form a cycle", strings.Join(append(chain[i:], name), " → "))
+			}
+		}
+		chain = append(chain, name)
+		template, ok := t[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("application.extends: template %s is not defined", name)
+		}
+		layers = append(layers, configurationLayer{source: fmt.Sprintf("template %s (%s)", name, template.file), content: template.content})
+		extends = template.content["extends"]
+	}
+
+	merged := map[interface{}]interface{}{}
+	provenance := models.ConfigurationProvenance{}
+	for i := len(layers) - 1; i >= 0; i-- {
+		mergeConfiguration(merged, layers[i].content, "", layers[i].source, provenance)
+	}
+	delete(merged, "extends")
+	delete(provenance, "extends")
+	if extends, ok := content["extends"]; ok && extends != nil {
+		merged["extends"] = extends
+		provenance["extends"] = newConfigurationSource("extends", extends, layers[0].source)
+	}
+	return merged, provenance, nil
+}
+
+// extendApplications replaces the decoded applications extending a template
+// with their merged configuration and records the provenance of the values of all the applications.
+// The errors are returned by the index of the application
+func (t ConfigurationTemplates) extendApplications(file string, raw rawConfiguration, root *models.RootConfiguration) map[int]error {
+	errs := map[int]error{}
+	for i, configuration := range root.ApplicationConfigurations {
+		if configuration == nil || i >= len(raw.Applications) {
+			continue
+		}
+		merged, provenance, err := t.extend(file, raw.Applications[i])
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if configuration.Extends != "" {
+			content, err := yaml.Marshal(merged)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			configuration = &models.ApplicationConfiguration{}
+			if err := yaml.Unmarshal(content, configuration); err != nil {
+				errs[i] = err
+				continue
+			}
+			root.ApplicationConfigurations[i] = configuration
+		}
+		configuration.Provenance = provenance
+	}
+	return errs
+}
+
+// mergeConfiguration merges a layer into the configuration built so far
+func mergeConfiguration(merged map[interface{}]interface{}, layer map[interface{}]interface{}, path string, source string, provenance models.ConfigurationProvenance) {
+	for key, value := range layer {
+		keyPath := joinPath(path, fmt.Sprint(key))
+		if value, ok := value.(map[interface{}]interface{}); ok {
+			// The mappings are always copied, for the templates not to be modified
+			inherited, ok := merged[key].(map[interface{}]interface{})
+			if !ok {
+				removeProvenance(provenance, keyPath)
+				inherited = map[interface{}]interface{}{}
+				merged[key] = inherited
+			}
+			mergeConfiguration(inherited, value, keyPath, source, provenance)
+			continue
+		}
+		removeProvenance(provenance, keyPath)
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+		provenance[keyPath] = newConfigurationSource(fmt.Sprint(key), value, source)
+	}
+}
+
+// removeProvenance removes the provenance of a value and of the values it contains
+func removeProvenance(provenance models.ConfigurationProvenance, path string) {
+	for key := range provenance {
+		if key == path || strings.HasPrefix(key, path+".") {
+			delete(provenance, key)
+		}
+	}
+}
+
+func newConfigurationSource(key string, value interface{}, source string) models.ConfigurationSource {
+	encoded, err := json.Marshal(toJSONValue(value, key))
+	if err != nil {
+		return models.ConfigurationSource{Value: fmt.Sprint(value), Source: source}
+	}
+	return models.ConfigurationSource{Value: string(encoded), Source: source}
+}
+
+// toJSONValue converts a YAML value to be encoded as JSON, redacting the secrets
+func toJSONValue(value interface{}, key string) interface{} {
+	if redactedKeys[key] {
+		return "***"
+	}
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			converted[fmt.Sprint(k)] = toJSONValue(item, fmt.Sprint(k))
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			converted[i] = toJSONValue(item, "")
+		}
+		return converted
+	}
+	return value
+}
@@ -0,0 +1,496 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/utils"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	yamlErrorLineRegex = regexp.MustCompile(`line (\d+): (.*)`)
+	errorPathRegex     = regexp.MustCompile(`\b(application|stack|templates)((?:\.[\w-]+|\[\d+\])+)`)
+	pathTokenRegex     = regexp.MustCompile(`[^.\[\]]+|\[\d+\]`)
+)
+
+// ConfigurationFile is the content of a configuration file
+type ConfigurationFile struct {
+	Name    string
+	Content []byte
+}
+
+// parsedConfigurationFile is a configuration file along with its lines,
+// used to locate the issues
+type parsedConfigurationFile struct {
+	ConfigurationFile
+	lines []yamlLine
+	root  models.RootConfiguration
+	raw   rawConfiguration
+}
+
+// ReadConfigurationFiles reads the configuration files found in the given paths
+// or, if none is given, in the default folder, as the configuration is loaded
+func ReadConfigurationFiles(paths []string, defaultFolder string) ([]ConfigurationFile, []models.ConfigurationIssue) {
+	files := []ConfigurationFile{}
+	issues := []models.ConfigurationIssue{}
+	discovered, err := DiscoverConfigurationFiles(paths, defaultFolder)
+	if err != nil {
+		for _, err := range err.(models.ConfigurationErrors) {
+			pathErr := err.(*ConfigurationPathError)
+			issues = append(issues, models.ConfigurationIssue{File: pathErr.Path, Severity: models.ConfigurationIssueSeverityError, Message: pathErr.Err.Error()})
+		}
+	}
+	for _, path := range discovered {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			issues = append(issues, models.ConfigurationIssue{File: path, Severity: models.ConfigurationIssueSeverityError, Message: err.Error()})
+			continue
+		}
+		files = append(files, ConfigurationFile{Name: path, Content: content})
+	}
+	return files, issues
+}
+
+// ValidateConfigurations parses the configuration files and reports all the issues found,
+// located by file, line and column: YAML errors, unknown keys, unset environment variables,
+// unreadable secrets, invalid templates, applications and stacks,
+// applications defined twice, extending undefined templates or depending on undefined applications,
+// invalid regexes, unreachable branch rules and placeholders which can never be resolved.
+// The checkouts of the applications of a running instance, if given,
+// are checked against the names of the stacks
+func ValidateConfigurations(files []ConfigurationFile, checkouts ApplicationCheckouts) []models.ConfigurationIssue {
+	issues := []models.ConfigurationIssue{}
+	parsedFiles := []*parsedConfigurationFile{}
+	for _, file := range files {
+		parsed, fileIssues := parseConfigurationFile(file)
+		issues = append(issues, fileIssues...)
+		if parsed != nil {
+			parsedFiles = append(parsedFiles, parsed)
+		}
+	}
+
+	// The applications are validated once merged with their templates
+	templates := ConfigurationTemplates{}
+	for _, file := range parsedFiles {
+		file.raw = parseRawConfiguration(file.Content)
+		if err := templates.collect(file.Name, file.raw); err != nil {
+			for _, err := range err.(models.ConfigurationErrors) {
+				issues = append(issues, file.newIssue(models.ConfigurationIssueSeverityError, "templates"+errorPath(err.Error(), "templates"), err.Error()))
+			}
+		}
+	}
+	extendErrors := map[*parsedConfigurationFile]map[int]error{}
+	for _, file := range parsedFiles {
+		extendErrors[file] = templates.extendApplications(file.Name, file.raw, &file.root)
+	}
+
+	// Without a readable source of the secrets, their placeholders are not checked
+	var secrets models.Secrets
+	var secretsFile *parsedConfigurationFile
+	for _, file := range parsedFiles {
+		if file.raw.Global.Secrets.IsSet() {
+			secretsFile = file
+		}
+	}
+	if secretsFile != nil {
+		loaded, err := LoadSecrets(secretsFile.raw.Global.Secrets, filepath.Dir(secretsFile.Name))
+		if err != nil {
+			issues = append(issues, secretsFile.newIssue(models.ConfigurationIssueSeverityWarning, "global.secrets", fmt.Sprintf("the secrets cannot be read: %s", err.Error())))
+		}
+		secrets = loaded
+	}
+
+	mutexBuilder := func() utils.RWLocker { return &sync.RWMutex{} }
+	applications := map[string]string{}
+	stackMembers := map[string][]string{}
+	for _, file := range parsedFiles {
+		for _, stack := range file.root.Stacks {
+			if stack == nil {
+				continue
+			}
+			for _, member := range stack.Applications {
+				for _, other := range stack.Applications {
+					if other.Application != member.Application {
+						stackMembers[member.Application] = append(stackMembers[member.Application], other.Application)
+					}
+				}
+			}
+		}
+	}
+
+	for _, file := range parsedFiles {
+		addIssue := func(severity models.ConfigurationIssueSeverity, path string, message string) {
+			issues = append(issues, file.newIssue(severity, path, message))
+		}
+		for i, configuration := range file.root.ApplicationConfigurations {
+			path := fmt.Sprintf("applications[%d]", i)
+			if configuration == nil {
+				addIssue(models.ConfigurationIssueSeverityError, path, "empty application")
+				continue
+			}
+			configuration.Secrets = secrets
+			if err, ok := extendErrors[file][i]; ok {
+				// The application is not validated without its templates
+				addIssue(models.ConfigurationIssueSeverityError, path+errorPath(err.Error(), "application"), err.Error())
+				if _, ok := applications[configuration.Name]; !ok && configuration.Name != "" {
+					applications[configuration.Name] = file.Name
+				}
+				continue
+			}
+			// The configuration gets its defaults even if invalid, and it is linted anyway
+			if _, err := models.NewApplicationConfiguration(configuration, mutexBuilder); err != nil {
+				errs, ok := err.(models.ConfigurationErrors)
+				if !ok {
+					errs = models.ConfigurationErrors{err}
+				}
+				for _, err := range errs {
+					addIssue(models.ConfigurationIssueSeverityError, path+errorPath(err.Error(), "application"), err.Error())
+				}
+			}
+			if configuration.Name != "" {
+				if previous, ok := applications[configuration.Name]; ok {
+					addIssue(models.ConfigurationIssueSeverityError, path+".name", fmt.Sprintf("application %s already defined in %s", configuration.Name, previous))
+				} else {
+					applications[configuration.Name] = file.Name
+				}
+			}
+			for _, issue := range models.LintApplicationConfiguration(configuration, stackMembers[configuration.Name]) {
+				addIssue(issue.Severity, path+"."+issue.Path, issue.Message)
+			}
+		}
+		for i, configuration := range file.root.Stacks {
+			path := fmt.Sprintf("stacks[%d]", i)
+			if configuration == nil {
+				addIssue(models.ConfigurationIssueSeverityError, path, "empty stack")
+				continue
+			}
+			if _, err := models.NewStackConfiguration(configuration); err != nil {
+				addIssue(models.ConfigurationIssueSeverityError, path+errorPath(err.Error(), "stack"), err.Error())
+			}
+		}
+	}
+
+	// References to other applications
+	applicationNames := make([]string, 0, len(applications))
+	for name := range applications {
+		applicationNames = append(applicationNames, name)
+	}
+	sort.Strings(applicationNames)
+	var defaultCheckouts []string
+	for _, file := range parsedFiles {
+		for _, configuration := range file.root.ApplicationConfigurations {
+			if configuration != nil && configuration.IsDefault && defaultCheckouts == nil {
+				defaultCheckouts = checkouts[configuration.Name]
+			}
+		}
+	}
+	for _, file := range parsedFiles {
+		for i, configuration := range file.root.ApplicationConfigurations {
+			if configuration == nil {
+				continue
+			}
+			for j, dependency := range configuration.Dependencies {
+				if _, ok := applications[dependency.Application]; !ok && dependency.Application != "" {
+					issues = append(issues, file.newIssue(models.ConfigurationIssueSeverityWarning, fmt.Sprintf("applications[%d].dependencies[%d].application", i, j), fmt.Sprintf("application %s is not defined", dependency.Application)))
+				}
+			}
+		}
+		for i, stack := range file.root.Stacks {
+			if stack == nil {
+				continue
+			}
+			if err := models.ValidateStackName(stack.Name, applicationNames, defaultCheckouts); err != nil {
+				issues = append(issues, file.newIssue(models.ConfigurationIssueSeverityError, fmt.Sprintf("stacks[%d].name", i), err.Error()))
+			}
+			for j, member := range stack.Applications {
+				if _, ok := applications[member.Application]; !ok && member.Application != "" {
+					issues = append(issues, file.newIssue(models.ConfigurationIssueSeverityWarning, fmt.Sprintf("stacks[%d].applications[%d].application", i, j), fmt.Sprintf("application %s is not defined", member.Application)))
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+	return issues
+}
+
+// parseConfigurationFile decodes a configuration file as the loader does,
+// reporting its YAML errors and its unknown keys
+func parseConfigurationFile(file ConfigurationFile) (*parsedConfigurationFile, []models.ConfigurationIssue) {
+	issues := []models.ConfigurationIssue{}
+	parsed := &parsedConfigurationFile{ConfigurationFile: file}
+	content, unset := interpolateEnvironment(file.Content)
+	parsed.Content = content
+	for _, variable := range unset {
+		issues = append(issues, models.ConfigurationIssue{
+			File:     file.Name,
+			Line:     variable.Line,
+			Column:   variable.Column,
+			Severity: models.ConfigurationIssueSeverityWarning,
+			Message:  variable.String(),
+		})
+	}
+	lines := strings.Split(string(parsed.Content), "\n")
+	yamlErrorIssue := func(message string) models.ConfigurationIssue {
+		issue := models.ConfigurationIssue{File: file.Name, Severity: models.ConfigurationIssueSeverityError, Message: message}
+		if match := yamlErrorLineRegex.FindStringSubmatch(message); match != nil {
+			issue.Line, _ = strconv.Atoi(match[1])
+			issue.Message = match[2]
+			if issue.Line > 0 && issue.Line <= len(lines) {
+				issue.Column = len(lines[issue.Line-1]) - len(strings.TrimLeft(lines[issue.Line-1], " \t")) + 1
+			}
+		}
+		return issue
+	}
+
+	if err := yaml.Unmarshal(parsed.Content, &parsed.root); err != nil {
+		typeError, ok := err.(*yaml.TypeError)
+		if !ok {
+			return nil, append(issues, yamlErrorIssue(err.Error()))
+		}
+		for _, message := range typeError.Errors {
+			issues = append(issues, yamlErrorIssue(message))
+		}
+	}
+
+	parsed.lines = parseYAMLLines(parsed.Content)
+	// The keys are walked as decoded by the loader, with its merge keys
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal(parsed.Content, &document); err == nil {
+		checkUnknownKeys(document, reflect.TypeOf(&parsed.root).Elem(), "", func(path string, message string) {
+			issues = append(issues, parsed.newIssue(models.ConfigurationIssueSeverityWarning, path, message))
+		})
+	}
+	return parsed, issues
+}
+
+// newIssue builds an issue located at the deepest key of the path found in the file
+func (f *parsedConfigurationFile) newIssue(severity models.ConfigurationIssueSeverity, path string, message string) models.ConfigurationIssue {
+	issue := models.ConfigurationIssue{File: f.Name, Path: path, Severity: severity, Message: message}
+	issue.Line, issue.Column = locateLine(f.lines, path)
+	return issue
+}
+
+// errorPath extracts the path of the key an error refers to
+// (e.g. ".forwards[0].pattern" from "application.forwards[0].pattern not defined")
+func errorPath(message string, prefix string) string {
+	for _, match := range errorPathRegex.FindAllStringSubmatch(message, -1) {
+		if match[1] == prefix {
+			return match[2]
+		}
+	}
+	return ""
+}
+
+// yamlLine is a line of a YAML document holding a value,
+// along with its indentation
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// parseYAMLLines retrieves the lines of a YAML document,
+// skipping the blank ones, the comments and the document markers
+func parseYAMLLines(content []byte) []yamlLine {
+	lines := []yamlLine{}
+	for i, line := range strings.Split(string(content), "\n") {
+		text := strings.TrimLeft(strings.TrimRight(line, " \t\r"), " ")
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" || text == "..." {
+			continue
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(line) - len(strings.TrimLeft(line, " ")), text: text})
+	}
+	return lines
+}
+
+// locateLine looks for the line and the column of the key at the given path
+// (e.g. applications[0].branches[1].test) following the indentation of the block style,
+// retrieving the deepest key or sequence item found
+func locateLine(lines []yamlLine, path string) (int, int) {
+	line, column := 0, 0
+	block := lines
+	for _, token := range pathTokenRegex.FindAllString(path, -1) {
+		if len(block) == 0 {
+			break
+		}
+		indent := block[0].indent
+		found := -1
+		if strings.HasPrefix(token, "[") {
+			index, _ := strconv.Atoi(strings.Trim(token, "[]"))
+			for i, l := range block {
+				if l.indent == indent && isSequenceItem(l.text) {
+					if index == 0 {
+						found = i
+						break
+					}
+					index--
+				}
+			}
+			if found < 0 {
+				break
+			}
+			item := block[found]
+			line, column = item.number, item.indent+3
+			// The content of the item starts on its line, after the dash
+			block = append([]yamlLine{{
+				number: item.number,
+				indent: item.indent + 2,
+				text:   strings.TrimLeft(strings.TrimPrefix(item.text, "-"), " "),
+			}}, blockOf(block[found+1:], item.indent, false)...)
+			continue
+		}
+		for i, l := range block {
+			if l.indent == indent && isKey(l.text, token) {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			break
+		}
+		key := block[found]
+		line, column = key.number, key.indent+1
+		block = blockOf(block[found+1:], key.indent, true)
+	}
+	return line, column
+}
+
+// blockOf retrieves the lines following a key, or a sequence item, nested into it.
+// The sequence items of a key may share its indentation
+func blockOf(lines []yamlLine, indent int, allowSequence bool) []yamlLine {
+	for i, l := range lines {
+		if l.indent < indent || (l.indent == indent && !(allowSequence && isSequenceItem(l.text))) {
+			return lines[:i]
+		}
+	}
+	return lines
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isKey(text string, key string) bool {
+	for _, prefix := range []string{key, `"` + key + `"`, "'" + key + "'"} {
+		if strings.HasPrefix(text, prefix+":") {
+			rest := text[len(prefix)+1:]
+			return rest == "" || strings.HasPrefix(rest, " ")
+		}
+	}
+	return false
+}
+
+// checkUnknownKeys walks a YAML value along with the type it gets decoded into,
+// reporting the keys which do not match any field and are therefore ignored
+func checkUnknownKeys(value interface{}, t reflect.Type, path string, report func(string, string)) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		mapping, ok := value.(yaml.MapSlice)
+		if !ok {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for _, field := range models.YAMLFields(t) {
+			fields[field.Name] = field.Type
+		}
+		for _, item := range mapping {
+			key := fmt.Sprint(item.Key)
+			keyPath := joinPath(path, key)
+			fieldType, ok := fields[key]
+			if !ok {
+				message := fmt.Sprintf("unknown key %q; it is ignored", key)
+				if suggestion := closestKey(key, fields); suggestion != "" {
+					message = fmt.Sprintf("unknown key %q; did you mean %q?", key, suggestion)
+				}
+				report(keyPath, message)
+				continue
+			}
+			checkUnknownKeys(item.Value, fieldType, keyPath, report)
+		}
+	case reflect.Slice, reflect.Array:
+		sequence, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range sequence {
+			checkUnknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), report)
+		}
+	case reflect.Map:
+		mapping, ok := value.(yaml.MapSlice)
+		if !ok {
+			return
+		}
+		for _, item := range mapping {
+			checkUnknownKeys(item.Value, t.Elem(), joinPath(path, fmt.Sprint(item.Key)), report)
+		}
+	}
+}
+
+// closestKey suggests the known key most similar to an unknown one
+func closestKey(key string, fields map[string]reflect.Type) string {
+	closest, closestDistance := "", 3
+	for name := range fields {
+		if distance := editDistance(key, name); distance < closestDistance || (distance == closestDistance && name < closest) {
+			closest, closestDistance = name, distance
+		}
+	}
+	return closest
+}
+
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -27,8 +27,13 @@ func NewInstance(port string) *Instance {
 }
 
 func DetectInstance(environment utils.Environment) (*Instance, error) {
-	execFolder := environment.GetExecutableFolder()
-	hostFilepath := filepath.Join(execFolder, ".host")
+	return DetectInstanceInFolder(environment.GetExecutableFolder())
+}
+
+// DetectInstanceInFolder looks for the .host file of a running instance in the folder,
+// checking that the instance is alive
+func DetectInstanceInFolder(folder string) (*Instance, error) {
+	hostFilepath := filepath.Join(folder, ".host")
 	if _, err := os.Stat(hostFilepath); os.IsNotExist(err) {
 		return nil, err
 	}
@@ -40,6 +45,7 @@ func DetectInstance(environment utils.Environment) (*Instance, error) {
 	if err != nil {
 		return nil, err
 	}
+	res.Body.Close()
 	if res.StatusCode == 200 {
 		return &Instance{
 			Host: string(host),
@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+	"github.com/wufe/polo/pkg/utils"
+)
+
+// SessionLog is the session logs storage.
+// Logs are appended to the database keyed by channel, session UUID and sequence number,
+// so they survive restarts and can be looked up after the session has been destroyed.
+// The appended logs are queued and written in batches,
+// not to hold the lock of the session while writing to the database
+type SessionLog struct {
+	utils.RWLocker
+	database  Database
+	retention models.LogsRetention
+	sizes     map[string]int
+	sequences map[string]int64
+	pending   []pendingSessionLog
+	flushing  sync.Mutex
+	flush     chan struct{}
+	log       logging.Logger
+}
+
+type pendingSessionLog struct {
+	sessionUUID string
+	prefix      []byte
+	key         []byte
+	value       []byte
+	logUUID     string
+}
+
+// NewSessionLog creates new session logs storage
+func NewSessionLog(db Database, global *models.GlobalConfiguration, mutexBuilder utils.MutexBuilder, logger logging.Logger) *SessionLog {
+	storage := &SessionLog{
+		RWLocker:  mutexBuilder(),
+		database:  db,
+		retention: global.Logs.Retention,
+		sizes:     make(map[string]int),
+		sequences: make(map[string]int64),
+		flush:     make(chan struct{}, 1),
+		log:       logger,
+	}
+	go storage.startFlushing()
+	return storage
+}
+
+// sessionLogsPrefix retrieves the prefix of the keys of the logs of a session.
+// Build logs are kept under "logs/", the ones of the other channels
+// under "<channel>-logs/" (e.g. "runtime-logs/")
+func sessionLogsPrefix(sessionUUID string, channel models.LogChannel) []byte {
+	if channel == models.LogChannelBuild {
+		return []byte(fmt.Sprintf("logs/%s/", sessionUUID))
+	}
+	return []byte(fmt.Sprintf("%s-logs/%s/", channel, sessionUUID))
+}
+
+// sessionLogKey retrieves the key of a log, ordered by its sequence number
+// within the logs of the session channel
+func sessionLogKey(prefix []byte, sequence int64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", prefix, sequence))
+}
+
+func sessionLogIndexKey(logUUID string) []byte {
+	return []byte(fmt.Sprintf("logs-index/%s", logUUID))
+}
+
+// Append queues a log entry of a session in the given channel.
+// The sequence number of the entry is assigned right away,
+// for the logs to keep the order they have been appended with
+func (s *SessionLog) Append(sessionUUID string, channel models.LogChannel, log models.Log) {
+	value, err := json.Marshal(log)
+	if err != nil {
+		s.log.Errorf("Error while serializing log of session %s: %s", sessionUUID, err.Error())
+		return
+	}
+	prefix := sessionLogsPrefix(sessionUUID, channel)
+	s.Lock()
+	sequence, ok := s.sequences[string(prefix)]
+	if !ok {
+		sequence = s.lastSequence(prefix)
+	}
+	sequence++
+	s.sequences[string(prefix)] = sequence
+	s.pending = append(s.pending, pendingSessionLog{
+		sessionUUID: sessionUUID,
+		prefix:      prefix,
+		key:         sessionLogKey(prefix, sequence),
+		value:       value,
+		logUUID:     log.UUID,
+	})
+	s.Unlock()
+
+	select {
+	case s.flush <- struct{}{}:
+	default:
+	}
+}
+
+func (s *SessionLog) startFlushing() {
+	for range s.flush {
+		s.Flush()
+	}
+}
+
+// Flush writes the queued logs to the database in a single batch,
+// applying the retention policy to each channel separately
+func (s *SessionLog) Flush() {
+	s.flushing.Lock()
+	defer s.flushing.Unlock()
+
+	s.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	batch := s.database.GetDB().NewWriteBatch()
+	defer batch.Cancel()
+	for _, log := range pending {
+		if err := batch.SetEntry(s.newEntry(log.key, log.value)); err != nil {
+			s.log.Errorf("Error while persisting log of session %s: %s", log.sessionUUID, err.Error())
+			return
+		}
+		if err := batch.SetEntry(s.newEntry(sessionLogIndexKey(log.logUUID), log.key)); err != nil {
+			s.log.Errorf("Error while persisting log of session %s: %s", log.sessionUUID, err.Error())
+			return
+		}
+	}
+	if err := batch.Flush(); err != nil {
+		s.log.Errorf("Error while persisting logs: %s", err.Error())
+		return
+	}
+
+	maxSize := s.retention.MaxSize * 1024
+	if maxSize <= 0 {
+		return
+	}
+	appended := make(map[string]int)
+	sessionUUIDs := make(map[string]string)
+	for _, log := range pending {
+		appended[string(log.prefix)] += len(log.value)
+		sessionUUIDs[string(log.prefix)] = log.sessionUUID
+	}
+	for prefix, appendedSize := range appended {
+		size, ok := s.sizes[prefix]
+		if !ok {
+			size = s.computeSize([]byte(prefix))
+		} else {
+			size += appendedSize
+		}
+		if size > maxSize {
+			// The logs expired in the meantime are not part of the size anymore
+			size = s.computeSize([]byte(prefix))
+		}
+		if size > maxSize {
+			// Trims a bit more than needed, not to trim at each append
+			size = s.trim(sessionUUIDs[prefix], []byte(prefix), size, maxSize*9/10)
+		}
+		s.sizes[prefix] = size
+	}
+}
+
+// Forget drops the sizes and sequence numbers kept for the channels of a session
+// once it has ended; they are read back from the database if more logs get appended
+func (s *SessionLog) Forget(sessionUUID string) {
+	s.Flush()
+	s.flushing.Lock()
+	defer s.flushing.Unlock()
+	s.Lock()
+	defer s.Unlock()
+
+	pending := make(map[string]bool)
+	for _, log := range s.pending {
+		pending[string(log.prefix)] = true
+	}
+	suffix := fmt.Sprintf("/%s/", sessionUUID)
+	for prefix := range s.sequences {
+		if strings.HasSuffix(prefix, suffix) && !pending[prefix] {
+			delete(s.sequences, prefix)
+		}
+	}
+	for prefix := range s.sizes {
+		if strings.HasSuffix(prefix, suffix) {
+			delete(s.sizes, prefix)
+		}
+	}
+}
+
+// lastSequence retrieves the sequence number of the last stored log
+// of a session channel, for the logs appended after a restart to follow it
+func (s *SessionLog) lastSequence(prefix []byte) int64 {
+	var sequence int64
+	s.database.GetDB().View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		options.Prefix = prefix
+		options.Reverse = true
+		it := txn.NewIterator(options)
+		defer it.Close()
+		seek := append(append([]byte{}, prefix...), 0xFF)
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			key := it.Item().Key()[len(prefix):]
+			if len(key) > 20 {
+				key = key[:20]
+			}
+			if parsed, err := strconv.ParseInt(string(key), 10, 64); err == nil {
+				sequence = parsed
+			}
+			break
+		}
+		return nil
+	})
+	return sequence
+}
+
+func (s *SessionLog) newEntry(key []byte, value []byte) *badger.Entry {
+	entry := badger.NewEntry(key, value)
+	if s.retention.MaxAge > 0 {
+		entry = entry.WithTTL(time.Duration(s.retention.MaxAge) * time.Hour)
+	}
+	return entry
+}
+
+func (s *SessionLog) computeSize(prefix []byte) int {
+	size := 0
+	s.database.GetDB().View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		options.Prefix = prefix
+		it := txn.NewIterator(options)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			size += int(it.Item().ValueSize())
+		}
+		return nil
+	})
+	return size
+}
+
+// trim removes the oldest logs of a session channel until their size fits the target
+func (s *SessionLog) trim(sessionUUID string, prefix []byte, size int, target int) int {
+	keys := [][]byte{}
+	logUUIDs := []string{}
+	s.database.GetDB().View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = prefix
+		it := txn.NewIterator(options)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix) && size > target; it.Next() {
+			item := it.Item()
+			var log models.Log
+			item.Value(func(v []byte) error {
+				return json.Unmarshal(v, &log)
+			})
+			keys = append(keys, item.KeyCopy(nil))
+			logUUIDs = append(logUUIDs, log.UUID)
+			size -= int(item.ValueSize())
+		}
+		return nil
+	})
+	batch := s.database.GetDB().NewWriteBatch()
+	defer batch.Cancel()
+	for i, key := range keys {
+		batch.Delete(key)
+		batch.Delete(sessionLogIndexKey(logUUIDs[i]))
+	}
+	if err := batch.Flush(); err != nil {
+		s.log.Errorf("Error while trimming logs of session %s: %s", sessionUUID, err.Error())
+	}
+	return size
+}
+
+// GetLogs retrieves the logs of a session channel, oldest first.
+// If afterLogUUID is set, only the logs following that one are retrieved;
+// if that log does not exist anymore, the logs are retrieved from the start.
+// A limit lower or equal to zero retrieves all the logs
+func (s *SessionLog) GetLogs(sessionUUID string, channel models.LogChannel, afterLogUUID string, limit int) []models.Log {
+	s.Flush()
+	logs := []models.Log{}
+	err := s.database.GetDB().View(func(txn *badger.Txn) error {
+		prefix := sessionLogsPrefix(sessionUUID, channel)
+		seek := prefix
+		if afterLogUUID != "" {
+			item, err := txn.Get(sessionLogIndexKey(afterLogUUID))
+			if err == nil {
+				if key, err := item.ValueCopy(nil); err == nil && bytes.HasPrefix(key, prefix) {
+					seek = key
+				}
+			}
+		}
+		options := badger.DefaultIteratorOptions
+		options.Prefix = prefix
+		it := txn.NewIterator(options)
+		defer it.Close()
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if !bytes.Equal(seek, prefix) && bytes.Equal(item.Key(), seek) {
+				continue
+			}
+			err := item.Value(func(v []byte) error {
+				var log models.Log
+				if err := json.Unmarshal(v, &log); err != nil {
+					return err
+				}
+				logs = append(logs, log)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if limit > 0 && len(logs) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.log.Errorf("Error while retrieving logs of session %s: %s", sessionUUID, err.Error())
+	}
+	return logs
+}
@@ -134,7 +134,7 @@ func (s *Session) AliveByApplicationCount(application *models.Application) int {
 	s.log.Trace("Getting alive sessions count by application")
 	count := 0
 	for _, session := range s.sessions {
-		if session.Application == application && session.Status.IsAlive() {
+		if session.Application == application && session.GetStatus().IsAlive() {
 			count++
 		}
 	}
@@ -211,7 +211,7 @@ func (s *Session) GetAliveApplicationSessionByCheckout(checkout string, applicat
 	sessions := s.sessions
 	s.RUnlock()
 	for _, session := range sessions {
-		if session.Application == application && session.Checkout == checkout && session.Status.IsAlive() {
+		if session.Application == application && session.Checkout == checkout && session.GetStatus().IsAlive() {
 			foundSession = session
 		}
 	}
@@ -219,15 +219,16 @@ func (s *Session) GetAliveApplicationSessionByCheckout(checkout string, applicat
 }
 
 // GetAliveApplicationSessionByCommitID retrieves a single session identified by its
-// status (which must be "alive") and by its commitID
-func (s *Session) GetAliveApplicationSessionByCommitID(commitID string, application *models.Application) *models.Session {
+// status (which must be "alive"), by its commitID and by its variant key,
+// leaving out the members of the stacks, which get destroyed along with their stack
+func (s *Session) GetAliveApplicationSessionByCommitID(commitID string, variantKey string, application *models.Application) *models.Session {
 	s.log.Trace("Getting alive session by commitID")
 	var foundSession *models.Session
 	s.RLock()
 	sessions := s.sessions
 	s.RUnlock()
 	for _, session := range sessions {
-		if session.Application == application && session.CommitID == commitID && session.Status.IsAlive() {
+		if session.Application == application && session.GetCommitID() == commitID && session.VariantKey() == variantKey && !session.GetStack().IsSet() && session.GetStatus().IsAlive() {
 			foundSession = session
 		}
 	}
@@ -242,7 +243,24 @@ func (s *Session) GetAliveApplicationSession(application *models.Application) []
 	sessions := s.sessions
 	s.RUnlock()
 	for _, session := range sessions {
-		if session.Application == application && session.Status.IsAlive() {
+		if session.Application == application && session.GetStatus().IsAlive() {
+			foundSessions = append(foundSessions, session)
+		}
+	}
+	return foundSessions
+}
+
+// GetAliveStackSessions retrieves all "alive" sessions
+// belonging to the stack instance identified by its name and checkout
+func (s *Session) GetAliveStackSessions(stack string, checkout string) []*models.Session {
+	s.log.Trace("Getting alive sessions in stack")
+	foundSessions := []*models.Session{}
+	s.RLock()
+	sessions := s.sessions
+	s.RUnlock()
+	for _, session := range sessions {
+		sessionStack := session.GetStack()
+		if sessionStack.Name == stack && sessionStack.Checkout == checkout && session.GetStatus().IsAlive() {
 			foundSessions = append(foundSessions, session)
 		}
 	}
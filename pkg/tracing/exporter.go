@@ -0,0 +1,170 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wufe/polo/pkg/logging"
+)
+
+const (
+	// Interval between the exports of the ended spans
+	exportInterval = time.Second
+	// Maximum amount of spans kept waiting for the export; the exceeding ones get dropped
+	exportQueueSize = 2048
+)
+
+// exporter sends the ended spans in batches
+// to the OTLP/HTTP endpoint of the collector, JSON encoded
+type exporter struct {
+	sync.Mutex
+	url         string
+	serviceName string
+	client      *http.Client
+	queue       []*Span
+	log         logging.Logger
+}
+
+func newExporter(endpoint string, serviceName string, logger logging.Logger) *exporter {
+	e := &exporter{
+		url:         strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		queue:       []*Span{},
+		log:         logger,
+	}
+	go e.run()
+	return e
+}
+
+func (e *exporter) enqueue(span *Span) {
+	e.Lock()
+	defer e.Unlock()
+	if len(e.queue) >= exportQueueSize {
+		return
+	}
+	e.queue = append(e.queue, span)
+}
+
+func (e *exporter) run() {
+	for range time.Tick(exportInterval) {
+		e.Lock()
+		spans := e.queue
+		e.queue = []*Span{}
+		e.Unlock()
+		if len(spans) > 0 {
+			if err := e.export(spans); err != nil {
+				e.log.Warnf("Error exporting %d spans: %s", len(spans), err.Error())
+			}
+		}
+	}
+}
+
+func (e *exporter) export(spans []*Span) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := e.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("collector responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// payload builds the ExportTraceServiceRequest of the spans
+func (e *exporter) payload(spans []*Span) map[string]interface{} {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.Lock()
+		s := otlpSpan{
+			TraceID:           span.context.TraceID,
+			SpanID:            span.context.SpanID,
+			ParentSpanID:      span.parentID,
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        attributes(span.attributes),
+		}
+		if span.err != "" {
+			s.Status = otlpStatus{Code: 2, Message: span.err}
+		}
+		span.Unlock()
+		otlpSpans = append(otlpSpans, s)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": attributes(map[string]interface{}{"service.name": e.serviceName}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "github.com/wufe/polo"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func attributes(values map[string]interface{}) []otlpKeyValue {
+	keyValues := make([]otlpKeyValue, 0, len(values))
+	for key, value := range values {
+		var v map[string]interface{}
+		switch typed := value.(type) {
+		case bool:
+			v = map[string]interface{}{"boolValue": typed}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(typed)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(typed, 10)}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(typed)}
+		}
+		keyValues = append(keyValues, otlpKeyValue{Key: key, Value: v})
+	}
+	return keyValues
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is the W3C Trace Context header
+const TraceparentHeader = "traceparent"
+
+// Extract retrieves the trace context propagated in the headers of a request, if valid
+func Extract(header http.Header) *SpanContext {
+	parts := strings.Split(strings.TrimSpace(header.Get(TraceparentHeader)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return nil
+	}
+	traceID, spanID := strings.ToLower(parts[1]), strings.ToLower(parts[2])
+	if !isHex(traceID, 32) || !isHex(spanID, 16) ||
+		traceID == strings.Repeat("0", 32) || spanID == strings.Repeat("0", 16) {
+		return nil
+	}
+	return &SpanContext{TraceID: traceID, SpanID: spanID}
+}
+
+// Inject propagates the trace context of the span in the headers of a request
+func Inject(span *Span, header http.Header) {
+	if span == nil {
+		return
+	}
+	header.Set(TraceparentHeader, fmt.Sprintf("00-%s-%s-01", span.context.TraceID, span.context.SpanID))
+}
+
+func isHex(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	for _, c := range value {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/wufe/polo/pkg/logging"
+	"github.com/wufe/polo/pkg/models"
+)
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+)
+
+// Tracer creates the spans and exports them, once ended,
+// to the OpenTelemetry collector.
+// A tracer without an endpoint does not create any span
+type Tracer struct {
+	exporter *exporter
+}
+
+func NewTracer(configuration *models.GlobalConfiguration, logger logging.Logger) *Tracer {
+	tracing := configuration.Tracing
+	if tracing.Endpoint == "" {
+		return &Tracer{}
+	}
+	return &Tracer{
+		exporter: newExporter(tracing.Endpoint, tracing.ServiceName, logger),
+	}
+}
+
+// Enabled tells whether the spans get exported
+func (t *Tracer) Enabled() bool {
+	return t != nil && t.exporter != nil
+}
+
+// Start starts a span, child of the one in the context if any,
+// or the root of a new trace otherwise
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	return t.start(ctx, name, SpanKindInternal, SpanFromContext(ctx))
+}
+
+// StartChild starts a span only if the context already carries one,
+// so that operations are traced only as part of a wider trace
+func (t *Tracer) StartChild(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return t.start(ctx, name, SpanKindInternal, parent)
+}
+
+// StartServer starts a span serving a request whose trace context,
+// if any, has been propagated by the caller
+func (t *Tracer) StartServer(ctx context.Context, name string, remote *SpanContext) (context.Context, *Span) {
+	var parent *Span
+	if remote != nil {
+		parent = &Span{context: *remote}
+	}
+	return t.start(ctx, name, SpanKindServer, parent)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, parent *Span) (context.Context, *Span) {
+	if !t.Enabled() {
+		return ctx, nil
+	}
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+	if parent != nil {
+		span.context.TraceID = parent.context.TraceID
+		span.parentID = parent.context.SpanID
+	} else {
+		span.context.TraceID = randomHex(16)
+	}
+	span.context.SpanID = randomHex(8)
+	return ContextWithSpan(ctx, span), span
+}
+
+// SpanContext identifies a span inside a trace
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+}
+
+// Span is a timed operation of a trace.
+// All its methods can be called on a nil span, which does nothing
+type Span struct {
+	sync.Mutex
+	tracer     *Tracer
+	context    SpanContext
+	parentID   string
+	name       string
+	kind       SpanKind
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	err        string
+	ended      bool
+}
+
+// Context retrieves the identifiers of the span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttribute sets an attribute of the span; values are strings, booleans or integers
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.err = err.Error()
+}
+
+// End ends the span and queues it for the export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	if s.ended {
+		s.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.Unlock()
+	s.tracer.exporter.enqueue(s)
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan attaches a span to the context
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext retrieves the span of the context, if any
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+func randomHex(bytes int) string {
+	b := make([]byte, bytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,11 +1,17 @@
 package utils
 
 import (
+	"flag"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// ConfigurationPathsVariable lists the configuration paths,
+// separated as the PATH (: on Unix, ; on Windows)
+const ConfigurationPathsVariable = "POLO_CONFIG"
+
 type Environment interface {
 	IsTest() bool
 	IsDev() bool
@@ -13,12 +19,19 @@ type Environment interface {
 	IsDiagnostics() bool
 	DevServerURL() string
 	GetExecutableFolder() string
+	GetConfigurationPaths() []string
 }
 
-type environmentImpl struct{}
+type environmentImpl struct {
+	configurationPaths []string
+}
 
 func DetectEnvironment() Environment {
-	return &environmentImpl{}
+	e := &environmentImpl{}
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ExitOnError)
+	flags.Var((*pathsFlag)(&e.configurationPaths), "config", "Configuration file, folder or glob; may be repeated (env "+ConfigurationPathsVariable+")")
+	flags.Parse(os.Args[1:])
+	return e
 }
 
 func (e *environmentImpl) IsTest() bool {
@@ -60,3 +73,37 @@ func (e *environmentImpl) GetExecutableFolder() string {
 	dir := filepath.Dir(executablePath)
 	return dir
 }
+
+// GetConfigurationPaths retrieves the files, folders and globs
+// the configuration is loaded from: the --config flags or,
+// if none is given, the POLO_CONFIG environment variable.
+// When empty, the configuration files of the executable folder are loaded
+func (e *environmentImpl) GetConfigurationPaths() []string {
+	if len(e.configurationPaths) > 0 {
+		return e.configurationPaths
+	}
+	return ConfigurationPathsFromEnvironment()
+}
+
+// ConfigurationPathsFromEnvironment splits the POLO_CONFIG environment variable
+func ConfigurationPathsFromEnvironment() []string {
+	paths := []string{}
+	for _, path := range filepath.SplitList(os.Getenv(ConfigurationPathsVariable)) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// pathsFlag is a flag which may be repeated
+type pathsFlag []string
+
+func (f *pathsFlag) String() string {
+	return strings.Join(*f, string(filepath.ListSeparator))
+}
+
+func (f *pathsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
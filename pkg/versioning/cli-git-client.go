@@ -2,17 +2,29 @@ package versioning
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/wufe/polo/pkg/execution"
+	"github.com/wufe/polo/pkg/models"
 )
 
+// cliCredentialHelper answers git with the username and the token of the environment,
+// for them not to appear in the arguments of the processes
+const cliCredentialHelper = `!f() { test "$1" = get && echo "username=${POLO_GIT_USERNAME}" && echo "password=${POLO_GIT_TOKEN}"; }; f`
+
 type CLIGitClient struct {
 	commandRunner execution.CommandRunner
+	auth          models.GitAuth
+	checkout      models.CheckoutConfiguration
 }
 
 func NewCLIGitClient(commandRunner execution.CommandRunner) GitClient {
@@ -21,9 +33,36 @@ func NewCLIGitClient(commandRunner execution.CommandRunner) GitClient {
 	}
 }
 
+func (client *CLIGitClient) WithAuth(auth models.GitAuth) GitClient {
+	return &CLIGitClient{
+		commandRunner: client.commandRunner,
+		auth:          auth,
+		checkout:      client.checkout,
+	}
+}
+
+func (client *CLIGitClient) WithCheckout(checkout models.CheckoutConfiguration) GitClient {
+	return &CLIGitClient{
+		commandRunner: client.commandRunner,
+		auth:          client.auth,
+		checkout:      checkout,
+	}
+}
+
 func (client *CLIGitClient) Clone(baseFolder string, outputFolder string, remote string) error {
-	cmd := exec.Command("git", "clone", remote, outputFolder)
-	cmd.Dir = baseFolder
+	args := append([]string{"clone"}, client.depthArgs()...)
+	if client.checkout.Filter != "" {
+		args = append(args, "--filter="+client.checkout.Filter)
+	}
+	if len(client.checkout.Sparse) > 0 {
+		// The files get checked out by the hard reset, once the sparse checkout is set
+		args = append(args, "--no-checkout")
+	}
+	cmd, err := client.command(baseFolder, append(args, remote, outputFolder)...)
+	if err != nil {
+		return err
+	}
+	client.skipLFSSmudge(cmd)
 	return client.execCommands(cmd)
 }
 
@@ -36,21 +75,203 @@ func (client *CLIGitClient) FetchAll(repoFolder string) error {
 		}
 	}
 
-	cmd := exec.Command("git", "fetch", "--force", "-u", "origin", "+refs/*:refs/*", "--prune")
-	cmd.Dir = repoFolder
+	cmd, err := client.command(repoFolder, append([]string{"fetch", "--force", "-u", "origin", "+refs/*:refs/*", "--prune"}, client.depthArgs()...)...)
+	if err != nil {
+		return err
+	}
+	return client.execCommands(cmd)
+}
+
+func (client *CLIGitClient) FetchRef(repoFolder string, ref string) error {
+	cmd, err := client.command(repoFolder, append([]string{"fetch", "--force", "-u", "origin", fmt.Sprintf("+%s:%s", ref, ref)}, client.depthArgs()...)...)
+	if err != nil {
+		return err
+	}
 	return client.execCommands(cmd)
 }
 
 func (client *CLIGitClient) HardReset(repoFolder string, commit string) error {
+	if len(client.checkout.Sparse) > 0 {
+		if err := client.setSparseCheckout(repoFolder); err != nil {
+			return err
+		}
+	}
+	if client.checkout.Depth > 0 || client.checkout.Filter != "" {
+		// The commit may be beyond the history fetched
+		exists := exec.Command("git", "cat-file", "-e", commit+"^{commit}")
+		exists.Dir = repoFolder
+		if client.execCommands(exists) != nil {
+			fetch, err := client.command(repoFolder, append([]string{"fetch", "origin", commit}, client.depthArgs()...)...)
+			if err != nil {
+				return err
+			}
+			if err := client.execCommands(fetch); err != nil {
+				return err
+			}
+		}
+	}
+
 	stash := exec.Command("git", "stash", "-u")
 	stash.Dir = repoFolder
 
-	reset := exec.Command("git", "reset", "--hard", commit)
-	reset.Dir = repoFolder
+	// The files left out of a partial clone are fetched by the reset
+	reset, err := client.command(repoFolder, "reset", "--hard", commit)
+	if err != nil {
+		return err
+	}
+	client.skipLFSSmudge(reset)
 
 	return client.execCommands(stash, reset)
 }
 
+func (client *CLIGitClient) UpdateSubmodules(repoFolder string) error {
+	sync, err := client.command(repoFolder, "submodule", "sync", "--recursive")
+	if err != nil {
+		return err
+	}
+	update, err := client.command(repoFolder, "submodule", "update", "--init", "--recursive", "--force")
+	if err != nil {
+		return err
+	}
+	return client.execCommands(sync, update)
+}
+
+func (client *CLIGitClient) CheckoutLFS(repoFolder string) error {
+	version := exec.Command("git", "lfs", "version")
+	version.Dir = repoFolder
+	if err := client.execCommands(version); err != nil {
+		return fmt.Errorf("git-lfs is not installed: %s", strings.TrimSpace(err.Error()))
+	}
+	pull, err := client.command(repoFolder, "lfs", "pull")
+	if err != nil {
+		return err
+	}
+	submodules, err := client.command(repoFolder, "submodule", "foreach", "--recursive", "git lfs pull")
+	if err != nil {
+		return err
+	}
+	return client.execCommands(pull, submodules)
+}
+
+// skipLFSSmudge leaves the LFS objects to CheckoutLFS, for its errors to be reported as such
+func (client *CLIGitClient) skipLFSSmudge(cmd *exec.Cmd) {
+	if !client.checkout.LFS {
+		return
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "GIT_LFS_SKIP_SMUDGE=1")
+}
+
+// setSparseCheckout restricts the files checked out to the sparse patterns (non-cone mode),
+// writing them at each reset for the changes of the configuration to apply
+func (client *CLIGitClient) setSparseCheckout(repoFolder string) error {
+	sparse := exec.Command("git", "config", "core.sparseCheckout", "true")
+	sparse.Dir = repoFolder
+	cone := exec.Command("git", "config", "core.sparseCheckoutCone", "false")
+	cone.Dir = repoFolder
+	if err := client.execCommands(sparse, cone); err != nil {
+		return err
+	}
+	info := filepath.Join(repoFolder, ".git", "info")
+	if err := os.MkdirAll(info, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(info, "sparse-checkout"), []byte(strings.Join(client.checkout.Sparse, "\n")+"\n"), 0644)
+}
+
+func (client *CLIGitClient) depthArgs() []string {
+	if client.checkout.Depth <= 0 {
+		return []string{}
+	}
+	return []string{"--depth", strconv.Itoa(client.checkout.Depth)}
+}
+
+// command builds a git command reaching the remote with the credentials of the client:
+// the SSH ones through GIT_SSH_COMMAND, the HTTPS ones through a credential helper
+// replacing the ones configured for the user running Polo
+func (client *CLIGitClient) command(dir string, args ...string) (*exec.Cmd, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if !client.auth.IsSet() {
+		return cmd, nil
+	}
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if ssh := client.auth.SSH; ssh.IsSet() {
+		env = append(env, "GIT_SSH_COMMAND="+sshCommand(ssh))
+		if ssh.Passphrase != "" {
+			askPass, err := sshAskPass()
+			if err != nil {
+				return nil, err
+			}
+			env = append(env,
+				"SSH_ASKPASS="+askPass,
+				"SSH_ASKPASS_REQUIRE=force",
+				"POLO_GIT_PASSPHRASE="+ssh.Passphrase,
+			)
+		}
+	}
+	if https := client.auth.HTTPS; https.IsSet() {
+		cmd.Args = append([]string{"git", "-c", "credential.helper=", "-c", "credential.helper=" + cliCredentialHelper}, args...)
+		env = append(env,
+			"POLO_GIT_USERNAME="+https.Username,
+			"POLO_GIT_TOKEN="+https.Token,
+		)
+	}
+	cmd.Env = env
+	return cmd, nil
+}
+
+func sshCommand(auth models.GitSSHAuth) string {
+	command := []string{"ssh"}
+	if auth.Key != "" {
+		command = append(command, "-i", shellQuote(auth.Key), "-o", "IdentitiesOnly=yes")
+	}
+	switch auth.KnownHosts {
+	case models.GitAuthKnownHostsStrict:
+		command = append(command, "-o", "StrictHostKeyChecking=yes")
+	case models.GitAuthKnownHostsAcceptNew:
+		command = append(command, "-o", "StrictHostKeyChecking=accept-new")
+	case models.GitAuthKnownHostsInsecure:
+		command = append(command, "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null")
+	}
+	if auth.KnownHostsFile != "" && auth.KnownHosts != models.GitAuthKnownHostsInsecure {
+		command = append(command, "-o", "UserKnownHostsFile="+shellQuote(auth.KnownHostsFile))
+	}
+	return strings.Join(command, " ")
+}
+
+func shellQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
+var askPass struct {
+	sync.Once
+	path string
+	err  error
+}
+
+// sshAskPass writes the program answering ssh with the passphrase of the environment
+// (SSH_ASKPASS, used without a terminal since OpenSSH 8.4)
+func sshAskPass() (string, error) {
+	askPass.Do(func() {
+		file, err := ioutil.TempFile("", "polo-askpass")
+		if err != nil {
+			askPass.err = err
+			return
+		}
+		defer file.Close()
+		if _, err := file.WriteString("#!/bin/sh\necho \"$POLO_GIT_PASSPHRASE\"\n"); err != nil {
+			askPass.err = err
+			return
+		}
+		askPass.path = file.Name()
+		askPass.err = os.Chmod(askPass.path, 0700)
+	})
+	return askPass.path, askPass.err
+}
+
 func (client *CLIGitClient) execCommands(cmds ...*exec.Cmd) error {
 	for _, cmd := range cmds {
 		errorLines := []string{}
@@ -61,7 +282,7 @@ func (client *CLIGitClient) execCommands(cmds ...*exec.Cmd) error {
 			}
 		}, cmd)
 		if err != nil {
-			return fmt.Errorf("%s\n%s", strings.Join(errorLines, "\n"), err.Error())
+			return errors.New(client.auth.Redact(fmt.Sprintf("%s\n%s", strings.Join(errorLines, "\n"), err.Error())))
 		}
 	}
 	return nil
@@ -0,0 +1,236 @@
+package versioning
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// historyWindow is the window of the history indexed by a fetch
+type historyWindow struct {
+	since      time.Time
+	maxCommits int // per ref; 0 for no limit
+	refsOnly   bool
+}
+
+// commitIndex reads the commits of a repository during a fetch.
+// The commits already known, e.g. indexed by the previous fetch, are not read again.
+// When the known commits are the index built by the previous fetch from the known tips,
+// the walk of the history stops at them, so that only the commits new since the known tips
+// get processed. The known commits may also be keyed by the annotated tags pointing to them
+type commitIndex struct {
+	repo      *git.Repository
+	known     map[string]*object.Commit
+	knownTips map[plumbing.Hash]bool
+	commits   map[plumbing.Hash]*object.Commit
+}
+
+func newCommitIndex(repo *git.Repository, known map[string]*object.Commit, knownTips []string) *commitIndex {
+	if known == nil {
+		known = map[string]*object.Commit{}
+	}
+	index := &commitIndex{
+		repo:      repo,
+		known:     known,
+		knownTips: map[plumbing.Hash]bool{},
+		commits:   map[plumbing.Hash]*object.Commit{},
+	}
+	for _, tip := range knownTips {
+		index.knownTips[plumbing.NewHash(tip)] = true
+	}
+	return index
+}
+
+// get retrieves a commit, or the one an annotated tag points to,
+// reading it from the repository only if not known
+func (i *commitIndex) get(hash plumbing.Hash) (*object.Commit, error) {
+	if commit, ok := i.commits[hash]; ok {
+		return commit, nil
+	}
+	commit, ok := i.known[hash.String()]
+	if !ok {
+		read, err := i.repo.CommitObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		commit = indexedCommit(read)
+	}
+	i.commits[hash] = commit
+	return commit, nil
+}
+
+// tips retrieves the commits the refs point to, as walked by git log --all
+func (i *commitIndex) tips() ([]*object.Commit, error) {
+	references, err := i.repo.References()
+	if err != nil {
+		return nil, err
+	}
+	tips := []*object.Commit{}
+	visited := map[plumbing.Hash]bool{}
+	err = references.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		commit, err := i.get(ref.Hash())
+		if err != nil {
+			// Annotated tags point to the tag object
+			tag, tagErr := i.repo.TagObject(ref.Hash())
+			if tagErr != nil || tag.TargetType != plumbing.CommitObject {
+				// The ref does not point to a commit, or its objects have not been fetched
+				return nil
+			}
+			if commit, err = i.get(tag.Target); err != nil {
+				return nil
+			}
+			i.commits[ref.Hash()] = commit
+		}
+		if !visited[commit.Hash] {
+			visited[commit.Hash] = true
+			tips = append(tips, commit)
+		}
+		return nil
+	})
+	return tips, err
+}
+
+// walk indexes the commits reachable from the tips within the window, which are always indexed.
+// The commits are sorted by committer time, newest first
+func (i *commitIndex) walk(tips []*object.Commit, window historyWindow) []*object.Commit {
+	indexed := map[plumbing.Hash]*object.Commit{}
+	for _, tip := range tips {
+		indexed[tip.Hash] = tip
+	}
+	switch {
+	case window.refsOnly:
+	case window.maxCommits > 0:
+		// Each ref has its own limit, so the walk of each ref
+		// cannot stop at the commits indexed for the others
+		for _, tip := range tips {
+			i.walkFrom([]*object.Commit{tip}, window, indexed, nil)
+		}
+	case len(i.knownTips) > 0:
+		// The known commits are the ones reachable from the known tips within the window,
+		// so their ancestors are known too
+		boundaries := []*object.Commit{}
+		i.walkFrom(tips, window, indexed, func(commit *object.Commit) bool {
+			if known, ok := i.known[commit.Hash.String()]; ok && known.Hash == commit.Hash {
+				boundaries = append(boundaries, commit)
+				return true
+			}
+			return false
+		})
+		i.walkKnown(boundaries, window, indexed)
+	default:
+		i.walkFrom(tips, window, indexed, nil)
+	}
+	commits := make([]*object.Commit, 0, len(indexed))
+	for _, commit := range indexed {
+		commits = append(commits, commit)
+	}
+	sort.Slice(commits, func(a, b int) bool {
+		if !commits[a].Committer.When.Equal(commits[b].Committer.When) {
+			return commits[a].Committer.When.After(commits[b].Committer.When)
+		}
+		return commits[a].Hash.String() < commits[b].Hash.String()
+	})
+	return commits
+}
+
+// walkFrom walks the history back from the given commits, newest first,
+// until the commits older than the window or up to its maximum number of commits.
+// The parents of the commits the stop function is true for are not walked
+func (i *commitIndex) walkFrom(from []*object.Commit, window historyWindow, indexed map[plumbing.Hash]*object.Commit, stop func(*object.Commit) bool) {
+	queue := &commitQueue{}
+	visited := map[plumbing.Hash]bool{}
+	for _, commit := range from {
+		if !visited[commit.Hash] {
+			visited[commit.Hash] = true
+			heap.Push(queue, commit)
+		}
+	}
+	count := 0
+	for queue.Len() > 0 {
+		commit := heap.Pop(queue).(*object.Commit)
+		indexed[commit.Hash] = commit
+		count++
+		if window.maxCommits > 0 && count >= window.maxCommits {
+			return
+		}
+		if stop != nil && stop(commit) {
+			continue
+		}
+		for _, parentHash := range commit.ParentHashes {
+			if visited[parentHash] {
+				continue
+			}
+			visited[parentHash] = true
+			parent, err := i.get(parentHash)
+			if err != nil {
+				// e.g. beyond the depth of a shallow clone
+				continue
+			}
+			if parent.Committer.When.Before(window.since) {
+				continue
+			}
+			heap.Push(queue, parent)
+		}
+	}
+}
+
+// walkKnown indexes the known ancestors of the given known commits still within the window,
+// without reading them from the repository
+func (i *commitIndex) walkKnown(from []*object.Commit, window historyWindow, indexed map[plumbing.Hash]*object.Commit) {
+	queue := from
+	for len(queue) > 0 {
+		commit := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, parentHash := range commit.ParentHashes {
+			if _, ok := indexed[parentHash]; ok {
+				continue
+			}
+			parent, ok := i.known[parentHash.String()]
+			if !ok || parent.Committer.When.Before(window.since) {
+				continue
+			}
+			indexed[parentHash] = parent
+			queue = append(queue, parent)
+		}
+	}
+}
+
+// indexedCommit keeps the fields of a commit used by the applications and the sessions,
+// with its parents to walk the history, dropping the rest (e.g. its signature and the storer)
+func indexedCommit(commit *object.Commit) *object.Commit {
+	return &object.Commit{
+		Hash:         commit.Hash,
+		Author:       commit.Author,
+		Committer:    commit.Committer,
+		Message:      commit.Message,
+		TreeHash:     commit.TreeHash,
+		ParentHashes: commit.ParentHashes,
+	}
+}
+
+// commitQueue is a priority queue of commits, the newest by committer time first
+type commitQueue []*object.Commit
+
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(a, b int) bool {
+	return q[a].Committer.When.After(q[b].Committer.When)
+}
+func (q commitQueue) Swap(a, b int) { q[a], q[b] = q[b], q[a] }
+
+func (q *commitQueue) Push(x interface{}) {
+	*q = append(*q, x.(*object.Commit))
+}
+
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	commit := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return commit
+}
@@ -1,11 +1,25 @@
 package versioning
 
-import "github.com/wufe/polo/pkg/execution"
+import (
+	"github.com/wufe/polo/pkg/execution"
+	"github.com/wufe/polo/pkg/models"
+)
 
 type GitClient interface {
 	Clone(baseFolder string, outputFolder string, remote string) error
 	FetchAll(repoFolder string) error
+	// FetchRef fetches a single ref (e.g. refs/heads/main), without pruning the others
+	FetchRef(repoFolder string, ref string) error
 	HardReset(repoFolder string, commit string) error
+	// UpdateSubmodules syncs and updates the submodules of the commit checked out, recursively
+	UpdateSubmodules(repoFolder string) error
+	// CheckoutLFS fetches and checks out the LFS objects of the commit checked out, of its submodules too
+	CheckoutLFS(repoFolder string) error
+	// WithAuth retrieves a client using the given credentials, e.g. the ones of an application
+	WithAuth(auth models.GitAuth) GitClient
+	// WithCheckout retrieves a client cloning, fetching and checking out
+	// with the given options, e.g. the ones of the session folders of an application
+	WithCheckout(checkout models.CheckoutConfiguration) GitClient
 }
 
 func GetGitClient(commandRunner execution.CommandRunner) GitClient {
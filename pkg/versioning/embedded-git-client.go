@@ -1,16 +1,34 @@
 package versioning
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
 	"path/filepath"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/wufe/polo/pkg/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	// errEmbeddedCheckout is returned cloning with the checkout options go-git does not implement
+	errEmbeddedCheckout = errors.New("partial clones and sparse checkouts are not supported by the embedded git client")
+	errEmbeddedLFS      = errors.New("LFS objects are not supported by the embedded git client")
 )
 
 type EmbeddedGitClient struct {
 	Auth transport.AuthMethod
+	// gitAuth are the credentials of an application, which take precedence over Auth
+	gitAuth  models.GitAuth
+	checkout models.CheckoutConfiguration
 }
 
 func NewEmbeddedGitClient(auth transport.AuthMethod) GitClient {
@@ -19,12 +37,36 @@ func NewEmbeddedGitClient(auth transport.AuthMethod) GitClient {
 	}
 }
 
+func (client *EmbeddedGitClient) WithAuth(auth models.GitAuth) GitClient {
+	return &EmbeddedGitClient{
+		Auth:     client.Auth,
+		gitAuth:  auth,
+		checkout: client.checkout,
+	}
+}
+
+func (client *EmbeddedGitClient) WithCheckout(checkout models.CheckoutConfiguration) GitClient {
+	return &EmbeddedGitClient{
+		Auth:     client.Auth,
+		gitAuth:  client.gitAuth,
+		checkout: checkout,
+	}
+}
+
 func (client *EmbeddedGitClient) Clone(baseFolder string, outFolder string, remote string) error {
-	_, err := git.PlainClone(filepath.Join(baseFolder, outFolder), false, &git.CloneOptions{
-		URL:  remote,
-		Auth: client.Auth,
+	if client.checkout.Filter != "" || len(client.checkout.Sparse) > 0 {
+		return errEmbeddedCheckout
+	}
+	auth, err := client.authFor(remote)
+	if err != nil {
+		return err
+	}
+	_, err = git.PlainClone(filepath.Join(baseFolder, outFolder), false, &git.CloneOptions{
+		URL:   remote,
+		Auth:  auth,
+		Depth: client.checkout.Depth,
 	})
-	return err
+	return client.redact(err)
 }
 
 func (client *EmbeddedGitClient) HardReset(repoFolder string, commit string) error {
@@ -42,18 +84,169 @@ func (client *EmbeddedGitClient) HardReset(repoFolder string, commit string) err
 	})
 }
 
+func (client *EmbeddedGitClient) UpdateSubmodules(repoFolder string) error {
+	repo, err := git.PlainOpen(repoFolder)
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	submodules, err := worktree.Submodules()
+	if err != nil {
+		return err
+	}
+	for _, submodule := range submodules {
+		auth, err := client.authFor(submodule.Config().URL)
+		if err != nil {
+			return err
+		}
+		err = submodule.Update(&git.SubmoduleUpdateOptions{
+			Init:              true,
+			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+			Auth:              auth,
+		})
+		if err != nil {
+			return fmt.Errorf("submodule %s: %s", submodule.Config().Path, client.redact(err).Error())
+		}
+	}
+	return nil
+}
+
+func (client *EmbeddedGitClient) CheckoutLFS(repoFolder string) error {
+	return errEmbeddedLFS
+}
+
 func (client *EmbeddedGitClient) FetchAll(repoFolder string) error {
+	return client.fetch(repoFolder, "refs/*:refs/*")
+}
+
+func (client *EmbeddedGitClient) FetchRef(repoFolder string, ref string) error {
+	return client.fetch(repoFolder, config.RefSpec(ref+":"+ref))
+}
+
+func (client *EmbeddedGitClient) fetch(repoFolder string, refSpec config.RefSpec) error {
 	repo, err := git.PlainOpen(repoFolder)
 	if err != nil {
 		return err
 	}
+	auth := client.Auth
+	if remote, err := repo.Remote(git.DefaultRemoteName); err == nil && len(remote.Config().URLs) > 0 {
+		if auth, err = client.authFor(remote.Config().URLs[0]); err != nil {
+			return err
+		}
+	}
 	err = repo.Fetch(&git.FetchOptions{
-		RefSpecs: []config.RefSpec{"refs/*:refs/*"},
+		RefSpecs: []config.RefSpec{refSpec},
 		Force:    true,
-		Auth:     client.Auth,
+		Auth:     auth,
+		Depth:    client.checkout.Depth,
 	})
 	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return err
+		return client.redact(err)
 	}
 	return nil
 }
+
+// authFor builds the method authenticating to a remote with the credentials of the application
+func (client *EmbeddedGitClient) authFor(remote string) (transport.AuthMethod, error) {
+	if !client.gitAuth.IsSet() {
+		return client.Auth, nil
+	}
+	endpoint, err := transport.NewEndpoint(remote)
+	if err != nil {
+		return nil, err
+	}
+	switch endpoint.Protocol {
+	case "ssh":
+		auth := client.gitAuth.SSH
+		if !auth.IsSet() {
+			return client.Auth, nil
+		}
+		user := endpoint.User
+		if user == "" {
+			user = gitssh.DefaultUsername
+		}
+		callback, err := hostKeyCallback(auth)
+		if err != nil {
+			return nil, err
+		}
+		if auth.Key == "" {
+			agentAuth, err := gitssh.NewSSHAgentAuth(user)
+			if err != nil {
+				return nil, err
+			}
+			agentAuth.HostKeyCallback = callback
+			return agentAuth, nil
+		}
+		keyAuth, err := gitssh.NewPublicKeysFromFile(user, auth.Key, auth.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the SSH key %s: %s", auth.Key, err.Error())
+		}
+		keyAuth.HostKeyCallback = callback
+		return keyAuth, nil
+	case "http", "https":
+		auth := client.gitAuth.HTTPS
+		if !auth.IsSet() {
+			return client.Auth, nil
+		}
+		return &githttp.BasicAuth{
+			Username: auth.Username,
+			Password: auth.Token,
+		}, nil
+	}
+	return client.Auth, nil
+}
+
+// hostKeyCallback checks the keys of the SSH hosts with the known hosts policy
+func hostKeyCallback(auth models.GitSSHAuth) (ssh.HostKeyCallback, error) {
+	if auth.KnownHosts == models.GitAuthKnownHostsInsecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	file := auth.KnownHostsFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	if auth.KnownHosts != models.GitAuthKnownHostsAcceptNew {
+		return gitssh.NewKnownHostsCallback(file)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+	known, err := os.OpenFile(file, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	known.Close()
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, err
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+			// Known host, or one whose key changed
+			return err
+		}
+		known, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer known.Close()
+		_, err = fmt.Fprintln(known, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+		return err
+	}, nil
+}
+
+func (client *EmbeddedGitClient) redact(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.New(client.gitAuth.Redact(err.Error()))
+}
@@ -17,7 +17,21 @@ type FetcherError struct {
 }
 
 type RepositoryFetcher interface {
-	Fetch(baseFolder string) (*FetchResult, []*FetcherError)
+	// Fetch fetches the given refs, or all of them if none is given,
+	// and retrieves the objects of the repository
+	Fetch(baseFolder string, options FetchOptions, refs ...string) (*FetchResult, []*FetcherError)
+}
+
+// FetchOptions are the window of the history to be indexed
+// and the commits already known (e.g. the CommitMap of the previous fetch),
+// which are not read again from the repository, along with the credentials of the remote.
+// KnownTips are the commits the refs pointed to when the known commits have been indexed
+// with the same window: the walk of the history stops at the known commits
+type FetchOptions struct {
+	History   models.FetchHistory
+	Known     map[string]*object.Commit
+	KnownTips []string
+	Auth      models.GitAuth
 }
 type RepositoryFetcherImpl struct {
 	gitClient GitClient
@@ -35,15 +49,19 @@ type FetchResult struct {
 	HashToObjectsMap map[string]*models.RemoteObject
 	BranchesMap      map[string]*models.Branch
 	TagsMap          map[string]*models.Tag
+	PullRequestsMap  map[string]*models.PullRequest
 	Commits          []string
 	CommitMap        map[string]*object.Commit
+	// Tips are the commits the refs point to
+	Tips []string
 }
 
-func (fetcher *RepositoryFetcherImpl) Fetch(baseFolder string) (*FetchResult, []*FetcherError) {
+func (fetcher *RepositoryFetcherImpl) Fetch(baseFolder string, options FetchOptions, refs ...string) (*FetchResult, []*FetcherError) {
 	objectsToHashMap := make(map[string]string)
 	hashToObjectsMap := make(map[string]*models.RemoteObject)
 	appBranches := make(map[string]*models.Branch)
 	appTags := make(map[string]*models.Tag)
+	appPullRequests := make(map[string]*models.PullRequest)
 	appCommits := []string{}
 	appCommitMap := make(map[string]*object.Commit)
 	errors := []*FetcherError{}
@@ -52,8 +70,9 @@ func (fetcher *RepositoryFetcherImpl) Fetch(baseFolder string) (*FetchResult, []
 		return func(hash string) {
 			if _, exists := hashToObjectsMap[hash]; !exists {
 				hashToObjectsMap[hash] = &models.RemoteObject{
-					Branches: []string{},
-					Tags:     []string{},
+					Branches:     []string{},
+					Tags:         []string{},
+					PullRequests: []string{},
 				}
 			}
 		}
@@ -61,15 +80,36 @@ func (fetcher *RepositoryFetcherImpl) Fetch(baseFolder string) (*FetchResult, []
 
 	registerHash := fetcher.registerObjectHash(objectsToHashMap)
 
+	since, err := options.History.SinceTime(time.Now())
+	if err != nil {
+		errors = append(errors, &FetcherError{err, false})
+		return nil, errors
+	}
+	window := historyWindow{
+		since:      since,
+		maxCommits: options.History.MaxCommits,
+		refsOnly:   options.History.RefsOnly,
+	}
+
 	// Open repository
 	repo, err := git.PlainOpen(baseFolder)
 	if err != nil {
 		errors = append(errors, &FetcherError{err, false})
 		return nil, errors
 	}
+	index := newCommitIndex(repo, options.Known, options.KnownTips)
 
 	// Fetch
-	err = fetcher.gitClient.FetchAll(baseFolder)
+	gitClient := fetcher.gitClient.WithAuth(options.Auth)
+	if len(refs) == 0 {
+		err = gitClient.FetchAll(baseFolder)
+	} else {
+		for _, ref := range refs {
+			if err = gitClient.FetchRef(baseFolder, ref); err != nil {
+				break
+			}
+		}
+	}
 	if err != nil && err != git.NoErrAlreadyUpToDate {
 		errors = append(errors, &FetcherError{
 			Error:    fmt.Errorf("%s\n\nEnsure your git cli can do a `fetch` inside %s", err.Error(), baseFolder),
@@ -100,7 +140,7 @@ func (fetcher *RepositoryFetcherImpl) Fetch(baseFolder string) (*FetchResult, []
 
 		hashToObjectsMap[refHash].Branches = appendWithoutDup(hashToObjectsMap[refHash].Branches, branchName)
 
-		commit, err := repo.CommitObject(ref.Hash())
+		commit, err := index.get(ref.Hash())
 		if err != nil {
 			return err
 		}
@@ -141,9 +181,10 @@ func (fetcher *RepositoryFetcherImpl) Fetch(baseFolder string) (*FetchResult, []
 		tagName := refName[len(tagPrefix):]
 		registerHash(tagName, refHash)
 
-		commit, err := repo.CommitObject(ref.Hash())
+		commit, err := index.get(ref.Hash())
 		if err != nil {
-			return err
+			// Annotated tags are read along with their tag object
+			return nil
 		}
 
 		// appTags = appendWithoutDup(appTags, tagName)
@@ -180,7 +221,10 @@ func (fetcher *RepositoryFetcherImpl) Fetch(baseFolder string) (*FetchResult, []
 
 		registerHash(tagName, refHash)
 
-		commit, err := ref.Commit()
+		if ref.TargetType != plumbing.CommitObject {
+			return object.ErrUnsupportedObject
+		}
+		commit, err := index.get(ref.Target)
 		if err != nil {
 			return err
 		}
@@ -206,23 +250,67 @@ func (fetcher *RepositoryFetcherImpl) Fetch(baseFolder string) (*FetchResult, []
 		return nil
 	})
 
-	// Log
-	// TODO: Configure "since"
-	since := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
-	until := time.Now().UTC()
-	logs, err := repo.Log(&git.LogOptions{All: true, Since: &since, Until: &until, Order: git.LogOrderCommitterTime})
+	// FEATURE: Pull request previews
+	// Pull requests (refs/pull/<n>/head and the like), checked out as pr-<n>
+	references, err := repo.References()
 	if err != nil {
 		errors = append(errors, &FetcherError{err, false})
 		return nil, errors
 	}
 
-	err = logs.ForEach(func(commit *object.Commit) error {
+	err = references.ForEach(func(ref *plumbing.Reference) error {
+		refName := ref.Name().String()
+		number, ok := models.ParsePullRequestRef(refName)
+		if !ok || ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		refHash := ref.Hash().String()
+
+		commit, err := index.get(ref.Hash())
+		if err != nil {
+			// The objects of the pull request may not have been fetched yet
+			return nil
+		}
+
+		checkout := models.PullRequestCheckout(number)
+		registerHash(checkout, refHash)
+		registerHash(refName, refHash)
+		checkObjectExists(refHash)
+
+		hashToObjectsMap[refHash].PullRequests = appendWithoutDup(hashToObjectsMap[refHash].PullRequests, checkout)
+
+		appPullRequests[checkout] = &models.PullRequest{
+			CheckoutObject: models.CheckoutObject{
+				Name:        checkout,
+				Hash:        refHash,
+				Author:      commit.Author.Name,
+				AuthorEmail: commit.Author.Email,
+				Date:        commit.Author.When,
+				Message:     commit.Message,
+			},
+			Number: number,
+			Ref:    refName,
+		}
+
+		return nil
+	})
+
+	// Log, within the window of the history
+	tips, err := index.tips()
+	if err != nil {
+		errors = append(errors, &FetcherError{err, false})
+		return nil, errors
+	}
+	for _, commit := range index.walk(tips, window) {
 		commitHash := commit.Hash.String()
 		registerHash(commitHash, commitHash)
 		appCommits = append(appCommits, commitHash)
 		appCommitMap[commitHash] = commit
-		return nil
-	})
+	}
+	appTips := make([]string, 0, len(tips))
+	for _, tip := range tips {
+		appTips = append(appTips, tip.Hash.String())
+	}
 
 	return &FetchResult{
 		AppCommits:       appCommits,
@@ -230,8 +318,10 @@ func (fetcher *RepositoryFetcherImpl) Fetch(baseFolder string) (*FetchResult, []
 		HashToObjectsMap: hashToObjectsMap,
 		BranchesMap:      appBranches,
 		TagsMap:          appTags,
+		PullRequestsMap:  appPullRequests,
 		Commits:          appCommits,
 		CommitMap:        appCommitMap,
+		Tips:             appTips,
 	}, errors
 }
 